@@ -1,20 +1,30 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/chats/sailing-backend/internal/config"
+	"github.com/chats/sailing-backend/internal/delivery/feed"
 	"github.com/chats/sailing-backend/internal/delivery/http/handler"
 	"github.com/chats/sailing-backend/internal/delivery/http/middleware"
+	"github.com/chats/sailing-backend/internal/delivery/mqtt"
+	"github.com/chats/sailing-backend/internal/delivery/webhook"
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/chats/sailing-backend/internal/events"
 	"github.com/chats/sailing-backend/internal/repository"
 	"github.com/chats/sailing-backend/internal/usecase"
 	"github.com/chats/sailing-backend/pkg/database"
+	"github.com/chats/sailing-backend/pkg/jwks"
 	"github.com/chats/sailing-backend/pkg/logger"
+	"github.com/chats/sailing-backend/pkg/ratelimit"
 	"github.com/gofiber/fiber/v2"
 	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func main() {
@@ -27,41 +37,223 @@ func main() {
 	// Initialize logger
 	logger.InitLogger(cfg.LogLevel)
 
-	log.Info().Msg("Starting Sailing Backend API...")
+	log.Info().Str("env", cfg.Environment).Msg("Starting Sailing Backend API...")
+	log.Info().Interface("config", cfg.Redacted()).Msg("Configuration loaded")
+
+	// MongoDB operation timeouts apply to every repository
+	timeouts := repository.Timeouts{
+		Query:  cfg.MongoDBQueryTimeout,
+		Batch:  cfg.MongoDBBatchTimeout,
+		Stream: cfg.MongoDBStreamTimeout,
+	}
 
 	// Connect to MongoDB
-	db, err := database.ConnectMongoDB(cfg.MongoDBURI, cfg.MongoDBDatabase)
+	db, err := database.ConnectMongoDB(cfg.MongoDBURI, cfg.MongoDBDatabase, cfg.MongoDBConnectTimeout)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to connect to MongoDB")
 	}
 
+	// "migrate" applies pending schema migrations and exits; "migrate status"
+	// lists them without applying anything
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrateCommand(cfg, db, os.Args[2:])
+		return
+	}
+
+	// Apply pending schema migrations, then use whichever layout gps_tracks has
+	if cfg.MigrateOnStart {
+		applied, err := database.Migrate(context.Background(), db, repository.Migrations(), repository.MigrationSteps(migrationOptions(cfg))...)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to apply schema migrations")
+		}
+		log.Info().Int("applied", applied).Msg("Schema migrations up to date")
+	}
+	detectCtx, cancel := context.WithTimeout(context.Background(), cfg.MongoDBQueryTimeout)
+	gpsTrackLayout, err := repository.DetectGPSTrackLayout(detectCtx, db)
+	cancel()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to detect gps_tracks layout")
+	}
+	log.Info().Stringer("layout", gpsTrackLayout).Msg("GPS track storage")
+
+	// Writes and their outbox events share a transaction where the
+	// deployment supports them
+	helloCtx, cancel := context.WithTimeout(context.Background(), cfg.MongoDBQueryTimeout)
+	transactions, err := repository.SupportsTransactions(helloCtx, db)
+	cancel()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to inspect MongoDB deployment")
+	}
+	if !transactions {
+		log.Warn().Msg("MongoDB is a standalone server without transactions; a failure between a write and its outbox events can lose the events. Run a replica set in production.")
+	}
+
 	// Initialize repositories
-	voyageRepo := repository.NewVoyageRepository(db)
-	checkpointRepo := repository.NewCheckpointRepository(db)
-	gpsTrackRepo := repository.NewGPSTrackRepository(db)
+	voyageRepo := repository.NewVoyageRepository(db, timeouts)
+	checkpointRepo := repository.NewCheckpointRepository(db, timeouts)
+	gpsTrackRepo := repository.NewGPSTrackRepository(db, gpsTrackLayout, timeouts)
+	eventRepo := repository.NewEventRepository(db, timeouts)
+	outboxRepo := repository.NewOutboxRepository(db, timeouts)
+	transactor := repository.NewTransactor(db, transactions)
+	webhookRepo := repository.NewWebhookRepository(db, timeouts)
+	webhookDeliveryRepo := repository.NewWebhookDeliveryRepository(db, timeouts)
+	apiKeyRepo := repository.NewAPIKeyRepository(db, timeouts)
+	userRepo := repository.NewUserRepository(db, timeouts)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db, timeouts)
+	auditRepo := repository.NewAuditRepository(db, timeouts)
+
+	// Webhook subscriptions are notified through a delivery queue
+	webhookUseCase := usecase.NewWebhookUseCase(webhookRepo, webhookDeliveryRepo, usecase.WebhookPolicy{
+		MaxAttempts:    cfg.WebhookMaxAttempts,
+		InitialBackoff: cfg.WebhookInitialBackoff,
+		MaxBackoff:     cfg.WebhookMaxBackoff,
+
+		AllowPrivateNetworks: cfg.WebhookAllowPrivateNetworks,
+	})
+
+	// Use cases write events to the outbox; the relay holding the lease
+	// appends them to the event log and the webhook queue, and every
+	// instance's relay follows the log into its live broker
+	broker := events.NewBroker(256)
+	relay := events.NewRelay(outboxRepo, eventRepo, broker, webhookUseCase,
+		cfg.OutboxPollInterval, cfg.OutboxBatchSize, cfg.OutboxLeaseTTL)
 
 	// Initialize use cases
-	voyageUseCase := usecase.NewVoyageUseCase(voyageRepo, checkpointRepo, gpsTrackRepo)
-	checkpointUseCase := usecase.NewCheckpointUseCase(checkpointRepo, voyageRepo)
-	gpsTrackUseCase := usecase.NewGPSTrackUseCase(gpsTrackRepo, voyageRepo)
+	voyageUseCase := usecase.NewVoyageUseCase(voyageRepo, checkpointRepo, gpsTrackRepo, transactor, outboxRepo)
+	checkpointUseCase := usecase.NewCheckpointUseCase(checkpointRepo, voyageRepo, transactor, outboxRepo)
+	gpsTrackUseCase := usecase.NewGPSTrackUseCase(gpsTrackRepo, voyageRepo, transactor, outboxRepo)
+	eventUseCase := usecase.NewEventUseCase(eventRepo)
+	apiKeyUseCase := usecase.NewAPIKeyUseCase(apiKeyRepo, cfg.APIKeyCacheTTL)
+	userUseCase := usecase.NewUserUseCase(userRepo, refreshTokenRepo)
+	auditUseCase := usecase.NewAuditUseCase(auditRepo, usecase.AuditConfig{
+		BatchSize:     cfg.AuditBatchSize,
+		FlushInterval: cfg.AuditFlushInterval,
+		QueueSize:     cfg.AuditQueueSize,
+		WriteTimeout:  cfg.AuditWriteTimeout,
+	})
+	authUseCase := usecase.NewAuthUseCase(userRepo, refreshTokenRepo, apiKeyUseCase, usecase.TokenConfig{
+		Secret:          cfg.JWTSecret,
+		Issuer:          cfg.JWTIssuer,
+		Audience:        cfg.JWTAudience,
+		AccessTokenTTL:  cfg.AccessTokenTTL,
+		RefreshTokenTTL: cfg.RefreshTokenTTL,
+	})
+	aisUseCase := usecase.NewAISUseCase(voyageRepo, gpsTrackUseCase)
+	ingestQueue := usecase.NewGPSIngestQueue(gpsTrackUseCase, usecase.GPSIngestConfig{
+		BatchSize:      cfg.IngestBatchSize,
+		FlushInterval:  cfg.IngestFlushInterval,
+		QueueSize:      cfg.IngestQueueSize,
+		Writers:        cfg.IngestWriters,
+		VoyageCacheTTL: cfg.IngestVoyageCacheTTL,
+		WriteTimeout:   cfg.IngestWriteTimeout,
+	})
 
 	// Initialize handlers
-	voyageHandler := handler.NewVoyageHandler(voyageUseCase)
+	pages := handler.PageSizes{Default: cfg.DefaultPageSize, Max: cfg.MaxPageSize}
+	voyageHandler := handler.NewVoyageHandler(voyageUseCase, pages)
 	checkpointHandler := handler.NewCheckpointHandler(checkpointUseCase)
-	gpsTrackHandler := handler.NewGPSTrackHandler(gpsTrackUseCase)
+	gpsTrackHandler := handler.NewGPSTrackHandler(ingestQueue)
+	ingestHandler := handler.NewIngestHandler(voyageUseCase, gpsTrackUseCase, aisUseCase)
+	importHandler := handler.NewImportHandler(voyageUseCase, checkpointUseCase, gpsTrackUseCase, cfg.ImportChunkSize)
+	exportHandler := handler.NewExportHandler(voyageUseCase, cfg.MongoDBStreamTimeout)
+	streamHandler := handler.NewStreamHandler(broker)
+	eventsHandler := handler.NewEventsHandler(broker, eventUseCase)
+	webhookHandler := handler.NewWebhookHandler(webhookUseCase, pages)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyUseCase)
+	userHandler := handler.NewUserHandler(userUseCase)
+	authHandler := handler.NewAuthHandler(authUseCase)
+	auditHandler := handler.NewAuditHandler(auditUseCase, pages)
+
+	// Bearer tokens: HMAC with JWT_SECRET, plus RS256/ES256 when a JWKS is configured
+	jwtConfig := middleware.JWTConfig{
+		Secret:   cfg.JWTSecret,
+		Issuer:   cfg.JWTIssuer,
+		Audience: cfg.JWTAudience,
+		Leeway:   cfg.JWTLeeway,
+	}
+	var keySet *jwks.KeySet
+	if cfg.JWTJWKSURL != "" || cfg.JWTJWKSFile != "" {
+		keySet, err = jwks.New(context.Background(), jwks.Config{
+			URL:             cfg.JWTJWKSURL,
+			File:            cfg.JWTJWKSFile,
+			RefreshInterval: cfg.JWTJWKSRefresh,
+		})
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to load JWKS")
+		}
+		keySet.Start()
+		jwtConfig.Keys = keySet
+	}
+
+	// Rate limits per principal, tenant and route group; the Mongo store
+	// shares counters between instances
+	var (
+		rateLimitStore ratelimit.Store
+		memoryStore    *ratelimit.MemoryStore
+	)
+	if cfg.RateLimitStore == "mongo" {
+		rateLimitStore = repository.NewRateLimitRepository(db, cfg.RateLimitStoreTimeout)
+	} else {
+		memoryStore = ratelimit.NewMemoryStore()
+		rateLimitStore = memoryStore
+	}
+	limiter := ratelimit.New(rateLimitStore, cfg.RateLimitWindow)
+	rateLimitConfig := middleware.RateLimitConfig{
+		Limiter: limiter,
+		PrincipalLimits: map[string]int{
+			middleware.RateLimitRead:   cfg.RateLimitRead,
+			middleware.RateLimitWrite:  cfg.RateLimitWrite,
+			middleware.RateLimitIngest: cfg.RateLimitIngest,
+			middleware.RateLimitAuth:   cfg.RateLimitAuth,
+		},
+		TenantLimits: map[string]int{
+			middleware.RateLimitRead:   cfg.RateLimitTenantRead,
+			middleware.RateLimitWrite:  cfg.RateLimitTenantWrite,
+			middleware.RateLimitIngest: cfg.RateLimitTenantIngest,
+		},
+		Overrides: cfg.RateLimitOverrides,
+	}
+	readLimit := middleware.RateLimit(middleware.RateLimitRead, rateLimitConfig)
+	writeLimit := middleware.RateLimit(middleware.RateLimitWrite, rateLimitConfig)
+	ingestLimit := middleware.RateLimit(middleware.RateLimitIngest, rateLimitConfig)
+	authLimit := middleware.RateLimit(middleware.RateLimitAuth, rateLimitConfig)
 
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
 		ErrorHandler: customErrorHandler,
 		AppName:      "Sailing Backend API",
+		BodyLimit:    cfg.BodyLimit,
+		// Rate limits and the audit log key on c.IP(), which reads
+		// ProxyHeader only on requests from a trusted proxy. Without
+		// trusted proxies, X-Forwarded-Proto is honoured from any peer as
+		// before.
+		ProxyHeader:             cfg.ProxyHeader,
+		EnableTrustedProxyCheck: len(cfg.TrustedProxies) > 0,
+		TrustedProxies:          cfg.TrustedProxies,
+		EnableIPValidation:      true,
 	})
 
 	// Setup security middlewares
-	middleware.SetupSecurityMiddlewares(app)
+	middleware.SetupSecurityMiddlewares(app, middleware.SecurityConfig{
+		AllowOrigins:          cfg.CORSAllowOrigins,
+		AllowMethods:          cfg.CORSAllowMethods,
+		AllowHeaders:          cfg.CORSAllowHeaders,
+		ExposeHeaders:         cfg.CORSExposeHeaders,
+		AllowCredentials:      cfg.CORSAllowCredentials,
+		MaxAge:                cfg.CORSMaxAge,
+		HSTSMaxAge:            cfg.HSTSMaxAge,
+		HSTSIncludeSubdomains: cfg.HSTSIncludeSubdomains,
+		HSTSPreload:           cfg.HSTSPreload,
+		ContentSecurityPolicy: cfg.ContentSecurityPolicy,
+	})
 
 	// Logger middleware
 	app.Use(middleware.LoggerMiddleware())
 
+	// Clients that keep failing authentication are blocked before their
+	// credentials are looked up
+	app.Use(middleware.FailedAuthLimit(limiter, cfg.RateLimitAuthFailures))
+
 	// Health check endpoint (no auth)
 	app.Get("/health", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{
@@ -70,23 +262,148 @@ func main() {
 		})
 	})
 
+	// Token endpoints (no auth; clients authenticate with their grant)
+	app.Post("/auth/token", authLimit, authHandler.Token)
+	app.Post("/auth/revoke", authLimit, authHandler.Revoke)
+
 	// API v1 routes with authentication
 	api := app.Group("/api/v1")
-	api.Use(middleware.AuthMiddleware())
+	api.Use(middleware.AuthMiddleware(middleware.AuthConfig{
+		JWT:             jwtConfig,
+		BootstrapAPIKey: cfg.APIKey,
+		APIKeys:         apiKeyUseCase,
+	}))
+
+	// Mutating requests are recorded in the audit log
+	api.Use(middleware.AuditMiddleware(auditUseCase))
+
+	// Permissions are checked per route group; each route lists its
+	// group's check explicitly because group middleware would also apply to
+	// every later route sharing the /api/v1 prefix. Rate limits are listed
+	// the same way: reads, writes and telemetry ingestion are counted apart.
+	readVoyages := middleware.RequireScope(domain.ScopeVoyagesRead)
+	writeVoyages := middleware.RequireScope(domain.ScopeVoyagesWrite)
+	ingestTracks := middleware.RequireScope(domain.ScopeTracksIngest)
+	readEvents := middleware.RequireScope(domain.ScopeEventsRead)
+	manageWebhooks := middleware.RequireScope(domain.ScopeWebhooksManage)
+	manageAPIKeys := middleware.RequireScope(domain.ScopeAPIKeysManage)
+	manageUsers := middleware.RequireScope(domain.ScopeUsersManage)
+	readAudit := middleware.RequireScope(domain.ScopeAuditRead)
 
 	// Voyage routes
-	api.Post("/voyages/depart", voyageHandler.Depart)
-	api.Post("/voyages/arrive", voyageHandler.Arrive)
-	api.Get("/voyages/all", voyageHandler.GetAllVoyages)
-	api.Get("/voyage/:id", voyageHandler.GetVoyageByID)
+	api.Post("/voyages/depart", writeLimit, writeVoyages, voyageHandler.Depart)
+	api.Post("/voyages/arrive", writeLimit, writeVoyages, voyageHandler.Arrive)
+	api.Get("/voyages/all", readLimit, readVoyages, voyageHandler.GetAllVoyages)
+	api.Get("/voyage/:id", readLimit, readVoyages, voyageHandler.GetVoyageByID)
 
 	// Checkpoint routes
-	api.Post("/checkpoints", checkpointHandler.CreateCheckpoint)
-	api.Post("/checkpoints/batch", checkpointHandler.CreateCheckpointsBatch)
+	api.Post("/checkpoints", writeLimit, writeVoyages, checkpointHandler.CreateCheckpoint)
+	api.Post("/checkpoints/batch", writeLimit, writeVoyages, checkpointHandler.CreateCheckpointsBatch)
 
 	// GPS Track routes
-	api.Post("/gps-tracks", gpsTrackHandler.CreateGPSTrack)
-	api.Post("/gps-tracks/batch", gpsTrackHandler.CreateGPSTracksBatch)
+	api.Post("/gps-tracks", ingestLimit, ingestTracks, gpsTrackHandler.CreateGPSTrack)
+	api.Post("/gps-tracks/batch", ingestLimit, ingestTracks, gpsTrackHandler.CreateGPSTracksBatch)
+
+	// Device feed routes
+	api.Post("/voyage/:id/nmea", ingestLimit, ingestTracks, ingestHandler.IngestNMEA)
+	api.Post("/ais", ingestLimit, ingestTracks, ingestHandler.IngestAIS)
+
+	// Live event streams
+	api.Get("/stream", readLimit, readEvents, streamHandler.Upgrade, streamHandler.Stream())
+	api.Get("/events", readLimit, readEvents, eventsHandler.Stream)
+
+	// Webhook routes
+	api.Post("/webhooks", writeLimit, manageWebhooks, webhookHandler.CreateWebhook)
+	api.Get("/webhooks", readLimit, manageWebhooks, webhookHandler.GetWebhooks)
+	api.Get("/webhooks/deliveries", readLimit, manageWebhooks, webhookHandler.GetDeliveries)
+	api.Post("/webhooks/deliveries/:id/retry", writeLimit, manageWebhooks, webhookHandler.RetryDelivery)
+	api.Get("/webhooks/:id", readLimit, manageWebhooks, webhookHandler.GetWebhook)
+	api.Patch("/webhooks/:id", writeLimit, manageWebhooks, webhookHandler.UpdateWebhook)
+	api.Delete("/webhooks/:id", writeLimit, manageWebhooks, webhookHandler.DeleteWebhook)
+	api.Post("/webhooks/:id/ping", writeLimit, manageWebhooks, webhookHandler.PingWebhook)
+
+	// API key routes
+	api.Post("/api-keys", writeLimit, manageAPIKeys, apiKeyHandler.CreateAPIKey)
+	api.Get("/api-keys", readLimit, manageAPIKeys, apiKeyHandler.GetAPIKeys)
+	api.Get("/api-keys/:id", readLimit, manageAPIKeys, apiKeyHandler.GetAPIKey)
+	api.Post("/api-keys/:id/rotate", writeLimit, manageAPIKeys, apiKeyHandler.RotateAPIKey)
+	api.Delete("/api-keys/:id", writeLimit, manageAPIKeys, apiKeyHandler.RevokeAPIKey)
+
+	// User routes
+	api.Post("/users", writeLimit, manageUsers, userHandler.CreateUser)
+	api.Get("/users", readLimit, manageUsers, userHandler.GetUsers)
+	api.Get("/users/:id", readLimit, manageUsers, userHandler.GetUser)
+	api.Put("/users/:id/password", writeLimit, manageUsers, userHandler.SetPassword)
+	api.Delete("/users/:id", writeLimit, manageUsers, userHandler.DisableUser)
+
+	// Audit log
+	api.Get("/audit-log", readLimit, readAudit, auditHandler.GetEntries)
+
+	// Import/export routes
+	api.Post("/voyage/:id/import/gpx", writeLimit, writeVoyages, importHandler.ImportGPX)
+	api.Post("/voyage/:id/import/csv", writeLimit, writeVoyages, importHandler.ImportCSV)
+	api.Get("/voyage/:id/export.gpx", readLimit, readVoyages, exportHandler.ExportGPX)
+	api.Get("/voyage/:id/export.geojson", readLimit, readVoyages, exportHandler.ExportGeoJSON)
+	api.Get("/voyage/:id/export.kml", readLimit, readVoyages, exportHandler.ExportKML)
+	api.Get("/voyage/:id/export.csv", readLimit, readVoyages, exportHandler.ExportCSV)
+	api.Get("/voyages/export.geojson", readLimit, readVoyages, exportHandler.ExportFleetGeoJSON)
+
+	// Buffered GPS ingestion
+	ingestQueue.Start()
+
+	// Background audit log writer
+	auditUseCase.Start()
+
+	// Direct NMEA/AIS feed listener
+	var feedListener *feed.Listener
+	if cfg.FeedUDPAddr != "" || cfg.FeedTCPAddr != "" {
+		feedListener = feed.NewListener(feed.Config{
+			UDPAddr:        cfg.FeedUDPAddr,
+			TCPAddr:        cfg.FeedTCPAddr,
+			Sources:        cfg.FeedSources,
+			BatchSize:      cfg.FeedBatchSize,
+			FlushInterval:  cfg.FeedFlushInterval,
+			QueueSize:      cfg.FeedQueueSize,
+			VoyageCacheTTL: cfg.FeedVoyageCacheTTL,
+			WriteTimeout:   cfg.FeedWriteTimeout,
+		}, voyageUseCase, gpsTrackUseCase, aisUseCase)
+		if err := feedListener.Start(); err != nil {
+			log.Fatal().Err(err).Msg("Failed to start feed listener")
+		}
+	}
+
+	// MQTT position subscriber
+	var mqttSubscriber *mqtt.Subscriber
+	if cfg.MQTTBrokerURL != "" {
+		mqttSubscriber = mqtt.NewSubscriber(mqtt.Config{
+			BrokerURL:       cfg.MQTTBrokerURL,
+			ClientID:        cfg.MQTTClientID,
+			Username:        cfg.MQTTUsername,
+			Password:        cfg.MQTTPassword,
+			Topic:           cfg.MQTTTopic,
+			BatchSize:       cfg.MQTTBatchSize,
+			FlushInterval:   cfg.MQTTFlushInterval,
+			QueueSize:       cfg.MQTTQueueSize,
+			DeadLetterTopic: cfg.MQTTDeadLetterTopic,
+			WriteTimeout:    cfg.MQTTWriteTimeout,
+			VoyageCacheTTL:  cfg.MQTTVoyageCacheTTL,
+		}, voyageUseCase, gpsTrackUseCase)
+		if err := mqttSubscriber.Start(); err != nil {
+			log.Fatal().Err(err).Msg("Failed to start MQTT subscriber")
+		}
+	}
+
+	// Outbox relay and webhook delivery worker
+	relay.Start()
+	webhookWorker := webhook.NewWorker(webhook.Config{
+		Workers:      cfg.WebhookWorkers,
+		PollInterval: cfg.WebhookPollInterval,
+		Timeout:      cfg.WebhookTimeout,
+		LeasePadding: cfg.WebhookLeasePadding,
+
+		AllowPrivateNetworks: cfg.WebhookAllowPrivateNetworks,
+	}, webhookUseCase)
+	webhookWorker.Start()
 
 	// Graceful shutdown
 	c := make(chan os.Signal, 1)
@@ -95,16 +412,89 @@ func main() {
 	go func() {
 		<-c
 		log.Info().Msg("Shutting down gracefully...")
+		eventsHandler.Shutdown()
 		_ = app.Shutdown()
 	}()
 
 	// Start server
-	addr := fmt.Sprintf(":%s", cfg.Port)
-	log.Info().Str("port", cfg.Port).Msg("Server starting...")
+	addr := fmt.Sprintf(":%d", cfg.Port)
+	log.Info().Int("port", cfg.Port).Msg("Server starting...")
 
 	if err := app.Listen(addr); err != nil {
 		log.Fatal().Err(err).Msg("Failed to start server")
 	}
+
+	// The HTTP server has stopped; flush queued GPS tracks, feed and MQTT
+	// data, outbox events and audit entries before exiting
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := ingestQueue.Shutdown(ctx); err != nil {
+		log.Error().Err(err).Msg("GPS ingestion queue did not stop cleanly")
+	}
+	if feedListener != nil {
+		if err := feedListener.Shutdown(ctx); err != nil {
+			log.Error().Err(err).Msg("Feed listener did not stop cleanly")
+		}
+	}
+	if mqttSubscriber != nil {
+		if err := mqttSubscriber.Shutdown(ctx); err != nil {
+			log.Error().Err(err).Msg("MQTT subscriber did not stop cleanly")
+		}
+	}
+	if err := relay.Shutdown(ctx); err != nil {
+		log.Error().Err(err).Msg("Outbox relay did not stop cleanly")
+	}
+	if err := webhookWorker.Shutdown(ctx); err != nil {
+		log.Error().Err(err).Msg("Webhook worker did not stop cleanly")
+	}
+	if err := auditUseCase.Shutdown(ctx); err != nil {
+		log.Error().Err(err).Msg("Audit log writer did not stop cleanly")
+	}
+	if keySet != nil {
+		if err := keySet.Shutdown(ctx); err != nil {
+			log.Error().Err(err).Msg("JWKS refresher did not stop cleanly")
+		}
+	}
+	if memoryStore != nil {
+		memoryStore.Close()
+	}
+}
+
+// runMigrateCommand implements the migrate subcommand
+func runMigrateCommand(cfg *config.Config, db *mongo.Database, args []string) {
+	ctx := context.Background()
+
+	if len(args) > 0 && args[0] == "status" {
+		states, err := database.MigrationStatus(ctx, db, repository.Migrations())
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to read migration status")
+		}
+		for _, state := range states {
+			applied := "pending"
+			if state.AppliedAt != nil {
+				applied = state.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%4d  %-20s  %s\n", state.Version, applied, state.Description)
+		}
+		return
+	}
+	if len(args) > 0 {
+		log.Fatal().Str("command", args[0]).Msg("Unknown migrate command; use \"migrate\" or \"migrate status\"")
+	}
+
+	applied, err := database.Migrate(ctx, db, repository.Migrations(), repository.MigrationSteps(migrationOptions(cfg))...)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to apply schema migrations")
+	}
+	log.Info().Int("applied", applied).Msg("Schema migrations up to date")
+}
+
+// migrationOptions selects the optional migration steps enabled in cfg
+func migrationOptions(cfg *config.Config) repository.MigrationOptions {
+	return repository.MigrationOptions{
+		LegacyTenantID:      cfg.LegacyTenantID,
+		GPSTracksTimeSeries: cfg.GPSTracksTimeSeries,
+	}
 }
 
 // customErrorHandler handles errors globally
@@ -3,17 +3,69 @@ db = db.getSiblingDB('sailing_db');
 db.createCollection('voyages');
 db.createCollection('checkpoints');
 db.createCollection('gps_tracks');
+db.createCollection('events');
+db.createCollection('outbox');
+db.createCollection('webhook_subscriptions');
+db.createCollection('webhook_deliveries');
+db.createCollection('api_keys');
+db.createCollection('users');
+db.createCollection('refresh_tokens');
+db.createCollection('audit_log');
+db.createCollection('rate_limits');
 
 // Create indexes
+// The API's schema migrations (internal/repository/migrations.go) create the
+// same indexes and are authoritative; keep the two in sync.
 db.voyages.createIndex({ "voyage_id": 1 }, { unique: true });
 db.voyages.createIndex({ "ship_id": 1 });
 db.voyages.createIndex({ "departure_time": 1 });
 db.voyages.createIndex({ "arrival_time": 1 });
+db.voyages.createIndex({ "mmsi": 1, "status": 1 });
 
 db.checkpoints.createIndex({ "voyage_id": 1 });
 db.checkpoints.createIndex({ "timestamp": 1 });
 
 db.gps_tracks.createIndex({ "voyage_id": 1 });
 db.gps_tracks.createIndex({ "timestamp": 1 });
+db.gps_tracks.createIndex({ "voyage_id": 1, "timestamp": 1 });
+
+db.events.createIndex({ "seq": 1 }, { unique: true });
+db.events.createIndex({ "event_id": 1 }, { unique: true });
+
+// Dispatched outbox entries are kept for a week for troubleshooting
+db.outbox.createIndex({ "dispatched": 1, "_id": 1 });
+db.outbox.createIndex({ "dispatched_at": 1 }, { expireAfterSeconds: 7 * 24 * 3600 });
+
+db.webhook_subscriptions.createIndex({ "active": 1 });
+db.webhook_deliveries.createIndex({ "status": 1, "next_attempt_at": 1 });
+db.webhook_deliveries.createIndex({ "status": 1, "updated_at": -1 });
+
+db.api_keys.createIndex({ "key_id": 1 }, { unique: true });
+
+db.users.createIndex({ "username": 1 }, { unique: true });
+db.refresh_tokens.createIndex({ "token_hash": 1 }, { unique: true });
+db.refresh_tokens.createIndex({ "family_id": 1 });
+db.refresh_tokens.createIndex({ "user_id": 1 });
+db.refresh_tokens.createIndex({ "expires_at": 1 }, { expireAfterSeconds: 0 });
+
+db.audit_log.createIndex({ "created_at": -1 });
+db.audit_log.createIndex({ "principal": 1, "created_at": -1 });
+db.audit_log.createIndex({ "changes.entity": 1, "changes.entity_id": 1, "created_at": -1 });
+
+db.rate_limits.createIndex({ "expires_at": 1 }, { expireAfterSeconds: 0 });
+
+// Tenant-scoped queries
+db.voyages.createIndex({ "tenant_id": 1, "created_at": -1 });
+db.voyages.createIndex({ "tenant_id": 1, "departure_time": -1 });
+db.voyages.createIndex({ "tenant_id": 1, "ship_id": 1, "status": 1 });
+db.voyages.createIndex({ "tenant_id": 1, "mmsi": 1, "status": 1 });
+db.checkpoints.createIndex({ "tenant_id": 1, "voyage_id": 1, "timestamp": 1 });
+db.gps_tracks.createIndex({ "tenant_id": 1, "voyage_id": 1, "timestamp": 1 });
+db.events.createIndex({ "tenant_id": 1, "seq": 1 });
+db.webhook_subscriptions.createIndex({ "tenant_id": 1, "created_at": -1 });
+db.webhook_deliveries.createIndex({ "event.tenant_id": 1, "updated_at": -1 });
+db.api_keys.createIndex({ "tenant_id": 1, "created_at": -1 });
+db.users.createIndex({ "tenant_id": 1, "created_at": -1 });
+db.audit_log.createIndex({ "tenant_id": 1, "created_at": -1 });
 
 print('Database initialized successfully');
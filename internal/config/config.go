@@ -1,42 +1,466 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"net"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
-// Config holds the application configuration
+// Defaults of the credentials
+const (
+	defaultJWTSecret = "default-secret-change-this"
+	defaultAPIKey    = "default-api-key-change-this"
+)
+
+// placeholderSecrets are the published example credentials, which
+// production refuses to start with
+var placeholderSecrets = map[string]bool{
+	defaultJWTSecret: true,
+	defaultAPIKey:    true,
+	"your-super-secret-jwt-key-change-this-in-production": true, // .env.example, docker-compose.yaml
+	"your-api-key-change-this-in-production":              true,
+}
+
+// Config holds the application configuration. Each setting is read from the
+// environment variable named by its env tag; in a config file, its key is
+// that name in lower case. Settings tagged secret are hidden by Redacted.
 type Config struct {
-	Port            string
-	MongoDBURI      string
-	MongoDBDatabase string
-	JWTSecret       string
-	APIKey          string
-	LogLevel        string
-	Environment     string
+	Port            int    `env:"PORT"`
+	MongoDBURI      string `env:"MONGODB_URI" secret:"url"`
+	MongoDBDatabase string `env:"MONGODB_DATABASE"`
+	JWTSecret       string `env:"JWT_SECRET" secret:"true"`
+	APIKey          string `env:"API_KEY" secret:"true"` // bootstrap admin key; managed keys live in MongoDB
+	LogLevel        string `env:"LOG_LEVEL"`
+	Environment     string `env:"ENV"`
+
+	// Time allowed at shutdown to flush queued tracks, events and audit
+	// entries
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT"`
+
+	// Timeouts of MongoDB operations: connecting, single documents, bulk
+	// writes and list pages, and cursors over whole voyages or the fleet
+	MongoDBConnectTimeout time.Duration `env:"MONGODB_CONNECT_TIMEOUT"`
+	MongoDBQueryTimeout   time.Duration `env:"MONGODB_QUERY_TIMEOUT"`
+	MongoDBBatchTimeout   time.Duration `env:"MONGODB_BATCH_TIMEOUT"`
+	MongoDBStreamTimeout  time.Duration `env:"MONGODB_STREAM_TIMEOUT"`
+
+	// Request limits: body size in bytes, records written per chunk during
+	// imports, and the default and largest page of list endpoints
+	BodyLimit       int `env:"BODY_LIMIT"`
+	ImportChunkSize int `env:"IMPORT_CHUNK_SIZE"`
+	DefaultPageSize int `env:"DEFAULT_PAGE_SIZE"`
+	MaxPageSize     int `env:"MAX_PAGE_SIZE"`
+
+	// Bearer token validation. RS256/ES256 tokens are checked against a JWKS
+	// loaded from a URL or file and reloaded every JWKSRefresh.
+	JWTJWKSURL     string        `env:"JWT_JWKS_URL" secret:"url"`
+	JWTJWKSFile    string        `env:"JWT_JWKS_FILE"`
+	JWTJWKSRefresh time.Duration `env:"JWT_JWKS_REFRESH"`
+	JWTIssuer      string        `env:"JWT_ISSUER"`
+	JWTAudience    string        `env:"JWT_AUDIENCE"`
+	JWTLeeway      time.Duration `env:"JWT_LEEWAY"`
+
+	// How long managed API keys are cached after lookup; revocations on
+	// other instances take up to this long to apply
+	APIKeyCacheTTL time.Duration `env:"API_KEY_CACHE_TTL"`
+
+	// Lifetimes of the tokens issued by /auth/token
+	AccessTokenTTL  time.Duration `env:"ACCESS_TOKEN_TTL"`
+	RefreshTokenTTL time.Duration `env:"REFRESH_TOKEN_TTL"`
+
+	// Requests allowed per RateLimitWindow in each route group, per
+	// principal and per tenant (0 disables a limit). Overrides are keyed by
+	// "<subject>/<group>" or "tenant:<id>/<group>". With RateLimitStore
+	// "mongo", counters are shared by all instances.
+	RateLimitWindow       time.Duration  `env:"RATE_LIMIT_WINDOW"`
+	RateLimitRead         int            `env:"RATE_LIMIT_READ"`
+	RateLimitWrite        int            `env:"RATE_LIMIT_WRITE"`
+	RateLimitIngest       int            `env:"RATE_LIMIT_INGEST"`
+	RateLimitAuth         int            `env:"RATE_LIMIT_AUTH"`          // per client IP
+	RateLimitAuthFailures int            `env:"RATE_LIMIT_AUTH_FAILURES"` // 401 responses per client IP before it is blocked
+	RateLimitTenantRead   int            `env:"RATE_LIMIT_TENANT_READ"`
+	RateLimitTenantWrite  int            `env:"RATE_LIMIT_TENANT_WRITE"`
+	RateLimitTenantIngest int            `env:"RATE_LIMIT_TENANT_INGEST"`
+	RateLimitOverrides    map[string]int `env:"RATE_LIMIT_OVERRIDES"`
+	RateLimitStore        string         `env:"RATE_LIMIT_STORE"`         // "memory" or "mongo"
+	RateLimitStoreTimeout time.Duration  `env:"RATE_LIMIT_STORE_TIMEOUT"` // after which requests are let through
+
+	// Client IPs are read from ProxyHeader on requests arriving from one of
+	// TrustedProxies (IPs or CIDRs); other requests use the peer address
+	ProxyHeader    string   `env:"PROXY_HEADER"`
+	TrustedProxies []string `env:"TRUSTED_PROXIES"`
+
+	// Browser access and security headers. Outside production, every origin
+	// is allowed unless CORSAllowOrigins is set; production allows none by
+	// default and sends HSTS.
+	CORSAllowOrigins      []string      `env:"CORS_ALLOW_ORIGINS"`
+	CORSAllowMethods      []string      `env:"CORS_ALLOW_METHODS"`
+	CORSAllowHeaders      []string      `env:"CORS_ALLOW_HEADERS"`
+	CORSExposeHeaders     []string      `env:"CORS_EXPOSE_HEADERS"`
+	CORSAllowCredentials  bool          `env:"CORS_ALLOW_CREDENTIALS"`
+	CORSMaxAge            time.Duration `env:"CORS_MAX_AGE"`
+	HSTSMaxAge            time.Duration `env:"HSTS_MAX_AGE"`
+	HSTSIncludeSubdomains bool          `env:"HSTS_INCLUDE_SUBDOMAINS"`
+	HSTSPreload           bool          `env:"HSTS_PRELOAD"`
+	ContentSecurityPolicy string        `env:"CONTENT_SECURITY_POLICY"`
+
+	// Store GPS tracks in a time-series collection by enabling the
+	// migration step that converts the regular one
+	GPSTracksTimeSeries bool `env:"GPS_TRACKS_TIMESERIES"`
+
+	// Tenant assigned to voyages, checkpoints, GPS tracks and events stored
+	// before tenants were introduced; empty leaves them without one
+	LegacyTenantID string `env:"LEGACY_TENANT_ID"`
+
+	// Apply pending schema migrations at startup; when disabled, run
+	// "migrate" before deploying
+	MigrateOnStart bool `env:"MIGRATE_ON_START"`
+
+	// Direct NMEA/AIS feed listener; disabled unless an address is set
+	FeedUDPAddr        string            `env:"FEED_UDP_ADDR"`
+	FeedTCPAddr        string            `env:"FEED_TCP_ADDR"`
+	FeedSources        map[string]string `env:"FEED_SOURCES"` // gateway IP -> ship ID
+	FeedBatchSize      int               `env:"FEED_BATCH_SIZE"`
+	FeedFlushInterval  time.Duration     `env:"FEED_FLUSH_INTERVAL"`
+	FeedQueueSize      int               `env:"FEED_QUEUE_SIZE"`
+	FeedVoyageCacheTTL time.Duration     `env:"FEED_VOYAGE_CACHE_TTL"`
+	FeedWriteTimeout   time.Duration     `env:"FEED_WRITE_TIMEOUT"`
+
+	// Buffered GPS ingestion queue behind POST /gps-tracks
+	IngestBatchSize      int           `env:"INGEST_BATCH_SIZE"`
+	IngestFlushInterval  time.Duration `env:"INGEST_FLUSH_INTERVAL"`
+	IngestQueueSize      int           `env:"INGEST_QUEUE_SIZE"`
+	IngestWriters        int           `env:"INGEST_WRITERS"`
+	IngestVoyageCacheTTL time.Duration `env:"INGEST_VOYAGE_CACHE_TTL"`
+	IngestWriteTimeout   time.Duration `env:"INGEST_WRITE_TIMEOUT"`
+
+	// Background writer of the audit log
+	AuditBatchSize     int           `env:"AUDIT_BATCH_SIZE"`
+	AuditFlushInterval time.Duration `env:"AUDIT_FLUSH_INTERVAL"`
+	AuditQueueSize     int           `env:"AUDIT_QUEUE_SIZE"`
+	AuditWriteTimeout  time.Duration `env:"AUDIT_WRITE_TIMEOUT"`
+
+	// MQTT position subscriber; disabled unless a broker URL is set
+	MQTTBrokerURL       string        `env:"MQTT_BROKER_URL" secret:"url"`
+	MQTTClientID        string        `env:"MQTT_CLIENT_ID"`
+	MQTTUsername        string        `env:"MQTT_USERNAME"`
+	MQTTPassword        string        `env:"MQTT_PASSWORD" secret:"true"`
+	MQTTTopic           string        `env:"MQTT_TOPIC"`
+	MQTTBatchSize       int           `env:"MQTT_BATCH_SIZE"`
+	MQTTFlushInterval   time.Duration `env:"MQTT_FLUSH_INTERVAL"`
+	MQTTQueueSize       int           `env:"MQTT_QUEUE_SIZE"`
+	MQTTDeadLetterTopic string        `env:"MQTT_DEAD_LETTER_TOPIC"` // rejected messages; empty only logs them
+	MQTTWriteTimeout    time.Duration `env:"MQTT_WRITE_TIMEOUT"`
+	MQTTVoyageCacheTTL  time.Duration `env:"MQTT_VOYAGE_CACHE_TTL"`
+
+	// Transactional outbox relay. One instance at a time dispatches, for as
+	// long as it keeps renewing its lease.
+	OutboxPollInterval time.Duration `env:"OUTBOX_POLL_INTERVAL"`
+	OutboxBatchSize    int           `env:"OUTBOX_BATCH_SIZE"`
+	OutboxLeaseTTL     time.Duration `env:"OUTBOX_LEASE_TTL"`
+
+	// Outgoing webhook delivery
+	WebhookWorkers        int           `env:"WEBHOOK_WORKERS"`
+	WebhookTimeout        time.Duration `env:"WEBHOOK_TIMEOUT"`
+	WebhookLeasePadding   time.Duration `env:"WEBHOOK_LEASE_PADDING"` // claim lease beyond WebhookTimeout
+	WebhookPollInterval   time.Duration `env:"WEBHOOK_POLL_INTERVAL"`
+	WebhookMaxAttempts    int           `env:"WEBHOOK_MAX_ATTEMPTS"`
+	WebhookInitialBackoff time.Duration `env:"WEBHOOK_INITIAL_BACKOFF"`
+	WebhookMaxBackoff     time.Duration `env:"WEBHOOK_MAX_BACKOFF"`
+
+	// Allow webhook URLs on loopback, private and link-local addresses;
+	// otherwise they are refused to keep webhooks from reaching internal
+	// services
+	WebhookAllowPrivateNetworks bool `env:"WEBHOOK_ALLOW_PRIVATE_NETWORKS"`
 }
 
-// LoadConfig loads the application configuration
+// LoadConfig loads the application configuration. Settings come from, in
+// increasing precedence: built-in defaults, the YAML or TOML file named by
+// CONFIG_FILE, and environment variables, including those from a .env file
+// that are not already set. Invalid settings are reported together.
 func LoadConfig() (*Config, error) {
 	// Load .env file if it exists
 	_ = godotenv.Load()
 
-	return &Config{
-		Port:            getEnv("PORT", "8080"),
-		MongoDBURI:      getEnv("MONGODB_URI", "mongodb://localhost:27017"),
-		MongoDBDatabase: getEnv("MONGODB_DATABASE", "sailing_db"),
-		JWTSecret:       getEnv("JWT_SECRET", "default-secret-change-this"),
-		APIKey:          getEnv("API_KEY", "default-api-key-change-this"),
-		LogLevel:        getEnv("LOG_LEVEL", "info"),
-		Environment:     getEnv("ENV", "development"),
-	}, nil
+	var file map[string]any
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		var err error
+		if file, err = readFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	// Some defaults depend on the environment, so it is resolved first
+	environment := "development"
+	if value, ok := file["env"].(string); ok && value != "" {
+		environment = value
+	}
+	if value := os.Getenv("ENV"); value != "" {
+		environment = value
+	}
+
+	cfg := defaults(environment)
+	var errs []error
+	errs = append(errs, cfg.applyFile(file)...)
+	errs = append(errs, cfg.applyEnv()...)
+	if len(errs) == 0 {
+		errs = cfg.validate()
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return cfg, nil
 }
 
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+// defaults returns the built-in configuration for an environment
+func defaults(environment string) *Config {
+	cfg := &Config{
+		Port:            8080,
+		MongoDBURI:      "mongodb://localhost:27017",
+		MongoDBDatabase: "sailing_db",
+		JWTSecret:       defaultJWTSecret,
+		APIKey:          defaultAPIKey,
+		LogLevel:        "info",
+		Environment:     environment,
+
+		ShutdownTimeout: 30 * time.Second,
+
+		MongoDBConnectTimeout: 10 * time.Second,
+		MongoDBQueryTimeout:   5 * time.Second,
+		MongoDBBatchTimeout:   10 * time.Second,
+		MongoDBStreamTimeout:  10 * time.Minute,
+
+		BodyLimit:       4 * 1024 * 1024,
+		ImportChunkSize: 1000,
+		DefaultPageSize: 100,
+		MaxPageSize:     1000,
+
+		JWTJWKSRefresh: 15 * time.Minute,
+		JWTLeeway:      30 * time.Second,
+
+		APIKeyCacheTTL: 30 * time.Second,
+
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 30 * 24 * time.Hour,
+
+		RateLimitWindow:       time.Minute,
+		RateLimitRead:         600,
+		RateLimitWrite:        120,
+		RateLimitIngest:       6000,
+		RateLimitAuth:         30,
+		RateLimitAuthFailures: 20,
+		RateLimitOverrides:    map[string]int{},
+		RateLimitStore:        "memory",
+		RateLimitStoreTimeout: 2 * time.Second,
+
+		CORSAllowMethods:      []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		CORSAllowHeaders:      []string{"Origin", "Content-Type", "Accept", "Authorization", "X-API-Key"},
+		CORSExposeHeaders:     []string{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After"},
+		HSTSIncludeSubdomains: true,
+		ContentSecurityPolicy: "default-src 'none'; frame-ancestors 'none'",
+
+		MigrateOnStart: true,
+
+		FeedSources:        map[string]string{},
+		FeedBatchSize:      100,
+		FeedFlushInterval:  time.Second,
+		FeedQueueSize:      10000,
+		FeedVoyageCacheTTL: 30 * time.Second,
+		FeedWriteTimeout:   30 * time.Second,
+
+		IngestBatchSize:      500,
+		IngestFlushInterval:  200 * time.Millisecond,
+		IngestQueueSize:      20000,
+		IngestWriters:        2,
+		IngestVoyageCacheTTL: 5 * time.Second,
+		IngestWriteTimeout:   30 * time.Second,
+
+		AuditBatchSize:     100,
+		AuditFlushInterval: time.Second,
+		AuditQueueSize:     10000,
+		AuditWriteTimeout:  30 * time.Second,
+
+		MQTTClientID:        "sailing-backend",
+		MQTTTopic:           "fleet/+/position",
+		MQTTBatchSize:       100,
+		MQTTFlushInterval:   time.Second,
+		MQTTQueueSize:       10000,
+		MQTTDeadLetterTopic: "fleet/dead-letter",
+		MQTTWriteTimeout:    30 * time.Second,
+		MQTTVoyageCacheTTL:  30 * time.Second,
+
+		OutboxPollInterval: 250 * time.Millisecond,
+		OutboxBatchSize:    500,
+		OutboxLeaseTTL:     15 * time.Second,
+
+		WebhookWorkers:        2,
+		WebhookTimeout:        10 * time.Second,
+		WebhookLeasePadding:   30 * time.Second,
+		WebhookPollInterval:   2 * time.Second,
+		WebhookMaxAttempts:    8,
+		WebhookInitialBackoff: 10 * time.Second,
+		WebhookMaxBackoff:     time.Hour,
+	}
+
+	if environment == "production" {
+		cfg.HSTSMaxAge = 365 * 24 * time.Hour
+	} else {
+		cfg.CORSAllowOrigins = []string{"*"}
+	}
+	return cfg
+}
+
+// IsProduction reports whether the application runs in production
+func (c *Config) IsProduction() bool {
+	return c.Environment == "production"
+}
+
+// validate returns every setting that is out of range, inconsistent, or
+// insecure in production
+func (c *Config) validate() []error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	positive := func(name string, value int) {
+		check(value > 0, "%s must be greater than zero, got %d", name, value)
+	}
+	positiveDuration := func(name string, value time.Duration) {
+		check(value > 0, "%s must be greater than zero, got %s", name, value)
+	}
+	nonNegative := func(name string, value int) {
+		check(value >= 0, "%s must not be negative, got %d", name, value)
 	}
-	return value
+
+	check(oneOf(c.Environment, "development", "test", "staging", "production"),
+		"ENV must be development, test, staging or production, got %q", c.Environment)
+	check(c.Port > 0 && c.Port <= 65535, "PORT must be between 1 and 65535, got %d", c.Port)
+	check(oneOf(c.LogLevel, "trace", "debug", "info", "warn", "error", "fatal", "panic", "disabled"),
+		"LOG_LEVEL must be trace, debug, info, warn, error, fatal, panic or disabled, got %q", c.LogLevel)
+	positiveDuration("SHUTDOWN_TIMEOUT", c.ShutdownTimeout)
+
+	check(strings.HasPrefix(c.MongoDBURI, "mongodb://") || strings.HasPrefix(c.MongoDBURI, "mongodb+srv://"),
+		"MONGODB_URI must start with mongodb:// or mongodb+srv://")
+	check(c.MongoDBDatabase != "", "MONGODB_DATABASE must not be empty")
+	positiveDuration("MONGODB_CONNECT_TIMEOUT", c.MongoDBConnectTimeout)
+	positiveDuration("MONGODB_QUERY_TIMEOUT", c.MongoDBQueryTimeout)
+	positiveDuration("MONGODB_BATCH_TIMEOUT", c.MongoDBBatchTimeout)
+	positiveDuration("MONGODB_STREAM_TIMEOUT", c.MongoDBStreamTimeout)
+
+	positive("BODY_LIMIT", c.BodyLimit)
+	positive("IMPORT_CHUNK_SIZE", c.ImportChunkSize)
+	positive("DEFAULT_PAGE_SIZE", c.DefaultPageSize)
+	positive("MAX_PAGE_SIZE", c.MaxPageSize)
+	check(c.DefaultPageSize <= c.MaxPageSize, "DEFAULT_PAGE_SIZE (%d) must not exceed MAX_PAGE_SIZE (%d)", c.DefaultPageSize, c.MaxPageSize)
+
+	check(c.JWTSecret != "", "JWT_SECRET must not be empty")
+	positiveDuration("JWT_JWKS_REFRESH", c.JWTJWKSRefresh)
+	check(c.JWTLeeway >= 0, "JWT_LEEWAY must not be negative, got %s", c.JWTLeeway)
+	positiveDuration("API_KEY_CACHE_TTL", c.APIKeyCacheTTL)
+	positiveDuration("ACCESS_TOKEN_TTL", c.AccessTokenTTL)
+	positiveDuration("REFRESH_TOKEN_TTL", c.RefreshTokenTTL)
+
+	positiveDuration("RATE_LIMIT_WINDOW", c.RateLimitWindow)
+	nonNegative("RATE_LIMIT_READ", c.RateLimitRead)
+	nonNegative("RATE_LIMIT_WRITE", c.RateLimitWrite)
+	nonNegative("RATE_LIMIT_INGEST", c.RateLimitIngest)
+	nonNegative("RATE_LIMIT_AUTH", c.RateLimitAuth)
+	nonNegative("RATE_LIMIT_AUTH_FAILURES", c.RateLimitAuthFailures)
+	nonNegative("RATE_LIMIT_TENANT_READ", c.RateLimitTenantRead)
+	nonNegative("RATE_LIMIT_TENANT_WRITE", c.RateLimitTenantWrite)
+	nonNegative("RATE_LIMIT_TENANT_INGEST", c.RateLimitTenantIngest)
+	for key, limit := range c.RateLimitOverrides {
+		owner, group, _ := strings.Cut(key, "/")
+		check(owner != "" && oneOf(group, "read", "write", "ingest", "auth"),
+			"RATE_LIMIT_OVERRIDES key %q must be <subject>/<group> with group read, write, ingest or auth", key)
+		check(limit >= 0, "RATE_LIMIT_OVERRIDES %q must not be negative, got %d", key, limit)
+	}
+	check(oneOf(c.RateLimitStore, "memory", "mongo"), "RATE_LIMIT_STORE must be memory or mongo, got %q", c.RateLimitStore)
+	positiveDuration("RATE_LIMIT_STORE_TIMEOUT", c.RateLimitStoreTimeout)
+	check(c.ProxyHeader == "" || len(c.TrustedProxies) > 0, "PROXY_HEADER requires TRUSTED_PROXIES")
+	for _, proxy := range c.TrustedProxies {
+		_, _, err := net.ParseCIDR(proxy)
+		check(err == nil || net.ParseIP(proxy) != nil, "TRUSTED_PROXIES entry %q must be an IP or CIDR", proxy)
+	}
+
+	wildcard := false
+	for _, origin := range c.CORSAllowOrigins {
+		if isWildcardOrigin(origin) {
+			wildcard = true
+		}
+	}
+	check(!(wildcard && c.CORSAllowCredentials), "CORS_ALLOW_CREDENTIALS requires explicit CORS_ALLOW_ORIGINS, not a wildcard")
+	check(c.HSTSMaxAge >= 0, "HSTS_MAX_AGE must not be negative, got %s", c.HSTSMaxAge)
+
+	positive("FEED_BATCH_SIZE", c.FeedBatchSize)
+	positiveDuration("FEED_FLUSH_INTERVAL", c.FeedFlushInterval)
+	positive("FEED_QUEUE_SIZE", c.FeedQueueSize)
+	positiveDuration("FEED_VOYAGE_CACHE_TTL", c.FeedVoyageCacheTTL)
+	positiveDuration("FEED_WRITE_TIMEOUT", c.FeedWriteTimeout)
+
+	positive("INGEST_BATCH_SIZE", c.IngestBatchSize)
+	positiveDuration("INGEST_FLUSH_INTERVAL", c.IngestFlushInterval)
+	positive("INGEST_QUEUE_SIZE", c.IngestQueueSize)
+	positive("INGEST_WRITERS", c.IngestWriters)
+	positiveDuration("INGEST_VOYAGE_CACHE_TTL", c.IngestVoyageCacheTTL)
+	positiveDuration("INGEST_WRITE_TIMEOUT", c.IngestWriteTimeout)
+
+	positive("AUDIT_BATCH_SIZE", c.AuditBatchSize)
+	positiveDuration("AUDIT_FLUSH_INTERVAL", c.AuditFlushInterval)
+	positive("AUDIT_QUEUE_SIZE", c.AuditQueueSize)
+	positiveDuration("AUDIT_WRITE_TIMEOUT", c.AuditWriteTimeout)
+
+	if c.MQTTBrokerURL != "" {
+		check(c.MQTTTopic != "", "MQTT_TOPIC must not be empty when MQTT_BROKER_URL is set")
+		check(c.MQTTClientID != "", "MQTT_CLIENT_ID must not be empty when MQTT_BROKER_URL is set")
+	}
+	positive("MQTT_BATCH_SIZE", c.MQTTBatchSize)
+	positiveDuration("MQTT_FLUSH_INTERVAL", c.MQTTFlushInterval)
+	positive("MQTT_QUEUE_SIZE", c.MQTTQueueSize)
+	positiveDuration("MQTT_WRITE_TIMEOUT", c.MQTTWriteTimeout)
+	positiveDuration("MQTT_VOYAGE_CACHE_TTL", c.MQTTVoyageCacheTTL)
+
+	positiveDuration("OUTBOX_POLL_INTERVAL", c.OutboxPollInterval)
+	positive("OUTBOX_BATCH_SIZE", c.OutboxBatchSize)
+	positiveDuration("OUTBOX_LEASE_TTL", c.OutboxLeaseTTL)
+
+	positive("WEBHOOK_WORKERS", c.WebhookWorkers)
+	positiveDuration("WEBHOOK_TIMEOUT", c.WebhookTimeout)
+	positiveDuration("WEBHOOK_LEASE_PADDING", c.WebhookLeasePadding)
+	positiveDuration("WEBHOOK_POLL_INTERVAL", c.WebhookPollInterval)
+	positive("WEBHOOK_MAX_ATTEMPTS", c.WebhookMaxAttempts)
+	positiveDuration("WEBHOOK_INITIAL_BACKOFF", c.WebhookInitialBackoff)
+	check(c.WebhookInitialBackoff <= c.WebhookMaxBackoff, "WEBHOOK_INITIAL_BACKOFF (%s) must not exceed WEBHOOK_MAX_BACKOFF (%s)",
+		c.WebhookInitialBackoff, c.WebhookMaxBackoff)
+
+	if c.IsProduction() {
+		check(!wildcard, "CORS_ALLOW_ORIGINS must list explicit origins in production")
+		check(!placeholderSecrets[c.JWTSecret], "JWT_SECRET must be set in production")
+		check(!placeholderSecrets[c.APIKey], "API_KEY must be set in production")
+	}
+
+	return errs
+}
+
+// oneOf reports whether value is one of allowed
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
+
+// isWildcardOrigin reports whether origin allows any host, as "*" and
+// "https://*" do; subdomain patterns such as "https://*.example.com" are fine
+func isWildcardOrigin(origin string) bool {
+	return origin == "*" || strings.HasSuffix(origin, "://*")
 }
@@ -0,0 +1,317 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// redactedValue replaces secrets in Redacted
+const redactedValue = "[REDACTED]"
+
+// setting is one field of Config with its environment variable
+type setting struct {
+	name   string // environment variable; lower-cased in config files
+	secret string // "true" to hide the value, "url" to hide a URL's password
+	value  reflect.Value
+}
+
+// settings lists the fields of c that have an env tag
+func (c *Config) settings() []setting {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+
+	var settings []setting
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("env")
+		if name == "" {
+			continue
+		}
+		settings = append(settings, setting{
+			name:   name,
+			secret: t.Field(i).Tag.Get("secret"),
+			value:  v.Field(i),
+		})
+	}
+	return settings
+}
+
+// readFile parses a YAML or TOML config file, chosen by its extension, into
+// settings keyed by lower-case name
+func readFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+
+	raw := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format; use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	file := make(map[string]any, len(raw))
+	for key, value := range raw {
+		file[strings.ToLower(key)] = value
+	}
+	return file, nil
+}
+
+// applyFile sets the settings present in a config file
+func (c *Config) applyFile(file map[string]any) []error {
+	var errs []error
+	known := make(map[string]bool)
+	for _, s := range c.settings() {
+		key := strings.ToLower(s.name)
+		known[key] = true
+
+		raw, ok := file[key]
+		if !ok || raw == nil {
+			continue
+		}
+		if err := setFromFile(s.value, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s in config file: %w", key, err))
+		}
+	}
+
+	var unknown []string
+	for key := range file {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		errs = append(errs, fmt.Errorf("unknown setting %q in config file", key))
+	}
+	return errs
+}
+
+// applyEnv sets the settings present in the environment. Empty variables
+// are ignored, except that an empty list clears it.
+func (c *Config) applyEnv() []error {
+	var errs []error
+	for _, s := range c.settings() {
+		value, ok := os.LookupEnv(s.name)
+		if !ok {
+			continue
+		}
+		if _, isList := s.value.Interface().([]string); value == "" && !isList {
+			continue
+		}
+		if err := parse(s.value, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+		}
+	}
+	return errs
+}
+
+// setFromFile sets a field from a decoded YAML or TOML value. Lists and maps
+// may be written natively or in their environment variable form.
+func setFromFile(field reflect.Value, raw any) error {
+	switch p := field.Addr().Interface().(type) {
+	case *[]string:
+		items, ok := raw.([]any)
+		if !ok {
+			break
+		}
+		list := make([]string, 0, len(items))
+		for _, item := range items {
+			s, err := scalar(item)
+			if err != nil {
+				return err
+			}
+			list = append(list, s)
+		}
+		*p = list
+		return nil
+	case *map[string]string:
+		pairs, ok := raw.(map[string]any)
+		if !ok {
+			break
+		}
+		m := make(map[string]string, len(pairs))
+		for k, item := range pairs {
+			s, err := scalar(item)
+			if err != nil {
+				return err
+			}
+			m[k] = s
+		}
+		*p = m
+		return nil
+	case *map[string]int:
+		pairs, ok := raw.(map[string]any)
+		if !ok {
+			break
+		}
+		m := make(map[string]int, len(pairs))
+		for k, item := range pairs {
+			s, err := scalar(item)
+			if err != nil {
+				return err
+			}
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return fmt.Errorf("invalid integer %q for %q", s, k)
+			}
+			m[k] = n
+		}
+		*p = m
+		return nil
+	}
+
+	s, err := scalar(raw)
+	if err != nil {
+		return err
+	}
+	return parse(field, s)
+}
+
+// scalar returns the text of a single decoded value
+func scalar(raw any) (string, error) {
+	switch v := raw.(type) {
+	case string:
+		return v, nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("expected a single value, got %T", raw)
+	}
+}
+
+// parse sets a field from its environment variable form
+func parse(field reflect.Value, value string) error {
+	switch p := field.Addr().Interface().(type) {
+	case *string:
+		*p = value
+	case *int:
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		*p = n
+	case *bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q; use true or false", value)
+		}
+		*p = b
+	case *time.Duration:
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("invalid duration %q; use a value such as 30s, 5m or 720h", value)
+		}
+		*p = d
+	case *[]string:
+		*p = parseList(value)
+	case *map[string]string:
+		m, err := parsePairs(value)
+		if err != nil {
+			return err
+		}
+		*p = m
+	case *map[string]int:
+		pairs, err := parsePairs(value)
+		if err != nil {
+			return err
+		}
+		m := make(map[string]int, len(pairs))
+		for k, v := range pairs {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid integer %q for %q", v, k)
+			}
+			m[k] = n
+		}
+		*p = m
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
+
+// parseList parses a comma-separated list, dropping empty items
+func parseList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// parsePairs parses a comma-separated list of key=value pairs
+func parsePairs(value string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	for _, pair := range parseList(value) {
+		k, v, ok := strings.Cut(pair, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !ok || k == "" || v == "" {
+			return nil, fmt.Errorf("invalid pair %q; use key=value", pair)
+		}
+		pairs[k] = v
+	}
+	return pairs, nil
+}
+
+// Redacted returns the settings by environment variable name for logging,
+// with secrets and the passwords of URLs hidden
+func (c *Config) Redacted() map[string]any {
+	redacted := make(map[string]any)
+	for _, s := range c.settings() {
+		value := s.value.Interface()
+		switch v := value.(type) {
+		case time.Duration:
+			value = v.String()
+		case string:
+			switch {
+			case v == "":
+			case s.secret == "true":
+				value = redactedValue
+			case s.secret == "url":
+				value = redactURL(v)
+			}
+		}
+		redacted[s.name] = value
+	}
+	return redacted
+}
+
+// redactURL hides the password in a URL's user info. It does not use
+// net/url, which rejects MongoDB URIs listing several hosts.
+func redactURL(raw string) string {
+	scheme, rest, ok := strings.Cut(raw, "://")
+	if !ok {
+		return raw
+	}
+	authority := rest
+	if end := strings.IndexAny(rest, "/?#"); end >= 0 {
+		authority = rest[:end]
+	}
+	at := strings.LastIndex(authority, "@")
+	if at < 0 {
+		return raw
+	}
+	user, _, hasPassword := strings.Cut(authority[:at], ":")
+	if !hasPassword {
+		return raw
+	}
+	return scheme + "://" + user + ":" + redactedValue + rest[at:]
+}
@@ -0,0 +1,337 @@
+// Package feed ingests line-delimited NMEA 0183 and AIS sentences pushed by
+// shipboard gateways over UDP or TCP
+package feed
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/chats/sailing-backend/internal/usecase"
+	"github.com/rs/zerolog/log"
+)
+
+// maxDatagramSize is the largest UDP payload read in one call
+const maxDatagramSize = 64 * 1024
+
+const (
+	// udpSourceIdle is how long a UDP sender may stay silent before its
+	// parsing state is dropped
+	udpSourceIdle = 2 * time.Minute
+
+	// maxUDPSources caps the senders tracked at once. UDP source addresses
+	// can be spoofed, so the number of distinct senders is not bounded.
+	maxUDPSources = 4096
+)
+
+// Config configures the feed listener
+type Config struct {
+	UDPAddr string // e.g. ":10110"; empty disables UDP
+	TCPAddr string // e.g. ":10110"; empty disables TCP
+
+	// Sources maps the remote IP of a gateway to the ship whose active
+	// voyage receives its NMEA fixes. AIS sentences identify the ship by
+	// MMSI and do not need a mapping.
+	Sources map[string]string
+
+	BatchSize      int           // fixes written per batch
+	FlushInterval  time.Duration // maximum time a fix waits in a partial batch
+	QueueSize      int           // buffered fixes before senders are throttled
+	VoyageCacheTTL time.Duration // how long a ship's active voyage is remembered
+	WriteTimeout   time.Duration // bounds a voyage lookup or batch write
+}
+
+// Listener accepts sentences over UDP and TCP and writes them through the
+// GPS track and AIS use cases
+type Listener struct {
+	cfg             Config
+	voyageUseCase   *usecase.VoyageUseCase
+	gpsTrackUseCase *usecase.GPSTrackUseCase
+	aisUseCase      *usecase.AISUseCase
+
+	voyages *usecase.ActiveVoyageCache
+	fixes   chan pendingFix // UDP fixes waiting for their voyage lookup
+	tracks  chan *domain.GPSTrack
+	reports chan usecase.AISReport
+
+	udpConn     net.PacketConn
+	tcpListener net.Listener
+
+	mu      sync.Mutex
+	conns   map[net.Conn]struct{}
+	closing bool
+
+	readers   sync.WaitGroup
+	resolvers sync.WaitGroup
+	writers   sync.WaitGroup
+}
+
+// NewListener creates a new feed listener
+func NewListener(cfg Config, voyageUseCase *usecase.VoyageUseCase, gpsTrackUseCase *usecase.GPSTrackUseCase, aisUseCase *usecase.AISUseCase) *Listener {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 10000
+	}
+	if cfg.VoyageCacheTTL <= 0 {
+		cfg.VoyageCacheTTL = 30 * time.Second
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = 30 * time.Second
+	}
+
+	return &Listener{
+		cfg:             cfg,
+		voyageUseCase:   voyageUseCase,
+		gpsTrackUseCase: gpsTrackUseCase,
+		aisUseCase:      aisUseCase,
+		voyages:         usecase.NewActiveVoyageCache(voyageUseCase, cfg.VoyageCacheTTL),
+		fixes:           make(chan pendingFix, cfg.QueueSize),
+		tracks:          make(chan *domain.GPSTrack, cfg.QueueSize),
+		reports:         make(chan usecase.AISReport, cfg.QueueSize),
+		conns:           make(map[net.Conn]struct{}),
+	}
+}
+
+// Start opens the configured sockets and begins processing sentences
+func (l *Listener) Start() error {
+	if l.cfg.UDPAddr != "" {
+		conn, err := net.ListenPacket("udp", l.cfg.UDPAddr)
+		if err != nil {
+			return err
+		}
+		l.udpConn = conn
+		log.Info().Str("addr", conn.LocalAddr().String()).Msg("Feed UDP listener started")
+	}
+
+	if l.cfg.TCPAddr != "" {
+		ln, err := net.Listen("tcp", l.cfg.TCPAddr)
+		if err != nil {
+			if l.udpConn != nil {
+				_ = l.udpConn.Close()
+			}
+			return err
+		}
+		l.tcpListener = ln
+		log.Info().Str("addr", ln.Addr().String()).Msg("Feed TCP listener started")
+	}
+
+	l.writers.Add(2)
+	go l.writeTracks()
+	go l.writeReports()
+	l.resolvers.Add(1)
+	go l.resolveFixes()
+
+	if l.udpConn != nil {
+		l.readers.Add(1)
+		go l.serveUDP()
+	}
+	if l.tcpListener != nil {
+		l.readers.Add(1)
+		go l.serveTCP()
+	}
+
+	return nil
+}
+
+// Shutdown stops accepting sentences and flushes everything already queued.
+// Connections are closed immediately; ctx bounds the wait for readers and
+// writers to finish.
+func (l *Listener) Shutdown(ctx context.Context) error {
+	if l.udpConn != nil {
+		_ = l.udpConn.Close()
+	}
+	if l.tcpListener != nil {
+		_ = l.tcpListener.Close()
+	}
+
+	// Connections accepted from now on are closed by serveTCP
+	l.mu.Lock()
+	l.closing = true
+	for conn := range l.conns {
+		_ = conn.Close()
+	}
+	l.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		l.readers.Wait()
+		close(l.fixes)
+		l.resolvers.Wait()
+		close(l.tracks)
+		close(l.reports)
+		l.writers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Info().Msg("Feed listener stopped")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// serveUDP reads datagrams, each holding one or more sentences. Sources are
+// tracked by address so multi-fragment AIS messages are reassembled per
+// sender; senders silent for udpSourceIdle are dropped. Once maxUDPSources
+// are tracked, datagrams from new senders are parsed on their own.
+func (l *Listener) serveUDP() {
+	defer l.readers.Done()
+
+	sources := make(map[string]*source)
+	defer func() {
+		for _, src := range sources {
+			src.flushFix()
+		}
+	}()
+
+	buf := make([]byte, maxDatagramSize)
+	lastSweep := time.Now()
+	for {
+		n, addr, err := l.udpConn.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Error().Err(err).Msg("Feed UDP read failed")
+			}
+			return
+		}
+
+		now := time.Now()
+		if now.Sub(lastSweep) >= udpSourceIdle/2 {
+			evictIdleSources(sources, now)
+			lastSweep = now
+		}
+
+		key := addr.String()
+		src, tracked := sources[key]
+		if !tracked {
+			if len(sources) >= maxUDPSources {
+				evictIdleSources(sources, now)
+				lastSweep = now
+			}
+			src = l.newSource(addr, false)
+			if len(sources) < maxUDPSources {
+				sources[key] = src
+				tracked = true
+			} else {
+				log.Debug().Str("remote", key).Msg("Too many feed UDP sources, datagram parsed without sender state")
+			}
+		}
+		src.seen = now
+
+		scanner := bufio.NewScanner(bytes.NewReader(buf[:n]))
+		for scanner.Scan() {
+			src.handleLine(scanner.Text())
+		}
+		if !tracked {
+			src.flushFix()
+		}
+	}
+}
+
+// evictIdleSources drops UDP senders not heard from for udpSourceIdle,
+// queueing the fix each was still assembling
+func evictIdleSources(sources map[string]*source, now time.Time) {
+	for key, src := range sources {
+		if now.Sub(src.seen) >= udpSourceIdle {
+			src.flushFix()
+			delete(sources, key)
+		}
+	}
+}
+
+// serveTCP accepts gateway connections, each streaming newline-terminated sentences
+func (l *Listener) serveTCP() {
+	defer l.readers.Done()
+
+	for {
+		conn, err := l.tcpListener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Error().Err(err).Msg("Feed TCP accept failed")
+			}
+			return
+		}
+
+		l.mu.Lock()
+		if l.closing {
+			l.mu.Unlock()
+			_ = conn.Close()
+			continue
+		}
+		l.conns[conn] = struct{}{}
+		l.mu.Unlock()
+
+		l.readers.Add(1)
+		go l.serveConn(conn)
+	}
+}
+
+func (l *Listener) serveConn(conn net.Conn) {
+	defer l.readers.Done()
+	defer func() {
+		l.mu.Lock()
+		delete(l.conns, conn)
+		l.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	log.Info().Str("remote", conn.RemoteAddr().String()).Msg("Feed TCP connection opened")
+
+	src := l.newSource(conn.RemoteAddr(), true)
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		src.handleLine(scanner.Text())
+	}
+	src.flushFix()
+
+	if err := scanner.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+		log.Warn().Err(err).Str("remote", conn.RemoteAddr().String()).Msg("Feed TCP connection failed")
+		return
+	}
+	log.Info().Str("remote", conn.RemoteAddr().String()).Msg("Feed TCP connection closed")
+}
+
+// enqueueTrack hands a fix to the batch writer. Blocking sources (TCP) wait
+// for room, which stops reading from the socket and pushes back on the
+// sender; datagram sources drop the fix instead.
+func (l *Listener) enqueueTrack(track *domain.GPSTrack, block bool) bool {
+	if block {
+		l.tracks <- track
+		return true
+	}
+
+	select {
+	case l.tracks <- track:
+		return true
+	default:
+		return false
+	}
+}
+
+// enqueueReport hands an AIS message to the batch writer, with the same
+// backpressure rules as enqueueTrack
+func (l *Listener) enqueueReport(report usecase.AISReport, block bool) bool {
+	if block {
+		l.reports <- report
+		return true
+	}
+
+	select {
+	case l.reports <- report:
+		return true
+	default:
+		return false
+	}
+}
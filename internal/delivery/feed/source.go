@@ -0,0 +1,145 @@
+package feed
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/usecase"
+	"github.com/chats/sailing-backend/pkg/ais"
+	"github.com/chats/sailing-backend/pkg/nmea"
+	"github.com/rs/zerolog/log"
+)
+
+// source holds the per-sender parsing state for one gateway
+type source struct {
+	l     *Listener
+	addr  string
+	ip    string
+	block bool
+
+	assembler *nmea.Assembler
+	decoder   *ais.Decoder
+
+	warnedUnmapped bool
+	seen           time.Time // last datagram, for UDP sources
+}
+
+func (l *Listener) newSource(addr net.Addr, block bool) *source {
+	ip := addr.String()
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+
+	return &source{
+		l:         l,
+		addr:      addr.String(),
+		ip:        ip,
+		block:     block,
+		assembler: nmea.NewAssembler(),
+		decoder:   ais.NewDecoder(),
+	}
+}
+
+// handleLine parses one sentence and queues whatever it completes
+func (s *source) handleLine(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+
+	sentence, err := nmea.Parse(line)
+	if err != nil {
+		log.Debug().Err(err).Str("remote", s.addr).Str("sentence", line).Msg("Feed sentence rejected")
+		return
+	}
+
+	if sentence.Start == '!' {
+		msg, err := s.decoder.Decode(sentence)
+		if err != nil {
+			log.Debug().Err(err).Str("remote", s.addr).Str("sentence", line).Msg("Feed AIS sentence rejected")
+			return
+		}
+		if msg == nil {
+			return
+		}
+		if !s.l.enqueueReport(usecase.AISReport{Message: msg, ReceivedAt: time.Now()}, s.block) {
+			log.Warn().Str("remote", s.addr).Msg("Feed queue full, AIS message dropped")
+		}
+		return
+	}
+
+	fix, err := s.assembler.Add(sentence)
+	if err != nil {
+		log.Debug().Err(err).Str("remote", s.addr).Str("sentence", line).Msg("Feed NMEA sentence rejected")
+		return
+	}
+	if fix != nil {
+		s.emitFix(fix)
+	}
+}
+
+// flushFix queues the fix still being assembled, if any
+func (s *source) flushFix() {
+	if fix := s.assembler.Flush(); fix != nil {
+		s.emitFix(fix)
+	}
+}
+
+// pendingFix is a UDP fix waiting for its ship's active voyage
+type pendingFix struct {
+	shipID string
+	addr   string
+	fix    *nmea.Fix
+}
+
+// emitFix attaches a fix to the active voyage of the source's ship and
+// queues it. TCP sources look the voyage up on their own connection, which
+// pushes back on the sender while the database is slow; UDP fixes are
+// handed to resolveFixes so that the shared read loop never waits on it.
+func (s *source) emitFix(fix *nmea.Fix) {
+	shipID, ok := s.l.cfg.Sources[s.ip]
+	if !ok {
+		if !s.warnedUnmapped {
+			log.Warn().Str("remote", s.addr).Msg("Feed source is not mapped to a ship, NMEA fixes ignored")
+			s.warnedUnmapped = true
+		}
+		return
+	}
+
+	if !s.block {
+		select {
+		case s.l.fixes <- pendingFix{shipID: shipID, addr: s.addr, fix: fix}:
+		default:
+			log.Warn().Str("remote", s.addr).Msg("Feed queue full, fix dropped")
+		}
+		return
+	}
+
+	s.l.resolveFix(pendingFix{shipID: shipID, addr: s.addr, fix: fix})
+}
+
+// resolveFixes looks up the voyages of queued UDP fixes
+func (l *Listener) resolveFixes() {
+	defer l.resolvers.Done()
+
+	for pending := range l.fixes {
+		l.resolveFix(pending)
+	}
+}
+
+// resolveFix attaches a fix to its ship's active voyage and queues it,
+// waiting for room in the queue
+func (l *Listener) resolveFix(pending pendingFix) {
+	ctx, cancel := context.WithTimeout(context.Background(), l.cfg.WriteTimeout)
+	defer cancel()
+
+	voyageID, err := l.voyages.VoyageID(ctx, pending.shipID)
+	if err != nil {
+		log.Debug().Err(err).Str("ship_id", pending.shipID).Msg("No active voyage for feed fix")
+		return
+	}
+
+	l.enqueueTrack(usecase.GPSTrackFromFix(voyageID, pending.fix), true)
+}
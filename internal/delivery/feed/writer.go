@@ -0,0 +1,117 @@
+package feed
+
+import (
+	"context"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/chats/sailing-backend/internal/usecase"
+	"github.com/rs/zerolog/log"
+)
+
+// writeTracks drains the track queue, writing fixes in batches of BatchSize
+// or every FlushInterval, whichever comes first
+func (l *Listener) writeTracks() {
+	defer l.writers.Done()
+
+	ticker := time.NewTicker(l.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*domain.GPSTrack, 0, l.cfg.BatchSize)
+	for {
+		select {
+		case track, ok := <-l.tracks:
+			if !ok {
+				l.flushTracks(batch)
+				return
+			}
+			batch = append(batch, track)
+			if len(batch) >= l.cfg.BatchSize {
+				l.flushTracks(batch)
+				batch = make([]*domain.GPSTrack, 0, l.cfg.BatchSize)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				l.flushTracks(batch)
+				batch = make([]*domain.GPSTrack, 0, l.cfg.BatchSize)
+			}
+		}
+	}
+}
+
+// flushTracks writes a batch of fixes. If the batch is rejected, e.g.
+// because one fix falls outside its voyage window, the fixes are retried
+// one by one so a single bad fix does not discard the rest.
+func (l *Listener) flushTracks(batch []*domain.GPSTrack) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.cfg.WriteTimeout)
+	defer cancel()
+
+	err := l.gpsTrackUseCase.CreateGPSTracksBatch(ctx, batch, usecase.IngestOptions{})
+	if err == nil {
+		log.Debug().Int("count", len(batch)).Msg("Feed GPS tracks written")
+		return
+	}
+
+	log.Warn().Err(err).Int("count", len(batch)).Msg("Feed batch rejected, writing fixes individually")
+	for _, track := range batch {
+		if err := l.gpsTrackUseCase.CreateGPSTrack(ctx, track, usecase.IngestOptions{}); err != nil {
+			log.Warn().Err(err).Str("voyage_id", track.VoyageID).Msg("Feed GPS track rejected")
+		}
+	}
+}
+
+// writeReports drains the AIS queue with the same batching rules as writeTracks
+func (l *Listener) writeReports() {
+	defer l.writers.Done()
+
+	ticker := time.NewTicker(l.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]usecase.AISReport, 0, l.cfg.BatchSize)
+	for {
+		select {
+		case report, ok := <-l.reports:
+			if !ok {
+				l.flushReports(batch)
+				return
+			}
+			batch = append(batch, report)
+			if len(batch) >= l.cfg.BatchSize {
+				l.flushReports(batch)
+				batch = make([]usecase.AISReport, 0, l.cfg.BatchSize)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				l.flushReports(batch)
+				batch = make([]usecase.AISReport, 0, l.cfg.BatchSize)
+			}
+		}
+	}
+}
+
+func (l *Listener) flushReports(batch []usecase.AISReport) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.cfg.WriteTimeout)
+	defer cancel()
+
+	result, err := l.aisUseCase.Ingest(ctx, batch)
+	if err != nil {
+		log.Error().Err(err).Int("count", len(batch)).Msg("Feed AIS batch failed")
+		return
+	}
+
+	for i, msgErr := range result.Errors {
+		log.Debug().Err(msgErr).Uint32("mmsi", batch[i].Message.Source()).Msg("Feed AIS message rejected")
+	}
+	log.Debug().
+		Int("tracks", len(result.Tracks)).
+		Int("updated_voyages", len(result.UpdatedVoyages)).
+		Msg("Feed AIS batch written")
+}
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/chats/sailing-backend/internal/usecase"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// APIKeyHandler handles API key management HTTP requests
+type APIKeyHandler struct {
+	apiKeyUseCase *usecase.APIKeyUseCase
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(apiKeyUseCase *usecase.APIKeyUseCase) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyUseCase: apiKeyUseCase,
+	}
+}
+
+// CreateAPIKeyRequest represents the create API key request body
+type CreateAPIKeyRequest struct {
+	Name      string         `json:"name"`
+	Owner     string         `json:"owner,omitempty"`
+	Role      domain.Role    `json:"role"`
+	Scopes    []domain.Scope `json:"scopes,omitempty"` // defaults to the role's scopes
+	ShipID    string         `json:"ship_id,omitempty"`
+	ExpiresAt *time.Time     `json:"expires_at,omitempty"`
+	TenantID  string         `json:"tenant_id,omitempty"` // only used by platform principals
+}
+
+// CreateAPIKey creates a key and returns it in full, which is not shown again
+func (h *APIKeyHandler) CreateAPIKey(c *fiber.Ctx) error {
+	var req CreateAPIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		log.Error().Err(err).Msg("Failed to parse create API key request")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	key := &domain.APIKey{
+		Name:      req.Name,
+		Owner:     req.Owner,
+		Role:      req.Role,
+		Scopes:    req.Scopes,
+		ShipID:    req.ShipID,
+		ExpiresAt: req.ExpiresAt,
+		TenantID:  req.TenantID,
+	}
+	if key.Scopes == nil {
+		key.Scopes = []domain.Scope{}
+	}
+
+	apiKey, err := h.apiKeyUseCase.CreateAPIKey(c.UserContext(), key)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create API key")
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	log.Info().Str("id", key.ID.Hex()).Str("key_id", key.KeyID).Str("role", string(key.Role)).Msg("API key created")
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "API key created successfully",
+		"data":    key,
+		"key":     apiKey,
+	})
+}
+
+// GetAPIKeys lists all keys without their secrets
+func (h *APIKeyHandler) GetAPIKeys(c *fiber.Ctx) error {
+	keys, err := h.apiKeyUseCase.GetAllAPIKeys(c.UserContext())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get API keys")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to retrieve API keys",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data":  keys,
+		"count": len(keys),
+	})
+}
+
+// GetAPIKey retrieves a key by ID
+func (h *APIKeyHandler) GetAPIKey(c *fiber.Ctx) error {
+	key, err := h.apiKeyUseCase.GetAPIKey(c.UserContext(), c.Params("id"))
+	if err != nil {
+		log.Error().Err(err).Str("id", c.Params("id")).Msg("Failed to get API key")
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": key,
+	})
+}
+
+// RotateAPIKey replaces a key's secret and returns the new key, which is not shown again
+func (h *APIKeyHandler) RotateAPIKey(c *fiber.Ctx) error {
+	key, apiKey, err := h.apiKeyUseCase.RotateAPIKey(c.UserContext(), c.Params("id"))
+	if err != nil {
+		log.Error().Err(err).Str("id", c.Params("id")).Msg("Failed to rotate API key")
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	log.Info().Str("id", key.ID.Hex()).Str("key_id", key.KeyID).Msg("API key rotated")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "API key rotated successfully",
+		"data":    key,
+		"key":     apiKey,
+	})
+}
+
+// RevokeAPIKey permanently disables a key
+func (h *APIKeyHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	key, err := h.apiKeyUseCase.RevokeAPIKey(c.UserContext(), c.Params("id"))
+	if err != nil {
+		log.Error().Err(err).Str("id", c.Params("id")).Msg("Failed to revoke API key")
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	log.Info().Str("id", key.ID.Hex()).Str("key_id", key.KeyID).Msg("API key revoked")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "API key revoked successfully",
+		"data":    key,
+	})
+}
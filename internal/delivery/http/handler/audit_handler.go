@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/chats/sailing-backend/internal/usecase"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// AuditHandler serves the audit log
+type AuditHandler struct {
+	auditUseCase *usecase.AuditUseCase
+	pages        PageSizes
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(auditUseCase *usecase.AuditUseCase, pages PageSizes) *AuditHandler {
+	return &AuditHandler{
+		auditUseCase: auditUseCase,
+		pages:        pages.withDefaults(),
+	}
+}
+
+// GetEntries lists audit entries, newest first. Entries can be filtered by
+// ?principal=, ?entity=, ?entity_id=, ?method= and the ?from= / ?to= range.
+func (h *AuditHandler) GetEntries(c *fiber.Ctx) error {
+	tr, err := timeRangeQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	limit, offset := h.pages.query(c)
+
+	filter := domain.AuditFilter{
+		Principal: c.Query("principal"),
+		Entity:    c.Query("entity"),
+		EntityID:  c.Query("entity_id"),
+		Method:    strings.ToUpper(c.Query("method")),
+		TimeRange: tr,
+	}
+
+	entries, err := h.auditUseCase.GetEntries(c.UserContext(), filter, limit, offset)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get audit entries")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to retrieve audit entries",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data":  entries,
+		"count": len(entries),
+	})
+}
@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/chats/sailing-backend/internal/usecase"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// AuthHandler serves the OAuth 2.0 token and revocation endpoints
+type AuthHandler struct {
+	authUseCase *usecase.AuthUseCase
+}
+
+// NewAuthHandler creates a new auth handler
+func NewAuthHandler(authUseCase *usecase.AuthUseCase) *AuthHandler {
+	return &AuthHandler{
+		authUseCase: authUseCase,
+	}
+}
+
+// TokenRequest represents a token request, sent as a form or as JSON
+type TokenRequest struct {
+	GrantType    string `json:"grant_type" form:"grant_type"`
+	Username     string `json:"username" form:"username"`
+	Password     string `json:"password" form:"password"`
+	RefreshToken string `json:"refresh_token" form:"refresh_token"`
+	ClientID     string `json:"client_id" form:"client_id"`
+	ClientSecret string `json:"client_secret" form:"client_secret"`
+}
+
+// RevokeRequest represents a token revocation request
+type RevokeRequest struct {
+	Token string `json:"token" form:"token"`
+}
+
+// Token issues tokens for the password, client_credentials and
+// refresh_token grants
+func (h *AuthHandler) Token(c *fiber.Ctx) error {
+	// Tokens must never be cached (RFC 6749 section 5.1)
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set(fiber.HeaderPragma, "no-cache")
+
+	var req TokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return oauthError(c, fiber.StatusBadRequest, "invalid_request", "invalid request body")
+	}
+
+	var (
+		tokens *usecase.Tokens
+		err    error
+	)
+	switch req.GrantType {
+	case "password":
+		if req.Username == "" || req.Password == "" {
+			return oauthError(c, fiber.StatusBadRequest, "invalid_request", "username and password are required")
+		}
+		tokens, err = h.authUseCase.PasswordGrant(c.UserContext(), req.Username, req.Password)
+	case "client_credentials":
+		// Clients may authenticate with HTTP Basic instead of the body
+		if id, secret, ok := basicAuth(c); ok {
+			req.ClientID, req.ClientSecret = id, secret
+		}
+		if req.ClientID == "" || req.ClientSecret == "" {
+			return oauthError(c, fiber.StatusBadRequest, "invalid_request", "client_id and client_secret are required")
+		}
+		tokens, err = h.authUseCase.ClientCredentialsGrant(c.UserContext(), req.ClientID, req.ClientSecret)
+	case "refresh_token":
+		if req.RefreshToken == "" {
+			return oauthError(c, fiber.StatusBadRequest, "invalid_request", "refresh_token is required")
+		}
+		tokens, err = h.authUseCase.RefreshGrant(c.UserContext(), req.RefreshToken)
+	case "":
+		return oauthError(c, fiber.StatusBadRequest, "invalid_request", "grant_type is required")
+	default:
+		return oauthError(c, fiber.StatusBadRequest, "unsupported_grant_type", "unsupported grant_type")
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidGrant):
+			return oauthError(c, fiber.StatusBadRequest, "invalid_grant", err.Error())
+		case errors.Is(err, domain.ErrInvalidAPIKey):
+			return oauthError(c, fiber.StatusUnauthorized, "invalid_client", "invalid client credentials")
+		}
+		log.Error().Err(err).Str("grant_type", req.GrantType).Msg("Failed to issue tokens")
+		return oauthError(c, fiber.StatusInternalServerError, "server_error", "failed to issue tokens")
+	}
+
+	scopes := make([]string, len(tokens.Scopes))
+	for i, scope := range tokens.Scopes {
+		scopes[i] = string(scope)
+	}
+
+	body := fiber.Map{
+		"access_token": tokens.AccessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(tokens.ExpiresIn.Seconds()),
+		"scope":        strings.Join(scopes, " "),
+	}
+	if tokens.RefreshToken != "" {
+		body["refresh_token"] = tokens.RefreshToken
+	}
+
+	return c.Status(fiber.StatusOK).JSON(body)
+}
+
+// Revoke revokes a refresh token along with every token rotated from the
+// same sign-in. It succeeds for unknown tokens (RFC 7009 section 2.2).
+func (h *AuthHandler) Revoke(c *fiber.Ctx) error {
+	var req RevokeRequest
+	if err := c.BodyParser(&req); err != nil || req.Token == "" {
+		return oauthError(c, fiber.StatusBadRequest, "invalid_request", "token is required")
+	}
+
+	if err := h.authUseCase.RevokeRefreshToken(c.UserContext(), req.Token); err != nil {
+		log.Error().Err(err).Msg("Failed to revoke refresh token")
+		return oauthError(c, fiber.StatusServiceUnavailable, "temporarily_unavailable", "failed to revoke token")
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// oauthError writes an error response in the RFC 6749 section 5.2 format
+func oauthError(c *fiber.Ctx, status int, code, description string) error {
+	if status == fiber.StatusUnauthorized {
+		c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="token"`)
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error":             code,
+		"error_description": description,
+	})
+}
+
+// basicAuth reads client credentials from an HTTP Basic Authorization header
+func basicAuth(c *fiber.Ctx) (string, string, bool) {
+	encoded, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Basic ")
+	if !ok {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", false
+	}
+	id, secret, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return "", "", false
+	}
+
+	// RFC 6749 section 2.3.1 form-encodes both parts
+	id, err = url.QueryUnescape(id)
+	if err != nil {
+		return "", "", false
+	}
+	secret, err = url.QueryUnescape(secret)
+	if err != nil {
+		return "", "", false
+	}
+	return id, secret, true
+}
@@ -29,9 +29,9 @@ func (h *CheckpointHandler) CreateCheckpoint(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.checkpointUseCase.CreateCheckpoint(c.Context(), &checkpoint); err != nil {
+	if err := h.checkpointUseCase.CreateCheckpoint(c.UserContext(), &checkpoint, ingestOptions(c)); err != nil {
 		log.Error().Err(err).Msg("Failed to create checkpoint")
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
@@ -57,9 +57,9 @@ func (h *CheckpointHandler) CreateCheckpointsBatch(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.checkpointUseCase.CreateCheckpointsBatch(c.Context(), checkpoints); err != nil {
+	if err := h.checkpointUseCase.CreateCheckpointsBatch(c.UserContext(), checkpoints, ingestOptions(c)); err != nil {
 		log.Error().Err(err).Msg("Failed to create checkpoints batch")
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
@@ -0,0 +1,287 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/gofiber/fiber/v2"
+)
+
+// CSV record types accepted by the import and export endpoints
+const (
+	csvTypeGPSTracks   = "gps_tracks"
+	csvTypeCheckpoints = "checkpoints"
+)
+
+// Column headers written by the CSV export; they are also the import defaults
+var (
+	gpsTrackCSVHeader   = []string{"voyage_id", "timestamp", "latitude", "longitude", "speed", "heading", "altitude"}
+	checkpointCSVHeader = []string{"voyage_id", "timestamp", "latitude", "longitude", "description", "temperature", "wind_speed", "wind_dir", "wave_height", "condition"}
+)
+
+// dmPattern matches degrees-minutes coordinates such as "13 45.378 N",
+// "13°45.378'N" or "-100 30.1"
+var dmPattern = regexp.MustCompile(`^\s*(-?\d{1,3})(?:°|\s)\s*(\d{1,2}(?:\.\d+)?)['′]?\s*([NSEWnsew])?\s*$`)
+
+// csvMapping describes how the columns of an uploaded CSV map onto records.
+// Columns are referenced by header name.
+type csvMapping struct {
+	Type            string
+	Delimiter       rune
+	TimestampCol    string
+	TimestampFormat string // "rfc3339", "unix", "unix_ms" or a Go time layout
+	Location        *time.Location
+	LatitudeCol     string
+	LongitudeCol    string
+	CoordFormat     string // "decimal" or "dm"
+
+	SpeedCol    string
+	HeadingCol  string
+	AltitudeCol string
+
+	DescriptionCol string
+	TemperatureCol string
+	WindSpeedCol   string
+	WindDirCol     string
+	WaveHeightCol  string
+	ConditionCol   string
+
+	index map[string]int
+}
+
+// csvMappingFromQuery reads the column mapping from query parameters
+func csvMappingFromQuery(c *fiber.Ctx) (*csvMapping, error) {
+	m := &csvMapping{
+		Type:            c.Query("type", csvTypeGPSTracks),
+		TimestampCol:    c.Query("timestamp_col", "timestamp"),
+		TimestampFormat: c.Query("timestamp_format", "rfc3339"),
+		LatitudeCol:     c.Query("lat_col", "latitude"),
+		LongitudeCol:    c.Query("lon_col", "longitude"),
+		CoordFormat:     c.Query("coord_format", "decimal"),
+		SpeedCol:        c.Query("speed_col", "speed"),
+		HeadingCol:      c.Query("heading_col", "heading"),
+		AltitudeCol:     c.Query("altitude_col", "altitude"),
+		DescriptionCol:  c.Query("description_col", "description"),
+		TemperatureCol:  c.Query("temperature_col", "temperature"),
+		WindSpeedCol:    c.Query("wind_speed_col", "wind_speed"),
+		WindDirCol:      c.Query("wind_dir_col", "wind_dir"),
+		WaveHeightCol:   c.Query("wave_height_col", "wave_height"),
+		ConditionCol:    c.Query("condition_col", "condition"),
+		Location:        time.UTC,
+		Delimiter:       ',',
+	}
+
+	if m.Type != csvTypeGPSTracks && m.Type != csvTypeCheckpoints {
+		return nil, fmt.Errorf("type must be %q or %q", csvTypeGPSTracks, csvTypeCheckpoints)
+	}
+	if m.CoordFormat != "decimal" && m.CoordFormat != "dm" {
+		return nil, errors.New(`coord_format must be "decimal" or "dm"`)
+	}
+	if tz := c.Query("timezone"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("unknown timezone %q", tz)
+		}
+		m.Location = loc
+	}
+	if d := c.Query("delimiter"); d != "" {
+		if d == `\t` {
+			d = "\t"
+		}
+		if len([]rune(d)) != 1 {
+			return nil, errors.New("delimiter must be a single character")
+		}
+		m.Delimiter = []rune(d)[0]
+	}
+
+	return m, nil
+}
+
+// bindHeader resolves the mapped column names against the CSV header row.
+// Timestamp, latitude and longitude are required; other columns are optional.
+func (m *csvMapping) bindHeader(header []string) error {
+	m.index = make(map[string]int, len(header))
+	for i, name := range header {
+		m.index[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+
+	for _, col := range []string{m.TimestampCol, m.LatitudeCol, m.LongitudeCol} {
+		if _, ok := m.index[col]; !ok {
+			return fmt.Errorf("missing required column %q", col)
+		}
+	}
+	return nil
+}
+
+// value returns the trimmed value of a mapped column, or "" if it is absent
+func (m *csvMapping) value(record []string, col string) string {
+	i, ok := m.index[col]
+	if !ok || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+func (m *csvMapping) gpsTrack(voyageID string, record []string) (*domain.GPSTrack, error) {
+	ts, loc, err := m.timeAndLocation(record)
+	if err != nil {
+		return nil, err
+	}
+
+	track := &domain.GPSTrack{VoyageID: voyageID, Location: loc, Timestamp: ts}
+	if track.Speed, err = m.optionalFloat(record, m.SpeedCol); err != nil {
+		return nil, err
+	}
+	if track.Heading, err = m.optionalFloat(record, m.HeadingCol); err != nil {
+		return nil, err
+	}
+	if track.Altitude, err = m.optionalFloat(record, m.AltitudeCol); err != nil {
+		return nil, err
+	}
+	return track, nil
+}
+
+func (m *csvMapping) checkpoint(voyageID string, record []string) (*domain.Checkpoint, error) {
+	ts, loc, err := m.timeAndLocation(record)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoint := &domain.Checkpoint{
+		VoyageID:    voyageID,
+		Location:    loc,
+		Timestamp:   ts,
+		Description: m.value(record, m.DescriptionCol),
+	}
+
+	var weather domain.WeatherInfo
+	if weather.Temperature, err = m.optionalFloat(record, m.TemperatureCol); err != nil {
+		return nil, err
+	}
+	if weather.WindSpeed, err = m.optionalFloat(record, m.WindSpeedCol); err != nil {
+		return nil, err
+	}
+	if weather.WindDir, err = m.optionalFloat(record, m.WindDirCol); err != nil {
+		return nil, err
+	}
+	if weather.WaveHeight, err = m.optionalFloat(record, m.WaveHeightCol); err != nil {
+		return nil, err
+	}
+	weather.Condition = m.value(record, m.ConditionCol)
+	if weather != (domain.WeatherInfo{}) {
+		checkpoint.Weather = &weather
+	}
+
+	return checkpoint, nil
+}
+
+func (m *csvMapping) timeAndLocation(record []string) (time.Time, domain.Location, error) {
+	ts, err := m.parseTimestamp(m.value(record, m.TimestampCol))
+	if err != nil {
+		return time.Time{}, domain.Location{}, err
+	}
+	lat, err := m.parseCoordinate(m.value(record, m.LatitudeCol), 90)
+	if err != nil {
+		return time.Time{}, domain.Location{}, fmt.Errorf("latitude: %w", err)
+	}
+	lon, err := m.parseCoordinate(m.value(record, m.LongitudeCol), 180)
+	if err != nil {
+		return time.Time{}, domain.Location{}, fmt.Errorf("longitude: %w", err)
+	}
+	return ts, domain.Location{Latitude: lat, Longitude: lon}, nil
+}
+
+func (m *csvMapping) parseTimestamp(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, errors.New("timestamp is empty")
+	}
+
+	switch m.TimestampFormat {
+	case "rfc3339":
+		return time.Parse(time.RFC3339, v)
+	case "unix", "unix_ms":
+		n, err := parseFinite(v)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid timestamp %q", v)
+		}
+		if m.TimestampFormat == "unix_ms" {
+			return time.UnixMilli(int64(n)).UTC(), nil
+		}
+		sec, frac := math.Modf(n)
+		return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+	default:
+		ts, err := time.ParseInLocation(m.TimestampFormat, v, m.Location)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid timestamp %q for format %q", v, m.TimestampFormat)
+		}
+		return ts, nil
+	}
+}
+
+// parseCoordinate parses a decimal or degrees-minutes coordinate
+func (m *csvMapping) parseCoordinate(v string, limit float64) (float64, error) {
+	var value float64
+	if m.CoordFormat == "dm" {
+		match := dmPattern.FindStringSubmatch(v)
+		if match == nil {
+			return 0, fmt.Errorf("invalid degrees-minutes value %q", v)
+		}
+		degrees, _ := strconv.ParseFloat(match[1], 64)
+		minutes, _ := strconv.ParseFloat(match[2], 64)
+		if minutes >= 60 {
+			return 0, fmt.Errorf("invalid minutes in %q", v)
+		}
+
+		negative := strings.HasPrefix(match[1], "-")
+		value = math.Abs(degrees) + minutes/60
+		switch strings.ToUpper(match[3]) {
+		case "S", "W":
+			negative = true
+		}
+		if negative {
+			value = -value
+		}
+	} else {
+		f, err := parseFinite(v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid decimal value %q", v)
+		}
+		value = f
+	}
+
+	if value < -limit || value > limit {
+		return 0, fmt.Errorf("value %q out of range", v)
+	}
+	return value, nil
+}
+
+func (m *csvMapping) optionalFloat(record []string, col string) (float64, error) {
+	v := m.value(record, col)
+	if v == "" {
+		return 0, nil
+	}
+	f, err := parseFinite(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid number %q", col, v)
+	}
+	return f, nil
+}
+
+// parseFinite parses a number, refusing the NaN and infinity spellings
+// strconv.ParseFloat accepts
+func parseFinite(v string) (float64, error) {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, errors.New("not a finite number")
+	}
+	return f, nil
+}
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testMapping returns the default GPS track mapping bound to header
+func testMapping(t *testing.T, header ...string) *csvMapping {
+	t.Helper()
+
+	m := &csvMapping{
+		Type:            csvTypeGPSTracks,
+		TimestampCol:    "timestamp",
+		TimestampFormat: "rfc3339",
+		Location:        time.UTC,
+		LatitudeCol:     "latitude",
+		LongitudeCol:    "longitude",
+		CoordFormat:     "decimal",
+		SpeedCol:        "speed",
+		HeadingCol:      "heading",
+		AltitudeCol:     "altitude",
+	}
+	if err := m.bindHeader(header); err != nil {
+		t.Fatalf("bindHeader: %v", err)
+	}
+	return m
+}
+
+func TestParseCoordinate(t *testing.T) {
+	tests := []struct {
+		format string
+		value  string
+		limit  float64
+		want   float64
+		err    string // substring of the error; none if empty
+	}{
+		{"decimal", "13.7563", 90, 13.7563, ""},
+		{"decimal", "-100.5", 180, -100.5, ""},
+		{"decimal", "90", 90, 90, ""},
+		{"decimal", "90.0001", 90, 0, "out of range"},
+		{"decimal", "NaN", 90, 0, "invalid decimal value"},
+		{"decimal", "nan", 90, 0, "invalid decimal value"},
+		{"decimal", "Inf", 180, 0, "invalid decimal value"},
+		{"decimal", "-Infinity", 180, 0, "invalid decimal value"},
+		{"decimal", "1e400", 180, 0, "invalid decimal value"},
+		{"decimal", "", 90, 0, "invalid decimal value"},
+		{"dm", "13 45.378 N", 90, 13 + 45.378/60, ""},
+		{"dm", "13°45.378'S", 90, -(13 + 45.378/60), ""},
+		{"dm", "-100 30.1", 180, -(100 + 30.1/60), ""},
+		{"dm", "100 30 W", 180, -100.5, ""},
+		{"dm", "13 60 N", 90, 0, "invalid minutes"},
+		{"dm", "95 10 N", 90, 0, "out of range"},
+		{"dm", "13.5", 90, 0, "invalid degrees-minutes value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format+" "+tt.value, func(t *testing.T) {
+			m := &csvMapping{CoordFormat: tt.format}
+			got, err := m.parseCoordinate(tt.value, tt.limit)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("parseCoordinate = %v, %v, want an error containing %q", got, err, tt.err)
+				}
+				return
+			}
+			if err != nil || math.Abs(got-tt.want) > 1e-9 {
+				t.Fatalf("parseCoordinate = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	want := time.Date(2024, 6, 1, 8, 30, 0, 0, time.UTC)
+	bangkok := time.FixedZone("ICT", 7*60*60)
+
+	tests := []struct {
+		format string
+		loc    *time.Location
+		value  string
+		want   time.Time
+		err    bool
+	}{
+		{"rfc3339", time.UTC, "2024-06-01T08:30:00Z", want, false},
+		{"rfc3339", time.UTC, "2024-06-01 08:30:00", time.Time{}, true},
+		{"unix", time.UTC, "1717230600", want, false},
+		{"unix", time.UTC, "1717230600.5", want.Add(500 * time.Millisecond), false},
+		{"unix_ms", time.UTC, "1717230600000", want, false},
+		{"unix", time.UTC, "NaN", time.Time{}, true},
+		{"unix_ms", time.UTC, "Inf", time.Time{}, true},
+		{"2006-01-02 15:04", bangkok, "2024-06-01 15:30", want, false},
+		{"2006-01-02 15:04", time.UTC, "01/06/2024", time.Time{}, true},
+		{"rfc3339", time.UTC, "", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format+" "+tt.value, func(t *testing.T) {
+			m := &csvMapping{TimestampFormat: tt.format, Location: tt.loc}
+			got, err := m.parseTimestamp(tt.value)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("parseTimestamp = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil || !got.Equal(tt.want) {
+				t.Fatalf("parseTimestamp = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVMappingGPSTrack(t *testing.T) {
+	m := testMapping(t, "\ufefftimestamp", " latitude ", "longitude", "speed", "heading")
+
+	tests := []struct {
+		name   string
+		record []string
+		err    string
+	}{
+		{"complete", []string{"2024-06-01T08:30:00Z", "13.5", "100.25", "6.5", "270"}, ""},
+		{"optional columns empty", []string{"2024-06-01T08:30:00Z", "13.5", "100.25", "", ""}, ""},
+		{"short record", []string{"2024-06-01T08:30:00Z", "13.5", "100.25"}, ""},
+		{"NaN speed", []string{"2024-06-01T08:30:00Z", "13.5", "100.25", "NaN", "270"}, `speed: invalid number "NaN"`},
+		{"infinite heading", []string{"2024-06-01T08:30:00Z", "13.5", "100.25", "6.5", "+Inf"}, `heading: invalid number "+Inf"`},
+		{"NaN latitude", []string{"2024-06-01T08:30:00Z", "NaN", "100.25", "6.5", "270"}, "latitude: invalid decimal value"},
+		{"longitude out of range", []string{"2024-06-01T08:30:00Z", "13.5", "181", "6.5", "270"}, "longitude: value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			track, err := m.gpsTrack("V1", tt.record)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("gpsTrack = %+v, %v, want an error containing %q", track, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("gpsTrack: %v", err)
+			}
+			if track.VoyageID != "V1" || track.Location.Latitude != 13.5 || track.Location.Longitude != 100.25 {
+				t.Fatalf("gpsTrack = %+v", track)
+			}
+		})
+	}
+}
+
+func TestCSVMappingBindHeader(t *testing.T) {
+	m := &csvMapping{TimestampCol: "time", LatitudeCol: "lat", LongitudeCol: "lon"}
+	if err := m.bindHeader([]string{"time", "lat"}); err == nil || !strings.Contains(err.Error(), `"lon"`) {
+		t.Fatalf("bindHeader = %v, want a missing lon column error", err)
+	}
+	if err := m.bindHeader([]string{"lon", "time", "lat", "extra"}); err != nil {
+		t.Fatalf("bindHeader: %v", err)
+	}
+}
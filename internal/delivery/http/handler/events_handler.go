@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/chats/sailing-backend/internal/events"
+	"github.com/chats/sailing-backend/internal/usecase"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// sseHeartbeatInterval keeps idle connections open through proxies
+	sseHeartbeatInterval = 15 * time.Second
+	// sseRetry is the reconnection delay suggested to clients, in milliseconds
+	sseRetry = 3000
+	// sseReplayFlushEvery bounds how many replayed events are buffered before flushing
+	sseReplayFlushEvery = 100
+)
+
+// errReplayDone stops replay once the client has gone away
+var errReplayDone = errors.New("replay aborted")
+
+// EventsHandler serves voyage events as Server-Sent Events
+type EventsHandler struct {
+	broker       *events.Broker
+	eventUseCase *usecase.EventUseCase
+	done         chan struct{}
+}
+
+// NewEventsHandler creates a new events handler
+func NewEventsHandler(broker *events.Broker, eventUseCase *usecase.EventUseCase) *EventsHandler {
+	return &EventsHandler{
+		broker:       broker,
+		eventUseCase: eventUseCase,
+		done:         make(chan struct{}),
+	}
+}
+
+// Shutdown ends all open streams so the server can stop
+func (h *EventsHandler) Shutdown() {
+	close(h.done)
+}
+
+// Stream handles GET /events. Clients reconnecting with Last-Event-ID first
+// receive every logged event after that ID, then live events.
+func (h *EventsHandler) Stream(c *fiber.Ctx) error {
+	filter, err := eventFilterQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// EventSource polyfills that cannot set headers pass the ID in the query
+	lastEventID := c.Get("Last-Event-ID", c.Query("last_event_id"))
+	var after int64
+	resume := lastEventID != ""
+	if resume {
+		after, err = strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || after < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Last-Event-ID must be a non-negative event sequence number",
+			})
+		}
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	// The writer runs after the handler returns, so keep the request's
+	// principal for tenant scoping
+	base := c.UserContext()
+	remote := c.IP()
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// Subscribe before replaying so nothing written in between is missed
+		sub := h.broker.Subscribe(filter)
+		defer h.broker.Unsubscribe(sub)
+
+		fmt.Fprintf(w, "retry: %d\n\n", sseRetry)
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		var replayed int64
+		if resume {
+			ctx, cancel := context.WithCancel(base)
+			defer cancel()
+
+			n := 0
+			err := h.eventUseCase.ReplayEvents(ctx, after, func(e *domain.Event) error {
+				replayed = e.Seq
+				if !filter.Matches(e) {
+					return nil
+				}
+				if err := writeSSEEvent(w, e); err != nil {
+					return err
+				}
+				n++
+				if n%sseReplayFlushEvery == 0 {
+					if err := w.Flush(); err != nil {
+						return errReplayDone
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				if !errors.Is(err, errReplayDone) {
+					log.Error().Err(err).Str("remote", remote).Msg("Failed to replay events")
+				}
+				return
+			}
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+
+		heartbeat := time.NewTicker(sseHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case e, ok := <-sub.Events():
+				if !ok {
+					// Lagging clients reconnect and resume from their last ID
+					return
+				}
+				// Skip live events already sent during replay
+				if e.Seq != 0 && e.Seq <= replayed {
+					continue
+				}
+				if err := writeSSEEvent(w, e); err != nil {
+					return
+				}
+				if err := w.Flush(); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+				if err := w.Flush(); err != nil {
+					return
+				}
+			case <-h.done:
+				return
+			}
+		}
+	})
+
+	return nil
+}
+
+// writeSSEEvent writes e as an SSE message identified by its sequence number
+func writeSSEEvent(w *bufio.Writer, e *domain.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	if e.Seq != 0 {
+		fmt.Fprintf(w, "id: %d\n", e.Seq)
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+	return err
+}
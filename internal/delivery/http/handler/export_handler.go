@@ -0,0 +1,341 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/chats/sailing-backend/internal/usecase"
+	"github.com/chats/sailing-backend/pkg/geojson"
+	"github.com/chats/sailing-backend/pkg/gpx"
+	"github.com/chats/sailing-backend/pkg/kml"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// ExportHandler streams voyage data in interchange formats
+type ExportHandler struct {
+	voyageUseCase *usecase.VoyageUseCase
+	timeout       time.Duration
+}
+
+// NewExportHandler creates a new export handler. A single export may stream
+// for up to timeout.
+func NewExportHandler(voyageUseCase *usecase.VoyageUseCase, timeout time.Duration) *ExportHandler {
+	if timeout <= 0 {
+		timeout = 10 * time.Minute
+	}
+	return &ExportHandler{
+		voyageUseCase: voyageUseCase,
+		timeout:       timeout,
+	}
+}
+
+// ExportGPX streams a voyage's checkpoints as waypoints and its GPS tracks
+// as a single track in GPX 1.1
+func (h *ExportHandler) ExportGPX(c *fiber.Ctx) error {
+	voyage, err := h.voyageUseCase.ResolveVoyage(c.UserContext(), c.Params("id"))
+	if err != nil {
+		log.Error().Err(err).Str("id", c.Params("id")).Msg("Failed to get voyage")
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	h.streamExport(c, fmt.Sprintf("voyage-%s.gpx", voyage.VoyageID), "application/gpx+xml", func(ctx context.Context, w *bufio.Writer) error {
+		return h.writeGPX(ctx, w, voyage)
+	})
+	return nil
+}
+
+// ExportGeoJSON streams a voyage as a GeoJSON FeatureCollection: a LineString
+// of its GPS tracks carrying the voyage metadata, and a Point per checkpoint
+func (h *ExportHandler) ExportGeoJSON(c *fiber.Ctx) error {
+	voyage, err := h.voyageUseCase.ResolveVoyage(c.UserContext(), c.Params("id"))
+	if err != nil {
+		log.Error().Err(err).Str("id", c.Params("id")).Msg("Failed to get voyage")
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	h.streamExport(c, fmt.Sprintf("voyage-%s.geojson", voyage.VoyageID), "application/geo+json", func(ctx context.Context, w *bufio.Writer) error {
+		gw, err := geojson.NewWriter(w)
+		if err != nil {
+			return err
+		}
+		if err := h.writeGeoJSONVoyage(ctx, gw, voyage, domain.TimeRange{}); err != nil {
+			return err
+		}
+		return gw.Close()
+	})
+	return nil
+}
+
+// ExportFleetGeoJSON streams every voyage under way within the optional
+// from/to query range as one GeoJSON FeatureCollection
+func (h *ExportHandler) ExportFleetGeoJSON(c *fiber.Ctx) error {
+	tr, err := timeRangeQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	h.streamExport(c, "fleet.geojson", "application/geo+json", func(ctx context.Context, w *bufio.Writer) error {
+		gw, err := geojson.NewWriter(w)
+		if err != nil {
+			return err
+		}
+		err = h.voyageUseCase.StreamVoyages(ctx, tr, func(voyage *domain.Voyage) error {
+			return h.writeGeoJSONVoyage(ctx, gw, voyage, tr)
+		})
+		if err != nil {
+			return err
+		}
+		return gw.Close()
+	})
+	return nil
+}
+
+// ExportKML streams a voyage as a KML document for Google Earth
+func (h *ExportHandler) ExportKML(c *fiber.Ctx) error {
+	voyage, err := h.voyageUseCase.ResolveVoyage(c.UserContext(), c.Params("id"))
+	if err != nil {
+		log.Error().Err(err).Str("id", c.Params("id")).Msg("Failed to get voyage")
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	h.streamExport(c, fmt.Sprintf("voyage-%s.kml", voyage.VoyageID), "application/vnd.google-earth.kml+xml", func(ctx context.Context, w *bufio.Writer) error {
+		return h.writeKML(ctx, w, voyage)
+	})
+	return nil
+}
+
+// ExportCSV streams a voyage's GPS tracks, or its checkpoints with
+// ?type=checkpoints, as CSV using the same columns the CSV import expects
+func (h *ExportHandler) ExportCSV(c *fiber.Ctx) error {
+	recordType := c.Query("type", csvTypeGPSTracks)
+	if recordType != csvTypeGPSTracks && recordType != csvTypeCheckpoints {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("type must be %q or %q", csvTypeGPSTracks, csvTypeCheckpoints),
+		})
+	}
+
+	voyage, err := h.voyageUseCase.ResolveVoyage(c.UserContext(), c.Params("id"))
+	if err != nil {
+		log.Error().Err(err).Str("id", c.Params("id")).Msg("Failed to get voyage")
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	filename := fmt.Sprintf("voyage-%s-%s.csv", voyage.VoyageID, recordType)
+	h.streamExport(c, filename, "text/csv; charset=utf-8", func(ctx context.Context, w *bufio.Writer) error {
+		var err error
+		cw := csv.NewWriter(w)
+		if recordType == csvTypeCheckpoints {
+			if err := cw.Write(checkpointCSVHeader); err != nil {
+				return err
+			}
+			err = h.voyageUseCase.StreamCheckpoints(ctx, voyage.VoyageID, domain.TimeRange{}, func(cp *domain.Checkpoint) error {
+				weather := cp.Weather
+				if weather == nil {
+					weather = &domain.WeatherInfo{}
+				}
+				return cw.Write([]string{
+					cp.VoyageID,
+					cp.Timestamp.UTC().Format(time.RFC3339Nano),
+					formatCSVFloat(cp.Location.Latitude),
+					formatCSVFloat(cp.Location.Longitude),
+					cp.Description,
+					formatCSVFloat(weather.Temperature),
+					formatCSVFloat(weather.WindSpeed),
+					formatCSVFloat(weather.WindDir),
+					formatCSVFloat(weather.WaveHeight),
+					weather.Condition,
+				})
+			})
+		} else {
+			if err := cw.Write(gpsTrackCSVHeader); err != nil {
+				return err
+			}
+			err = h.voyageUseCase.StreamGPSTracks(ctx, voyage.VoyageID, domain.TimeRange{}, func(t *domain.GPSTrack) error {
+				return cw.Write([]string{
+					t.VoyageID,
+					t.Timestamp.UTC().Format(time.RFC3339Nano),
+					formatCSVFloat(t.Location.Latitude),
+					formatCSVFloat(t.Location.Longitude),
+					formatCSVFloat(t.Speed),
+					formatCSVFloat(t.Heading),
+					formatCSVFloat(t.Altitude),
+				})
+			})
+		}
+		if err != nil {
+			return err
+		}
+		cw.Flush()
+		return cw.Error()
+	})
+	return nil
+}
+
+func formatCSVFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// streamExport sends an attachment whose body is produced by write after the
+// handler returns. Once streaming has started the status can no longer
+// change, so failures are only logged and the document is left truncated.
+func (h *ExportHandler) streamExport(c *fiber.Ctx, filename, contentType string, write func(ctx context.Context, w *bufio.Writer) error) {
+	c.Attachment(filename)
+	c.Set(fiber.HeaderContentType, contentType)
+
+	// The writer runs after the handler returns, so keep the request's
+	// principal for tenant scoping
+	base := c.UserContext()
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		ctx, cancel := context.WithTimeout(base, h.timeout)
+		defer cancel()
+
+		if err := write(ctx, w); err != nil {
+			log.Error().Err(err).Str("file", filename).Msg("Export aborted")
+			return
+		}
+		if err := w.Flush(); err != nil {
+			log.Error().Err(err).Str("file", filename).Msg("Export aborted")
+		}
+	})
+}
+
+func (h *ExportHandler) writeGPX(ctx context.Context, w *bufio.Writer, voyage *domain.Voyage) error {
+	gw, err := gpx.NewWriter(w, "Sailing Backend API", gpx.Metadata{
+		Name:        fmt.Sprintf("%s %s", voyage.ShipName, voyage.VoyageID),
+		Description: voyageDescription(voyage),
+		Time:        voyage.DepartureTime,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = h.voyageUseCase.StreamCheckpoints(ctx, voyage.VoyageID, domain.TimeRange{}, func(cp *domain.Checkpoint) error {
+		return gw.WriteWaypoint(gpx.Waypoint{
+			Latitude:    cp.Location.Latitude,
+			Longitude:   cp.Location.Longitude,
+			Time:        cp.Timestamp,
+			Name:        cp.Timestamp.UTC().Format(time.RFC3339),
+			Description: cp.Description,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := gw.BeginTrack(voyage.ShipName); err != nil {
+		return err
+	}
+	err = h.voyageUseCase.StreamGPSTracks(ctx, voyage.VoyageID, domain.TimeRange{}, func(t *domain.GPSTrack) error {
+		speed, heading := t.Speed, t.Heading
+		pt := gpx.TrackPoint{
+			Latitude:  t.Location.Latitude,
+			Longitude: t.Location.Longitude,
+			Time:      t.Timestamp,
+			Speed:     &speed,
+			Course:    &heading,
+		}
+		if t.Altitude != 0 {
+			altitude := t.Altitude
+			pt.Elevation = &altitude
+		}
+		return gw.WriteTrackPoint(pt)
+	})
+	if err != nil {
+		return err
+	}
+
+	return gw.Close()
+}
+
+// checkpointProperties are the GeoJSON properties of a checkpoint Point
+type checkpointProperties struct {
+	VoyageID    string              `json:"voyage_id"`
+	Timestamp   time.Time           `json:"timestamp"`
+	Description string              `json:"description,omitempty"`
+	Weather     *domain.WeatherInfo `json:"weather,omitempty"`
+}
+
+// writeGeoJSONVoyage writes a voyage's track and checkpoints within tr as features
+func (h *ExportHandler) writeGeoJSONVoyage(ctx context.Context, gw *geojson.Writer, voyage *domain.Voyage, tr domain.TimeRange) error {
+	if err := gw.BeginLineString("voyage-"+voyage.VoyageID, voyage); err != nil {
+		return err
+	}
+	err := h.voyageUseCase.StreamGPSTracks(ctx, voyage.VoyageID, tr, func(t *domain.GPSTrack) error {
+		return gw.AddPosition(t.Location.Longitude, t.Location.Latitude)
+	})
+	if err != nil {
+		return err
+	}
+	if err := gw.EndLineString(); err != nil {
+		return err
+	}
+
+	return h.voyageUseCase.StreamCheckpoints(ctx, voyage.VoyageID, tr, func(cp *domain.Checkpoint) error {
+		return gw.WritePoint("checkpoint-"+cp.ID.Hex(), cp.Location.Longitude, cp.Location.Latitude, checkpointProperties{
+			VoyageID:    cp.VoyageID,
+			Timestamp:   cp.Timestamp,
+			Description: cp.Description,
+			Weather:     cp.Weather,
+		})
+	})
+}
+
+func (h *ExportHandler) writeKML(ctx context.Context, w *bufio.Writer, voyage *domain.Voyage) error {
+	kw, err := kml.NewWriter(w, fmt.Sprintf("%s %s", voyage.ShipName, voyage.VoyageID), voyageDescription(voyage))
+	if err != nil {
+		return err
+	}
+
+	err = h.voyageUseCase.StreamCheckpoints(ctx, voyage.VoyageID, domain.TimeRange{}, func(cp *domain.Checkpoint) error {
+		return kw.WritePlacemark(kml.Placemark{
+			Name:        cp.Timestamp.UTC().Format(time.RFC3339),
+			Description: cp.Description,
+			Time:        cp.Timestamp,
+			Latitude:    cp.Location.Latitude,
+			Longitude:   cp.Location.Longitude,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	var arrival time.Time
+	if voyage.ArrivalTime != nil {
+		arrival = *voyage.ArrivalTime
+	}
+	if err := kw.BeginLineString(voyage.ShipName, voyageDescription(voyage), voyage.DepartureTime, arrival); err != nil {
+		return err
+	}
+	err = h.voyageUseCase.StreamGPSTracks(ctx, voyage.VoyageID, domain.TimeRange{}, func(t *domain.GPSTrack) error {
+		return kw.AddCoordinate(t.Location.Longitude, t.Location.Latitude)
+	})
+	if err != nil {
+		return err
+	}
+
+	return kw.Close()
+}
+
+// voyageDescription summarises a voyage's route for export metadata
+func voyageDescription(voyage *domain.Voyage) string {
+	if voyage.ArrivalPort == "" {
+		return fmt.Sprintf("Departed %s", voyage.DeparturePort)
+	}
+	return fmt.Sprintf("%s to %s", voyage.DeparturePort, voyage.ArrivalPort)
+}
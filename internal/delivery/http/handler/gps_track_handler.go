@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"errors"
+
 	"github.com/chats/sailing-backend/internal/domain"
 	"github.com/chats/sailing-backend/internal/usecase"
 	"github.com/gofiber/fiber/v2"
@@ -9,17 +11,17 @@ import (
 
 // GPSTrackHandler handles GPS track-related HTTP requests
 type GPSTrackHandler struct {
-	gpsTrackUseCase *usecase.GPSTrackUseCase
+	ingestQueue *usecase.GPSIngestQueue
 }
 
 // NewGPSTrackHandler creates a new GPS track handler
-func NewGPSTrackHandler(gpsTrackUseCase *usecase.GPSTrackUseCase) *GPSTrackHandler {
+func NewGPSTrackHandler(ingestQueue *usecase.GPSIngestQueue) *GPSTrackHandler {
 	return &GPSTrackHandler{
-		gpsTrackUseCase: gpsTrackUseCase,
+		ingestQueue: ingestQueue,
 	}
 }
 
-// CreateGPSTrack creates a single GPS track
+// CreateGPSTrack validates a single GPS track and queues it for writing
 func (h *GPSTrackHandler) CreateGPSTrack(c *fiber.Ctx) error {
 	var track domain.GPSTrack
 	if err := c.BodyParser(&track); err != nil {
@@ -29,25 +31,22 @@ func (h *GPSTrackHandler) CreateGPSTrack(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.gpsTrackUseCase.CreateGPSTrack(c.Context(), &track); err != nil {
-		log.Error().Err(err).Msg("Failed to create GPS track")
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+	if err := h.ingestQueue.Enqueue(c.UserContext(), []*domain.GPSTrack{&track}, ingestOptions(c)); err != nil {
+		return h.enqueueError(c, err)
 	}
 
-	log.Info().
+	log.Debug().
 		Str("voyage_id", track.VoyageID).
 		Str("track_id", track.ID.Hex()).
-		Msg("GPS track created")
+		Msg("GPS track accepted")
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"message": "GPS track created successfully",
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "GPS track accepted",
 		"data":    track,
 	})
 }
 
-// CreateGPSTracksBatch creates multiple GPS tracks
+// CreateGPSTracksBatch validates multiple GPS tracks and queues them for writing
 func (h *GPSTrackHandler) CreateGPSTracksBatch(c *fiber.Ctx) error {
 	var tracks []*domain.GPSTrack
 	if err := c.BodyParser(&tracks); err != nil {
@@ -57,18 +56,30 @@ func (h *GPSTrackHandler) CreateGPSTracksBatch(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.gpsTrackUseCase.CreateGPSTracksBatch(c.Context(), tracks); err != nil {
-		log.Error().Err(err).Msg("Failed to create GPS tracks batch")
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+	if err := h.ingestQueue.Enqueue(c.UserContext(), tracks, ingestOptions(c)); err != nil {
+		return h.enqueueError(c, err)
 	}
 
-	log.Info().Int("count", len(tracks)).Msg("GPS tracks batch created")
+	log.Debug().Int("count", len(tracks)).Msg("GPS tracks batch accepted")
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"message": "GPS tracks created successfully",
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "GPS tracks accepted",
 		"data":    tracks,
 		"count":   len(tracks),
 	})
 }
+
+// enqueueError responds to a refused enqueue, asking clients to back off
+// when the queue is full
+func (h *GPSTrackHandler) enqueueError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, domain.ErrIngestQueueFull) || errors.Is(err, domain.ErrIngestQueueClosed) {
+		log.Warn().Err(err).Msg("GPS tracks refused")
+		c.Set(fiber.HeaderRetryAfter, "1")
+	} else {
+		log.Error().Err(err).Msg("Failed to accept GPS tracks")
+	}
+
+	return c.Status(errorStatus(err)).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/chats/sailing-backend/internal/usecase"
+	"github.com/gofiber/fiber/v2"
+)
+
+// PageSizes are the page sizes of list endpoints
+type PageSizes struct {
+	Default int // used without ?limit=
+	Max     int // largest page accepted
+}
+
+// withDefaults returns p with unset sizes set to their defaults
+func (p PageSizes) withDefaults() PageSizes {
+	if p.Default <= 0 {
+		p.Default = 100
+	}
+	if p.Max <= 0 {
+		p.Max = 1000
+	}
+	return p
+}
+
+// query reads ?limit= and ?offset=, capping limit at the largest page size
+func (p PageSizes) query(c *fiber.Ctx) (limit, offset int) {
+	limit = c.QueryInt("limit", p.Default)
+	if limit <= 0 {
+		limit = p.Default
+	}
+	if limit > p.Max {
+		limit = p.Max
+	}
+	offset = c.QueryInt("offset", 0)
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+// errorStatus maps use case errors to HTTP status codes
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrVoyageNotFound),
+		errors.Is(err, domain.ErrWebhookNotFound),
+		errors.Is(err, domain.ErrDeliveryNotFound),
+		errors.Is(err, domain.ErrAPIKeyNotFound),
+		errors.Is(err, domain.ErrUserNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, domain.ErrShipNotPermitted):
+		return fiber.StatusForbidden
+	case errors.Is(err, domain.ErrUserExists):
+		return fiber.StatusConflict
+	case errors.Is(err, domain.ErrInvalidInput),
+		errors.Is(err, domain.ErrInvalidWebhook),
+		errors.Is(err, domain.ErrInvalidAPIKey),
+		errors.Is(err, domain.ErrInvalidUser):
+		return fiber.StatusBadRequest
+	case errors.Is(err, domain.ErrIngestQueueFull):
+		return fiber.StatusTooManyRequests
+	case errors.Is(err, domain.ErrIngestQueueClosed),
+		errors.Is(err, domain.ErrGPSTracksConverting):
+		return fiber.StatusServiceUnavailable
+	case errors.Is(err, domain.ErrVoyageNotInProgress),
+		errors.Is(err, domain.ErrVoyageCancelled):
+		return fiber.StatusConflict
+	case errors.Is(err, domain.ErrTimestampBeforeDeparture),
+		errors.Is(err, domain.ErrTimestampAfterArrival):
+		return fiber.StatusUnprocessableEntity
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
+
+// ingestOptions reads the per-request ingestion flags from the query string
+func ingestOptions(c *fiber.Ctx) usecase.IngestOptions {
+	return usecase.IngestOptions{
+		AllowBackfill: c.QueryBool("backfill"),
+	}
+}
+
+// uploadedFile returns the uploaded document, sent either as the raw request
+// body or as the "file" field of a multipart form
+func uploadedFile(c *fiber.Ctx) (io.Reader, error) {
+	if !strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		return bytes.NewReader(c.Body()), nil
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// timeRangeQuery parses the optional RFC 3339 "from" and "to" query parameters
+func timeRangeQuery(c *fiber.Ctx) (domain.TimeRange, error) {
+	var tr domain.TimeRange
+	if v := c.Query("from"); v != "" {
+		from, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return tr, errors.New("from must be an RFC 3339 timestamp")
+		}
+		tr.From = from
+	}
+	if v := c.Query("to"); v != "" {
+		to, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return tr, errors.New("to must be an RFC 3339 timestamp")
+		}
+		tr.To = to
+	}
+	if !tr.From.IsZero() && !tr.To.IsZero() && tr.To.Before(tr.From) {
+		return tr, errors.New("to must not be before from")
+	}
+	return tr, nil
+}
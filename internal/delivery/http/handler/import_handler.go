@@ -0,0 +1,358 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/chats/sailing-backend/internal/usecase"
+	"github.com/chats/sailing-backend/pkg/gpx"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// ImportHandler handles bulk imports of voyage data from files
+type ImportHandler struct {
+	voyageUseCase     *usecase.VoyageUseCase
+	checkpointUseCase *usecase.CheckpointUseCase
+	gpsTrackUseCase   *usecase.GPSTrackUseCase
+	chunkSize         int // records written per batch
+}
+
+// NewImportHandler creates a new import handler
+func NewImportHandler(voyageUseCase *usecase.VoyageUseCase, checkpointUseCase *usecase.CheckpointUseCase, gpsTrackUseCase *usecase.GPSTrackUseCase, chunkSize int) *ImportHandler {
+	if chunkSize <= 0 {
+		chunkSize = 1000
+	}
+	return &ImportHandler{
+		voyageUseCase:     voyageUseCase,
+		checkpointUseCase: checkpointUseCase,
+		gpsTrackUseCase:   gpsTrackUseCase,
+		chunkSize:         chunkSize,
+	}
+}
+
+// ImportGPX imports GPX track points as GPS tracks and waypoints as checkpoints
+func (h *ImportHandler) ImportGPX(c *fiber.Ctx) error {
+	voyage, err := h.voyageUseCase.ResolveVoyage(c.UserContext(), c.Params("id"))
+	if err != nil {
+		log.Error().Err(err).Str("id", c.Params("id")).Msg("Failed to get voyage")
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	file, err := uploadedFile(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	doc, err := gpx.Parse(file)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse GPX import")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Points without a time cannot be placed within the voyage and are skipped
+	skipped := 0
+	var tracks []*domain.GPSTrack
+	for _, trk := range doc.Tracks {
+		for _, seg := range trk.Segments {
+			for _, pt := range seg.Points {
+				if pt.Time == nil {
+					skipped++
+					continue
+				}
+				tracks = append(tracks, trackFromGPXPoint(voyage.VoyageID, &pt))
+			}
+		}
+	}
+
+	var checkpoints []*domain.Checkpoint
+	for _, wpt := range doc.Waypoints {
+		if wpt.Time == nil {
+			skipped++
+			continue
+		}
+		checkpoints = append(checkpoints, checkpointFromGPXWaypoint(voyage.VoyageID, &wpt))
+	}
+
+	if len(tracks) == 0 && len(checkpoints) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "no timestamped track points or waypoints in GPX file",
+			"skipped": skipped,
+		})
+	}
+
+	opts := ingestOptions(c)
+	imported := fiber.Map{"gps_tracks": 0, "checkpoints": 0, "skipped": skipped}
+
+	for start := 0; start < len(tracks); start += h.chunkSize {
+		chunk := tracks[start:min(start+h.chunkSize, len(tracks))]
+		if err := h.gpsTrackUseCase.CreateGPSTracksBatch(c.UserContext(), chunk, opts); err != nil {
+			log.Error().Err(err).Msg("Failed to import GPX track points")
+			return c.Status(errorStatus(err)).JSON(fiber.Map{
+				"error":    err.Error(),
+				"imported": imported,
+			})
+		}
+		imported["gps_tracks"] = start + len(chunk)
+	}
+
+	for start := 0; start < len(checkpoints); start += h.chunkSize {
+		chunk := checkpoints[start:min(start+h.chunkSize, len(checkpoints))]
+		if err := h.checkpointUseCase.CreateCheckpointsBatch(c.UserContext(), chunk, opts); err != nil {
+			log.Error().Err(err).Msg("Failed to import GPX waypoints")
+			return c.Status(errorStatus(err)).JSON(fiber.Map{
+				"error":    err.Error(),
+				"imported": imported,
+			})
+		}
+		imported["checkpoints"] = start + len(chunk)
+	}
+
+	log.Info().
+		Str("voyage_id", voyage.VoyageID).
+		Int("gps_tracks", len(tracks)).
+		Int("checkpoints", len(checkpoints)).
+		Int("skipped", skipped).
+		Msg("GPX imported")
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":  "GPX imported successfully",
+		"imported": imported,
+	})
+}
+
+// RowError describes a CSV row that could not be imported
+type RowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// csvRow is a parsed CSV record awaiting its batch write
+type csvRow struct {
+	line       int
+	track      *domain.GPSTrack
+	checkpoint *domain.Checkpoint
+}
+
+// ImportCSV imports GPS tracks or checkpoints from a CSV file with a header
+// row. The column mapping, timestamp and coordinate formats are read from the
+// query string; rows that cannot be parsed or stored are reported individually.
+func (h *ImportHandler) ImportCSV(c *fiber.Ctx) error {
+	voyage, err := h.voyageUseCase.ResolveVoyage(c.UserContext(), c.Params("id"))
+	if err != nil {
+		log.Error().Err(err).Str("id", c.Params("id")).Msg("Failed to get voyage")
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	mapping, err := csvMappingFromQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	file, err := uploadedFile(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	reader := csv.NewReader(file)
+	reader.Comma = mapping.Delimiter
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "missing CSV header row",
+		})
+	}
+	if err := mapping.bindHeader(header); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	opts := ingestOptions(c)
+	imported := 0
+	rowErrors := []RowError{}
+	pending := make([]csvRow, 0, h.chunkSize)
+
+	flush := func() error {
+		n, errs, err := h.writeCSVRows(c.UserContext(), mapping.Type, pending, opts)
+		imported += n
+		rowErrors = append(rowErrors, errs...)
+		pending = pending[:0]
+		return err
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Malformed rows are reported like invalid ones; any other
+			// error means the upload itself could not be read
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error":    "invalid CSV: " + err.Error(),
+					"imported": imported,
+					"errors":   rowErrors,
+				})
+			}
+			rowErrors = append(rowErrors, RowError{Row: parseErr.Line, Error: parseErr.Err.Error()})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		row := csvRow{line: line}
+		if mapping.Type == csvTypeCheckpoints {
+			row.checkpoint, err = mapping.checkpoint(voyage.VoyageID, record)
+		} else {
+			row.track, err = mapping.gpsTrack(voyage.VoyageID, record)
+		}
+		if err != nil {
+			rowErrors = append(rowErrors, RowError{Row: line, Error: err.Error()})
+			continue
+		}
+
+		pending = append(pending, row)
+		if len(pending) == h.chunkSize {
+			if err := flush(); err != nil {
+				return csvImportFailed(c, err, imported, rowErrors)
+			}
+		}
+	}
+	if len(pending) > 0 {
+		if err := flush(); err != nil {
+			return csvImportFailed(c, err, imported, rowErrors)
+		}
+	}
+
+	log.Info().
+		Str("voyage_id", voyage.VoyageID).
+		Str("type", mapping.Type).
+		Int("imported", imported).
+		Int("rejected_rows", len(rowErrors)).
+		Msg("CSV imported")
+
+	status := fiber.StatusCreated
+	if imported == 0 {
+		status = fiber.StatusBadRequest
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"message":  "CSV import finished",
+		"type":     mapping.Type,
+		"imported": imported,
+		"errors":   rowErrors,
+	})
+}
+
+// writeCSVRows writes a chunk of rows through the batch use case. If the
+// batch is rejected by validation, rows are retried one at a time so the
+// offending rows can be reported; storage failures abort the import.
+func (h *ImportHandler) writeCSVRows(ctx context.Context, recordType string, rows []csvRow, opts usecase.IngestOptions) (int, []RowError, error) {
+	var err error
+	if recordType == csvTypeCheckpoints {
+		checkpoints := make([]*domain.Checkpoint, len(rows))
+		for i, row := range rows {
+			checkpoints[i] = row.checkpoint
+		}
+		err = h.checkpointUseCase.CreateCheckpointsBatch(ctx, checkpoints, opts)
+	} else {
+		tracks := make([]*domain.GPSTrack, len(rows))
+		for i, row := range rows {
+			tracks[i] = row.track
+		}
+		err = h.gpsTrackUseCase.CreateGPSTracksBatch(ctx, tracks, opts)
+	}
+	if err == nil {
+		return len(rows), nil, nil
+	}
+	if errorStatus(err) == fiber.StatusInternalServerError {
+		return 0, nil, err
+	}
+
+	imported := 0
+	var rowErrors []RowError
+	for _, row := range rows {
+		if recordType == csvTypeCheckpoints {
+			err = h.checkpointUseCase.CreateCheckpoint(ctx, row.checkpoint, opts)
+		} else {
+			err = h.gpsTrackUseCase.CreateGPSTrack(ctx, row.track, opts)
+		}
+		if err != nil {
+			if errorStatus(err) == fiber.StatusInternalServerError {
+				return imported, rowErrors, err
+			}
+			rowErrors = append(rowErrors, RowError{Row: row.line, Error: err.Error()})
+			continue
+		}
+		imported++
+	}
+	return imported, rowErrors, nil
+}
+
+func csvImportFailed(c *fiber.Ctx, err error, imported int, rowErrors []RowError) error {
+	log.Error().Err(err).Msg("Failed to import CSV")
+	return c.Status(errorStatus(err)).JSON(fiber.Map{
+		"error":    err.Error(),
+		"imported": imported,
+		"errors":   rowErrors,
+	})
+}
+
+func trackFromGPXPoint(voyageID string, pt *gpx.Point) *domain.GPSTrack {
+	track := &domain.GPSTrack{
+		VoyageID: voyageID,
+		Location: domain.Location{
+			Latitude:  pt.Latitude,
+			Longitude: pt.Longitude,
+		},
+		Timestamp: *pt.Time,
+	}
+	if pt.Elevation != nil {
+		track.Altitude = *pt.Elevation
+	}
+	if speed, ok := pt.SpeedKnots(); ok {
+		track.Speed = speed
+	}
+	if course, ok := pt.Course(); ok {
+		track.Heading = course
+	}
+	return track
+}
+
+func checkpointFromGPXWaypoint(voyageID string, wpt *gpx.Point) *domain.Checkpoint {
+	description := wpt.Name
+	if wpt.Description != "" {
+		if description != "" {
+			description += ": "
+		}
+		description += wpt.Description
+	}
+
+	return &domain.Checkpoint{
+		VoyageID: voyageID,
+		Location: domain.Location{
+			Latitude:  wpt.Latitude,
+			Longitude: wpt.Longitude,
+		},
+		Timestamp:   *wpt.Time,
+		Description: description,
+	}
+}
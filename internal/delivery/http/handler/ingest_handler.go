@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/chats/sailing-backend/internal/usecase"
+	"github.com/chats/sailing-backend/pkg/ais"
+	"github.com/chats/sailing-backend/pkg/nmea"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// IngestHandler handles raw device feed uploads
+type IngestHandler struct {
+	voyageUseCase   *usecase.VoyageUseCase
+	gpsTrackUseCase *usecase.GPSTrackUseCase
+	aisUseCase      *usecase.AISUseCase
+}
+
+// NewIngestHandler creates a new ingest handler
+func NewIngestHandler(voyageUseCase *usecase.VoyageUseCase, gpsTrackUseCase *usecase.GPSTrackUseCase, aisUseCase *usecase.AISUseCase) *IngestHandler {
+	return &IngestHandler{
+		voyageUseCase:   voyageUseCase,
+		gpsTrackUseCase: gpsTrackUseCase,
+		aisUseCase:      aisUseCase,
+	}
+}
+
+// LineError describes a line of an uploaded feed that could not be used
+type LineError struct {
+	Line     int    `json:"line"`
+	Sentence string `json:"sentence"`
+	Error    string `json:"error"`
+}
+
+// scanError reports a request body that could not be read past line, e.g.
+// because the line is longer than bufio.MaxScanTokenSize
+func scanError(c *fiber.Ctx, line int, err error) error {
+	if errors.Is(err, bufio.ErrTooLong) {
+		err = fmt.Errorf("longer than %d bytes", bufio.MaxScanTokenSize)
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error": fmt.Sprintf("line %d: %v", line, err),
+	})
+}
+
+// IngestNMEA converts raw NMEA 0183 sentences into GPS tracks for a voyage
+func (h *IngestHandler) IngestNMEA(c *fiber.Ctx) error {
+	voyage, err := h.voyageUseCase.ResolveVoyage(c.UserContext(), c.Params("id"))
+	if err != nil {
+		log.Error().Err(err).Str("id", c.Params("id")).Msg("Failed to get voyage")
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	assembler := nmea.NewAssembler()
+	var tracks []*domain.GPSTrack
+	lineErrors := []LineError{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(c.Body()))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := string(bytes.TrimSpace(scanner.Bytes()))
+		if line == "" {
+			continue
+		}
+
+		sentence, err := nmea.Parse(line)
+		if err == nil {
+			var fix *nmea.Fix
+			fix, err = assembler.Add(sentence)
+			if fix != nil {
+				tracks = append(tracks, usecase.GPSTrackFromFix(voyage.VoyageID, fix))
+			}
+		}
+		if err != nil {
+			lineErrors = append(lineErrors, LineError{Line: lineNo, Sentence: line, Error: err.Error()})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return scanError(c, lineNo+1, err)
+	}
+	if fix := assembler.Flush(); fix != nil {
+		tracks = append(tracks, usecase.GPSTrackFromFix(voyage.VoyageID, fix))
+	}
+
+	if len(tracks) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":  "no valid position fixes in request body",
+			"errors": lineErrors,
+		})
+	}
+
+	if err := h.gpsTrackUseCase.CreateGPSTracksBatch(c.UserContext(), tracks, ingestOptions(c)); err != nil {
+		log.Error().Err(err).Msg("Failed to create GPS tracks from NMEA")
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"error":  err.Error(),
+			"errors": lineErrors,
+		})
+	}
+
+	log.Info().
+		Str("voyage_id", voyage.VoyageID).
+		Int("count", len(tracks)).
+		Int("rejected_lines", len(lineErrors)).
+		Msg("NMEA sentences ingested")
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "GPS tracks created successfully",
+		"data":    tracks,
+		"count":   len(tracks),
+		"errors":  lineErrors,
+	})
+}
+
+// IngestAIS decodes AIVDM/AIVDO sentences and applies them to the active
+// voyages of the transmitting ships
+func (h *IngestHandler) IngestAIS(c *fiber.Ctx) error {
+	receivedAt := time.Now()
+	decoder := ais.NewDecoder()
+
+	var reports []usecase.AISReport
+	var messageLines []int
+	var messageSentences []string
+	lineErrors := []LineError{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(c.Body()))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := string(bytes.TrimSpace(scanner.Bytes()))
+		if line == "" {
+			continue
+		}
+
+		sentence, err := nmea.Parse(line)
+		if err == nil {
+			var msg ais.Message
+			msg, err = decoder.Decode(sentence)
+			if msg != nil {
+				reports = append(reports, usecase.AISReport{Message: msg, ReceivedAt: receivedAt})
+				messageLines = append(messageLines, lineNo)
+				messageSentences = append(messageSentences, line)
+			}
+		}
+		if err != nil {
+			lineErrors = append(lineErrors, LineError{Line: lineNo, Sentence: line, Error: err.Error()})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return scanError(c, lineNo+1, err)
+	}
+
+	result, err := h.aisUseCase.Ingest(c.UserContext(), reports)
+	if result != nil {
+		for i, msgErr := range result.Errors {
+			lineErrors = append(lineErrors, LineError{Line: messageLines[i], Sentence: messageSentences[i], Error: msgErr.Error()})
+		}
+		sort.Slice(lineErrors, func(i, j int) bool { return lineErrors[i].Line < lineErrors[j].Line })
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to ingest AIS messages")
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"error":  err.Error(),
+			"errors": lineErrors,
+		})
+	}
+
+	log.Info().
+		Int("messages", len(reports)).
+		Int("tracks", len(result.Tracks)).
+		Int("updated_voyages", len(result.UpdatedVoyages)).
+		Int("rejected_lines", len(lineErrors)).
+		Msg("AIS messages ingested")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":         "AIS messages processed",
+		"data":            result.Tracks,
+		"count":           len(result.Tracks),
+		"updated_voyages": result.UpdatedVoyages,
+		"errors":          lineErrors,
+	})
+}
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/chats/sailing-backend/internal/events"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// streamPingInterval keeps idle WebSocket connections alive through proxies
+const streamPingInterval = 30 * time.Second
+
+// StreamHandler pushes live voyage events to WebSocket clients
+type StreamHandler struct {
+	broker *events.Broker
+}
+
+// NewStreamHandler creates a new stream handler
+func NewStreamHandler(broker *events.Broker) *StreamHandler {
+	return &StreamHandler{
+		broker: broker,
+	}
+}
+
+// streamSubscription is the filter a client sends, either as query
+// parameters on connect or later as a JSON message
+type streamSubscription struct {
+	Types     []string  `json:"types"`
+	VoyageIDs []string  `json:"voyage_ids"`
+	ShipIDs   []string  `json:"ship_ids"`
+	BBox      []float64 `json:"bbox"` // [min_lon, min_lat, max_lon, max_lat]
+}
+
+func (s *streamSubscription) filter() (*events.Filter, error) {
+	var bbox *events.BoundingBox
+	if len(s.BBox) > 0 {
+		if len(s.BBox) != 4 {
+			return nil, errors.New("bbox must be [min_lon, min_lat, max_lon, max_lat]")
+		}
+		bbox = &events.BoundingBox{
+			MinLongitude: s.BBox[0],
+			MinLatitude:  s.BBox[1],
+			MaxLongitude: s.BBox[2],
+			MaxLatitude:  s.BBox[3],
+		}
+		if bbox.MinLatitude > bbox.MaxLatitude {
+			return nil, errors.New("bbox min_lat must not exceed max_lat")
+		}
+	}
+	return events.NewFilter(s.Types, s.VoyageIDs, s.ShipIDs, bbox), nil
+}
+
+// Upgrade validates the initial subscription and lets WebSocket handshakes through
+func (h *StreamHandler) Upgrade(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return fiber.ErrUpgradeRequired
+	}
+
+	filter, err := eventFilterQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	c.Locals("stream_filter", filter)
+	return c.Next()
+}
+
+// Stream serves an upgraded connection until the client disconnects
+func (h *StreamHandler) Stream() fiber.Handler {
+	return websocket.New(func(conn *websocket.Conn) {
+		filter, _ := conn.Locals("stream_filter").(*events.Filter)
+		sub := h.broker.Subscribe(filter)
+		defer h.broker.Unsubscribe(sub)
+
+		remote := conn.RemoteAddr().String()
+		log.Info().Str("remote", remote).Msg("Stream client connected")
+
+		// Reader: applies subscription updates until the client goes away
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				_, msg, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+
+				var update streamSubscription
+				if err := json.Unmarshal(msg, &update); err != nil {
+					log.Debug().Err(err).Str("remote", remote).Msg("Invalid stream subscription")
+					continue
+				}
+				f, err := update.filter()
+				if err != nil {
+					log.Debug().Err(err).Str("remote", remote).Msg("Invalid stream subscription")
+					continue
+				}
+				f.TenantID = filter.TenantID
+				sub.SetFilter(f)
+			}
+		}()
+
+		ping := time.NewTicker(streamPingInterval)
+		defer ping.Stop()
+
+		for {
+			select {
+			case event, ok := <-sub.Events():
+				if !ok {
+					if sub.Lagged() {
+						_ = conn.WriteControl(websocket.CloseMessage,
+							websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client too slow"),
+							time.Now().Add(time.Second))
+					}
+					return
+				}
+				if err := conn.WriteJSON(event); err != nil {
+					return
+				}
+			case <-ping.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(5*time.Second)); err != nil {
+					return
+				}
+			case <-closed:
+				log.Info().Str("remote", remote).Msg("Stream client disconnected")
+				return
+			}
+		}
+	})
+}
+
+// eventFilterQuery reads an event filter from the types, voyage_id, ship_id
+// and bbox query parameters, limited to the caller's tenant
+func eventFilterQuery(c *fiber.Ctx) (*events.Filter, error) {
+	sub := streamSubscription{
+		Types:     splitQuery(c.Query("types")),
+		VoyageIDs: splitQuery(c.Query("voyage_id")),
+		ShipIDs:   splitQuery(c.Query("ship_id")),
+	}
+	for _, v := range splitQuery(c.Query("bbox")) {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, errors.New("bbox must be min_lon,min_lat,max_lon,max_lat")
+		}
+		sub.BBox = append(sub.BBox, f)
+	}
+
+	filter, err := sub.filter()
+	if err != nil {
+		return nil, err
+	}
+	tenant, limited := domain.TenantFromContext(c.UserContext())
+	if limited && tenant == "" {
+		return nil, errors.New("credential has no tenant")
+	}
+	filter.TenantID = tenant
+	return filter, nil
+}
+
+// splitQuery splits a comma-separated query value
+func splitQuery(v string) []string {
+	if v == "" {
+		return nil
+	}
+	parts := strings.Split(v, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
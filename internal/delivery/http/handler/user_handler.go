@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/chats/sailing-backend/internal/usecase"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// UserHandler handles user management HTTP requests
+type UserHandler struct {
+	userUseCase *usecase.UserUseCase
+}
+
+// NewUserHandler creates a new user handler
+func NewUserHandler(userUseCase *usecase.UserUseCase) *UserHandler {
+	return &UserHandler{
+		userUseCase: userUseCase,
+	}
+}
+
+// CreateUserRequest represents the create user request body
+type CreateUserRequest struct {
+	Username string         `json:"username"`
+	Password string         `json:"password"`
+	Role     domain.Role    `json:"role"`
+	Scopes   []domain.Scope `json:"scopes,omitempty"` // defaults to the role's scopes
+	ShipID   string         `json:"ship_id,omitempty"`
+	TenantID string         `json:"tenant_id,omitempty"` // only used by platform principals
+}
+
+// SetPasswordRequest represents the set password request body
+type SetPasswordRequest struct {
+	Password string `json:"password"`
+}
+
+// CreateUser creates a user who can sign in at the token endpoint
+func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
+	var req CreateUserRequest
+	if err := c.BodyParser(&req); err != nil {
+		log.Error().Err(err).Msg("Failed to parse create user request")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	user := &domain.User{
+		Username: req.Username,
+		Role:     req.Role,
+		Scopes:   req.Scopes,
+		ShipID:   req.ShipID,
+		TenantID: req.TenantID,
+	}
+
+	if err := h.userUseCase.CreateUser(c.UserContext(), user, req.Password); err != nil {
+		log.Error().Err(err).Msg("Failed to create user")
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	log.Info().Str("id", user.ID.Hex()).Str("username", user.Username).Str("role", string(user.Role)).Msg("User created")
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "User created successfully",
+		"data":    user,
+	})
+}
+
+// GetUsers lists all users
+func (h *UserHandler) GetUsers(c *fiber.Ctx) error {
+	users, err := h.userUseCase.GetAllUsers(c.UserContext())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get users")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to retrieve users",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data":  users,
+		"count": len(users),
+	})
+}
+
+// GetUser retrieves a user by ID
+func (h *UserHandler) GetUser(c *fiber.Ctx) error {
+	user, err := h.userUseCase.GetUser(c.UserContext(), c.Params("id"))
+	if err != nil {
+		log.Error().Err(err).Str("id", c.Params("id")).Msg("Failed to get user")
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": user,
+	})
+}
+
+// SetPassword replaces a user's password and revokes their refresh tokens
+func (h *UserHandler) SetPassword(c *fiber.Ctx) error {
+	var req SetPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		log.Error().Err(err).Msg("Failed to parse set password request")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	user, err := h.userUseCase.SetPassword(c.UserContext(), c.Params("id"), req.Password)
+	if err != nil {
+		log.Error().Err(err).Str("id", c.Params("id")).Msg("Failed to set user password")
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	log.Info().Str("id", user.ID.Hex()).Msg("User password changed")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Password changed successfully",
+		"data":    user,
+	})
+}
+
+// DisableUser stops a user from signing in and revokes their refresh tokens
+func (h *UserHandler) DisableUser(c *fiber.Ctx) error {
+	user, err := h.userUseCase.DisableUser(c.UserContext(), c.Params("id"))
+	if err != nil {
+		log.Error().Err(err).Str("id", c.Params("id")).Msg("Failed to disable user")
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	log.Info().Str("id", user.ID.Hex()).Msg("User disabled")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "User disabled successfully",
+		"data":    user,
+	})
+}
@@ -1,8 +1,6 @@
 package handler
 
 import (
-	"strconv"
-
 	"github.com/chats/sailing-backend/internal/domain"
 	"github.com/chats/sailing-backend/internal/usecase"
 	"github.com/gofiber/fiber/v2"
@@ -12,12 +10,14 @@ import (
 // VoyageHandler handles voyage-related HTTP requests
 type VoyageHandler struct {
 	voyageUseCase *usecase.VoyageUseCase
+	pages         PageSizes
 }
 
 // NewVoyageHandler creates a new voyage handler
-func NewVoyageHandler(voyageUseCase *usecase.VoyageUseCase) *VoyageHandler {
+func NewVoyageHandler(voyageUseCase *usecase.VoyageUseCase, pages PageSizes) *VoyageHandler {
 	return &VoyageHandler{
 		voyageUseCase: voyageUseCase,
+		pages:         pages.withDefaults(),
 	}
 }
 
@@ -25,9 +25,11 @@ func NewVoyageHandler(voyageUseCase *usecase.VoyageUseCase) *VoyageHandler {
 type DepartRequest struct {
 	ShipID        string `json:"ship_id"`
 	ShipName      string `json:"ship_name"`
+	MMSI          string `json:"mmsi,omitempty"`
 	DeparturePort string `json:"departure_port"`
 	ArrivalPort   string `json:"arrival_port,omitempty"`
 	VoyageID      string `json:"voyage_id,omitempty"`
+	TenantID      string `json:"tenant_id,omitempty"` // only used by platform principals
 }
 
 // ArriveRequest represents the arrive request body
@@ -47,16 +49,18 @@ func (h *VoyageHandler) Depart(c *fiber.Ctx) error {
 	}
 
 	voyage := &domain.Voyage{
+		TenantID:      req.TenantID,
 		VoyageID:      req.VoyageID,
 		ShipID:        req.ShipID,
 		ShipName:      req.ShipName,
+		MMSI:          req.MMSI,
 		DeparturePort: req.DeparturePort,
 		ArrivalPort:   req.ArrivalPort,
 	}
 
-	if err := h.voyageUseCase.DepartVoyage(c.Context(), voyage); err != nil {
+	if err := h.voyageUseCase.DepartVoyage(c.UserContext(), voyage); err != nil {
 		log.Error().Err(err).Msg("Failed to create voyage")
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
@@ -79,9 +83,9 @@ func (h *VoyageHandler) Arrive(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.voyageUseCase.ArriveVoyage(c.Context(), req.VoyageID, req.ArrivalPort); err != nil {
+	if err := h.voyageUseCase.ArriveVoyage(c.UserContext(), req.VoyageID, req.ArrivalPort); err != nil {
 		log.Error().Err(err).Msg("Failed to update voyage arrival")
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
@@ -95,10 +99,9 @@ func (h *VoyageHandler) Arrive(c *fiber.Ctx) error {
 
 // GetAllVoyages retrieves all voyages
 func (h *VoyageHandler) GetAllVoyages(c *fiber.Ctx) error {
-	limit, _ := strconv.Atoi(c.Query("limit", "100"))
-	offset, _ := strconv.Atoi(c.Query("offset", "0"))
+	limit, offset := h.pages.query(c)
 
-	voyages, err := h.voyageUseCase.GetAllVoyages(c.Context(), limit, offset)
+	voyages, err := h.voyageUseCase.GetAllVoyages(c.UserContext(), limit, offset)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to get voyages")
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -121,7 +124,7 @@ func (h *VoyageHandler) GetVoyageByID(c *fiber.Ctx) error {
 		})
 	}
 
-	voyage, err := h.voyageUseCase.GetVoyageByID(c.Context(), id)
+	voyage, err := h.voyageUseCase.GetVoyageByID(c.UserContext(), id)
 	if err != nil {
 		log.Error().Err(err).Str("id", id).Msg("Failed to get voyage")
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/chats/sailing-backend/internal/usecase"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// WebhookHandler handles webhook subscription HTTP requests
+type WebhookHandler struct {
+	webhookUseCase *usecase.WebhookUseCase
+	pages          PageSizes
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(webhookUseCase *usecase.WebhookUseCase, pages PageSizes) *WebhookHandler {
+	return &WebhookHandler{
+		webhookUseCase: webhookUseCase,
+		pages:          pages.withDefaults(),
+	}
+}
+
+// CreateWebhookRequest represents the create webhook request body
+type CreateWebhookRequest struct {
+	URL         string   `json:"url"`
+	EventTypes  []string `json:"event_types,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Active      *bool    `json:"active,omitempty"`
+	TenantID    string   `json:"tenant_id,omitempty"` // only used by platform principals
+}
+
+// UpdateWebhookRequest represents the update webhook request body; omitted fields are left unchanged
+type UpdateWebhookRequest struct {
+	URL         *string   `json:"url,omitempty"`
+	EventTypes  *[]string `json:"event_types,omitempty"`
+	Description *string   `json:"description,omitempty"`
+	Active      *bool     `json:"active,omitempty"`
+}
+
+// CreateWebhook creates a subscription and returns its signing secret, which is not shown again
+func (h *WebhookHandler) CreateWebhook(c *fiber.Ctx) error {
+	var req CreateWebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		log.Error().Err(err).Msg("Failed to parse create webhook request")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	sub := &domain.WebhookSubscription{
+		TenantID:    req.TenantID,
+		URL:         req.URL,
+		EventTypes:  req.EventTypes,
+		Description: req.Description,
+		Active:      req.Active == nil || *req.Active,
+	}
+	if sub.EventTypes == nil {
+		sub.EventTypes = []string{}
+	}
+
+	if err := h.webhookUseCase.CreateSubscription(c.UserContext(), sub); err != nil {
+		log.Error().Err(err).Msg("Failed to create webhook")
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	log.Info().Str("id", sub.ID.Hex()).Str("url", sub.URL).Msg("Webhook created")
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "webhook created successfully",
+		"data":    sub,
+		"secret":  sub.Secret,
+	})
+}
+
+// GetWebhooks lists all subscriptions
+func (h *WebhookHandler) GetWebhooks(c *fiber.Ctx) error {
+	subs, err := h.webhookUseCase.GetAllSubscriptions(c.UserContext())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get webhooks")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to retrieve webhooks",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data":  subs,
+		"count": len(subs),
+	})
+}
+
+// GetWebhook retrieves a subscription by ID
+func (h *WebhookHandler) GetWebhook(c *fiber.Ctx) error {
+	sub, err := h.webhookUseCase.GetSubscription(c.UserContext(), c.Params("id"))
+	if err != nil {
+		log.Error().Err(err).Str("id", c.Params("id")).Msg("Failed to get webhook")
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": sub,
+	})
+}
+
+// UpdateWebhook applies a partial update to a subscription
+func (h *WebhookHandler) UpdateWebhook(c *fiber.Ctx) error {
+	var req UpdateWebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		log.Error().Err(err).Msg("Failed to parse update webhook request")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	sub, err := h.webhookUseCase.GetSubscription(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if req.URL != nil {
+		sub.URL = *req.URL
+	}
+	if req.EventTypes != nil {
+		sub.EventTypes = *req.EventTypes
+	}
+	if req.Description != nil {
+		sub.Description = *req.Description
+	}
+	if req.Active != nil {
+		sub.Active = *req.Active
+	}
+
+	if err := h.webhookUseCase.UpdateSubscription(c.UserContext(), sub); err != nil {
+		log.Error().Err(err).Str("id", sub.ID.Hex()).Msg("Failed to update webhook")
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "webhook updated successfully",
+		"data":    sub,
+	})
+}
+
+// DeleteWebhook removes a subscription
+func (h *WebhookHandler) DeleteWebhook(c *fiber.Ctx) error {
+	if err := h.webhookUseCase.DeleteSubscription(c.UserContext(), c.Params("id")); err != nil {
+		log.Error().Err(err).Str("id", c.Params("id")).Msg("Failed to delete webhook")
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	log.Info().Str("id", c.Params("id")).Msg("Webhook deleted")
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "webhook deleted successfully",
+	})
+}
+
+// PingWebhook queues a webhook.ping event for the subscription
+func (h *WebhookHandler) PingWebhook(c *fiber.Ctx) error {
+	delivery, err := h.webhookUseCase.Ping(c.UserContext(), c.Params("id"))
+	if err != nil {
+		log.Error().Err(err).Str("id", c.Params("id")).Msg("Failed to ping webhook")
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "ping queued",
+		"data":    delivery,
+	})
+}
+
+// GetDeliveries lists webhook deliveries; ?status=dead returns the dead-letter list
+func (h *WebhookHandler) GetDeliveries(c *fiber.Ctx) error {
+	limit, offset := h.pages.query(c)
+
+	deliveries, err := h.webhookUseCase.GetDeliveries(c.UserContext(), c.Query("status"), limit, offset)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get webhook deliveries")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to retrieve webhook deliveries",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data":  deliveries,
+		"count": len(deliveries),
+	})
+}
+
+// RetryDelivery requeues a dead-lettered delivery
+func (h *WebhookHandler) RetryDelivery(c *fiber.Ctx) error {
+	delivery, err := h.webhookUseCase.RetryDelivery(c.UserContext(), c.Params("id"))
+	if err != nil {
+		log.Error().Err(err).Str("id", c.Params("id")).Msg("Failed to retry webhook delivery")
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "delivery requeued",
+		"data":    delivery,
+	})
+}
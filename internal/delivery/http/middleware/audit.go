@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"errors"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditRecorder stores audit entries
+type AuditRecorder interface {
+	Record(entry *domain.AuditEntry)
+}
+
+// AuditMiddleware records every mutating request in the audit log, with the
+// changes the use cases report through the request's user context. It must
+// run after AuthMiddleware; requests rejected there are not recorded.
+func AuditMiddleware(recorder AuditRecorder) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+			return c.Next()
+		}
+
+		trail := &domain.AuditTrail{}
+		c.SetUserContext(domain.WithAuditTrail(c.UserContext(), trail))
+
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			// The error handler sets the final status after this returns
+			status = fiber.StatusInternalServerError
+			var fe *fiber.Error
+			if errors.As(err, &fe) {
+				status = fe.Code
+			}
+		}
+
+		// Entries are written after the request completes, when fiber may
+		// have reused the request's buffers, so strings are copied
+		entry := &domain.AuditEntry{
+			ID:        primitive.NewObjectID(),
+			Method:    utils.CopyString(c.Method()),
+			Route:     c.Route().Path,
+			Path:      utils.CopyString(c.Path()),
+			Status:    status,
+			IP:        utils.CopyString(c.IP()),
+			Changes:   trail.Changes(),
+			CreatedAt: time.Now(),
+		}
+		if principal := domain.PrincipalFromContext(c.UserContext()); principal != nil {
+			entry.Principal = principal.Subject
+			entry.Role = principal.Role
+			entry.TenantID = principal.TenantID
+		}
+		recorder.Record(entry)
+
+		return err
+	}
+}
@@ -1,30 +1,74 @@
 package middleware
 
 import (
-	"os"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
 	"strings"
 
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog/log"
 )
 
+// APIKeyAuthenticator resolves managed API keys to principals
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, apiKey string) (*domain.Principal, error)
+}
+
+// AuthConfig holds the credentials AuthMiddleware accepts
+type AuthConfig struct {
+	JWT JWTConfig
+	// BootstrapAPIKey is an admin key from the environment, used to create
+	// the first managed keys
+	BootstrapAPIKey string
+	APIKeys         APIKeyAuthenticator
+}
+
 // AuthMiddleware validates JWT token or API key
-func AuthMiddleware() fiber.Handler {
+func AuthMiddleware(cfg AuthConfig) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		// Check for API Key first
 		apiKey := c.Get("X-API-Key")
+		authHeader := c.Get("Authorization")
+
+		// Browsers cannot set headers on WebSocket handshakes, so those may
+		// carry the credentials in the query string instead
+		if apiKey == "" && authHeader == "" && websocket.IsWebSocketUpgrade(c) {
+			apiKey = c.Query("api_key")
+			if token := c.Query("access_token"); token != "" {
+				authHeader = "Bearer " + token
+			}
+		}
+
+		// Check for API Key first; the bootstrap key is a platform admin credential
 		if apiKey != "" {
-			expectedAPIKey := os.Getenv("API_KEY")
-			if apiKey == expectedAPIKey {
-				return c.Next()
+			if cfg.BootstrapAPIKey != "" && subtle.ConstantTimeCompare([]byte(apiKey), []byte(cfg.BootstrapAPIKey)) == 1 {
+				return withPrincipal(c, &domain.Principal{
+					Subject: "api-key:bootstrap",
+					Role:    domain.RoleAdmin,
+					Scopes:  append(domain.RoleAdmin.Scopes(), domain.ScopePlatform),
+				})
 			}
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "invalid API key",
-			})
+
+			principal, err := cfg.APIKeys.Authenticate(c.UserContext(), apiKey)
+			if err != nil {
+				if !errors.Is(err, domain.ErrInvalidAPIKey) {
+					log.Error().Err(err).Msg("Failed to validate API key")
+					return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+						"error": "failed to validate API key",
+					})
+				}
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"error": "invalid API key",
+				})
+			}
+			return withPrincipal(c, principal)
 		}
 
 		// Check for Bearer token
-		authHeader := c.Get("Authorization")
 		if authHeader == "" {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error": "missing authorization header",
@@ -39,28 +83,103 @@ func AuthMiddleware() fiber.Handler {
 			})
 		}
 
-		tokenString := parts[1]
-
-		// Validate JWT token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Validate the signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fiber.NewError(fiber.StatusUnauthorized, "invalid signing method")
-			}
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
-
+		token, err := cfg.JWT.parse(c.UserContext(), parts[1])
 		if err != nil || !token.Valid {
+			log.Debug().Err(err).Msg("Rejected bearer token")
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error": "invalid or expired token",
 			})
 		}
 
 		// Store claims in context for later use
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			c.Locals("user", claims)
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "invalid token claims",
+			})
+		}
+		c.Locals("user", claims)
+
+		principal, err := principalFromClaims(claims)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": err.Error(),
+			})
 		}
 
+		return withPrincipal(c, principal)
+	}
+}
+
+// RequireScope rejects requests whose principal lacks any of the scopes
+func RequireScope(scopes ...domain.Scope) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		principal := domain.PrincipalFromContext(c.UserContext())
+		for _, scope := range scopes {
+			if !principal.HasScope(scope) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"error":              fmt.Sprintf("missing permission: %s", scope),
+					"missing_permission": scope,
+				})
+			}
+		}
 		return c.Next()
 	}
 }
+
+// withPrincipal makes the principal available to handlers and, through the
+// request's user context, to use cases. Principals without a tenant are
+// rejected unless they are platform principals.
+func withPrincipal(c *fiber.Ctx, principal *domain.Principal) error {
+	if principal.TenantID == "" && !principal.Platform() {
+		log.Warn().Str("subject", principal.Subject).Msg("Rejected credential without a tenant")
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "credential has no tenant and lacks the platform scope",
+		})
+	}
+
+	c.Locals("principal", principal)
+	c.SetUserContext(domain.WithPrincipal(c.UserContext(), principal))
+	return c.Next()
+}
+
+// principalFromClaims reads the role, scopes, ship and tenant of a JWT. Scopes come from
+// the space-separated "scope" claim or the "scopes" array, limited to those
+// the role allows as for API keys and users; without either, the role's
+// default scopes apply.
+func principalFromClaims(claims jwt.MapClaims) (*domain.Principal, error) {
+	principal := &domain.Principal{}
+	principal.Subject, _ = claims.GetSubject()
+
+	if role, ok := claims["role"].(string); ok && role != "" {
+		principal.Role = domain.Role(role)
+		if !principal.Role.Valid() {
+			return nil, fmt.Errorf("unknown role %q", role)
+		}
+	}
+
+	switch scopes := claims["scopes"].(type) {
+	case []interface{}:
+		for _, s := range scopes {
+			if scope, ok := s.(string); ok {
+				principal.Scopes = append(principal.Scopes, domain.Scope(scope))
+			}
+		}
+	default:
+		if scope, ok := claims["scope"].(string); ok {
+			for _, s := range strings.Fields(scope) {
+				principal.Scopes = append(principal.Scopes, domain.Scope(s))
+			}
+		}
+	}
+
+	principal.Scopes = principal.Role.Limit(principal.Scopes)
+
+	principal.ShipID, _ = claims["ship_id"].(string)
+	principal.TenantID, _ = claims["tenant_id"].(string)
+	if principal.Role == domain.RoleVessel && principal.ShipID == "" {
+		return nil, errors.New("vessel tokens require a ship_id claim")
+	}
+
+	return principal, nil
+}
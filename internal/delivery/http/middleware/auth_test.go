@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestPrincipalFromClaims(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		scopes []domain.Scope
+		err    bool
+	}{
+		{
+			name:   "role defaults",
+			claims: jwt.MapClaims{"role": "read-only"},
+			scopes: domain.RoleReadOnly.Scopes(),
+		},
+		{
+			name:   "scope claim narrows the role",
+			claims: jwt.MapClaims{"role": "admin", "scope": "voyages:read events:read"},
+			scopes: []domain.Scope{domain.ScopeVoyagesRead, domain.ScopeEventsRead},
+		},
+		{
+			name:   "scopes beyond the role are dropped",
+			claims: jwt.MapClaims{"role": "read-only", "scope": "voyages:read users:manage platform"},
+			scopes: []domain.Scope{domain.ScopeVoyagesRead},
+		},
+		{
+			name:   "scopes array beyond the role is dropped",
+			claims: jwt.MapClaims{"role": "vessel", "ship_id": "S1", "scopes": []interface{}{"tracks:ingest", "api-keys:manage"}},
+			scopes: []domain.Scope{domain.ScopeTracksIngest},
+		},
+		{
+			name:   "admin may list platform",
+			claims: jwt.MapClaims{"role": "admin", "scope": "platform voyages:read"},
+			scopes: []domain.Scope{domain.ScopePlatform, domain.ScopeVoyagesRead},
+		},
+		{
+			name:   "no role grants nothing",
+			claims: jwt.MapClaims{"scope": "voyages:read users:manage"},
+			scopes: []domain.Scope{},
+		},
+		{
+			name:   "unknown role",
+			claims: jwt.MapClaims{"role": "captain"},
+			err:    true,
+		},
+		{
+			name:   "vessel without ship",
+			claims: jwt.MapClaims{"role": "vessel"},
+			err:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := principalFromClaims(tt.claims)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("principalFromClaims = %+v, want an error", principal)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("principalFromClaims: %v", err)
+			}
+			if fmt.Sprint(principal.Scopes) != fmt.Sprint(tt.scopes) {
+				t.Fatalf("Scopes = %v, want %v", principal.Scopes, tt.scopes)
+			}
+			if tt.claims["role"] == "read-only" && principal.HasScope(domain.ScopeUsersManage) {
+				t.Fatal("read-only token was granted users:manage")
+			}
+		})
+	}
+}
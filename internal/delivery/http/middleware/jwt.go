@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// KeyProvider returns the public key a token's kid header refers to
+type KeyProvider interface {
+	Key(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// JWTConfig controls which bearer tokens are accepted
+type JWTConfig struct {
+	Secret   string        // HMAC secret; empty disables HS256 tokens
+	Keys     KeyProvider   // RS256/ES256 public keys, e.g. a JWKS; nil disables them
+	Issuer   string        // required "iss", if set
+	Audience string        // required "aud" entry, if set
+	Leeway   time.Duration // clock skew allowed when checking exp, nbf and iat
+}
+
+// parse validates a token's signature and standard claims. Tokens must
+// carry an expiry.
+func (cfg JWTConfig) parse(ctx context.Context, tokenString string) (*jwt.Token, error) {
+	var methods []string
+	if cfg.Secret != "" {
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if cfg.Keys != nil {
+		methods = append(methods, "RS256", "ES256")
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithLeeway(cfg.Leeway),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+
+	return jwt.NewParser(opts...).Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodHMAC:
+			return []byte(cfg.Secret), nil
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+			kid, _ := token.Header["kid"].(string)
+			key, err := cfg.Keys.Key(ctx, kid)
+			if err != nil {
+				return nil, err
+			}
+			return matchKey(token.Method, key)
+		default:
+			return nil, errors.New("invalid signing method")
+		}
+	})
+}
+
+// matchKey rejects keys of a different type than the token's algorithm
+func matchKey(method jwt.SigningMethod, key crypto.PublicKey) (crypto.PublicKey, error) {
+	switch method.(type) {
+	case *jwt.SigningMethodRSA:
+		if k, ok := key.(*rsa.PublicKey); ok {
+			return k, nil
+		}
+	case *jwt.SigningMethodECDSA:
+		if k, ok := key.(*ecdsa.PublicKey); ok {
+			return k, nil
+		}
+	}
+	return nil, errors.New("key does not match signing method")
+}
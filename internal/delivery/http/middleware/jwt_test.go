@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chats/sailing-backend/pkg/jwks"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// newJWKSServer serves the public half of key under kid
+func newJWKSServer(t *testing.T, kid string, key *rsa.PrivateKey) *httptest.Server {
+	t.Helper()
+	set, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": kid,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(set)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAuthMiddlewareWithJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := newJWKSServer(t, "key-1", key)
+	keys, err := jwks.New(context.Background(), jwks.Config{URL: server.URL, Client: server.Client()})
+	if err != nil {
+		t.Fatalf("jwks.New: %v", err)
+	}
+
+	app := fiber.New()
+	app.Use(AuthMiddleware(AuthConfig{JWT: JWTConfig{
+		Keys:     keys,
+		Issuer:   "https://issuer.example",
+		Audience: "sailing-api",
+	}}))
+	app.Get("/", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) })
+
+	claims := func(exp time.Duration) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"sub":       "device-1",
+			"iss":       "https://issuer.example",
+			"aud":       "sailing-api",
+			"iat":       time.Now().Unix(),
+			"role":      "operator",
+			"tenant_id": "acme",
+		}
+		if exp != 0 {
+			c["exp"] = time.Now().Add(exp).Unix()
+		}
+		return c
+	}
+	sign := func(method jwt.SigningMethod, kid string, claims jwt.MapClaims, signingKey interface{}) string {
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		s, err := token.SignedString(signingKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	wrongAudience := claims(time.Hour)
+	wrongAudience["aud"] = "other-api"
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"valid", sign(jwt.SigningMethodRS256, "key-1", claims(time.Hour), key), fiber.StatusNoContent},
+		{"single key without kid", sign(jwt.SigningMethodRS256, "", claims(time.Hour), key), fiber.StatusNoContent},
+		{"no expiry", sign(jwt.SigningMethodRS256, "key-1", claims(0), key), fiber.StatusUnauthorized},
+		{"expired", sign(jwt.SigningMethodRS256, "key-1", claims(-time.Hour), key), fiber.StatusUnauthorized},
+		{"wrong audience", sign(jwt.SigningMethodRS256, "key-1", wrongAudience, key), fiber.StatusUnauthorized},
+		{"unknown kid", sign(jwt.SigningMethodRS256, "key-2", claims(time.Hour), key), fiber.StatusUnauthorized},
+		{"signed by another key", sign(jwt.SigningMethodRS256, "key-1", claims(time.Hour), otherKey), fiber.StatusUnauthorized},
+		{"algorithm not matching key", sign(jwt.SigningMethodES256, "key-1", claims(time.Hour), ecKey), fiber.StatusUnauthorized},
+		{"HMAC without a secret", sign(jwt.SigningMethodHS256, "key-1", claims(time.Hour), []byte("secret")), fiber.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("status %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/chats/sailing-backend/pkg/ratelimit"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// Route groups with separate rate limits
+const (
+	RateLimitRead   = "read"
+	RateLimitWrite  = "write"
+	RateLimitIngest = "ingest"
+	RateLimitAuth   = "auth" // token endpoints, limited per client IP
+)
+
+// RateLimitConfig holds the request quotas per window of the limiter
+type RateLimitConfig struct {
+	Limiter *ratelimit.Limiter
+	// PrincipalLimits is each principal's quota per route group. Requests
+	// without a principal are limited per client IP.
+	PrincipalLimits map[string]int
+	// TenantLimits is the quota per route group shared by all of a tenant's
+	// principals; groups without one are not limited per tenant
+	TenantLimits map[string]int
+	// Overrides replace the quota of one principal or tenant, keyed by
+	// "<subject>/<group>" or "tenant:<id>/<group>"
+	Overrides map[string]int
+}
+
+// rateLimitBucket is one counter a request is checked against
+type rateLimitBucket struct {
+	key   string
+	limit int
+}
+
+// RateLimit limits requests to a route group per principal and per tenant.
+// It must run after AuthMiddleware, except on routes without authentication.
+// Responses carry X-RateLimit-* headers for the tightest of the counters.
+func RateLimit(group string, cfg RateLimitConfig) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var buckets []rateLimitBucket
+
+		principal := domain.PrincipalFromContext(c.UserContext())
+		if principal == nil {
+			buckets = append(buckets, cfg.bucket("ip:"+c.IP(), group, cfg.PrincipalLimits))
+		} else {
+			buckets = append(buckets, cfg.bucket(principal.Subject, group, cfg.PrincipalLimits))
+			if principal.TenantID != "" {
+				buckets = append(buckets, cfg.bucket("tenant:"+principal.TenantID, group, cfg.TenantLimits))
+			}
+		}
+
+		var tightest *ratelimit.Result
+		for _, bucket := range buckets {
+			if bucket.limit <= 0 {
+				continue
+			}
+
+			result, err := cfg.Limiter.Allow(c.UserContext(), group+":"+bucket.key, bucket.limit)
+			if err != nil {
+				// An unavailable store must not take the API down with it
+				log.Warn().Err(err).Str("key", bucket.key).Msg("Rate limit check failed; allowing request")
+				continue
+			}
+			if tightest == nil || !result.Allowed || (tightest.Allowed && result.Remaining < tightest.Remaining) {
+				tightest = &result
+			}
+			if !result.Allowed {
+				break
+			}
+		}
+		if tightest == nil {
+			return c.Next()
+		}
+
+		resetIn := int(time.Until(tightest.Reset).Round(time.Second).Seconds())
+		c.Set("X-RateLimit-Limit", strconv.Itoa(tightest.Limit))
+		c.Set("X-RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+		c.Set("X-RateLimit-Reset", strconv.Itoa(resetIn))
+
+		if !tightest.Allowed {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(resetIn))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error": "rate limit exceeded",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// bucket returns the counter of owner in group, with its override if any
+func (cfg RateLimitConfig) bucket(owner, group string, limits map[string]int) rateLimitBucket {
+	limit, ok := cfg.Overrides[owner+"/"+group]
+	if !ok {
+		limit = limits[group]
+	}
+	return rateLimitBucket{key: owner, limit: limit}
+}
+
+// failedAuthSweepInterval is how often expired blocks are removed
+const failedAuthSweepInterval = time.Minute
+
+// FailedAuthLimit blocks a client IP for the rest of the window once more
+// than limit of its requests in that window were rejected with 401. It must
+// run before AuthMiddleware, so blocked clients cost no credential lookups.
+// Blocks are kept per instance; with a shared store each instance blocks the
+// IP at its next failure after the shared count passes limit. A limit of 0
+// disables it.
+func FailedAuthLimit(limiter *ratelimit.Limiter, limit int) fiber.Handler {
+	if limit <= 0 {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+
+	var (
+		mu      sync.Mutex
+		blocked = make(map[string]time.Time) // block end by client IP
+		sweepAt time.Time
+	)
+
+	return func(c *fiber.Ctx) error {
+		ip := c.IP()
+		now := time.Now()
+
+		mu.Lock()
+		until, ok := blocked[ip]
+		if ok && !now.Before(until) {
+			delete(blocked, ip)
+			ok = false
+		}
+		mu.Unlock()
+		if ok {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(until.Sub(now).Round(time.Second).Seconds())))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error": "too many failed authentication attempts",
+			})
+		}
+
+		err := c.Next()
+		if c.Response().StatusCode() != fiber.StatusUnauthorized {
+			return err
+		}
+
+		result, lerr := limiter.Allow(c.UserContext(), "auth-failures:ip:"+ip, limit)
+		if lerr != nil {
+			log.Warn().Err(lerr).Str("ip", ip).Msg("Failed to count failed authentication")
+			return err
+		}
+		if !result.Allowed {
+			mu.Lock()
+			blocked[ip] = result.Reset
+			if now.After(sweepAt) {
+				for k, until := range blocked {
+					if !now.Before(until) {
+						delete(blocked, k)
+					}
+				}
+				sweepAt = now.Add(failedAuthSweepInterval)
+			}
+			mu.Unlock()
+			log.Warn().Str("ip", ip).Time("until", result.Reset).Msg("Blocking client after repeated failed authentication")
+		}
+		return err
+	}
+}
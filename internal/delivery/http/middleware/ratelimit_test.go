@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chats/sailing-backend/pkg/ratelimit"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestFailedAuthLimitClientIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		trusted []string // the test client connects from 0.0.0.0
+		want    []int    // statuses of requests from 203.0.113.1, 203.0.113.1, 203.0.113.2
+	}{
+		{
+			name:    "header from a trusted proxy",
+			trusted: []string{"0.0.0.0/8"},
+			want:    []int{fiber.StatusUnauthorized, fiber.StatusUnauthorized, fiber.StatusUnauthorized},
+		},
+		{
+			name:    "header from another peer is ignored",
+			trusted: []string{"10.0.0.1"},
+			want:    []int{fiber.StatusUnauthorized, fiber.StatusUnauthorized, fiber.StatusTooManyRequests},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := ratelimit.NewMemoryStore()
+			defer store.Close()
+
+			app := fiber.New(fiber.Config{
+				ProxyHeader:             "X-Real-IP",
+				EnableTrustedProxyCheck: true,
+				TrustedProxies:          tt.trusted,
+				EnableIPValidation:      true,
+			})
+			app.Use(FailedAuthLimit(ratelimit.New(store, time.Hour), 1))
+			app.Get("/", func(c *fiber.Ctx) error {
+				return c.SendStatus(fiber.StatusUnauthorized)
+			})
+
+			for i, ip := range []string{"203.0.113.1", "203.0.113.1", "203.0.113.2"} {
+				req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+				req.Header.Set("X-Real-IP", ip)
+				resp, err := app.Test(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp.StatusCode != tt.want[i] {
+					t.Errorf("request %d from %s: status %d, want %d", i+1, ip, resp.StatusCode, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -1,43 +1,61 @@
 package middleware
 
 import (
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/helmet"
-	"github.com/gofiber/fiber/v2/middleware/limiter"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 )
 
+// SecurityConfig holds the CORS policy and security headers
+type SecurityConfig struct {
+	// AllowOrigins lists the origins browsers may call the API from, such as
+	// "https://app.example.com" or "https://*.example.com". When empty, no
+	// CORS headers are sent and browsers only allow same-origin requests.
+	AllowOrigins     []string
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           time.Duration // how long browsers may cache preflight results
+
+	// Strict-Transport-Security, sent on HTTPS requests when HSTSMaxAge is set
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+
+	ContentSecurityPolicy string
+}
+
 // SetupSecurityMiddlewares configures all security middlewares
-func SetupSecurityMiddlewares(app *fiber.App) {
+func SetupSecurityMiddlewares(app *fiber.App, cfg SecurityConfig) {
 	// Recover middleware to handle panics
 	app.Use(recover.New(recover.Config{
 		EnableStackTrace: true,
 	}))
 
 	// Helmet middleware for security headers
-	app.Use(helmet.New())
-
-	// CORS middleware
-	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization, X-API-Key",
+	app.Use(helmet.New(helmet.Config{
+		HSTSMaxAge:            int(cfg.HSTSMaxAge.Seconds()),
+		HSTSExcludeSubdomains: !cfg.HSTSIncludeSubdomains,
+		HSTSPreloadEnabled:    cfg.HSTSPreload,
+		ContentSecurityPolicy: cfg.ContentSecurityPolicy,
 	}))
 
-	// Rate limiting
-	app.Use(limiter.New(limiter.Config{
-		Max:        100,
-		Expiration: 1 * time.Minute,
-		KeyGenerator: func(c *fiber.Ctx) string {
-			return c.IP()
-		},
-		LimitReached: func(c *fiber.Ctx) error {
-			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
-				"error": "rate limit exceeded",
-			})
-		},
+	// CORS middleware; fiber allows every origin when none are listed, so it
+	// is left out instead
+	if len(cfg.AllowOrigins) == 0 {
+		return
+	}
+	app.Use(cors.New(cors.Config{
+		AllowOrigins:     strings.Join(cfg.AllowOrigins, ","),
+		AllowMethods:     strings.Join(cfg.AllowMethods, ","),
+		AllowHeaders:     strings.Join(cfg.AllowHeaders, ","),
+		ExposeHeaders:    strings.Join(cfg.ExposeHeaders, ","),
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           int(cfg.MaxAge.Seconds()),
 	}))
 }
@@ -0,0 +1,114 @@
+package mqtt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+)
+
+// positionPayload is one position published by a tracker
+type positionPayload struct {
+	Latitude  *float64        `json:"latitude"`
+	Longitude *float64        `json:"longitude"`
+	Speed     float64         `json:"speed"`              // knots
+	Heading   float64         `json:"heading"`            // degrees
+	Altitude  float64         `json:"altitude,omitempty"` // meters
+	Timestamp json.RawMessage `json:"timestamp,omitempty"`
+}
+
+// deadLetter is published for a message that can never be stored
+type deadLetter struct {
+	Topic      string    `json:"topic"`
+	Error      string    `json:"error"`
+	ReceivedAt time.Time `json:"received_at"`
+	Payload    string    `json:"payload"`
+}
+
+// decodePositions parses a payload holding one position object, or an
+// array of them from trackers that buffer while offline
+func decodePositions(payload []byte) ([]*domain.GPSTrack, error) {
+	payload = bytes.TrimSpace(payload)
+
+	var positions []positionPayload
+	if len(payload) > 0 && payload[0] == '[' {
+		if err := json.Unmarshal(payload, &positions); err != nil {
+			return nil, err
+		}
+	} else {
+		var p positionPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return nil, err
+		}
+		positions = []positionPayload{p}
+	}
+
+	if len(positions) == 0 {
+		return nil, errors.New("no positions in payload")
+	}
+
+	tracks := make([]*domain.GPSTrack, len(positions))
+	for i, p := range positions {
+		track, err := p.track()
+		if err != nil {
+			return nil, fmt.Errorf("position %d: %w", i, err)
+		}
+		tracks[i] = track
+	}
+
+	return tracks, nil
+}
+
+func (p *positionPayload) track() (*domain.GPSTrack, error) {
+	if p.Latitude == nil || p.Longitude == nil {
+		return nil, errors.New("latitude and longitude are required")
+	}
+	if math.Abs(*p.Latitude) > 90 || math.Abs(*p.Longitude) > 180 {
+		return nil, errors.New("latitude or longitude out of range")
+	}
+
+	ts, err := parseTimestamp(p.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.GPSTrack{
+		Location: domain.Location{
+			Latitude:  *p.Latitude,
+			Longitude: *p.Longitude,
+		},
+		Speed:     p.Speed,
+		Heading:   p.Heading,
+		Altitude:  p.Altitude,
+		Timestamp: ts,
+	}, nil
+}
+
+// parseTimestamp accepts an RFC 3339 string or Unix seconds; a missing
+// timestamp is left zero so the time of ingestion is used
+func parseTimestamp(raw json.RawMessage) (time.Time, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return time.Time{}, nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		ts, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return time.Time{}, errors.New("timestamp must be RFC 3339 or Unix seconds")
+		}
+		return ts, nil
+	}
+
+	secs, err := strconv.ParseFloat(string(raw), 64)
+	if err != nil {
+		return time.Time{}, errors.New("timestamp must be RFC 3339 or Unix seconds")
+	}
+	whole, frac := math.Modf(secs)
+	return time.Unix(int64(whole), int64(frac*1e9)).UTC(), nil
+}
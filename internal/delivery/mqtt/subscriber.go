@@ -0,0 +1,386 @@
+// Package mqtt ingests GPS positions that fleet trackers publish to an MQTT
+// broker
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/chats/sailing-backend/internal/usecase"
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/rs/zerolog/log"
+)
+
+// Retry delays while storage is failing
+const (
+	minRetryDelay = 500 * time.Millisecond
+	maxRetryDelay = 30 * time.Second
+)
+
+// Config configures the MQTT subscriber
+type Config struct {
+	BrokerURL string // e.g. "tcp://localhost:1883"
+	ClientID  string // must be stable so the broker keeps the session across restarts
+	Username  string
+	Password  string
+
+	// Topic is the subscription filter; the level matched by its single
+	// "+" wildcard is taken as the ship ID, e.g. "fleet/+/position"
+	Topic string
+
+	// DeadLetterTopic receives messages that can never be stored, such as
+	// malformed payloads or positions for a ship without an active voyage.
+	// Empty only logs them.
+	DeadLetterTopic string
+
+	BatchSize      int           // positions written per batch
+	FlushInterval  time.Duration // maximum time a position waits in a partial batch
+	QueueSize      int           // buffered messages; keep above the broker's in-flight limit
+	WriteTimeout   time.Duration // bounds a voyage lookup or batch write
+	VoyageCacheTTL time.Duration // how long a ship's active voyage is remembered
+}
+
+// queuedMessage is a received message awaiting persistence and acknowledgement
+type queuedMessage struct {
+	msg        paho.Message
+	shipID     string
+	tracks     []*domain.GPSTrack
+	receivedAt time.Time
+
+	stored    bool
+	rejection error // why the message can never be stored
+}
+
+// Subscriber receives positions at QoS 1 and writes them through the GPS
+// track use case.
+//
+// Messages are acknowledged in the order they arrive, as MQTT requires, and
+// only once their positions are stored, so the broker redelivers anything
+// lost to a crash when the session resumes. Storage failures are retried
+// until they succeed or the subscriber shuts down, which holds back the
+// broker while the database is unavailable. Messages that can never be
+// stored are published to the dead-letter topic and acknowledged, so they
+// do not take up the broker's in-flight window.
+type Subscriber struct {
+	cfg             Config
+	gpsTrackUseCase *usecase.GPSTrackUseCase
+	voyages         *usecase.ActiveVoyageCache
+
+	client    paho.Client
+	shipLevel int
+
+	// publish sends a dead letter; set by Start, replaced in tests
+	publish func(topic string, payload []byte) error
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan *queuedMessage
+	abort  chan struct{} // closed when Shutdown gives up waiting
+	writer sync.WaitGroup
+}
+
+// NewSubscriber creates a new MQTT subscriber
+func NewSubscriber(cfg Config, voyageUseCase *usecase.VoyageUseCase, gpsTrackUseCase *usecase.GPSTrackUseCase) *Subscriber {
+	if cfg.ClientID == "" {
+		cfg.ClientID = "sailing-backend"
+	}
+	if cfg.Topic == "" {
+		cfg.Topic = "fleet/+/position"
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 10000
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = 30 * time.Second
+	}
+	if cfg.VoyageCacheTTL <= 0 {
+		cfg.VoyageCacheTTL = 30 * time.Second
+	}
+
+	return &Subscriber{
+		cfg:             cfg,
+		gpsTrackUseCase: gpsTrackUseCase,
+		voyages:         usecase.NewActiveVoyageCache(voyageUseCase, cfg.VoyageCacheTTL),
+		queue:           make(chan *queuedMessage, cfg.QueueSize),
+		abort:           make(chan struct{}),
+	}
+}
+
+// Start begins connecting to the broker. Connection and reconnection happen
+// in the background, so an unavailable broker does not block startup.
+func (s *Subscriber) Start() error {
+	shipLevel, err := shipLevel(s.cfg.Topic)
+	if err != nil {
+		return err
+	}
+	s.shipLevel = shipLevel
+	if s.cfg.DeadLetterTopic != "" && topicMatches(s.cfg.Topic, s.cfg.DeadLetterTopic) {
+		return errors.New("mqtt: dead-letter topic must not match the subscription topic")
+	}
+
+	opts := paho.NewClientOptions().
+		AddBroker(s.cfg.BrokerURL).
+		SetClientID(s.cfg.ClientID).
+		SetUsername(s.cfg.Username).
+		SetPassword(s.cfg.Password).
+		SetCleanSession(false).
+		SetAutoAckDisabled(true).
+		SetOrderMatters(true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(5 * time.Second).
+		SetMaxReconnectInterval(time.Minute).
+		SetOnConnectHandler(s.subscribe).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			log.Warn().Err(err).Msg("MQTT connection lost, reconnecting")
+		})
+	s.client = paho.NewClient(opts)
+	s.publish = func(topic string, payload []byte) error {
+		token := s.client.Publish(topic, 1, false, payload)
+		if !token.WaitTimeout(s.cfg.WriteTimeout) {
+			return errors.New("mqtt: timed out publishing dead letter")
+		}
+		return token.Error()
+	}
+
+	s.writer.Add(1)
+	go s.writeTracks()
+
+	s.client.Connect()
+	log.Info().Str("broker", s.cfg.BrokerURL).Str("topic", s.cfg.Topic).Msg("MQTT subscriber started")
+
+	return nil
+}
+
+// Shutdown unsubscribes, writes and acknowledges everything already queued,
+// then disconnects. Messages still unwritten when ctx is done are left
+// unacknowledged for the broker to redeliver.
+func (s *Subscriber) Shutdown(ctx context.Context) error {
+	if s.client.IsConnectionOpen() {
+		s.client.Unsubscribe(s.cfg.Topic).WaitTimeout(5 * time.Second)
+	}
+
+	s.mu.Lock()
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.writer.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		close(s.abort)
+		err = ctx.Err()
+	}
+
+	s.client.Disconnect(250)
+	return err
+}
+
+// subscribe (re)subscribes after every successful connection
+func (s *Subscriber) subscribe(client paho.Client) {
+	token := client.Subscribe(s.cfg.Topic, 1, s.handle)
+	if token.Wait() && token.Error() != nil {
+		log.Error().Err(token.Error()).Str("topic", s.cfg.Topic).Msg("MQTT subscribe failed")
+		return
+	}
+	log.Info().Str("topic", s.cfg.Topic).Msg("MQTT subscribed")
+}
+
+// handle decodes a message and queues it for the writer, in the order the
+// broker sent it. Paho calls it on its receive loop, so it does no I/O; it
+// only waits if the queue is full, which the broker's in-flight limit
+// prevents when QueueSize is above it.
+func (s *Subscriber) handle(_ paho.Client, msg paho.Message) {
+	m := &queuedMessage{msg: msg, receivedAt: time.Now()}
+
+	levels := strings.Split(msg.Topic(), "/")
+	if s.shipLevel < len(levels) {
+		m.shipID = levels[s.shipLevel]
+		m.tracks, m.rejection = decodePositions(msg.Payload())
+	} else {
+		m.rejection = errors.New("topic has no ship ID level")
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		// Unacknowledged, so the broker redelivers it after restart
+		return
+	}
+	s.queue <- m
+}
+
+// writeTracks drains the queue, writing positions in batches of BatchSize
+// or every FlushInterval, whichever comes first
+func (s *Subscriber) writeTracks() {
+	defer s.writer.Done()
+
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	var batch []*queuedMessage
+	size := 0
+	for {
+		select {
+		case m, ok := <-s.queue:
+			if !ok {
+				s.flush(batch)
+				return
+			}
+			batch = append(batch, m)
+			size += len(m.tracks)
+			if size >= s.cfg.BatchSize {
+				if !s.flush(batch) {
+					return
+				}
+				batch, size = nil, 0
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				if !s.flush(batch) {
+					return
+				}
+				batch, size = nil, 0
+			}
+		}
+	}
+}
+
+// flush stores a batch, retrying storage failures with backoff, then
+// dead-letters the messages that were rejected and acknowledges every
+// message in order. It returns false if shutdown interrupted the retries,
+// leaving the batch and everything after it unacknowledged.
+func (s *Subscriber) flush(batch []*queuedMessage) bool {
+	if len(batch) == 0 {
+		return true
+	}
+
+	delay := minRetryDelay
+	for {
+		err := s.store(batch)
+		if err == nil {
+			break
+		}
+
+		log.Error().Err(err).Dur("retry_in", delay).Msg("Failed to write MQTT GPS tracks")
+		select {
+		case <-s.abort:
+			return false
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+
+	for _, m := range batch {
+		if m.rejection != nil {
+			s.deadLetter(m)
+		}
+		m.msg.Ack()
+	}
+	return true
+}
+
+// store resolves the voyages of the batch's messages and writes their
+// positions, marking each message stored or rejected. Messages handled by
+// an earlier attempt are skipped. An error means storage failed and the
+// batch should be tried again.
+func (s *Subscriber) store(batch []*queuedMessage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.WriteTimeout)
+	defer cancel()
+
+	var pending []*queuedMessage
+	var tracks []*domain.GPSTrack
+	for _, m := range batch {
+		if m.stored || m.rejection != nil {
+			continue
+		}
+
+		voyageID, err := s.voyages.VoyageID(ctx, m.shipID)
+		if err != nil {
+			if !usecase.IsRejected(err) {
+				return err
+			}
+			m.rejection = err
+			continue
+		}
+		for _, track := range m.tracks {
+			track.VoyageID = voyageID
+		}
+
+		pending = append(pending, m)
+		tracks = append(tracks, m.tracks...)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	err := s.gpsTrackUseCase.CreateGPSTracksBatch(ctx, tracks, usecase.IngestOptions{})
+	if err == nil {
+		for _, m := range pending {
+			m.stored = true
+		}
+		log.Debug().Int("count", len(tracks)).Msg("MQTT GPS tracks written")
+		return nil
+	}
+	if !usecase.IsRejected(err) {
+		return err
+	}
+
+	// Some position does not fit its voyage; write messages one at a time
+	// to find which
+	log.Warn().Err(err).Int("count", len(tracks)).Msg("MQTT batch rejected, writing messages individually")
+	for _, m := range pending {
+		err := s.gpsTrackUseCase.CreateGPSTracksBatch(ctx, m.tracks, usecase.IngestOptions{})
+		switch {
+		case err == nil:
+			m.stored = true
+		case usecase.IsRejected(err):
+			m.rejection = err
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// deadLetter records a message that can never be stored
+func (s *Subscriber) deadLetter(m *queuedMessage) {
+	logEvent := log.Warn().Err(m.rejection).Str("topic", m.msg.Topic()).Str("ship_id", m.shipID)
+	if s.cfg.DeadLetterTopic == "" {
+		logEvent.Msg("MQTT message rejected")
+		return
+	}
+
+	payload, err := json.Marshal(deadLetter{
+		Topic:      m.msg.Topic(),
+		Error:      m.rejection.Error(),
+		ReceivedAt: m.receivedAt,
+		Payload:    string(m.msg.Payload()),
+	})
+	if err == nil {
+		err = s.publish(s.cfg.DeadLetterTopic, payload)
+	}
+	if err != nil {
+		logEvent.AnErr("dead_letter_error", err).Msg("MQTT message rejected and could not be dead-lettered")
+		return
+	}
+	logEvent.Msg("MQTT message rejected and dead-lettered")
+}
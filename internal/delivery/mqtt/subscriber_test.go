@@ -0,0 +1,417 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/chats/sailing-backend/internal/usecase"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+// memoryStore backs the voyage and GPS track use cases. Batch writes fail
+// while failures is positive.
+type memoryStore struct {
+	domain.VoyageRepository
+	domain.GPSTrackRepository
+	domain.OutboxRepository
+
+	mu       sync.Mutex
+	voyages  map[string]*domain.Voyage // by ship ID
+	tracks   []*domain.GPSTrack
+	failures int
+}
+
+func newMemoryStore(shipIDs ...string) *memoryStore {
+	m := &memoryStore{voyages: make(map[string]*domain.Voyage)}
+	for _, shipID := range shipIDs {
+		m.voyages[shipID] = &domain.Voyage{
+			VoyageID:      "V-" + shipID,
+			ShipID:        shipID,
+			Status:        domain.VoyageStatusInProgress,
+			DepartureTime: time.Now().Add(-time.Hour),
+		}
+	}
+	return m
+}
+
+func (m *memoryStore) GetActiveVoyageByShipID(ctx context.Context, shipID string) (*domain.Voyage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v, ok := m.voyages[shipID]; ok {
+		return v, nil
+	}
+	return nil, domain.ErrVoyageNotFound
+}
+
+func (m *memoryStore) GetVoyageByVoyageID(ctx context.Context, voyageID string) (*domain.Voyage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, v := range m.voyages {
+		if v.VoyageID == voyageID {
+			return v, nil
+		}
+	}
+	return nil, domain.ErrVoyageNotFound
+}
+
+func (m *memoryStore) TransactionalWrites(ctx context.Context) bool {
+	return true
+}
+
+func (m *memoryStore) CreateGPSTracksBatch(ctx context.Context, tracks []*domain.GPSTrack) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.failures > 0 {
+		m.failures--
+		return errors.New("connection refused")
+	}
+	m.tracks = append(m.tracks, tracks...)
+	return nil
+}
+
+func (m *memoryStore) AddEvents(ctx context.Context, events []*domain.Event) error {
+	return nil
+}
+
+func (m *memoryStore) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (m *memoryStore) storedTracks() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.tracks)
+}
+
+func newTestSubscriber(store *memoryStore, cfg Config) *Subscriber {
+	voyageUseCase := usecase.NewVoyageUseCase(store, nil, store, store, store)
+	gpsTrackUseCase := usecase.NewGPSTrackUseCase(store, store, store, store)
+	s := NewSubscriber(cfg, voyageUseCase, gpsTrackUseCase)
+	s.shipLevel, _ = shipLevel(s.cfg.Topic)
+	return s
+}
+
+// testMessage is a paho.Message recording its acknowledgement
+type testMessage struct {
+	id      uint16
+	topic   string
+	payload []byte
+	acks    *[]uint16
+	mu      *sync.Mutex
+}
+
+func (m *testMessage) Duplicate() bool   { return false }
+func (m *testMessage) Qos() byte         { return 1 }
+func (m *testMessage) Retained() bool    { return false }
+func (m *testMessage) Topic() string     { return m.topic }
+func (m *testMessage) MessageID() uint16 { return m.id }
+func (m *testMessage) Payload() []byte   { return m.payload }
+func (m *testMessage) Ack() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	*m.acks = append(*m.acks, m.id)
+}
+
+// received lists messages as a tracker would publish them
+type received struct {
+	topic   string
+	payload string
+}
+
+// queueMessages passes messages through the subscriber's handler and
+// returns the queued batch and the recorded acknowledgements
+func queueMessages(s *Subscriber, messages []received) ([]*queuedMessage, *[]uint16, *sync.Mutex) {
+	acks := &[]uint16{}
+	mu := &sync.Mutex{}
+	for i, r := range messages {
+		s.handle(nil, &testMessage{id: uint16(i + 1), topic: r.topic, payload: []byte(r.payload), acks: acks, mu: mu})
+	}
+
+	batch := make([]*queuedMessage, 0, len(messages))
+	for range messages {
+		batch = append(batch, <-s.queue)
+	}
+	return batch, acks, mu
+}
+
+var position = `{"latitude": 13.75, "longitude": 100.5, "speed": 12}`
+
+func TestFlushAcknowledgesInOrderAndDeadLetters(t *testing.T) {
+	store := newMemoryStore("SHIP001", "SHIP002")
+	s := newTestSubscriber(store, Config{DeadLetterTopic: "fleet/dead-letter"})
+
+	var deadLetters []deadLetter
+	s.publish = func(topic string, payload []byte) error {
+		var dl deadLetter
+		if err := json.Unmarshal(payload, &dl); err != nil {
+			t.Fatalf("dead letter is not JSON: %v", err)
+		}
+		deadLetters = append(deadLetters, dl)
+		return nil
+	}
+
+	batch, acks, _ := queueMessages(s, []received{
+		{"fleet/SHIP001/position", position},
+		{"fleet/SHIP001/position", `{"latitude": 91, "longitude": 0}`},
+		{"fleet/UNKNOWN/position", position},
+		{"fleet/SHIP002/position", "[" + position + "," + position + "]"},
+		{"fleet/SHIP002/position", `not json`},
+	})
+
+	if !s.flush(batch) {
+		t.Fatal("flush was interrupted")
+	}
+
+	if want := []uint16{1, 2, 3, 4, 5}; !equalIDs(*acks, want) {
+		t.Errorf("acknowledged %v, want %v", *acks, want)
+	}
+	if got := store.storedTracks(); got != 3 {
+		t.Errorf("stored %d tracks, want 3", got)
+	}
+	if len(deadLetters) != 3 {
+		t.Fatalf("dead-lettered %d messages, want 3", len(deadLetters))
+	}
+	if deadLetters[1].Topic != "fleet/UNKNOWN/position" || deadLetters[1].Payload != position {
+		t.Errorf("dead letter = %+v, want the UNKNOWN ship's message", deadLetters[1])
+	}
+}
+
+func TestFlushRetriesStorageFailures(t *testing.T) {
+	store := newMemoryStore("SHIP001")
+	store.failures = 2
+	s := newTestSubscriber(store, Config{})
+
+	batch, acks, _ := queueMessages(s, []received{
+		{"fleet/SHIP001/position", position},
+		{"fleet/SHIP001/position", position},
+	})
+
+	if !s.flush(batch) {
+		t.Fatal("flush was interrupted")
+	}
+	if want := []uint16{1, 2}; !equalIDs(*acks, want) {
+		t.Errorf("acknowledged %v, want %v", *acks, want)
+	}
+	if got := store.storedTracks(); got != 2 {
+		t.Errorf("stored %d tracks, want 2", got)
+	}
+}
+
+func TestFlushLeavesMessagesUnacknowledgedOnShutdown(t *testing.T) {
+	store := newMemoryStore("SHIP001")
+	store.failures = 1000
+	s := newTestSubscriber(store, Config{})
+
+	batch, acks, mu := queueMessages(s, []received{
+		{"fleet/SHIP001/position", position},
+		{"fleet/SHIP001/bad", `{}`},
+	})
+
+	time.AfterFunc(100*time.Millisecond, func() { close(s.abort) })
+	if s.flush(batch) {
+		t.Fatal("flush finished although storage kept failing")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(*acks) != 0 {
+		t.Errorf("acknowledged %v, want none", *acks)
+	}
+}
+
+func TestTopicMatches(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"fleet/+/position", "fleet/SHIP001/position", true},
+		{"fleet/+/position", "fleet/dead-letter", false},
+		{"fleet/+/position", "fleet/SHIP001/position/extra", false},
+		{"fleet/#", "fleet/dead-letter", true},
+		{"#", "anything/at/all", true},
+		{"fleet/+", "fleet/dead-letter", true},
+	}
+
+	for _, tt := range tests {
+		if got := topicMatches(tt.filter, tt.topic); got != tt.want {
+			t.Errorf("topicMatches(%q, %q) = %v, want %v", tt.filter, tt.topic, got, tt.want)
+		}
+	}
+}
+
+// testBroker is just enough of an MQTT 3.1.1 broker for one subscriber:
+// it accepts the connection and subscription, then publishes at QoS 1 and
+// records the order of PUBACKs and the messages the client publishes
+type testBroker struct {
+	t        *testing.T
+	listener net.Listener
+
+	subscribed chan struct{}
+	pubacks    chan uint16
+	published  chan *packets.PublishPacket
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &testBroker{
+		t:          t,
+		listener:   listener,
+		subscribed: make(chan struct{}),
+		pubacks:    make(chan uint16, 100),
+		published:  make(chan *packets.PublishPacket, 100),
+	}
+	go b.serve()
+	t.Cleanup(func() { _ = listener.Close() })
+	return b
+}
+
+func (b *testBroker) url() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+func (b *testBroker) serve() {
+	conn, err := b.listener.Accept()
+	if err != nil {
+		return
+	}
+	b.mu.Lock()
+	b.conn = conn
+	b.mu.Unlock()
+	defer conn.Close()
+
+	for {
+		packet, err := packets.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+
+		switch p := packet.(type) {
+		case *packets.ConnectPacket:
+			b.write(packets.NewControlPacket(packets.Connack))
+		case *packets.SubscribePacket:
+			suback := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			suback.MessageID = p.MessageID
+			suback.ReturnCodes = []byte{1}
+			b.write(suback)
+			close(b.subscribed)
+		case *packets.PubackPacket:
+			b.pubacks <- p.MessageID
+		case *packets.PublishPacket:
+			b.published <- p
+			if p.Qos == 1 {
+				puback := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				puback.MessageID = p.MessageID
+				b.write(puback)
+			}
+		case *packets.PingreqPacket:
+			b.write(packets.NewControlPacket(packets.Pingresp))
+		case *packets.UnsubscribePacket:
+			unsuback := packets.NewControlPacket(packets.Unsuback).(*packets.UnsubackPacket)
+			unsuback.MessageID = p.MessageID
+			b.write(unsuback)
+		case *packets.DisconnectPacket:
+			return
+		}
+	}
+}
+
+func (b *testBroker) write(packet packets.ControlPacket) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := packet.Write(b.conn); err != nil {
+		b.t.Errorf("broker write: %v", err)
+	}
+}
+
+func (b *testBroker) publish(id uint16, topic, payload string) {
+	publish := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+	publish.Qos = 1
+	publish.MessageID = id
+	publish.TopicName = topic
+	publish.Payload = []byte(payload)
+	b.write(publish)
+}
+
+func TestSubscriberAgainstBroker(t *testing.T) {
+	broker := newTestBroker(t)
+	store := newMemoryStore("SHIP001")
+	s := newTestSubscriber(store, Config{
+		BrokerURL:       broker.url(),
+		ClientID:        "subscriber-test",
+		Topic:           "fleet/+/position",
+		DeadLetterTopic: "fleet/dead-letter",
+		BatchSize:       100,
+		FlushInterval:   20 * time.Millisecond,
+	})
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-broker.subscribed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscriber did not subscribe")
+	}
+
+	broker.publish(1, "fleet/SHIP001/position", position)
+	broker.publish(2, "fleet/SHIP002/position", position) // no active voyage
+	broker.publish(3, "fleet/SHIP001/position", `garbage`)
+	broker.publish(4, "fleet/SHIP001/position", position)
+
+	var pubacks []uint16
+	for len(pubacks) < 4 {
+		select {
+		case id := <-broker.pubacks:
+			pubacks = append(pubacks, id)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received PUBACKs %v, want 4", pubacks)
+		}
+	}
+	if want := []uint16{1, 2, 3, 4}; !equalIDs(pubacks, want) {
+		t.Errorf("PUBACK order %v, want %v", pubacks, want)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case p := <-broker.published:
+			if p.TopicName != "fleet/dead-letter" {
+				t.Errorf("published to %q, want the dead-letter topic", p.TopicName)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("dead letter not published")
+		}
+	}
+	if got := store.storedTracks(); got != 2 {
+		t.Errorf("stored %d tracks, want 2", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+}
+
+func equalIDs(a, b []uint16) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,39 @@
+package mqtt
+
+import (
+	"errors"
+	"strings"
+)
+
+// shipLevel returns the index of the single "+" level of a subscription
+// topic, which holds the ship ID
+func shipLevel(topic string) (int, error) {
+	level := -1
+	for i, l := range strings.Split(topic, "/") {
+		if l == "+" {
+			if level >= 0 {
+				return 0, errors.New("mqtt: topic must contain exactly one + wildcard")
+			}
+			level = i
+		}
+	}
+	if level < 0 {
+		return 0, errors.New("mqtt: topic must contain a + wildcard for the ship ID")
+	}
+	return level, nil
+}
+
+// topicMatches reports whether topic matches the subscription filter
+func topicMatches(filter, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	for i, f := range filterLevels {
+		if f == "#" {
+			return true
+		}
+		if i >= len(topicLevels) || (f != "+" && f != topicLevels[i]) {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
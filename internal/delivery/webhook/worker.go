@@ -0,0 +1,185 @@
+// Package webhook delivers queued voyage events to subscribed partner endpoints
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/chats/sailing-backend/internal/usecase"
+	"github.com/chats/sailing-backend/pkg/webhook"
+	"github.com/rs/zerolog/log"
+)
+
+// userAgent identifies webhook requests to receivers
+const userAgent = "sailing-backend-webhooks/1.0"
+
+// Config configures the delivery worker
+type Config struct {
+	Workers      int           // concurrent deliveries
+	PollInterval time.Duration // wait between polls when the queue is empty
+	Timeout      time.Duration // per-request timeout
+	// LeasePadding is how much longer than Timeout a claimed delivery stays
+	// hidden from other workers, covering signing and recording the result
+	LeasePadding time.Duration
+
+	// AllowPrivateNetworks lets the default client connect to loopback,
+	// private and link-local addresses
+	AllowPrivateNetworks bool
+
+	// Client sends the requests; defaults to a client with Timeout that
+	// only connects to public addresses. Tests can point it at an
+	// httptest.Server.
+	Client *http.Client
+}
+
+// Worker claims due deliveries from the queue and POSTs them, signed, to
+// their subscription's URL
+type Worker struct {
+	cfg            Config
+	webhookUseCase *usecase.WebhookUseCase
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewWorker creates a new delivery worker
+func NewWorker(cfg Config, webhookUseCase *usecase.WebhookUseCase) *Worker {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 2
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 2 * time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.LeasePadding <= 0 {
+		cfg.LeasePadding = 30 * time.Second
+	}
+	if cfg.Client == nil && cfg.AllowPrivateNetworks {
+		cfg.Client = &http.Client{Timeout: cfg.Timeout}
+	}
+	if cfg.Client == nil {
+		cfg.Client = webhook.NewClient(cfg.Timeout)
+	}
+
+	return &Worker{
+		cfg:            cfg,
+		webhookUseCase: webhookUseCase,
+		stop:           make(chan struct{}),
+	}
+}
+
+// Start launches the delivery goroutines
+func (w *Worker) Start() {
+	w.wg.Add(w.cfg.Workers)
+	for i := 0; i < w.cfg.Workers; i++ {
+		go w.run()
+	}
+	log.Info().Int("workers", w.cfg.Workers).Msg("Webhook worker started")
+}
+
+// Shutdown stops claiming deliveries and waits for in-flight ones to finish.
+// Deliveries interrupted by ctx are retried once their lease expires.
+func (w *Worker) Shutdown(ctx context.Context) error {
+	close(w.stop)
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *Worker) run() {
+	defer w.wg.Done()
+
+	// A claimed delivery stays hidden from other workers for a while
+	// longer than one attempt can take
+	lease := w.cfg.Timeout + w.cfg.LeasePadding
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+
+		delivery, sub, err := w.webhookUseCase.ClaimDelivery(context.Background(), lease)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to claim webhook delivery")
+		}
+		if delivery == nil {
+			select {
+			case <-w.stop:
+				return
+			case <-time.After(w.cfg.PollInterval):
+			}
+			continue
+		}
+
+		statusCode, err := w.send(delivery, sub)
+		if err := w.webhookUseCase.RecordAttempt(context.Background(), delivery, statusCode, err); err != nil {
+			log.Error().Err(err).Str("delivery_id", delivery.ID.Hex()).Msg("Failed to record webhook attempt")
+			continue
+		}
+
+		logEvent := log.Debug()
+		if delivery.Status == domain.DeliveryStatusDead {
+			logEvent = log.Warn()
+		}
+		logEvent.
+			Str("delivery_id", delivery.ID.Hex()).
+			Str("url", sub.URL).
+			Str("event", delivery.Event.Type).
+			Int("attempt", delivery.Attempts).
+			Int("status_code", statusCode).
+			Str("status", delivery.Status).
+			Msg("Webhook delivery attempted")
+	}
+}
+
+// send POSTs the delivery's event and returns the response status code
+func (w *Worker) send(delivery *domain.WebhookDelivery, sub *domain.WebhookSubscription) (int, error) {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), w.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(webhook.HeaderEvent, delivery.Event.Type)
+	req.Header.Set(webhook.HeaderDelivery, delivery.ID.Hex())
+	req.Header.Set(webhook.HeaderSignature, webhook.Sign(sub.Secret, time.Now(), body))
+
+	resp, err := w.cfg.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Drain a little of the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,282 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/chats/sailing-backend/internal/usecase"
+	"github.com/chats/sailing-backend/pkg/webhook"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryWebhooks implements domain.WebhookRepository and
+// domain.WebhookDeliveryRepository in memory
+type memoryWebhooks struct {
+	mu         sync.Mutex
+	subs       map[primitive.ObjectID]*domain.WebhookSubscription
+	deliveries map[primitive.ObjectID]*domain.WebhookDelivery
+}
+
+func newMemoryWebhooks() *memoryWebhooks {
+	return &memoryWebhooks{
+		subs:       make(map[primitive.ObjectID]*domain.WebhookSubscription),
+		deliveries: make(map[primitive.ObjectID]*domain.WebhookDelivery),
+	}
+}
+
+func (m *memoryWebhooks) CreateSubscription(ctx context.Context, sub *domain.WebhookSubscription) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sub.ID = primitive.NewObjectID()
+	copied := *sub
+	m.subs[sub.ID] = &copied
+	return nil
+}
+
+func (m *memoryWebhooks) UpdateSubscription(ctx context.Context, sub *domain.WebhookSubscription) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	copied := *sub
+	m.subs[sub.ID] = &copied
+	return nil
+}
+
+func (m *memoryWebhooks) DeleteSubscription(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	oid, _ := primitive.ObjectIDFromHex(id)
+	delete(m.subs, oid)
+	return nil
+}
+
+func (m *memoryWebhooks) GetSubscriptionByID(ctx context.Context, id string) (*domain.WebhookSubscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	oid, _ := primitive.ObjectIDFromHex(id)
+	sub, ok := m.subs[oid]
+	if !ok {
+		return nil, domain.ErrWebhookNotFound
+	}
+	copied := *sub
+	return &copied, nil
+}
+
+func (m *memoryWebhooks) GetAllSubscriptions(ctx context.Context) ([]*domain.WebhookSubscription, error) {
+	return m.GetActiveSubscriptions(ctx)
+}
+
+func (m *memoryWebhooks) GetActiveSubscriptions(ctx context.Context) ([]*domain.WebhookSubscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var subs []*domain.WebhookSubscription
+	for _, sub := range m.subs {
+		copied := *sub
+		subs = append(subs, &copied)
+	}
+	return subs, nil
+}
+
+func (m *memoryWebhooks) CreateDeliveries(ctx context.Context, deliveries []*domain.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range deliveries {
+		d.ID = primitive.NewObjectID()
+		copied := *d
+		m.deliveries[d.ID] = &copied
+	}
+	return nil
+}
+
+func (m *memoryWebhooks) UpdateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	copied := *delivery
+	m.deliveries[delivery.ID] = &copied
+	return nil
+}
+
+func (m *memoryWebhooks) GetDeliveryByID(ctx context.Context, id string) (*domain.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	oid, _ := primitive.ObjectIDFromHex(id)
+	d, ok := m.deliveries[oid]
+	if !ok {
+		return nil, domain.ErrDeliveryNotFound
+	}
+	copied := *d
+	return &copied, nil
+}
+
+func (m *memoryWebhooks) GetDeliveries(ctx context.Context, status string, limit, offset int) ([]*domain.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []*domain.WebhookDelivery
+	for _, d := range m.deliveries {
+		if status == "" || d.Status == status {
+			copied := *d
+			list = append(list, &copied)
+		}
+	}
+	return list, nil
+}
+
+func (m *memoryWebhooks) ClaimDueDelivery(ctx context.Context, now time.Time, lease time.Duration) (*domain.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []*domain.WebhookDelivery
+	for _, d := range m.deliveries {
+		if d.Status == domain.DeliveryStatusPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	if len(due) == 0 {
+		return nil, nil
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	due[0].NextAttemptAt = now.Add(lease)
+	copied := *due[0]
+	return &copied, nil
+}
+
+// deliverTo subscribes url, queues a ping and runs a worker until the
+// delivery leaves the pending state or the test times out
+func deliverTo(t *testing.T, url string, policy usecase.WebhookPolicy) (*memoryWebhooks, *domain.WebhookDelivery, string) {
+	t.Helper()
+
+	repo := newMemoryWebhooks()
+	policy.AllowPrivateNetworks = true
+	uc := usecase.NewWebhookUseCase(repo, repo, policy)
+
+	sub := &domain.WebhookSubscription{URL: url, Active: true}
+	if err := uc.CreateSubscription(context.Background(), sub); err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+	queued, err := uc.Ping(context.Background(), sub.ID.Hex())
+	if err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+
+	worker := NewWorker(Config{Workers: 1, PollInterval: 5 * time.Millisecond, Timeout: time.Second, AllowPrivateNetworks: true}, uc)
+	worker.Start()
+	defer func() {
+		if err := worker.Shutdown(context.Background()); err != nil {
+			t.Errorf("Shutdown: %v", err)
+		}
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		delivery, err := repo.GetDeliveryByID(context.Background(), queued.ID.Hex())
+		if err != nil {
+			t.Fatalf("GetDeliveryByID: %v", err)
+		}
+		if delivery.Status != domain.DeliveryStatusPending {
+			return repo, delivery, sub.Secret
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("delivery still pending")
+	return nil, nil, ""
+}
+
+func TestWorkerDeliversSignedEvent(t *testing.T) {
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	requests := make(chan received, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- received{header: r.Header.Clone(), body: body}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	_, delivery, secret := deliverTo(t, server.URL, usecase.WebhookPolicy{})
+
+	if delivery.Status != domain.DeliveryStatusDelivered || delivery.Attempts != 1 || delivery.DeliveredAt == nil {
+		t.Fatalf("delivery = %+v, want delivered after one attempt", delivery)
+	}
+
+	req := <-requests
+	if got := req.header.Get(webhook.HeaderEvent); got != domain.EventWebhookPing {
+		t.Errorf("%s = %q, want %q", webhook.HeaderEvent, got, domain.EventWebhookPing)
+	}
+	if got := req.header.Get(webhook.HeaderDelivery); got != delivery.ID.Hex() {
+		t.Errorf("%s = %q, want %q", webhook.HeaderDelivery, got, delivery.ID.Hex())
+	}
+	if err := webhook.Verify(secret, req.header.Get(webhook.HeaderSignature), req.body, time.Minute, time.Now()); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+}
+
+func TestWorkerRetriesThenDeadLetters(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	_, delivery, _ := deliverTo(t, server.URL, usecase.WebhookPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     4 * time.Millisecond,
+	})
+
+	if delivery.Status != domain.DeliveryStatusDead {
+		t.Fatalf("status = %q, want %q", delivery.Status, domain.DeliveryStatusDead)
+	}
+	if delivery.Attempts != 3 || hits.Load() != 3 {
+		t.Errorf("attempts = %d, requests = %d, want 3 each", delivery.Attempts, hits.Load())
+	}
+	if delivery.LastStatusCode != http.StatusServiceUnavailable || delivery.LastError == "" {
+		t.Errorf("last status = %d, error = %q", delivery.LastStatusCode, delivery.LastError)
+	}
+}
+
+func TestWorkerRecoversAfterFailure(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	_, delivery, _ := deliverTo(t, server.URL, usecase.WebhookPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     4 * time.Millisecond,
+	})
+
+	if delivery.Status != domain.DeliveryStatusDelivered || delivery.Attempts != 2 || delivery.LastError != "" {
+		t.Fatalf("delivery = %+v, want delivered on the second attempt", delivery)
+	}
+}
+
+func TestWorkerRefusesPrivateAddressByDefault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached the loopback server")
+	}))
+	defer server.Close()
+
+	worker := NewWorker(Config{Timeout: time.Second}, nil)
+	statusCode, err := worker.send(&domain.WebhookDelivery{
+		ID:    primitive.NewObjectID(),
+		Event: &domain.Event{Type: domain.EventWebhookPing},
+	}, &domain.WebhookSubscription{URL: server.URL, Secret: "whsec_test"})
+	if err == nil {
+		t.Fatalf("send returned status %d, want an error", statusCode)
+	}
+}
@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKey is a managed credential for machine clients. Only a hash of the
+// secret is stored; the full key is shown once, when it is created or rotated.
+type APIKey struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	KeyID      string             `json:"key_id" bson:"key_id"` // public part of the key, used to look it up
+	TenantID   string             `json:"tenant_id,omitempty" bson:"tenant_id,omitempty"`
+	Name       string             `json:"name" bson:"name"`
+	Owner      string             `json:"owner,omitempty" bson:"owner,omitempty"`
+	Role       Role               `json:"role" bson:"role"`
+	Scopes     []Scope            `json:"scopes" bson:"scopes"`
+	ShipID     string             `json:"ship_id,omitempty" bson:"ship_id,omitempty"`
+	SecretHash string             `json:"-" bson:"secret_hash"` // hex SHA-256 of the secret part
+	ExpiresAt  *time.Time         `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+	LastUsedAt *time.Time         `json:"last_used_at,omitempty" bson:"last_used_at,omitempty"`
+	RevokedAt  *time.Time         `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+	CreatedBy  string             `json:"created_by,omitempty" bson:"created_by,omitempty"`
+	UpdatedBy  string             `json:"updated_by,omitempty" bson:"updated_by,omitempty"`
+	CreatedAt  time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt  time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// Usable reports whether the key may authenticate requests at now
+func (k *APIKey) Usable(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// Principal returns the identity requests made with the key act as
+func (k *APIKey) Principal() *Principal {
+	return &Principal{
+		Subject:  "api-key:" + k.KeyID,
+		Role:     k.Role,
+		Scopes:   k.Role.Limit(k.Scopes),
+		ShipID:   k.ShipID,
+		TenantID: k.TenantID,
+	}
+}
@@ -0,0 +1,167 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Audited actions
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+// Audited entity types
+const (
+	AuditEntityVoyage          = "voyage"
+	AuditEntityCheckpoint      = "checkpoint"
+	AuditEntityGPSTrack        = "gps_track"
+	AuditEntityWebhook         = "webhook"
+	AuditEntityWebhookDelivery = "webhook_delivery"
+	AuditEntityAPIKey          = "api_key"
+	AuditEntityUser            = "user"
+)
+
+// AuditEntry records one mutating request made by an authenticated principal
+type AuditEntry struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	TenantID  string             `json:"tenant_id,omitempty" bson:"tenant_id,omitempty"`
+	Principal string             `json:"principal" bson:"principal"` // the principal's subject
+	Role      Role               `json:"role,omitempty" bson:"role,omitempty"`
+	Method    string             `json:"method" bson:"method"`
+	Route     string             `json:"route" bson:"route"` // route pattern, e.g. /api/v1/webhooks/:id
+	Path      string             `json:"path" bson:"path"`
+	Status    int                `json:"status" bson:"status"`
+	IP        string             `json:"ip" bson:"ip"`
+	Changes   []AuditChange      `json:"changes" bson:"changes"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// AuditChange is one entity created, updated or deleted by a request, or,
+// for bulk writes, a count of the entities created for one voyage
+type AuditChange struct {
+	Action   string `json:"action" bson:"action"`
+	Entity   string `json:"entity" bson:"entity"`
+	EntityID string `json:"entity_id,omitempty" bson:"entity_id,omitempty"`
+	// VoyageID and Count summarise a bulk write in place of entity IDs
+	VoyageID string `json:"voyage_id,omitempty" bson:"voyage_id,omitempty"`
+	Count    int    `json:"count,omitempty" bson:"count,omitempty"`
+	// Diff holds the fields that changed, by their JSON name. Fields hidden
+	// from the API, such as secrets, never appear.
+	Diff map[string]FieldChange `json:"diff,omitempty" bson:"diff,omitempty"`
+}
+
+// FieldChange is the value of a field before and after a change
+type FieldChange struct {
+	Before interface{} `json:"before" bson:"before"`
+	After  interface{} `json:"after" bson:"after"`
+}
+
+// AuditFilter selects audit entries; empty fields match everything
+type AuditFilter struct {
+	Principal string
+	Entity    string
+	EntityID  string
+	Method    string
+	TimeRange TimeRange
+}
+
+// AuditTrail collects the changes made while serving a request
+type AuditTrail struct {
+	mu      sync.Mutex
+	changes []AuditChange
+}
+
+// Changes returns the changes recorded so far
+func (t *AuditTrail) Changes() []AuditChange {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]AuditChange{}, t.changes...)
+}
+
+type auditTrailKey struct{}
+
+// WithAuditTrail returns a copy of ctx whose changes are recorded in trail
+func WithAuditTrail(ctx context.Context, trail *AuditTrail) context.Context {
+	return context.WithValue(ctx, auditTrailKey{}, trail)
+}
+
+// RecordChange adds a change to the audit trail carried by ctx, if any.
+// before is nil for created entities and after is nil for deleted ones; pass
+// nil for both to record only the entity's ID.
+func RecordChange(ctx context.Context, action, entity, entityID string, before, after interface{}) {
+	trail, _ := ctx.Value(auditTrailKey{}).(*AuditTrail)
+	if trail == nil {
+		return
+	}
+
+	change := AuditChange{
+		Action:   action,
+		Entity:   entity,
+		EntityID: entityID,
+	}
+	if before != nil || after != nil {
+		change.Diff = Diff(before, after)
+	}
+
+	trail.mu.Lock()
+	trail.changes = append(trail.changes, change)
+	trail.mu.Unlock()
+}
+
+// RecordBulkCreate adds to the audit trail carried by ctx, if any, that
+// count entities were created for a voyage. Bulk writes are summarised so
+// entries stay small however many records a request carries.
+func RecordBulkCreate(ctx context.Context, entity, voyageID string, count int) {
+	trail, _ := ctx.Value(auditTrailKey{}).(*AuditTrail)
+	if trail == nil {
+		return
+	}
+
+	trail.mu.Lock()
+	trail.changes = append(trail.changes, AuditChange{
+		Action:   AuditActionCreate,
+		Entity:   entity,
+		VoyageID: voyageID,
+		Count:    count,
+	})
+	trail.mu.Unlock()
+}
+
+// Diff compares the JSON forms of two values field by field. Either value
+// may be nil.
+func Diff(before, after interface{}) map[string]FieldChange {
+	b, a := jsonFields(before), jsonFields(after)
+
+	diff := make(map[string]FieldChange)
+	for name, value := range b {
+		if !reflect.DeepEqual(value, a[name]) {
+			diff[name] = FieldChange{Before: value, After: a[name]}
+		}
+	}
+	for name, value := range a {
+		if _, ok := b[name]; !ok {
+			diff[name] = FieldChange{Before: nil, After: value}
+		}
+	}
+	return diff
+}
+
+func jsonFields(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]interface{}
+	json.Unmarshal(data, &fields)
+	return fields
+}
@@ -0,0 +1,170 @@
+package domain
+
+import "context"
+
+// Role is a named set of default scopes granted to a principal
+type Role string
+
+const (
+	RoleAdmin    Role = "admin"
+	RoleOperator Role = "operator"
+	RoleVessel   Role = "vessel"
+	RoleReadOnly Role = "read-only"
+)
+
+// Scope is a single permission checked by the API
+type Scope string
+
+const (
+	ScopeVoyagesRead    Scope = "voyages:read"
+	ScopeVoyagesWrite   Scope = "voyages:write"
+	ScopeTracksIngest   Scope = "tracks:ingest"
+	ScopeEventsRead     Scope = "events:read"
+	ScopeWebhooksManage Scope = "webhooks:manage"
+	ScopeAPIKeysManage  Scope = "api-keys:manage"
+	ScopeUsersManage    Scope = "users:manage"
+	ScopeAuditRead      Scope = "audit:read"
+	// ScopePlatform lets a principal without a tenant act on every tenant's data
+	ScopePlatform Scope = "platform"
+)
+
+// roleScopes lists the scopes each role grants when a credential does not
+// list its own
+var roleScopes = map[Role][]Scope{
+	RoleAdmin:    {ScopeVoyagesRead, ScopeVoyagesWrite, ScopeTracksIngest, ScopeEventsRead, ScopeWebhooksManage, ScopeAPIKeysManage, ScopeUsersManage, ScopeAuditRead},
+	RoleOperator: {ScopeVoyagesRead, ScopeVoyagesWrite, ScopeTracksIngest, ScopeEventsRead},
+	RoleVessel:   {ScopeVoyagesRead, ScopeVoyagesWrite, ScopeTracksIngest},
+	RoleReadOnly: {ScopeVoyagesRead, ScopeEventsRead},
+}
+
+// roleExtraScopes lists the scopes a role allows but only grants when a
+// credential lists them
+var roleExtraScopes = map[Role][]Scope{
+	RoleAdmin: {ScopePlatform},
+}
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	_, ok := roleScopes[r]
+	return ok
+}
+
+// Scopes returns the default scopes granted by the role
+func (r Role) Scopes() []Scope {
+	return append([]Scope(nil), roleScopes[r]...)
+}
+
+// Allows reports whether the role may be granted scope: a credential's
+// scopes are limited to those of its role
+func (r Role) Allows(scope Scope) bool {
+	for _, s := range roleScopes[r] {
+		if s == scope {
+			return true
+		}
+	}
+	for _, s := range roleExtraScopes[r] {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Limit returns the scopes the role allows, or the role's scopes if none
+// are listed
+func (r Role) Limit(scopes []Scope) []Scope {
+	if len(scopes) == 0 {
+		return r.Scopes()
+	}
+	allowed := make([]Scope, 0, len(scopes))
+	for _, scope := range scopes {
+		if r.Allows(scope) {
+			allowed = append(allowed, scope)
+		}
+	}
+	return allowed
+}
+
+// Valid reports whether s is a known scope
+func (s Scope) Valid() bool {
+	return RoleAdmin.Allows(s)
+}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Subject string  `json:"subject"`
+	Role    Role    `json:"role"`
+	Scopes  []Scope `json:"scopes"`
+	// ShipID restricts a vessel credential to the voyages of its own ship
+	ShipID string `json:"ship_id,omitempty"`
+	// TenantID restricts the principal to its organisation's data. Only
+	// platform principals may have none; see Platform.
+	TenantID string `json:"tenant_id,omitempty"`
+}
+
+// HasScope reports whether the principal was granted scope. Scopes listed
+// by the credential are honoured for every role, admins included; a
+// principal without any falls back to its role's scopes.
+func (p *Principal) HasScope(scope Scope) bool {
+	if p == nil {
+		return false
+	}
+	scopes := p.Scopes
+	if len(scopes) == 0 {
+		scopes = roleScopes[p.Role]
+	}
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Platform reports whether the principal may act on every tenant's data:
+// it has no tenant and was granted ScopePlatform
+func (p *Principal) Platform() bool {
+	return p != nil && p.TenantID == "" && p.HasScope(ScopePlatform)
+}
+
+// CanWriteShip reports whether the principal may write to voyages of shipID.
+// Principals without a ship, and internal callers without a principal, may
+// write to any ship.
+func (p *Principal) CanWriteShip(shipID string) bool {
+	return p == nil || p.ShipID == "" || p.ShipID == shipID
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// TenantFromContext returns the tenant data is limited to for the principal
+// carried by ctx, and whether it is limited at all. Internal callers
+// without a principal and platform principals are not limited; any other
+// principal is limited to its tenant, even if it has none.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	p := PrincipalFromContext(ctx)
+	if p == nil || p.Platform() {
+		return "", false
+	}
+	return p.TenantID, true
+}
+
+// SubjectFromContext returns the subject of the principal carried by ctx,
+// or "" for internal callers
+func SubjectFromContext(ctx context.Context) string {
+	if p := PrincipalFromContext(ctx); p != nil {
+		return p.Subject
+	}
+	return ""
+}
+
+// PrincipalFromContext returns the principal carried by ctx, or nil for
+// internal callers such as the feed listener
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
@@ -6,17 +6,31 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Voyage statuses
+const (
+	VoyageStatusInProgress = "in_progress"
+	VoyageStatusCompleted  = "completed"
+	VoyageStatusCancelled  = "cancelled"
+)
+
 // Voyage represents a sailing voyage
 type Voyage struct {
 	ID            primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	TenantID      string             `json:"tenant_id,omitempty" bson:"tenant_id,omitempty"`
 	VoyageID      string             `json:"voyage_id" bson:"voyage_id"`
 	ShipID        string             `json:"ship_id" bson:"ship_id"`
 	ShipName      string             `json:"ship_name" bson:"ship_name"`
+	MMSI          string             `json:"mmsi,omitempty" bson:"mmsi,omitempty"`
 	DeparturePort string             `json:"departure_port" bson:"departure_port"`
 	ArrivalPort   string             `json:"arrival_port,omitempty" bson:"arrival_port,omitempty"`
 	DepartureTime time.Time          `json:"departure_time" bson:"departure_time"`
 	ArrivalTime   *time.Time         `json:"arrival_time,omitempty" bson:"arrival_time,omitempty"`
-	Status        string             `json:"status" bson:"status"` // "in_progress", "completed", "cancelled"
+	Status        string             `json:"status" bson:"status"`                               // "in_progress", "completed", "cancelled"
+	Destination   string             `json:"destination,omitempty" bson:"destination,omitempty"` // reported by AIS
+	ETA           *time.Time         `json:"eta,omitempty" bson:"eta,omitempty"`                 // reported by AIS
+	Draught       float64            `json:"draught,omitempty" bson:"draught,omitempty"`         // meters, reported by AIS
+	CreatedBy     string             `json:"created_by,omitempty" bson:"created_by,omitempty"`   // subject of the principal
+	UpdatedBy     string             `json:"updated_by,omitempty" bson:"updated_by,omitempty"`
 	CreatedAt     time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt     time.Time          `json:"updated_at" bson:"updated_at"`
 }
@@ -24,23 +38,28 @@ type Voyage struct {
 // Checkpoint represents a checkpoint during a voyage
 type Checkpoint struct {
 	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	TenantID    string             `json:"tenant_id,omitempty" bson:"tenant_id,omitempty"` // copied from the voyage
 	VoyageID    string             `json:"voyage_id" bson:"voyage_id"`
 	Location    Location           `json:"location" bson:"location"`
 	Timestamp   time.Time          `json:"timestamp" bson:"timestamp"`
 	Description string             `json:"description,omitempty" bson:"description,omitempty"`
 	Weather     *WeatherInfo       `json:"weather,omitempty" bson:"weather,omitempty"`
+	CreatedBy   string             `json:"created_by,omitempty" bson:"created_by,omitempty"`
 	CreatedAt   time.Time          `json:"created_at" bson:"created_at"`
 }
 
 // GPSTrack represents GPS tracking data
 type GPSTrack struct {
 	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	TenantID  string             `json:"tenant_id,omitempty" bson:"tenant_id,omitempty"` // copied from the voyage
 	VoyageID  string             `json:"voyage_id" bson:"voyage_id"`
+	ShipID    string             `json:"ship_id,omitempty" bson:"ship_id,omitempty"` // copied from the voyage
 	Location  Location           `json:"location" bson:"location"`
 	Speed     float64            `json:"speed" bson:"speed"`                           // knots
 	Heading   float64            `json:"heading" bson:"heading"`                       // degrees
 	Altitude  float64            `json:"altitude,omitempty" bson:"altitude,omitempty"` // meters
 	Timestamp time.Time          `json:"timestamp" bson:"timestamp"`
+	CreatedBy string             `json:"created_by,omitempty" bson:"created_by,omitempty"`
 	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
 }
 
@@ -65,3 +84,9 @@ type VoyageWithDetails struct {
 	Checkpoints []*Checkpoint `json:"checkpoints"`
 	GPSTracks   []*GPSTrack   `json:"gps_tracks"`
 }
+
+// TimeRange bounds a query by time; a zero From or To leaves that side open
+type TimeRange struct {
+	From time.Time
+	To   time.Time
+}
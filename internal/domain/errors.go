@@ -0,0 +1,28 @@
+package domain
+
+import "errors"
+
+// Errors returned by repositories and use cases that callers may want to
+// distinguish, e.g. to pick an HTTP status code
+var (
+	ErrVoyageNotFound           = errors.New("voyage not found")
+	ErrVoyageNotInProgress      = errors.New("voyage is not in progress")
+	ErrVoyageCancelled          = errors.New("voyage is cancelled")
+	ErrTimestampBeforeDeparture = errors.New("timestamp is before voyage departure time")
+	ErrTimestampAfterArrival    = errors.New("timestamp is after voyage arrival time")
+	ErrInvalidInput             = errors.New("invalid input")
+	ErrWebhookNotFound          = errors.New("webhook subscription not found")
+	ErrDeliveryNotFound         = errors.New("webhook delivery not found")
+	ErrInvalidWebhook           = errors.New("invalid webhook subscription")
+	ErrIngestQueueFull          = errors.New("ingestion queue is full, retry later")
+	ErrIngestQueueClosed        = errors.New("ingestion queue is shutting down")
+	ErrGPSTracksConverting      = errors.New("GPS track storage is being converted, retry shortly")
+	ErrShipNotPermitted         = errors.New("voyage belongs to another ship")
+	ErrAPIKeyNotFound           = errors.New("API key not found")
+	ErrInvalidAPIKey            = errors.New("invalid API key")
+	ErrUserNotFound             = errors.New("user not found")
+	ErrInvalidUser              = errors.New("invalid user")
+	ErrUserExists               = errors.New("username is already taken")
+	ErrInvalidGrant             = errors.New("invalid credentials or refresh token")
+	ErrRefreshTokenNotFound     = errors.New("refresh token not found")
+)
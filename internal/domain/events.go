@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types
+const (
+	EventVoyageDeparted    = "voyage.departed"
+	EventVoyageArrived     = "voyage.arrived"
+	EventCheckpointCreated = "checkpoint.created"
+	EventGPSTrackCreated   = "gps_track.created"
+)
+
+// Event is a notification that voyage state changed
+type Event struct {
+	Seq        int64           `json:"seq,omitempty" bson:"seq"` // position in the event log, assigned when persisted
+	ID         string          `json:"id" bson:"event_id"`
+	Type       string          `json:"type" bson:"type"`
+	TenantID   string          `json:"tenant_id,omitempty" bson:"tenant_id,omitempty"`
+	VoyageID   string          `json:"voyage_id" bson:"voyage_id"`
+	ShipID     string          `json:"ship_id" bson:"ship_id"`
+	Location   *Location       `json:"location,omitempty" bson:"location,omitempty"`
+	OccurredAt time.Time       `json:"occurred_at" bson:"occurred_at"`
+	Data       json.RawMessage `json:"data" bson:"data"` // the voyage, checkpoint or GPS track
+}
+
+// NewEvent creates an event about voyage carrying data as its payload
+func NewEvent(eventType string, voyage *Voyage, location *Location, data interface{}) (*Event, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Event{
+		ID:         uuid.New().String(),
+		Type:       eventType,
+		TenantID:   voyage.TenantID,
+		VoyageID:   voyage.VoyageID,
+		ShipID:     voyage.ShipID,
+		Location:   location,
+		OccurredAt: time.Now(),
+		Data:       payload,
+	}, nil
+}
+
+// EventPublisher delivers events to interested subscribers
+type EventPublisher interface {
+	Publish(ctx context.Context, events ...*Event)
+}
@@ -2,6 +2,9 @@ package domain
 
 import (
 	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // VoyageRepository defines the interface for voyage data operations
@@ -11,6 +14,9 @@ type VoyageRepository interface {
 	GetVoyageByID(ctx context.Context, id string) (*Voyage, error)
 	GetAllVoyages(ctx context.Context, limit, offset int) ([]*Voyage, error)
 	GetVoyageByVoyageID(ctx context.Context, voyageID string) (*Voyage, error)
+	StreamVoyages(ctx context.Context, tr TimeRange, fn func(*Voyage) error) error
+	GetActiveVoyageByShipID(ctx context.Context, shipID string) (*Voyage, error)
+	GetActiveVoyageByMMSI(ctx context.Context, mmsi string) (*Voyage, error)
 }
 
 // CheckpointRepository defines the interface for checkpoint data operations
@@ -18,11 +24,103 @@ type CheckpointRepository interface {
 	CreateCheckpoint(ctx context.Context, checkpoint *Checkpoint) error
 	CreateCheckpointsBatch(ctx context.Context, checkpoints []*Checkpoint) error
 	GetCheckpointsByVoyageID(ctx context.Context, voyageID string) ([]*Checkpoint, error)
+	StreamCheckpointsByVoyageID(ctx context.Context, voyageID string, tr TimeRange, fn func(*Checkpoint) error) error
 }
 
 // GPSTrackRepository defines the interface for GPS track data operations
 type GPSTrackRepository interface {
+	// TransactionalWrites reports whether tracks can be written in a
+	// transaction together with other writes
+	TransactionalWrites(ctx context.Context) bool
+	// CreateGPSTrack and CreateGPSTracksBatch skip tracks whose ID is
+	// already stored, so a failed write can be retried
 	CreateGPSTrack(ctx context.Context, track *GPSTrack) error
 	CreateGPSTracksBatch(ctx context.Context, tracks []*GPSTrack) error
 	GetGPSTracksByVoyageID(ctx context.Context, voyageID string) ([]*GPSTrack, error)
+	StreamGPSTracksByVoyageID(ctx context.Context, voyageID string, tr TimeRange, fn func(*GPSTrack) error) error
+}
+
+// EventRepository defines the interface for the persisted event log
+type EventRepository interface {
+	AppendEvents(ctx context.Context, events []*Event) error
+	StreamEventsAfter(ctx context.Context, seq int64, fn func(*Event) error) error
+	// LastEventSeq returns the highest sequence number in the log, or 0 if
+	// it is empty
+	LastEventSeq(ctx context.Context) (int64, error)
+}
+
+// WebhookRepository defines the interface for webhook subscription data operations
+type WebhookRepository interface {
+	CreateSubscription(ctx context.Context, sub *WebhookSubscription) error
+	UpdateSubscription(ctx context.Context, sub *WebhookSubscription) error
+	DeleteSubscription(ctx context.Context, id string) error
+	GetSubscriptionByID(ctx context.Context, id string) (*WebhookSubscription, error)
+	GetAllSubscriptions(ctx context.Context) ([]*WebhookSubscription, error)
+	GetActiveSubscriptions(ctx context.Context) ([]*WebhookSubscription, error)
+}
+
+// WebhookDeliveryRepository defines the interface for the webhook delivery queue
+type WebhookDeliveryRepository interface {
+	CreateDeliveries(ctx context.Context, deliveries []*WebhookDelivery) error
+	UpdateDelivery(ctx context.Context, delivery *WebhookDelivery) error
+	GetDeliveryByID(ctx context.Context, id string) (*WebhookDelivery, error)
+	GetDeliveries(ctx context.Context, status string, limit, offset int) ([]*WebhookDelivery, error)
+	// ClaimDueDelivery leases the oldest pending delivery due at now by
+	// pushing its next attempt to now+lease, or returns nil if none is due
+	ClaimDueDelivery(ctx context.Context, now time.Time, lease time.Duration) (*WebhookDelivery, error)
+}
+
+// Transactor runs a function inside a database transaction. Repository
+// calls made with the context passed to fn take part in the transaction.
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// OutboxRepository defines the interface for events awaiting dispatch
+type OutboxRepository interface {
+	AddEvents(ctx context.Context, events []*Event) error
+	GetPendingEvents(ctx context.Context, limit int) ([]*Event, error)
+	MarkDispatched(ctx context.Context, eventIDs []string) error
+	// AcquireRelayLease makes owner the only relay until ttl from now,
+	// renewing the lease if owner already holds it. It reports false while
+	// another owner's lease has not expired.
+	AcquireRelayLease(ctx context.Context, owner string, ttl time.Duration) (bool, error)
+	ReleaseRelayLease(ctx context.Context, owner string) error
+}
+
+// APIKeyRepository defines the interface for managed API key data access
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key *APIKey) error
+	UpdateAPIKey(ctx context.Context, key *APIKey) error
+	GetAPIKeyByID(ctx context.Context, id string) (*APIKey, error)
+	GetAPIKeyByKeyID(ctx context.Context, keyID string) (*APIKey, error)
+	GetAllAPIKeys(ctx context.Context) ([]*APIKey, error)
+	// TouchAPIKey saves only the key's LastUsedAt
+	TouchAPIKey(ctx context.Context, key *APIKey) error
+}
+
+// UserRepository defines the interface for user data access
+type UserRepository interface {
+	CreateUser(ctx context.Context, user *User) error
+	UpdateUser(ctx context.Context, user *User) error
+	GetUserByID(ctx context.Context, id string) (*User, error)
+	GetUserByUsername(ctx context.Context, username string) (*User, error)
+	GetAllUsers(ctx context.Context) ([]*User, error)
+}
+
+// RefreshTokenRepository defines the interface for issued refresh tokens
+type RefreshTokenRepository interface {
+	CreateRefreshToken(ctx context.Context, token *RefreshToken) error
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*RefreshToken, error)
+	// MarkRefreshTokenUsed sets UsedAt on an unused token and reports
+	// whether this call was the one that used it
+	MarkRefreshTokenUsed(ctx context.Context, id primitive.ObjectID, usedAt time.Time) (bool, error)
+	RevokeFamily(ctx context.Context, familyID string, revokedAt time.Time) error
+	RevokeUserTokens(ctx context.Context, userID primitive.ObjectID, revokedAt time.Time) error
+}
+
+// AuditRepository defines the interface for the audit log
+type AuditRepository interface {
+	InsertAuditEntries(ctx context.Context, entries []*AuditEntry) error
+	FindAuditEntries(ctx context.Context, filter AuditFilter, limit, offset int) ([]*AuditEntry, error)
 }
@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// User is a person who signs in with a username and password to obtain tokens
+type User struct {
+	ID           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	TenantID     string             `json:"tenant_id,omitempty" bson:"tenant_id,omitempty"`
+	Username     string             `json:"username" bson:"username"`
+	PasswordHash string             `json:"-" bson:"password_hash"` // bcrypt
+	Role         Role               `json:"role" bson:"role"`
+	Scopes       []Scope            `json:"scopes" bson:"scopes"`
+	ShipID       string             `json:"ship_id,omitempty" bson:"ship_id,omitempty"`
+	Disabled     bool               `json:"disabled" bson:"disabled"`
+	CreatedBy    string             `json:"created_by,omitempty" bson:"created_by,omitempty"`
+	UpdatedBy    string             `json:"updated_by,omitempty" bson:"updated_by,omitempty"`
+	CreatedAt    time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// Principal returns the identity the user's tokens act as
+func (u *User) Principal() *Principal {
+	return &Principal{
+		Subject:  "user:" + u.ID.Hex(),
+		Role:     u.Role,
+		Scopes:   u.Role.Limit(u.Scopes),
+		ShipID:   u.ShipID,
+		TenantID: u.TenantID,
+	}
+}
+
+// RefreshToken is an issued refresh token. Each use replaces it with a new
+// token in the same family; presenting a used token again revokes the
+// whole family, since it means the token was copied.
+type RefreshToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	TokenHash string             `bson:"token_hash"` // hex SHA-256 of the token
+	FamilyID  string             `bson:"family_id"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	UsedAt    *time.Time         `bson:"used_at,omitempty"`
+	RevokedAt *time.Time         `bson:"revoked_at,omitempty"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
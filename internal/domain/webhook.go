@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EventWebhookPing is sent to a subscription on request to check the receiver
+const EventWebhookPing = "webhook.ping"
+
+// Webhook delivery statuses
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusDelivered = "delivered"
+	DeliveryStatusDead      = "dead" // gave up after the maximum number of attempts
+)
+
+// WebhookSubscription is a partner endpoint notified of voyage events
+type WebhookSubscription struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	TenantID    string             `json:"tenant_id,omitempty" bson:"tenant_id,omitempty"` // only this tenant's events; empty for all
+	URL         string             `json:"url" bson:"url"`
+	EventTypes  []string           `json:"event_types" bson:"event_types"` // empty means all event types
+	Description string             `json:"description,omitempty" bson:"description,omitempty"`
+	Active      bool               `json:"active" bson:"active"`
+	Secret      string             `json:"-" bson:"secret"` // HMAC-SHA256 signing key, only shown on creation
+	CreatedBy   string             `json:"created_by,omitempty" bson:"created_by,omitempty"`
+	UpdatedBy   string             `json:"updated_by,omitempty" bson:"updated_by,omitempty"`
+	CreatedAt   time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// Wants reports whether the subscription should receive event
+func (s *WebhookSubscription) Wants(event *Event) bool {
+	if !s.Active {
+		return false
+	}
+	if s.TenantID != "" && s.TenantID != event.TenantID {
+		return false
+	}
+	if event.Type == EventWebhookPing || len(s.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range s.EventTypes {
+		if t == event.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one event queued for one subscription
+type WebhookDelivery struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	SubscriptionID primitive.ObjectID `json:"subscription_id" bson:"subscription_id"`
+	Event          *Event             `json:"event" bson:"event"`
+	Status         string             `json:"status" bson:"status"` // "pending", "delivered", "dead"
+	Attempts       int                `json:"attempts" bson:"attempts"`
+	NextAttemptAt  time.Time          `json:"next_attempt_at" bson:"next_attempt_at"`
+	LastStatusCode int                `json:"last_status_code,omitempty" bson:"last_status_code,omitempty"`
+	LastError      string             `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	DeliveredAt    *time.Time         `json:"delivered_at,omitempty" bson:"delivered_at,omitempty"`
+	CreatedAt      time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at" bson:"updated_at"`
+}
@@ -0,0 +1,108 @@
+// Package events fans domain events out to live subscribers
+package events
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/chats/sailing-backend/internal/domain"
+)
+
+// Broker is an in-memory publish/subscribe hub for domain events
+type Broker struct {
+	bufferSize int
+
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+// NewBroker creates a broker whose subscribers buffer up to bufferSize events
+func NewBroker(bufferSize int) *Broker {
+	if bufferSize <= 0 {
+		bufferSize = 256
+	}
+	return &Broker{
+		bufferSize: bufferSize,
+		subs:       make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscription receives the events matching its filter
+type Subscription struct {
+	events chan *domain.Event
+	filter atomic.Pointer[Filter]
+	lagged atomic.Bool
+	once   sync.Once
+}
+
+// Events returns the channel events are delivered on. It is closed when the
+// subscription ends, either by Unsubscribe or because the subscriber fell
+// too far behind.
+func (s *Subscription) Events() <-chan *domain.Event {
+	return s.events
+}
+
+// SetFilter replaces the subscription's filter
+func (s *Subscription) SetFilter(f *Filter) {
+	s.filter.Store(f)
+}
+
+// Lagged reports whether the subscription was closed because its buffer filled up
+func (s *Subscription) Lagged() bool {
+	return s.lagged.Load()
+}
+
+func (s *Subscription) close() {
+	s.once.Do(func() { close(s.events) })
+}
+
+// Subscribe registers a new subscription; a nil filter matches every event
+func (b *Broker) Subscribe(f *Filter) *Subscription {
+	s := &Subscription{events: make(chan *domain.Event, b.bufferSize)}
+	s.SetFilter(f)
+
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+
+	return s
+}
+
+// Unsubscribe removes a subscription and closes its channel
+func (b *Broker) Unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	delete(b.subs, s)
+	b.mu.Unlock()
+	s.close()
+}
+
+// Publish implements domain.EventPublisher. Delivery never blocks the
+// publisher: a subscriber whose buffer is full is disconnected so it can
+// reconnect rather than silently miss events.
+func (b *Broker) Publish(_ context.Context, events ...*domain.Event) {
+	var lagging []*Subscription
+
+	b.mu.RLock()
+	for s := range b.subs {
+		f := s.filter.Load()
+	deliver:
+		for _, e := range events {
+			if !f.Matches(e) {
+				continue
+			}
+			select {
+			case s.events <- e:
+			default:
+				lagging = append(lagging, s)
+				break deliver
+			}
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, s := range lagging {
+		s.lagged.Store(true)
+		b.Unsubscribe(s)
+	}
+}
@@ -0,0 +1,81 @@
+package events
+
+import "github.com/chats/sailing-backend/internal/domain"
+
+// BoundingBox is a longitude/latitude rectangle
+type BoundingBox struct {
+	MinLongitude float64 `json:"min_lon"`
+	MinLatitude  float64 `json:"min_lat"`
+	MaxLongitude float64 `json:"max_lon"`
+	MaxLatitude  float64 `json:"max_lat"`
+}
+
+// Contains reports whether loc lies inside the box. Boxes whose minimum
+// longitude exceeds the maximum wrap across the antimeridian.
+func (b *BoundingBox) Contains(loc domain.Location) bool {
+	if loc.Latitude < b.MinLatitude || loc.Latitude > b.MaxLatitude {
+		return false
+	}
+	if b.MinLongitude <= b.MaxLongitude {
+		return loc.Longitude >= b.MinLongitude && loc.Longitude <= b.MaxLongitude
+	}
+	return loc.Longitude >= b.MinLongitude || loc.Longitude <= b.MaxLongitude
+}
+
+// Filter selects events by tenant, type, voyage, ship and area. Empty
+// criteria match everything. The bounding box only applies to events that
+// carry a location, so voyage status changes are delivered to area
+// subscriptions too.
+type Filter struct {
+	TenantID  string
+	Types     map[string]bool
+	VoyageIDs map[string]bool
+	ShipIDs   map[string]bool
+	BBox      *BoundingBox
+}
+
+// NewFilter builds a filter from lists of accepted values
+func NewFilter(types, voyageIDs, shipIDs []string, bbox *BoundingBox) *Filter {
+	return &Filter{
+		Types:     toSet(types),
+		VoyageIDs: toSet(voyageIDs),
+		ShipIDs:   toSet(shipIDs),
+		BBox:      bbox,
+	}
+}
+
+// Matches reports whether e passes the filter; a nil filter matches everything
+func (f *Filter) Matches(e *domain.Event) bool {
+	if f == nil {
+		return true
+	}
+	if f.TenantID != "" && f.TenantID != e.TenantID {
+		return false
+	}
+	if len(f.Types) > 0 && !f.Types[e.Type] {
+		return false
+	}
+	if len(f.VoyageIDs) > 0 && !f.VoyageIDs[e.VoyageID] {
+		return false
+	}
+	if len(f.ShipIDs) > 0 && !f.ShipIDs[e.ShipID] {
+		return false
+	}
+	if f.BBox != nil && e.Location != nil && !f.BBox.Contains(*e.Location) {
+		return false
+	}
+	return true
+}
+
+func toSet(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		if v != "" {
+			set[v] = true
+		}
+	}
+	return set
+}
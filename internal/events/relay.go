@@ -0,0 +1,237 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/rs/zerolog/log"
+)
+
+// Relay dispatches events written to the transactional outbox. Each batch
+// is appended to the event log, handed to the leased publisher and only then
+// marked dispatched, so an event is delivered at least once even if the
+// process stops part way through.
+//
+// Every instance runs a relay, but only the one holding the outbox lease
+// dispatches, so events are not published once per instance and keep their
+// order. The others take over when the lease expires.
+//
+// The live publisher feeds clients connected to this instance, so every
+// relay hands it events by following the event log in sequence order,
+// whether or not it holds the lease.
+type Relay struct {
+	outbox    domain.OutboxRepository
+	eventLog  domain.EventRepository
+	live      domain.EventPublisher
+	leased    domain.EventPublisher
+	interval  time.Duration
+	batchSize int
+	leaseTTL  time.Duration
+
+	owner      string
+	leaseUntil time.Time // when the lease held by this relay expires; zero if not held
+
+	tailing bool  // whether tailSeq has been read from the event log
+	tailSeq int64 // last event handed to the live publisher
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewRelay creates a relay that polls outbox every interval while it holds
+// a lease of leaseTTL, publishing to leased, and follows eventLog every
+// interval, publishing to live
+func NewRelay(outbox domain.OutboxRepository, eventLog domain.EventRepository, live, leased domain.EventPublisher, interval time.Duration, batchSize int, leaseTTL time.Duration) *Relay {
+	if interval <= 0 {
+		interval = 250 * time.Millisecond
+	}
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	if leaseTTL <= 0 {
+		leaseTTL = 15 * time.Second
+	}
+
+	host, _ := os.Hostname()
+	return &Relay{
+		outbox:    outbox,
+		eventLog:  eventLog,
+		live:      live,
+		leased:    leased,
+		interval:  interval,
+		batchSize: batchSize,
+		leaseTTL:  leaseTTL,
+		owner:     fmt.Sprintf("%s/%d/%d", host, os.Getpid(), time.Now().UnixNano()),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start begins relaying in the background
+func (r *Relay) Start() {
+	go r.run()
+	log.Info().Dur("interval", r.interval).Msg("Outbox relay started")
+}
+
+// Shutdown stops polling after dispatching whatever is already in the
+// outbox and handing the live publisher what is in the event log
+func (r *Relay) Shutdown(ctx context.Context) error {
+	close(r.stop)
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Relay) run() {
+	defer close(r.done)
+
+	for {
+		r.poll()
+
+		select {
+		case <-r.stop:
+			r.poll()
+			r.release()
+			return
+		case <-time.After(r.interval):
+		}
+	}
+}
+
+// poll dispatches the outbox if this relay holds the lease, then hands the
+// live publisher the events logged since the last poll. The log position is
+// read before the first dispatch, so nothing this relay logs is skipped.
+func (r *Relay) poll() {
+	if !r.tailing {
+		seq, err := r.eventLog.LastEventSeq(context.Background())
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to read event log position")
+		} else {
+			r.tailSeq, r.tailing = seq, true
+		}
+	}
+
+	r.drain()
+
+	if r.tailing {
+		r.tail(context.Background())
+	}
+}
+
+// lead reports whether this relay holds the outbox lease, taking it if it
+// is free and renewing it once half of it has elapsed
+func (r *Relay) lead(ctx context.Context) bool {
+	if time.Until(r.leaseUntil) > r.leaseTTL/2 {
+		return true
+	}
+
+	wasLeader := !r.leaseUntil.IsZero()
+	until := time.Now().Add(r.leaseTTL)
+	ok, err := r.outbox.AcquireRelayLease(ctx, r.owner, r.leaseTTL)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to acquire outbox relay lease")
+		ok = false
+	}
+
+	switch {
+	case ok && !wasLeader:
+		log.Info().Msg("Outbox relay lease acquired, dispatching events")
+	case !ok && wasLeader:
+		log.Warn().Msg("Outbox relay lease lost, another instance dispatches events")
+	}
+
+	if !ok {
+		r.leaseUntil = time.Time{}
+		return false
+	}
+	r.leaseUntil = until
+	return true
+}
+
+// release gives up the lease so another instance can take over at once
+func (r *Relay) release() {
+	if r.leaseUntil.IsZero() {
+		return
+	}
+
+	// The outbox bounds the release with its query timeout
+	if err := r.outbox.ReleaseRelayLease(context.Background(), r.owner); err != nil {
+		log.Error().Err(err).Msg("Failed to release outbox relay lease")
+	}
+	r.leaseUntil = time.Time{}
+}
+
+// drain dispatches batches until the outbox is empty, an error occurs or
+// the lease is not held
+func (r *Relay) drain() {
+	for {
+		if !r.lead(context.Background()) {
+			return
+		}
+
+		n, err := r.dispatch(context.Background())
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to relay outbox events")
+			return
+		}
+		if n < r.batchSize {
+			return
+		}
+	}
+}
+
+// dispatch relays one batch and returns its size
+func (r *Relay) dispatch(ctx context.Context) (int, error) {
+	events, err := r.outbox.GetPendingEvents(ctx, r.batchSize)
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+
+	if err := r.eventLog.AppendEvents(ctx, events); err != nil {
+		return 0, err
+	}
+
+	r.leased.Publish(ctx, events...)
+
+	ids := make([]string, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+	if err := r.outbox.MarkDispatched(ctx, ids); err != nil {
+		return 0, err
+	}
+
+	return len(events), nil
+}
+
+// tail hands the live publisher the events logged after tailSeq, in batches
+func (r *Relay) tail(ctx context.Context) {
+	var batch []*domain.Event
+	publish := func() {
+		if len(batch) == 0 {
+			return
+		}
+		r.live.Publish(ctx, batch...)
+		r.tailSeq = batch[len(batch)-1].Seq
+		batch = nil
+	}
+
+	err := r.eventLog.StreamEventsAfter(ctx, r.tailSeq, func(event *domain.Event) error {
+		batch = append(batch, event)
+		if len(batch) == r.batchSize {
+			publish()
+		}
+		return nil
+	})
+	publish()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read event log")
+	}
+}
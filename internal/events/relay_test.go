@@ -0,0 +1,175 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+)
+
+// memoryOutbox is an outbox and event log shared by the relays of several
+// instances
+type memoryOutbox struct {
+	mu      sync.Mutex
+	pending []*domain.Event
+	logged  []*domain.Event
+	owner   string
+}
+
+func (o *memoryOutbox) AddEvents(_ context.Context, events []*domain.Event) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.pending = append(o.pending, events...)
+	return nil
+}
+
+func (o *memoryOutbox) GetPendingEvents(_ context.Context, limit int) ([]*domain.Event, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.pending) < limit {
+		limit = len(o.pending)
+	}
+	return append([]*domain.Event(nil), o.pending[:limit]...), nil
+}
+
+func (o *memoryOutbox) MarkDispatched(_ context.Context, ids []string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.pending = o.pending[len(ids):]
+	return nil
+}
+
+func (o *memoryOutbox) AcquireRelayLease(_ context.Context, owner string, _ time.Duration) (bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.owner == "" {
+		o.owner = owner
+	}
+	return o.owner == owner, nil
+}
+
+func (o *memoryOutbox) ReleaseRelayLease(_ context.Context, owner string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.owner == owner {
+		o.owner = ""
+	}
+	return nil
+}
+
+func (o *memoryOutbox) AppendEvents(_ context.Context, events []*domain.Event) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, event := range events {
+		event.Seq = int64(len(o.logged) + 1)
+		o.logged = append(o.logged, event)
+	}
+	return nil
+}
+
+func (o *memoryOutbox) StreamEventsAfter(_ context.Context, seq int64, fn func(*domain.Event) error) error {
+	o.mu.Lock()
+	logged := append([]*domain.Event(nil), o.logged[seq:]...)
+	o.mu.Unlock()
+
+	for _, event := range logged {
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *memoryOutbox) LastEventSeq(context.Context) (int64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return int64(len(o.logged)), nil
+}
+
+// recorder remembers the IDs of the events published to it
+type recorder struct {
+	ids []string
+}
+
+func (r *recorder) Publish(_ context.Context, events ...*domain.Event) {
+	for _, event := range events {
+		r.ids = append(r.ids, event.ID)
+	}
+}
+
+func TestRelayFeedsEveryInstance(t *testing.T) {
+	store := &memoryOutbox{}
+	store.logged = []*domain.Event{{ID: "old", Seq: 1}} // logged before the relays started
+
+	var live, webhooks [2]recorder
+	relays := [2]*Relay{
+		NewRelay(store, store, &live[0], &webhooks[0], time.Second, 2, time.Minute),
+		NewRelay(store, store, &live[1], &webhooks[1], time.Second, 2, time.Minute),
+	}
+	relays[0].poll() // takes the lease
+	relays[1].poll()
+
+	var want []string
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("e%d", i)
+		want = append(want, id)
+		if err := store.AddEvents(context.Background(), []*domain.Event{{ID: id}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The instance without the lease may poll first; it sees the events
+	// once the leader has logged them
+	relays[1].poll()
+	relays[0].poll()
+	relays[1].poll()
+
+	if fmt.Sprint(webhooks[0].ids) != fmt.Sprint(want) {
+		t.Errorf("leader webhooks = %v, want %v", webhooks[0].ids, want)
+	}
+	if len(webhooks[1].ids) != 0 {
+		t.Errorf("follower webhooks = %v, want none", webhooks[1].ids)
+	}
+	for i := range live {
+		if fmt.Sprint(live[i].ids) != fmt.Sprint(want) {
+			t.Errorf("relay %d live = %v, want %v", i, live[i].ids, want)
+		}
+	}
+	if len(store.pending) != 0 {
+		t.Errorf("%d events left in the outbox", len(store.pending))
+	}
+}
+
+func TestRelayFollowsLeaseHandover(t *testing.T) {
+	store := &memoryOutbox{}
+
+	var live, webhooks [2]recorder
+	relays := [2]*Relay{
+		NewRelay(store, store, &live[0], &webhooks[0], time.Second, 10, time.Minute),
+		NewRelay(store, store, &live[1], &webhooks[1], time.Second, 10, time.Minute),
+	}
+	relays[0].poll()
+	relays[1].poll()
+
+	_ = store.AddEvents(context.Background(), []*domain.Event{{ID: "a"}})
+	relays[0].poll()
+	relays[0].release()
+
+	_ = store.AddEvents(context.Background(), []*domain.Event{{ID: "b"}})
+	relays[1].poll() // takes over
+	relays[0].poll()
+
+	for i, want := range []string{"[a]", "[b]"} {
+		if got := fmt.Sprint(webhooks[i].ids); got != want {
+			t.Errorf("relay %d webhooks = %s, want %s", i, got, want)
+		}
+	}
+	for i := range live {
+		if got := fmt.Sprint(live[i].ids); got != "[a b]" {
+			t.Errorf("relay %d live = %s, want [a b]", i, got)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type apiKeyRepository struct {
+	collection *mongo.Collection
+	timeouts   Timeouts
+}
+
+// NewAPIKeyRepository creates a new API key repository
+func NewAPIKeyRepository(db *mongo.Database, timeouts Timeouts) domain.APIKeyRepository {
+	return &apiKeyRepository{
+		collection: db.Collection("api_keys"),
+		timeouts:   timeouts.withDefaults(),
+	}
+}
+
+func (r *apiKeyRepository) CreateAPIKey(ctx context.Context, key *domain.APIKey) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
+	defer cancel()
+
+	result, err := r.collection.InsertOne(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	key.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *apiKeyRepository) UpdateAPIKey(ctx context.Context, key *domain.APIKey) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
+	defer cancel()
+
+	update := bson.M{
+		"$set": bson.M{
+			"name":        key.Name,
+			"owner":       key.Owner,
+			"role":        key.Role,
+			"scopes":      key.Scopes,
+			"ship_id":     key.ShipID,
+			"secret_hash": key.SecretHash,
+			"expires_at":  key.ExpiresAt,
+			"revoked_at":  key.RevokedAt,
+			"updated_by":  key.UpdatedBy,
+			"updated_at":  key.UpdatedAt,
+		},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, withTenant(ctx, bson.M{"_id": key.ID}, tenantField), update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return domain.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+func (r *apiKeyRepository) GetAPIKeyByID(ctx context.Context, id string) (*domain.APIKey, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, domain.ErrAPIKeyNotFound
+	}
+
+	return r.findOne(ctx, bson.M{"_id": objectID})
+}
+
+func (r *apiKeyRepository) GetAPIKeyByKeyID(ctx context.Context, keyID string) (*domain.APIKey, error) {
+	return r.findOne(ctx, bson.M{"key_id": keyID})
+}
+
+func (r *apiKeyRepository) GetAllAPIKeys(ctx context.Context) ([]*domain.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Batch)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := r.collection.Find(ctx, withTenant(ctx, bson.M{}, tenantField), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	keys := []*domain.APIKey{}
+	if err = cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (r *apiKeyRepository) TouchAPIKey(ctx context.Context, key *domain.APIKey) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": key.ID}, bson.M{
+		"$set": bson.M{"last_used_at": key.LastUsedAt},
+	})
+	return err
+}
+
+func (r *apiKeyRepository) findOne(ctx context.Context, filter bson.M) (*domain.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
+	defer cancel()
+
+	var key domain.APIKey
+	err := r.collection.FindOne(ctx, withTenant(ctx, filter, tenantField)).Decode(&key)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, domain.ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+
+	return &key, nil
+}
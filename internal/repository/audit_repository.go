@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type auditRepository struct {
+	collection *mongo.Collection
+	timeouts   Timeouts
+}
+
+// NewAuditRepository creates a new audit log repository
+func NewAuditRepository(db *mongo.Database, timeouts Timeouts) domain.AuditRepository {
+	return &auditRepository{
+		collection: db.Collection("audit_log"),
+		timeouts:   timeouts.withDefaults(),
+	}
+}
+
+func (r *auditRepository) InsertAuditEntries(ctx context.Context, entries []*domain.AuditEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Batch)
+	defer cancel()
+
+	docs := make([]interface{}, len(entries))
+	for i, entry := range entries {
+		docs[i] = entry
+	}
+
+	// Entries carry their IDs, so when a retried batch was partly written
+	// before, the entries already stored fail as duplicates and the rest
+	// are still inserted
+	_, err := r.collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	if onlyDuplicateKeys(err) {
+		return nil
+	}
+	return err
+}
+
+func (r *auditRepository) FindAuditEntries(ctx context.Context, filter domain.AuditFilter, limit, offset int) ([]*domain.AuditEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Batch)
+	defer cancel()
+
+	query := bson.M{}
+	if filter.Principal != "" {
+		query["principal"] = filter.Principal
+	}
+	if filter.Method != "" {
+		query["method"] = filter.Method
+	}
+	// Entity and ID must match the same change
+	if filter.Entity != "" || filter.EntityID != "" {
+		change := bson.M{}
+		if filter.Entity != "" {
+			change["entity"] = filter.Entity
+		}
+		if filter.EntityID != "" {
+			change["entity_id"] = filter.EntityID
+		}
+		query["changes"] = bson.M{"$elemMatch": change}
+	}
+	if ts := timeRangeFilter(filter.TimeRange); ts != nil {
+		query["created_at"] = ts
+	}
+
+	opts := options.Find().
+		SetLimit(int64(limit)).
+		SetSkip(int64(offset)).
+		SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, withTenant(ctx, query, tenantField), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := []*domain.AuditEntry{}
+	if err = cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// duplicateKeyCode is the server error code for a unique index violation
+const duplicateKeyCode = 11000
+
+// onlyDuplicateKeys reports whether err is a bulk write error caused only
+// by duplicate keys
+func onlyDuplicateKeys(err error) bool {
+	var bwe mongo.BulkWriteException
+	if !errors.As(err, &bwe) || bwe.WriteConcernError != nil || len(bwe.WriteErrors) == 0 {
+		return false
+	}
+	for _, we := range bwe.WriteErrors {
+		if we.Code != duplicateKeyCode {
+			return false
+		}
+	}
+	return true
+}
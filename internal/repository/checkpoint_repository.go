@@ -2,27 +2,29 @@ package repository
 
 import (
 	"context"
-	"time"
 
 	"github.com/chats/sailing-backend/internal/domain"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type checkpointRepository struct {
 	collection *mongo.Collection
+	timeouts   Timeouts
 }
 
 // NewCheckpointRepository creates a new checkpoint repository
-func NewCheckpointRepository(db *mongo.Database) domain.CheckpointRepository {
+func NewCheckpointRepository(db *mongo.Database, timeouts Timeouts) domain.CheckpointRepository {
 	return &checkpointRepository{
 		collection: db.Collection("checkpoints"),
+		timeouts:   timeouts.withDefaults(),
 	}
 }
 
 func (r *checkpointRepository) CreateCheckpoint(ctx context.Context, checkpoint *domain.Checkpoint) error {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
 	defer cancel()
 
 	result, err := r.collection.InsertOne(ctx, checkpoint)
@@ -35,7 +37,7 @@ func (r *checkpointRepository) CreateCheckpoint(ctx context.Context, checkpoint
 }
 
 func (r *checkpointRepository) CreateCheckpointsBatch(ctx context.Context, checkpoints []*domain.Checkpoint) error {
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Batch)
 	defer cancel()
 
 	// Convert to []interface{} for InsertMany
@@ -58,10 +60,10 @@ func (r *checkpointRepository) CreateCheckpointsBatch(ctx context.Context, check
 }
 
 func (r *checkpointRepository) GetCheckpointsByVoyageID(ctx context.Context, voyageID string) ([]*domain.Checkpoint, error) {
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Batch)
 	defer cancel()
 
-	cursor, err := r.collection.Find(ctx, bson.M{"voyage_id": voyageID})
+	cursor, err := r.collection.Find(ctx, withTenant(ctx, bson.M{"voyage_id": voyageID}, tenantField))
 	if err != nil {
 		return nil, err
 	}
@@ -74,3 +76,34 @@ func (r *checkpointRepository) GetCheckpointsByVoyageID(ctx context.Context, voy
 
 	return checkpoints, nil
 }
+
+// StreamCheckpointsByVoyageID calls fn for each document of a voyage in timestamp order without
+// loading them all into memory. Iteration stops at the first error from fn.
+func (r *checkpointRepository) StreamCheckpointsByVoyageID(ctx context.Context, voyageID string, tr domain.TimeRange, fn func(*domain.Checkpoint) error) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Stream)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}})
+	filter := withTenant(ctx, bson.M{"voyage_id": voyageID}, tenantField)
+	if ts := timeRangeFilter(tr); ts != nil {
+		filter["timestamp"] = ts
+	}
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var checkpoint domain.Checkpoint
+		if err := cursor.Decode(&checkpoint); err != nil {
+			return err
+		}
+		if err := fn(&checkpoint); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
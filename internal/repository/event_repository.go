@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// eventSequenceID is the counters document holding the last event sequence number
+const eventSequenceID = "events"
+
+type eventRepository struct {
+	collection *mongo.Collection
+	counters   *mongo.Collection
+	timeouts   Timeouts
+}
+
+// NewEventRepository creates a new event log repository
+func NewEventRepository(db *mongo.Database, timeouts Timeouts) domain.EventRepository {
+	return &eventRepository{
+		collection: db.Collection("events"),
+		counters:   db.Collection("counters"),
+		timeouts:   timeouts.withDefaults(),
+	}
+}
+
+// AppendEvents assigns the next sequence numbers to events and stores them.
+// Events already in the log, matched by ID, keep their sequence number and
+// are not stored again, so a batch can safely be appended more than once.
+func (r *eventRepository) AppendEvents(ctx context.Context, events []*domain.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Batch)
+	defer cancel()
+
+	logged, err := r.loggedSeqs(ctx, events)
+	if err != nil {
+		return err
+	}
+
+	var fresh []*domain.Event
+	for _, event := range events {
+		if seq, ok := logged[event.ID]; ok {
+			event.Seq = seq
+		} else {
+			fresh = append(fresh, event)
+		}
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+
+	// Reserve a block of sequence numbers in one round trip
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After)
+	err = r.counters.FindOneAndUpdate(ctx,
+		bson.M{"_id": eventSequenceID},
+		bson.M{"$inc": bson.M{"seq": int64(len(fresh))}},
+		opts,
+	).Decode(&counter)
+	if err != nil {
+		return err
+	}
+
+	first := counter.Seq - int64(len(fresh)) + 1
+	docs := make([]interface{}, len(fresh))
+	for i, event := range fresh {
+		event.Seq = first + int64(i)
+		docs[i] = event
+	}
+
+	_, err = r.collection.InsertMany(ctx, docs)
+	return err
+}
+
+// loggedSeqs returns the sequence numbers of those events already in the log
+func (r *eventRepository) loggedSeqs(ctx context.Context, events []*domain.Event) (map[string]int64, error) {
+	ids := make([]string, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+
+	opts := options.Find().SetProjection(bson.M{"event_id": 1, "seq": 1})
+	cursor, err := r.collection.Find(ctx, bson.M{"event_id": bson.M{"$in": ids}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	logged := make(map[string]int64)
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID  string `bson:"event_id"`
+			Seq int64  `bson:"seq"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		logged[doc.ID] = doc.Seq
+	}
+
+	return logged, cursor.Err()
+}
+
+// StreamEventsAfter calls fn for each event with a sequence number above seq, in order
+func (r *eventRepository) StreamEventsAfter(ctx context.Context, seq int64, fn func(*domain.Event) error) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Stream)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "seq", Value: 1}})
+	cursor, err := r.collection.Find(ctx, withTenant(ctx, bson.M{"seq": bson.M{"$gt": seq}}, tenantField), opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var event domain.Event
+		if err := cursor.Decode(&event); err != nil {
+			return err
+		}
+		if err := fn(&event); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// LastEventSeq returns the highest sequence number in the log, or 0 if it is empty
+func (r *eventRepository) LastEventSeq(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
+	defer cancel()
+
+	var doc struct {
+		Seq int64 `bson:"seq"`
+	}
+	opts := options.FindOne().
+		SetSort(bson.D{{Key: "seq", Value: -1}}).
+		SetProjection(bson.M{"seq": 1})
+	err := r.collection.FindOne(ctx, bson.M{}, opts).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	return doc.Seq, err
+}
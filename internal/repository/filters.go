@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"github.com/chats/sailing-backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// timeRangeFilter builds a range condition for tr, or nil if tr is unbounded
+func timeRangeFilter(tr domain.TimeRange) bson.M {
+	cond := bson.M{}
+	if !tr.From.IsZero() {
+		cond["$gte"] = tr.From
+	}
+	if !tr.To.IsZero() {
+		cond["$lte"] = tr.To
+	}
+	if len(cond) == 0 {
+		return nil
+	}
+	return cond
+}
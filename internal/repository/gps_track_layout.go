@@ -0,0 +1,527 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	gpsTracksCollection       = "gps_tracks"
+	legacyGPSTracksCollection = "gps_tracks_legacy"
+
+	// gpsTrackMigrationCollection holds how far the copy into the
+	// time-series collection has got
+	gpsTrackMigrationCollection = "gps_tracks_migration"
+
+	// gpsTrackConversionMarker is the gps_tracks_migration document present
+	// from just before gps_tracks is moved aside until the time-series
+	// collection exists
+	gpsTrackConversionMarker = "convert"
+
+	// gpsTrackConversionSettle is how long the conversion waits after
+	// setting its marker, so that every instance sees it and writes that
+	// started before it finish
+	gpsTrackConversionSettle = 5 * time.Second
+
+	// gpsTrackMigrationBatch is the number of tracks copied per insert
+	gpsTrackMigrationBatch = 1000
+)
+
+// GPSTrackLayout is how the gps_tracks collection stores tracks
+type GPSTrackLayout int
+
+const (
+	// GPSTrackLayoutRegular stores tracks as plain documents with a top-level voyage_id
+	GPSTrackLayoutRegular GPSTrackLayout = iota
+	// GPSTrackLayoutTimeSeries stores tracks in a time-series collection
+	// with timeField "timestamp" and metaField "meta" holding the voyage
+	// and ship IDs
+	GPSTrackLayoutTimeSeries
+)
+
+func (l GPSTrackLayout) String() string {
+	if l == GPSTrackLayoutTimeSeries {
+		return "timeseries"
+	}
+	return "regular"
+}
+
+// voyageField is the path of the voyage ID in stored documents
+func (l GPSTrackLayout) voyageField() string {
+	if l == GPSTrackLayoutTimeSeries {
+		return "meta.voyage_id"
+	}
+	return "voyage_id"
+}
+
+// tenantField is the path of the tenant ID in stored documents
+func (l GPSTrackLayout) tenantField() string {
+	if l == GPSTrackLayoutTimeSeries {
+		return "meta." + tenantField
+	}
+	return tenantField
+}
+
+// document returns the stored form of track in the layout
+func (l GPSTrackLayout) document(track *domain.GPSTrack) interface{} {
+	if l == GPSTrackLayoutTimeSeries {
+		return newTimeSeriesTrack(track)
+	}
+	return track
+}
+
+// decode reads the current document in the layout
+func (l GPSTrackLayout) decode(cursor *mongo.Cursor) (*domain.GPSTrack, error) {
+	if l == GPSTrackLayoutTimeSeries {
+		var doc timeSeriesTrack
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		return doc.track(), nil
+	}
+
+	var track domain.GPSTrack
+	if err := cursor.Decode(&track); err != nil {
+		return nil, err
+	}
+	return &track, nil
+}
+
+// trackMeta is the metaField of a time-series track; MongoDB groups
+// measurements with equal meta into the same buckets
+type trackMeta struct {
+	TenantID string `bson:"tenant_id,omitempty"`
+	VoyageID string `bson:"voyage_id"`
+	ShipID   string `bson:"ship_id,omitempty"`
+}
+
+// timeSeriesTrack is the stored form of a GPS track in the time-series layout
+type timeSeriesTrack struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	Timestamp time.Time          `bson:"timestamp"`
+	Meta      trackMeta          `bson:"meta"`
+	Location  domain.Location    `bson:"location"`
+	Speed     float64            `bson:"speed"`
+	Heading   float64            `bson:"heading"`
+	Altitude  float64            `bson:"altitude,omitempty"`
+	CreatedBy string             `bson:"created_by,omitempty"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+func newTimeSeriesTrack(track *domain.GPSTrack) *timeSeriesTrack {
+	return &timeSeriesTrack{
+		ID:        track.ID,
+		Timestamp: track.Timestamp,
+		Meta: trackMeta{
+			TenantID: track.TenantID,
+			VoyageID: track.VoyageID,
+			ShipID:   track.ShipID,
+		},
+		Location:  track.Location,
+		Speed:     track.Speed,
+		Heading:   track.Heading,
+		Altitude:  track.Altitude,
+		CreatedBy: track.CreatedBy,
+		CreatedAt: track.CreatedAt,
+	}
+}
+
+func (d *timeSeriesTrack) track() *domain.GPSTrack {
+	return &domain.GPSTrack{
+		ID:        d.ID,
+		TenantID:  d.Meta.TenantID,
+		VoyageID:  d.Meta.VoyageID,
+		ShipID:    d.Meta.ShipID,
+		Location:  d.Location,
+		Speed:     d.Speed,
+		Heading:   d.Heading,
+		Altitude:  d.Altitude,
+		Timestamp: d.Timestamp,
+		CreatedBy: d.CreatedBy,
+		CreatedAt: d.CreatedAt,
+	}
+}
+
+// DetectGPSTrackLayout reports how the gps_tracks collection is stored. A
+// missing collection is reported as regular. ctx bounds the lookup.
+func DetectGPSTrackLayout(ctx context.Context, db *mongo.Database) (GPSTrackLayout, error) {
+	specs, err := db.ListCollectionSpecifications(ctx, bson.M{"name": gpsTracksCollection})
+	if err != nil {
+		return GPSTrackLayoutRegular, err
+	}
+	if len(specs) > 0 && specs[0].Type == "timeseries" {
+		return GPSTrackLayoutTimeSeries, nil
+	}
+	return GPSTrackLayoutRegular, nil
+}
+
+// unstoredTracks returns the tracks whose IDs are not in collection yet.
+// Time-series collections have no index on _id, so there the lookup is
+// narrowed by voyage and time to use the voyage index.
+func unstoredTracks(ctx context.Context, collection *mongo.Collection, layout GPSTrackLayout, tracks []*domain.GPSTrack) ([]*domain.GPSTrack, error) {
+	if len(tracks) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]primitive.ObjectID, len(tracks))
+	voyages := make(map[string]bool)
+	from, to := tracks[0].Timestamp, tracks[0].Timestamp
+	for i, track := range tracks {
+		ids[i] = track.ID
+		voyages[track.VoyageID] = true
+		if track.Timestamp.Before(from) {
+			from = track.Timestamp
+		}
+		if track.Timestamp.After(to) {
+			to = track.Timestamp
+		}
+	}
+
+	filter := bson.M{"_id": bson.M{"$in": ids}}
+	if layout == GPSTrackLayoutTimeSeries {
+		voyageIDs := make([]string, 0, len(voyages))
+		for id := range voyages {
+			voyageIDs = append(voyageIDs, id)
+		}
+		filter[layout.voyageField()] = bson.M{"$in": voyageIDs}
+		filter["timestamp"] = bson.M{"$gte": from, "$lte": to}
+	}
+
+	cursor, err := collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	stored := make(map[primitive.ObjectID]bool)
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		stored[doc.ID] = true
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	if len(stored) == 0 {
+		return tracks, nil
+	}
+
+	missing := make([]*domain.GPSTrack, 0, len(tracks)-len(stored))
+	for _, track := range tracks {
+		if !stored[track.ID] {
+			missing = append(missing, track)
+		}
+	}
+	return missing, nil
+}
+
+// migrateGPSTracksToTimeSeries converts gps_tracks to the time-series
+// layout. The regular collection is renamed to gps_tracks_legacy, a
+// time-series gps_tracks is created and the legacy tracks are copied into
+// it, with ship IDs filled in from their voyages. The copy resumes where it
+// stopped if interrupted; the legacy collection is kept for the operator
+// to drop once satisfied.
+//
+// Running instances hold their GPS track writes from the moment the
+// conversion marker is set until the time-series collection exists, so no
+// track is written in the regular layout after the rename.
+func migrateGPSTracksToTimeSeries(ctx context.Context, db *mongo.Database) error {
+	layout, err := DetectGPSTrackLayout(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	progress := db.Collection(gpsTrackMigrationCollection)
+	if layout == GPSTrackLayoutRegular {
+		if err := announceGPSTrackConversion(ctx, progress); err != nil {
+			return err
+		}
+		if err := convertGPSTracksCollection(ctx, db); err != nil {
+			return err
+		}
+	}
+	if _, err := progress.DeleteOne(ctx, bson.M{"_id": gpsTrackConversionMarker}); err != nil {
+		return err
+	}
+
+	return copyLegacyGPSTracks(ctx, db)
+}
+
+// announceGPSTrackConversion sets the conversion marker and waits until it
+// has been set for gpsTrackConversionSettle. A marker left by an
+// interrupted conversion keeps its original time.
+func announceGPSTrackConversion(ctx context.Context, progress *mongo.Collection) error {
+	var marker struct {
+		StartedAt time.Time `bson:"started_at"`
+	}
+	err := progress.FindOneAndUpdate(ctx,
+		bson.M{"_id": gpsTrackConversionMarker},
+		bson.M{"$setOnInsert": bson.M{"started_at": time.Now()}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&marker)
+	if err != nil {
+		return fmt.Errorf("failed to set the %s conversion marker: %w", gpsTracksCollection, err)
+	}
+
+	wait := time.Until(marker.StartedAt.Add(gpsTrackConversionSettle))
+	if wait <= 0 {
+		return nil
+	}
+	log.Info().Dur("wait", wait).Msg("Holding GPS track writes before converting gps_tracks")
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// convertGPSTracksCollection moves any regular gps_tracks aside and creates
+// the time-series collection in its place
+func convertGPSTracksCollection(ctx context.Context, db *mongo.Database) error {
+	names, err := db.ListCollectionNames(ctx, bson.M{"name": bson.M{"$in": bson.A{gpsTracksCollection, legacyGPSTracksCollection}}})
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool)
+	for _, name := range names {
+		existing[name] = true
+	}
+
+	if existing[gpsTracksCollection] && existing[legacyGPSTracksCollection] {
+		// An interrupted conversion whose regular collection was recreated
+		// by a write that started before the marker was set
+		if err := moveStrayGPSTracks(ctx, db); err != nil {
+			return err
+		}
+	} else if existing[gpsTracksCollection] {
+		err := db.Client().Database("admin").RunCommand(ctx, bson.D{
+			{Key: "renameCollection", Value: db.Name() + "." + gpsTracksCollection},
+			{Key: "to", Value: db.Name() + "." + legacyGPSTracksCollection},
+		}).Err()
+		if err != nil {
+			return fmt.Errorf("failed to rename %s: %w", gpsTracksCollection, err)
+		}
+		log.Info().Msg("Renamed gps_tracks to gps_tracks_legacy")
+	}
+
+	opts := options.CreateCollection().SetTimeSeriesOptions(
+		options.TimeSeries().
+			SetTimeField("timestamp").
+			SetMetaField("meta").
+			SetGranularity("seconds"),
+	)
+	if err := db.CreateCollection(ctx, gpsTracksCollection, opts); err != nil {
+		// A write that started before the marker was set may have recreated
+		// a regular gps_tracks since the rename
+		layout, detectErr := DetectGPSTrackLayout(ctx, db)
+		if detectErr != nil || layout != GPSTrackLayoutRegular {
+			return fmt.Errorf("failed to create time-series %s: %w", gpsTracksCollection, err)
+		}
+		if err := moveStrayGPSTracks(ctx, db); err != nil {
+			return err
+		}
+		if err := db.CreateCollection(ctx, gpsTracksCollection, opts); err != nil {
+			return fmt.Errorf("failed to create time-series %s: %w", gpsTracksCollection, err)
+		}
+	}
+
+	err = ensureIndexes(ctx, db, gpsTracksCollection,
+		mongo.IndexModel{Keys: bson.D{{Key: "meta.voyage_id", Value: 1}, {Key: "timestamp", Value: 1}}},
+		mongo.IndexModel{Keys: bson.D{{Key: "meta.tenant_id", Value: 1}, {Key: "meta.voyage_id", Value: 1}, {Key: "timestamp", Value: 1}}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to index %s: %w", gpsTracksCollection, err)
+	}
+
+	log.Info().Msg("Created time-series gps_tracks collection")
+	return nil
+}
+
+// moveStrayGPSTracks moves the tracks of a regular gps_tracks recreated
+// after the rename into gps_tracks_legacy, from where they are copied with
+// the rest, and drops it
+func moveStrayGPSTracks(ctx context.Context, db *mongo.Database) error {
+	stray := db.Collection(gpsTracksCollection)
+	legacy := db.Collection(legacyGPSTracksCollection)
+
+	cursor, err := stray.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	moved := 0
+	var batch []interface{}
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		// Tracks already in the legacy collection are skipped
+		_, err := legacy.InsertMany(ctx, batch, options.InsertMany().SetOrdered(false))
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+		moved += len(batch)
+		batch = nil
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		var doc bson.Raw
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		batch = append(batch, doc)
+		if len(batch) == gpsTrackMigrationBatch {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	if err := stray.Drop(ctx); err != nil {
+		return err
+	}
+	log.Warn().Int("tracks", moved).Msg("Moved GPS tracks written during the conversion into gps_tracks_legacy")
+	return nil
+}
+
+// copyLegacyGPSTracks copies legacy tracks not yet in the time-series
+// collection, in _id order. The last _id copied is recorded after each
+// batch so an interrupted copy resumes there; tracks of a batch copied
+// before the interruption are skipped. A finished copy is recorded too, so
+// later runs return at once.
+func copyLegacyGPSTracks(ctx context.Context, db *mongo.Database) error {
+	legacy := db.Collection(legacyGPSTracksCollection)
+	target := db.Collection(gpsTracksCollection)
+	progress := db.Collection(gpsTrackMigrationCollection)
+	voyages := db.Collection("voyages")
+
+	filter := bson.M{}
+	var last struct {
+		LastID primitive.ObjectID `bson:"last_id"`
+		Done   bool               `bson:"done"`
+	}
+	err := progress.FindOne(ctx, bson.M{"_id": "copy"}).Decode(&last)
+	switch {
+	case err == nil && last.Done:
+		return nil
+	case err == nil:
+		filter["_id"] = bson.M{"$gt": last.LastID}
+	case err != mongo.ErrNoDocuments:
+		return err
+	}
+
+	cursor, err := legacy.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	ships := make(map[string]string)
+	shipID := func(voyageID string) (string, error) {
+		if id, ok := ships[voyageID]; ok {
+			return id, nil
+		}
+		var voyage domain.Voyage
+		err := voyages.FindOne(ctx, bson.M{"voyage_id": voyageID}).Decode(&voyage)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return "", err
+		}
+		ships[voyageID] = voyage.ShipID
+		return voyage.ShipID, nil
+	}
+
+	copied := 0
+	batch := make([]*domain.GPSTrack, 0, gpsTrackMigrationBatch)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		missing, err := unstoredTracks(ctx, target, GPSTrackLayoutTimeSeries, batch)
+		if err != nil {
+			return err
+		}
+		if len(missing) > 0 {
+			docs := make([]interface{}, len(missing))
+			for i, track := range missing {
+				docs[i] = newTimeSeriesTrack(track)
+			}
+			if _, err := target.InsertMany(ctx, docs); err != nil {
+				return err
+			}
+		}
+
+		_, err = progress.UpdateOne(ctx, bson.M{"_id": "copy"},
+			bson.M{"$set": bson.M{"last_id": batch[len(batch)-1].ID}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+		copied += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		track := new(domain.GPSTrack)
+		if err := cursor.Decode(track); err != nil {
+			return err
+		}
+		if track.ShipID == "" {
+			if track.ShipID, err = shipID(track.VoyageID); err != nil {
+				return err
+			}
+		}
+
+		batch = append(batch, track)
+		if len(batch) == gpsTrackMigrationBatch {
+			if err := flush(); err != nil {
+				return err
+			}
+			log.Info().Int("copied", copied).Msg("Copying GPS tracks to time-series collection")
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	_, err = progress.UpdateOne(ctx, bson.M{"_id": "copy"},
+		bson.M{"$set": bson.M{"done": true}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return err
+	}
+	if copied > 0 {
+		log.Info().Int("copied", copied).Msg("GPS tracks copied to time-series collection; gps_tracks_legacy can be dropped")
+	}
+	return nil
+}
@@ -2,75 +2,281 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// gpsTrackLayoutRecheck is how long a detected layout is trusted before it
+// is detected again, so instances notice gps_tracks being converted by another
+const gpsTrackLayoutRecheck = 10 * time.Second
+
+const (
+	// gpsTrackConversionCheck is how long writes to the regular layout trust
+	// that no conversion has started; the conversion waits
+	// gpsTrackConversionSettle, which must be longer, before moving the
+	// collection aside
+	gpsTrackConversionCheck = time.Second
+
+	// gpsTrackConversionPoll is how often writes waiting for a conversion
+	// check whether the time-series collection exists
+	gpsTrackConversionPoll = 250 * time.Millisecond
+)
+
+// errTimeSeriesTransaction is returned when tracks are written to a
+// time-series collection inside a transaction, which MongoDB does not allow
+var errTimeSeriesTransaction = errors.New("GPS tracks in a time-series collection cannot be written inside a transaction")
+
 type gpsTrackRepository struct {
+	db         *mongo.Database
 	collection *mongo.Collection
+	timeouts   Timeouts
+
+	mu        sync.Mutex
+	layout    GPSTrackLayout
+	checkedAt time.Time
+
+	converting          bool // a conversion marker was found at conversionCheckedAt
+	conversionCheckedAt time.Time
 }
 
-// NewGPSTrackRepository creates a new GPS track repository
-func NewGPSTrackRepository(db *mongo.Database) domain.GPSTrackRepository {
+// NewGPSTrackRepository creates a new GPS track repository for collections
+// stored in the given layout. The layout is detected again periodically
+// and after failed writes.
+func NewGPSTrackRepository(db *mongo.Database, layout GPSTrackLayout, timeouts Timeouts) domain.GPSTrackRepository {
 	return &gpsTrackRepository{
-		collection: db.Collection("gps_tracks"),
+		db:         db,
+		collection: db.Collection(gpsTracksCollection),
+		layout:     layout,
+		checkedAt:  time.Now(),
+		timeouts:   timeouts.withDefaults(),
 	}
 }
 
+// TransactionalWrites reports whether tracks can be written in the same
+// transaction as other writes, which time-series collections do not allow
+func (r *gpsTrackRepository) TransactionalWrites(ctx context.Context) bool {
+	return r.currentLayout(ctx) == GPSTrackLayoutRegular
+}
+
 func (r *gpsTrackRepository) CreateGPSTrack(ctx context.Context, track *domain.GPSTrack) error {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
 	defer cancel()
 
-	result, err := r.collection.InsertOne(ctx, track)
-	if err != nil {
-		return err
-	}
-
-	track.ID = result.InsertedID.(primitive.ObjectID)
-	return nil
+	return r.insert(ctx, []*domain.GPSTrack{track})
 }
 
 func (r *gpsTrackRepository) CreateGPSTracksBatch(ctx context.Context, tracks []*domain.GPSTrack) error {
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Batch)
 	defer cancel()
 
-	// Convert to []interface{} for InsertMany
-	docs := make([]interface{}, len(tracks))
-	for i, track := range tracks {
-		docs[i] = track
+	return r.insert(ctx, tracks)
+}
+
+// insert stores tracks that are not stored yet, so retrying a write that
+// failed part way, or whose outcome is unknown, does not duplicate tracks.
+// Tracks without an ID are given one first.
+func (r *gpsTrackRepository) insert(ctx context.Context, tracks []*domain.GPSTrack) error {
+	layout, err := r.writeLayout(ctx)
+	if err != nil {
+		return err
+	}
+	if layout == GPSTrackLayoutTimeSeries && inTransaction(ctx) {
+		return errTimeSeriesTransaction
+	}
+
+	for _, track := range tracks {
+		if track.ID.IsZero() {
+			track.ID = primitive.NewObjectID()
+		}
 	}
 
-	results, err := r.collection.InsertMany(ctx, docs)
+	missing, err := unstoredTracks(ctx, r.collection, layout, tracks)
 	if err != nil {
 		return err
 	}
+	if len(missing) == 0 {
+		return nil
+	}
 
-	// Update IDs in the original slice
-	for i, id := range results.InsertedIDs {
-		tracks[i].ID = id.(primitive.ObjectID)
+	docs := make([]interface{}, len(missing))
+	for i, track := range missing {
+		docs[i] = layout.document(track)
 	}
 
+	if _, err := r.collection.InsertMany(ctx, docs); err != nil {
+		// The collection may have been converted by another instance
+		r.expireLayout()
+		return err
+	}
 	return nil
 }
 
 func (r *gpsTrackRepository) GetGPSTracksByVoyageID(ctx context.Context, voyageID string) ([]*domain.GPSTrack, error) {
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Batch)
 	defer cancel()
 
-	cursor, err := r.collection.Find(ctx, bson.M{"voyage_id": voyageID})
+	layout := r.currentLayout(ctx)
+	cursor, err := r.collection.Find(ctx, withTenant(ctx, bson.M{layout.voyageField(): voyageID}, layout.tenantField()))
 	if err != nil {
 		return nil, err
 	}
 	defer cursor.Close(ctx)
 
 	var tracks []*domain.GPSTrack
-	if err = cursor.All(ctx, &tracks); err != nil {
-		return nil, err
+	for cursor.Next(ctx) {
+		track, err := layout.decode(cursor)
+		if err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, track)
 	}
 
-	return tracks, nil
+	return tracks, cursor.Err()
+}
+
+// StreamGPSTracksByVoyageID calls fn for each document of a voyage in timestamp order without
+// loading them all into memory. Iteration stops at the first error from fn.
+func (r *gpsTrackRepository) StreamGPSTracksByVoyageID(ctx context.Context, voyageID string, tr domain.TimeRange, fn func(*domain.GPSTrack) error) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Stream)
+	defer cancel()
+
+	layout := r.currentLayout(ctx)
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}})
+	filter := withTenant(ctx, bson.M{layout.voyageField(): voyageID}, layout.tenantField())
+	if ts := timeRangeFilter(tr); ts != nil {
+		filter["timestamp"] = ts
+	}
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		track, err := layout.decode(cursor)
+		if err != nil {
+			return err
+		}
+		if err := fn(track); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// currentLayout returns the layout of gps_tracks, detecting it again once
+// gpsTrackLayoutRecheck has passed. If detection fails, the last known
+// layout is used.
+func (r *gpsTrackRepository) currentLayout(ctx context.Context) GPSTrackLayout {
+	r.mu.Lock()
+	layout := r.layout
+	stale := time.Since(r.checkedAt) >= gpsTrackLayoutRecheck
+	if stale {
+		// Only one caller detects; the others keep using the current layout
+		r.checkedAt = time.Now()
+	}
+	r.mu.Unlock()
+	if !stale {
+		return layout
+	}
+
+	detectCtx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
+	detected, err := DetectGPSTrackLayout(detectCtx, r.db)
+	cancel()
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to detect gps_tracks layout")
+		return layout
+	}
+
+	r.mu.Lock()
+	if detected != r.layout {
+		log.Info().Stringer("layout", detected).Msg("gps_tracks layout changed")
+		r.layout = detected
+	}
+	r.mu.Unlock()
+	return detected
+}
+
+// writeLayout returns the layout to write tracks in. While gps_tracks is
+// being converted, writes wait for the time-series collection: writing the
+// regular layout could recreate the collection the conversion has just
+// moved aside, or store documents the time-series layout cannot find.
+// Conversion only goes from regular to time-series, so once the
+// time-series layout is detected nothing is checked.
+func (r *gpsTrackRepository) writeLayout(ctx context.Context) (GPSTrackLayout, error) {
+	for {
+		layout := r.currentLayout(ctx)
+		if layout == GPSTrackLayoutTimeSeries {
+			return layout, nil
+		}
+
+		converting, err := r.conversionInProgress(ctx)
+		if err != nil {
+			return layout, err
+		}
+		if !converting {
+			return layout, nil
+		}
+
+		r.expireLayout()
+		select {
+		case <-ctx.Done():
+			return layout, fmt.Errorf("%w: %v", domain.ErrGPSTracksConverting, ctx.Err())
+		case <-time.After(gpsTrackConversionPoll):
+		}
+	}
+}
+
+// conversionInProgress reports whether the conversion marker is set. A
+// missing marker is trusted for gpsTrackConversionCheck; a present one is
+// looked up again on every call.
+func (r *gpsTrackRepository) conversionInProgress(ctx context.Context) (bool, error) {
+	r.mu.Lock()
+	if !r.converting && time.Since(r.conversionCheckedAt) < gpsTrackConversionCheck {
+		r.mu.Unlock()
+		return false, nil
+	}
+	r.mu.Unlock()
+
+	checkCtx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
+	defer cancel()
+
+	err := r.db.Collection(gpsTrackMigrationCollection).FindOne(checkCtx, bson.M{"_id": gpsTrackConversionMarker}).Err()
+	if err != nil && err != mongo.ErrNoDocuments {
+		return false, err
+	}
+	converting := err == nil
+
+	r.mu.Lock()
+	if converting && !r.converting {
+		log.Info().Msg("gps_tracks is being converted, GPS track writes wait for the time-series collection")
+	}
+	r.converting = converting
+	r.conversionCheckedAt = time.Now()
+	r.mu.Unlock()
+	return converting, nil
+}
+
+// expireLayout makes the next call to currentLayout detect the layout again
+func (r *gpsTrackRepository) expireLayout() {
+	r.mu.Lock()
+	r.checkedAt = time.Time{}
+	r.mu.Unlock()
+}
+
+// inTransaction reports whether ctx carries a session with a transaction in progress
+func inTransaction(ctx context.Context) bool {
+	session, ok := mongo.SessionFromContext(ctx).(mongo.XSession)
+	return ok && session.ClientSession().TransactionRunning()
 }
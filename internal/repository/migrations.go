@@ -0,0 +1,326 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chats/sailing-backend/pkg/database"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MigrationOptions selects the optional migration steps
+type MigrationOptions struct {
+	// LegacyTenantID is assigned to records stored without a tenant
+	LegacyTenantID string
+	// GPSTracksTimeSeries converts gps_tracks to a time-series collection
+	GPSTracksTimeSeries bool
+}
+
+// Migrations returns the schema migrations for the application's
+// collections. Append new migrations with the next version; never change
+// or renumber one that has been released. Version 9 converted gps_tracks
+// in earlier releases and is recorded on databases it converted, so it
+// must not be reused.
+func Migrations() []database.Migration {
+	return []database.Migration{
+		{
+			Version:     1,
+			Description: "create voyage, checkpoint and GPS track indexes",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				err := ensureIndexes(ctx, db, "voyages",
+					mongo.IndexModel{Keys: bson.D{{Key: "voyage_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+					mongo.IndexModel{Keys: bson.D{{Key: "ship_id", Value: 1}}},
+					mongo.IndexModel{Keys: bson.D{{Key: "departure_time", Value: 1}}},
+					mongo.IndexModel{Keys: bson.D{{Key: "arrival_time", Value: 1}}},
+					mongo.IndexModel{Keys: bson.D{{Key: "mmsi", Value: 1}, {Key: "status", Value: 1}}},
+				)
+				if err != nil {
+					return err
+				}
+
+				err = ensureIndexes(ctx, db, "checkpoints",
+					mongo.IndexModel{Keys: bson.D{{Key: "voyage_id", Value: 1}}},
+					mongo.IndexModel{Keys: bson.D{{Key: "timestamp", Value: 1}}},
+				)
+				if err != nil {
+					return err
+				}
+
+				layout, err := DetectGPSTrackLayout(ctx, db)
+				if err != nil {
+					return err
+				}
+				if layout == GPSTrackLayoutTimeSeries {
+					return ensureIndexes(ctx, db, gpsTracksCollection,
+						mongo.IndexModel{Keys: bson.D{{Key: "meta.voyage_id", Value: 1}, {Key: "timestamp", Value: 1}}},
+					)
+				}
+				return ensureIndexes(ctx, db, gpsTracksCollection,
+					mongo.IndexModel{Keys: bson.D{{Key: "voyage_id", Value: 1}}},
+					mongo.IndexModel{Keys: bson.D{{Key: "timestamp", Value: 1}}},
+					mongo.IndexModel{Keys: bson.D{{Key: "voyage_id", Value: 1}, {Key: "timestamp", Value: 1}}},
+				)
+			},
+		},
+		{
+			Version:     2,
+			Description: "create event log, outbox and webhook indexes",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				err := ensureIndexes(ctx, db, "events",
+					mongo.IndexModel{Keys: bson.D{{Key: "seq", Value: 1}}, Options: options.Index().SetUnique(true)},
+					mongo.IndexModel{Keys: bson.D{{Key: "event_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+				)
+				if err != nil {
+					return err
+				}
+
+				err = ensureIndexes(ctx, db, "outbox",
+					mongo.IndexModel{Keys: bson.D{{Key: "dispatched", Value: 1}, {Key: "_id", Value: 1}}},
+					// Dispatched entries are kept for a week for troubleshooting
+					mongo.IndexModel{Keys: bson.D{{Key: "dispatched_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(7 * 24 * 3600)},
+				)
+				if err != nil {
+					return err
+				}
+
+				err = ensureIndexes(ctx, db, "webhook_subscriptions",
+					mongo.IndexModel{Keys: bson.D{{Key: "active", Value: 1}}},
+				)
+				if err != nil {
+					return err
+				}
+
+				return ensureIndexes(ctx, db, "webhook_deliveries",
+					mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+					mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}, {Key: "updated_at", Value: -1}}},
+				)
+			},
+		},
+		{
+			Version:     3,
+			Description: "backfill ship_id on GPS tracks from their voyages",
+			Up:          backfillGPSTrackShipIDs,
+		},
+		{
+			Version:     4,
+			Description: "create API key indexes",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				return ensureIndexes(ctx, db, "api_keys",
+					mongo.IndexModel{Keys: bson.D{{Key: "key_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+				)
+			},
+		},
+		{
+			Version:     5,
+			Description: "create tenant-scoped indexes",
+			Up:          createTenantIndexes,
+		},
+		{
+			Version:     6,
+			Description: "create user and refresh token indexes",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				err := ensureIndexes(ctx, db, "users",
+					mongo.IndexModel{Keys: bson.D{{Key: "username", Value: 1}}, Options: options.Index().SetUnique(true)},
+					mongo.IndexModel{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "created_at", Value: -1}}},
+				)
+				if err != nil {
+					return err
+				}
+
+				return ensureIndexes(ctx, db, "refresh_tokens",
+					mongo.IndexModel{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+					mongo.IndexModel{Keys: bson.D{{Key: "family_id", Value: 1}}},
+					mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}}},
+					// Expired tokens are useless, so Mongo removes them
+					mongo.IndexModel{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+				)
+			},
+		},
+		{
+			Version:     7,
+			Description: "create audit log indexes",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				return ensureIndexes(ctx, db, "audit_log",
+					mongo.IndexModel{Keys: bson.D{{Key: "created_at", Value: -1}}},
+					mongo.IndexModel{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "created_at", Value: -1}}},
+					mongo.IndexModel{Keys: bson.D{{Key: "principal", Value: 1}, {Key: "created_at", Value: -1}}},
+					mongo.IndexModel{Keys: bson.D{{Key: "changes.entity", Value: 1}, {Key: "changes.entity_id", Value: 1}, {Key: "created_at", Value: -1}}},
+				)
+			},
+		},
+		{
+			Version:     8,
+			Description: "expire shared rate limit counters",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				return ensureIndexes(ctx, db, "rate_limits",
+					mongo.IndexModel{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+				)
+			},
+		},
+	}
+}
+
+// MigrationSteps returns the optional changes enabled in opts. They run
+// after the migrations on every start, whatever versions have been applied,
+// and do nothing once made.
+func MigrationSteps(opts MigrationOptions) []database.Step {
+	var steps []database.Step
+	if opts.LegacyTenantID != "" {
+		steps = append(steps, database.Step{
+			Description: "assign records without a tenant to " + opts.LegacyTenantID,
+			Up:          backfillTenant(opts.LegacyTenantID),
+		})
+	}
+	if opts.GPSTracksTimeSeries {
+		steps = append(steps, database.Step{
+			Description: "convert GPS tracks to a time-series collection",
+			Up:          migrateGPSTracksToTimeSeries,
+		})
+	}
+	return steps
+}
+
+// backfillTenant returns a step setting tenantID on the voyages,
+// checkpoints, GPS tracks and events stored before tenants were introduced
+func backfillTenant(tenantID string) func(ctx context.Context, db *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		layout, err := DetectGPSTrackLayout(ctx, db)
+		if err != nil {
+			return err
+		}
+
+		// Only the metaField of a time-series collection can be updated
+		fields := []struct{ collection, field string }{
+			{"voyages", tenantField},
+			{"checkpoints", tenantField},
+			{gpsTracksCollection, layout.tenantField()},
+			{"events", tenantField},
+		}
+		for _, f := range fields {
+			result, err := db.Collection(f.collection).UpdateMany(ctx,
+				bson.M{f.field: bson.M{"$in": bson.A{nil, ""}}},
+				bson.M{"$set": bson.M{f.field: tenantID}},
+			)
+			if err != nil {
+				return fmt.Errorf("failed to assign %s to a tenant: %w", f.collection, err)
+			}
+			if result.ModifiedCount > 0 {
+				log.Info().Str("collection", f.collection).Str("tenant_id", tenantID).Int64("records", result.ModifiedCount).Msg("Assigned records without a tenant")
+			}
+		}
+		return nil
+	}
+}
+
+// createTenantIndexes adds indexes led by the tenant ID for the queries
+// tenant-scoped principals make
+func createTenantIndexes(ctx context.Context, db *mongo.Database) error {
+	err := ensureIndexes(ctx, db, "voyages",
+		mongo.IndexModel{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		mongo.IndexModel{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "departure_time", Value: -1}}},
+		mongo.IndexModel{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "ship_id", Value: 1}, {Key: "status", Value: 1}}},
+		mongo.IndexModel{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "mmsi", Value: 1}, {Key: "status", Value: 1}}},
+	)
+	if err != nil {
+		return err
+	}
+
+	err = ensureIndexes(ctx, db, "checkpoints",
+		mongo.IndexModel{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "voyage_id", Value: 1}, {Key: "timestamp", Value: 1}}},
+	)
+	if err != nil {
+		return err
+	}
+
+	layout, err := DetectGPSTrackLayout(ctx, db)
+	if err != nil {
+		return err
+	}
+	err = ensureIndexes(ctx, db, gpsTracksCollection,
+		mongo.IndexModel{Keys: bson.D{{Key: layout.tenantField(), Value: 1}, {Key: layout.voyageField(), Value: 1}, {Key: "timestamp", Value: 1}}},
+	)
+	if err != nil {
+		return err
+	}
+
+	err = ensureIndexes(ctx, db, "events",
+		mongo.IndexModel{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "seq", Value: 1}}},
+	)
+	if err != nil {
+		return err
+	}
+
+	err = ensureIndexes(ctx, db, "webhook_subscriptions",
+		mongo.IndexModel{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	)
+	if err != nil {
+		return err
+	}
+
+	err = ensureIndexes(ctx, db, "webhook_deliveries",
+		mongo.IndexModel{Keys: bson.D{{Key: "event.tenant_id", Value: 1}, {Key: "updated_at", Value: -1}}},
+	)
+	if err != nil {
+		return err
+	}
+
+	return ensureIndexes(ctx, db, "api_keys",
+		mongo.IndexModel{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	)
+}
+
+// ensureIndexes creates indexes on a collection; indexes that already exist
+// with the same keys and options are left alone
+func ensureIndexes(ctx context.Context, db *mongo.Database, collection string, models ...mongo.IndexModel) error {
+	_, err := db.Collection(collection).Indexes().CreateMany(ctx, models)
+	return err
+}
+
+// backfillGPSTrackShipIDs copies each voyage's ship_id onto its GPS tracks
+// that were stored before tracks carried one
+func backfillGPSTrackShipIDs(ctx context.Context, db *mongo.Database) error {
+	layout, err := DetectGPSTrackLayout(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	// Only the metaField of a time-series collection can be updated
+	shipField := "ship_id"
+	if layout == GPSTrackLayoutTimeSeries {
+		shipField = "meta.ship_id"
+	}
+
+	opts := options.Find().SetProjection(bson.M{"voyage_id": 1, "ship_id": 1})
+	cursor, err := db.Collection("voyages").Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	tracks := db.Collection(gpsTracksCollection)
+	for cursor.Next(ctx) {
+		var voyage struct {
+			VoyageID string `bson:"voyage_id"`
+			ShipID   string `bson:"ship_id"`
+		}
+		if err := cursor.Decode(&voyage); err != nil {
+			return err
+		}
+		if voyage.ShipID == "" {
+			continue
+		}
+
+		_, err := tracks.UpdateMany(ctx,
+			bson.M{layout.voyageField(): voyage.VoyageID, shipField: bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{shipField: voyage.ShipID}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
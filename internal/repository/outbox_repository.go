@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// outboxEntry wraps an event awaiting dispatch
+type outboxEntry struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty"`
+	Event        *domain.Event      `bson:"event"`
+	Dispatched   bool               `bson:"dispatched"`
+	CreatedAt    time.Time          `bson:"created_at"`
+	DispatchedAt *time.Time         `bson:"dispatched_at,omitempty"`
+}
+
+// relayLeaseID is the document in outbox_relay naming the relay that dispatches
+const relayLeaseID = "relay"
+
+type outboxRepository struct {
+	collection *mongo.Collection
+	leases     *mongo.Collection
+	timeouts   Timeouts
+}
+
+// NewOutboxRepository creates a new outbox repository
+func NewOutboxRepository(db *mongo.Database, timeouts Timeouts) domain.OutboxRepository {
+	return &outboxRepository{
+		collection: db.Collection("outbox"),
+		leases:     db.Collection("outbox_relay"),
+		timeouts:   timeouts.withDefaults(),
+	}
+}
+
+func (r *outboxRepository) AddEvents(ctx context.Context, events []*domain.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Batch)
+	defer cancel()
+
+	now := time.Now()
+	docs := make([]interface{}, len(events))
+	for i, event := range events {
+		docs[i] = &outboxEntry{
+			Event:     event,
+			CreatedAt: now,
+		}
+	}
+
+	_, err := r.collection.InsertMany(ctx, docs)
+	return err
+}
+
+// GetPendingEvents returns up to limit undispatched events, oldest first
+func (r *outboxRepository) GetPendingEvents(ctx context.Context, limit int) ([]*domain.Event, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Batch)
+	defer cancel()
+
+	opts := options.Find().
+		SetLimit(int64(limit)).
+		SetSort(bson.D{{Key: "_id", Value: 1}})
+
+	cursor, err := r.collection.Find(ctx, bson.M{"dispatched": false}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var entries []*outboxEntry
+	if err = cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+
+	events := make([]*domain.Event, len(entries))
+	for i, entry := range entries {
+		events[i] = entry.Event
+	}
+
+	return events, nil
+}
+
+func (r *outboxRepository) MarkDispatched(ctx context.Context, eventIDs []string) error {
+	if len(eventIDs) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Batch)
+	defer cancel()
+
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"event.event_id": bson.M{"$in": eventIDs}},
+		bson.M{"$set": bson.M{"dispatched": true, "dispatched_at": time.Now()}},
+	)
+	return err
+}
+
+// AcquireRelayLease takes or renews the relay lease. Taking a lease held
+// by another owner fails on the unique _id when the upsert tries to insert.
+func (r *outboxRepository) AcquireRelayLease(ctx context.Context, owner string, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
+	defer cancel()
+
+	now := time.Now()
+	_, err := r.leases.UpdateOne(ctx,
+		bson.M{
+			"_id": relayLeaseID,
+			"$or": bson.A{
+				bson.M{"owner": owner},
+				bson.M{"expires_at": bson.M{"$lt": now}},
+			},
+		},
+		bson.M{"$set": bson.M{"owner": owner, "expires_at": now.Add(ttl)}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *outboxRepository) ReleaseRelayLease(ctx context.Context, owner string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
+	defer cancel()
+
+	_, err := r.leases.DeleteOne(ctx, bson.M{"_id": relayLeaseID, "owner": owner})
+	return err
+}
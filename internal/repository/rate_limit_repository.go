@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/chats/sailing-backend/pkg/ratelimit"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type rateLimitRepository struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+// NewRateLimitRepository creates a rate limit counter store shared by every
+// API instance using the database. Increments give up after timeout, which
+// is kept short because every request waits for them.
+func NewRateLimitRepository(db *mongo.Database, timeout time.Duration) ratelimit.Store {
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	return &rateLimitRepository{
+		collection: db.Collection("rate_limits"),
+		timeout:    timeout,
+	}
+}
+
+type rateLimitCounter struct {
+	Count int `bson:"count"`
+}
+
+func (r *rateLimitRepository) Increment(ctx context.Context, key string, window, expires time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	filter := bson.M{"_id": key + "@" + strconv.FormatInt(window.Unix(), 10)}
+	update := bson.M{
+		"$inc":         bson.M{"count": 1},
+		"$setOnInsert": bson.M{"expires_at": expires},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var counter rateLimitCounter
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&counter)
+	if mongo.IsDuplicateKeyError(err) {
+		// Two instances inserted the window's counter at once; the loser
+		// retries as an update
+		err = r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&counter)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return counter.Count, nil
+}
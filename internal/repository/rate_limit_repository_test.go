@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// testDatabase connects to the server in MONGODB_TEST_URI and returns a
+// database dropped after the test; the test is skipped without one
+func testDatabase(t *testing.T) *mongo.Database {
+	t.Helper()
+
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+
+	db := client.Database("sailing_test_" + strconv.FormatInt(time.Now().UnixNano(), 36))
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = db.Drop(ctx)
+		_ = client.Disconnect(ctx)
+	})
+	return db
+}
+
+func TestRateLimitRepositoryIncrement(t *testing.T) {
+	store := NewRateLimitRepository(testDatabase(t), 0)
+
+	ctx := context.Background()
+	window := time.Now().Truncate(time.Minute)
+	expires := window.Add(time.Minute)
+
+	// Concurrent first increments race to insert the window's counter
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.Increment(ctx, "ip:203.0.113.1", window, expires); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("Increment: %v", err)
+	}
+
+	if got, err := store.Increment(ctx, "ip:203.0.113.1", window, expires); err != nil || got != 11 {
+		t.Errorf("Increment = %d, %v, want 11", got, err)
+	}
+	if got, err := store.Increment(ctx, "ip:203.0.113.1", expires, expires.Add(time.Minute)); err != nil || got != 1 {
+		t.Errorf("Increment in the next window = %d, %v, want 1", got, err)
+	}
+	if got, err := store.Increment(ctx, "ip:203.0.113.2", window, expires); err != nil || got != 1 {
+		t.Errorf("Increment of another key = %d, %v, want 1", got, err)
+	}
+}
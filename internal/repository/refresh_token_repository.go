@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type refreshTokenRepository struct {
+	collection *mongo.Collection
+	timeouts   Timeouts
+}
+
+// NewRefreshTokenRepository creates a new refresh token repository
+func NewRefreshTokenRepository(db *mongo.Database, timeouts Timeouts) domain.RefreshTokenRepository {
+	return &refreshTokenRepository{
+		collection: db.Collection("refresh_tokens"),
+		timeouts:   timeouts.withDefaults(),
+	}
+}
+
+func (r *refreshTokenRepository) CreateRefreshToken(ctx context.Context, token *domain.RefreshToken) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
+	defer cancel()
+
+	result, err := r.collection.InsertOne(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	token.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *refreshTokenRepository) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*domain.RefreshToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
+	defer cancel()
+
+	var token domain.RefreshToken
+	err := r.collection.FindOne(ctx, bson.M{"token_hash": tokenHash}).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, domain.ErrRefreshTokenNotFound
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (r *refreshTokenRepository) MarkRefreshTokenUsed(ctx context.Context, id primitive.ObjectID, usedAt time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
+	defer cancel()
+
+	// Matching only unused tokens makes concurrent refreshes race safely:
+	// exactly one of them marks the token
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "used_at": nil, "revoked_at": nil},
+		bson.M{"$set": bson.M{"used_at": usedAt}},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount == 1, nil
+}
+
+func (r *refreshTokenRepository) RevokeFamily(ctx context.Context, familyID string, revokedAt time.Time) error {
+	return r.revoke(ctx, bson.M{"family_id": familyID}, revokedAt)
+}
+
+func (r *refreshTokenRepository) RevokeUserTokens(ctx context.Context, userID primitive.ObjectID, revokedAt time.Time) error {
+	return r.revoke(ctx, bson.M{"user_id": userID}, revokedAt)
+}
+
+func (r *refreshTokenRepository) revoke(ctx context.Context, filter bson.M, revokedAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
+	defer cancel()
+
+	filter["revoked_at"] = nil
+	_, err := r.collection.UpdateMany(ctx, filter, bson.M{
+		"$set": bson.M{"revoked_at": revokedAt},
+	})
+	return err
+}
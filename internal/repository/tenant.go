@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// tenantField is the path of the tenant ID in stored documents
+const tenantField = "tenant_id"
+
+// withTenant restricts filter to the tenant of the principal carried by ctx,
+// stored under field. Platform principals and internal callers are not
+// restricted; a principal without a tenant that is not a platform
+// principal matches nothing, as stored tenant IDs are never empty.
+func withTenant(ctx context.Context, filter bson.M, field string) bson.M {
+	if tenant, limited := domain.TenantFromContext(ctx); limited {
+		filter[field] = tenant
+	}
+	return filter
+}
@@ -0,0 +1,25 @@
+package repository
+
+import "time"
+
+// Timeouts bounds the MongoDB operations of a repository. Zero fields take
+// their defaults.
+type Timeouts struct {
+	Query  time.Duration // single-document reads and writes
+	Batch  time.Duration // bulk writes and list pages
+	Stream time.Duration // cursors over a whole voyage, the fleet or the event log
+}
+
+// withDefaults returns t with zero fields set to their defaults
+func (t Timeouts) withDefaults() Timeouts {
+	if t.Query <= 0 {
+		t.Query = 5 * time.Second
+	}
+	if t.Batch <= 0 {
+		t.Batch = 10 * time.Second
+	}
+	if t.Stream <= 0 {
+		t.Stream = 10 * time.Minute
+	}
+	return t
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type transactor struct {
+	client       *mongo.Client
+	transactions bool
+}
+
+// NewTransactor creates a transactor for the database's client. MongoDB
+// only supports transactions on replica sets and sharded clusters; on a
+// standalone server, pass transactions false and fn runs without one, so
+// a failure part way through can leave its earlier writes in place.
+func NewTransactor(db *mongo.Database, transactions bool) domain.Transactor {
+	return &transactor{
+		client:       db.Client(),
+		transactions: transactions,
+	}
+}
+
+// SupportsTransactions reports whether the deployment db belongs to is a
+// replica set or sharded cluster, which are the ones supporting transactions.
+// ctx bounds the lookup.
+func SupportsTransactions(ctx context.Context, db *mongo.Database) (bool, error) {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	err := db.Client().Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if err != nil {
+		return false, err
+	}
+	return hello.SetName != "" || hello.Msg == "isdbgrid", nil
+}
+
+// WithinTransaction runs fn in a transaction, retrying it on transient
+// errors, and commits if fn returns nil
+func (t *transactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !t.transactions {
+		return fn(ctx)
+	}
+
+	session, err := t.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
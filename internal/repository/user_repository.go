@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type userRepository struct {
+	collection *mongo.Collection
+	timeouts   Timeouts
+}
+
+// NewUserRepository creates a new user repository
+func NewUserRepository(db *mongo.Database, timeouts Timeouts) domain.UserRepository {
+	return &userRepository{
+		collection: db.Collection("users"),
+		timeouts:   timeouts.withDefaults(),
+	}
+}
+
+func (r *userRepository) CreateUser(ctx context.Context, user *domain.User) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
+	defer cancel()
+
+	result, err := r.collection.InsertOne(ctx, user)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return domain.ErrUserExists
+		}
+		return err
+	}
+
+	user.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *userRepository) UpdateUser(ctx context.Context, user *domain.User) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
+	defer cancel()
+
+	update := bson.M{
+		"$set": bson.M{
+			"password_hash": user.PasswordHash,
+			"role":          user.Role,
+			"scopes":        user.Scopes,
+			"ship_id":       user.ShipID,
+			"disabled":      user.Disabled,
+			"updated_by":    user.UpdatedBy,
+			"updated_at":    user.UpdatedAt,
+		},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, withTenant(ctx, bson.M{"_id": user.ID}, tenantField), update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *userRepository) GetUserByID(ctx context.Context, id string) (*domain.User, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, domain.ErrUserNotFound
+	}
+
+	return r.findOne(ctx, bson.M{"_id": objectID})
+}
+
+func (r *userRepository) GetUserByUsername(ctx context.Context, username string) (*domain.User, error) {
+	return r.findOne(ctx, bson.M{"username": username})
+}
+
+func (r *userRepository) GetAllUsers(ctx context.Context) ([]*domain.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Batch)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := r.collection.Find(ctx, withTenant(ctx, bson.M{}, tenantField), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []*domain.User{}
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (r *userRepository) findOne(ctx context.Context, filter bson.M) (*domain.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
+	defer cancel()
+
+	var user domain.User
+	err := r.collection.FindOne(ctx, withTenant(ctx, filter, tenantField)).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, domain.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
@@ -3,7 +3,6 @@ package repository
 import (
 	"context"
 	"errors"
-	"time"
 
 	"github.com/chats/sailing-backend/internal/domain"
 	"go.mongodb.org/mongo-driver/bson"
@@ -14,17 +13,19 @@ import (
 
 type voyageRepository struct {
 	collection *mongo.Collection
+	timeouts   Timeouts
 }
 
 // NewVoyageRepository creates a new voyage repository
-func NewVoyageRepository(db *mongo.Database) domain.VoyageRepository {
+func NewVoyageRepository(db *mongo.Database, timeouts Timeouts) domain.VoyageRepository {
 	return &voyageRepository{
 		collection: db.Collection("voyages"),
+		timeouts:   timeouts.withDefaults(),
 	}
 }
 
 func (r *voyageRepository) CreateVoyage(ctx context.Context, voyage *domain.Voyage) error {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
 	defer cancel()
 
 	result, err := r.collection.InsertOne(ctx, voyage)
@@ -37,15 +38,19 @@ func (r *voyageRepository) CreateVoyage(ctx context.Context, voyage *domain.Voya
 }
 
 func (r *voyageRepository) UpdateVoyage(ctx context.Context, voyage *domain.Voyage) error {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
 	defer cancel()
 
-	filter := bson.M{"_id": voyage.ID}
+	filter := withTenant(ctx, bson.M{"_id": voyage.ID}, tenantField)
 	update := bson.M{
 		"$set": bson.M{
 			"arrival_port": voyage.ArrivalPort,
 			"arrival_time": voyage.ArrivalTime,
 			"status":       voyage.Status,
+			"destination":  voyage.Destination,
+			"eta":          voyage.ETA,
+			"draught":      voyage.Draught,
+			"updated_by":   voyage.UpdatedBy,
 			"updated_at":   voyage.UpdatedAt,
 		},
 	}
@@ -56,14 +61,14 @@ func (r *voyageRepository) UpdateVoyage(ctx context.Context, voyage *domain.Voya
 	}
 
 	if result.MatchedCount == 0 {
-		return errors.New("voyage not found")
+		return domain.ErrVoyageNotFound
 	}
 
 	return nil
 }
 
 func (r *voyageRepository) GetVoyageByID(ctx context.Context, id string) (*domain.Voyage, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
 	defer cancel()
 
 	objectID, err := primitive.ObjectIDFromHex(id)
@@ -72,10 +77,10 @@ func (r *voyageRepository) GetVoyageByID(ctx context.Context, id string) (*domai
 	}
 
 	var voyage domain.Voyage
-	err = r.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&voyage)
+	err = r.collection.FindOne(ctx, withTenant(ctx, bson.M{"_id": objectID}, tenantField)).Decode(&voyage)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, errors.New("voyage not found")
+			return nil, domain.ErrVoyageNotFound
 		}
 		return nil, err
 	}
@@ -84,7 +89,7 @@ func (r *voyageRepository) GetVoyageByID(ctx context.Context, id string) (*domai
 }
 
 func (r *voyageRepository) GetAllVoyages(ctx context.Context, limit, offset int) ([]*domain.Voyage, error) {
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Batch)
 	defer cancel()
 
 	opts := options.Find().
@@ -92,7 +97,7 @@ func (r *voyageRepository) GetAllVoyages(ctx context.Context, limit, offset int)
 		SetSkip(int64(offset)).
 		SetSort(bson.D{{Key: "created_at", Value: -1}})
 
-	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	cursor, err := r.collection.Find(ctx, withTenant(ctx, bson.M{}, tenantField), opts)
 	if err != nil {
 		return nil, err
 	}
@@ -107,14 +112,78 @@ func (r *voyageRepository) GetAllVoyages(ctx context.Context, limit, offset int)
 }
 
 func (r *voyageRepository) GetVoyageByVoyageID(ctx context.Context, voyageID string) (*domain.Voyage, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
 	defer cancel()
 
 	var voyage domain.Voyage
-	err := r.collection.FindOne(ctx, bson.M{"voyage_id": voyageID}).Decode(&voyage)
+	err := r.collection.FindOne(ctx, withTenant(ctx, bson.M{"voyage_id": voyageID}, tenantField)).Decode(&voyage)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, errors.New("voyage not found")
+			return nil, domain.ErrVoyageNotFound
+		}
+		return nil, err
+	}
+
+	return &voyage, nil
+}
+
+// StreamVoyages calls fn for each voyage under way at some point within tr,
+// most recent departure first
+func (r *voyageRepository) StreamVoyages(ctx context.Context, tr domain.TimeRange, fn func(*domain.Voyage) error) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Stream)
+	defer cancel()
+
+	filter := withTenant(ctx, bson.M{}, tenantField)
+	if !tr.To.IsZero() {
+		filter["departure_time"] = bson.M{"$lte": tr.To}
+	}
+	if !tr.From.IsZero() {
+		filter["$or"] = bson.A{
+			bson.M{"arrival_time": bson.M{"$gte": tr.From}},
+			bson.M{"arrival_time": nil},
+		}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "departure_time", Value: -1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var voyage domain.Voyage
+		if err := cursor.Decode(&voyage); err != nil {
+			return err
+		}
+		if err := fn(&voyage); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+func (r *voyageRepository) GetActiveVoyageByShipID(ctx context.Context, shipID string) (*domain.Voyage, error) {
+	return r.findActiveVoyage(ctx, bson.M{"ship_id": shipID, "status": domain.VoyageStatusInProgress})
+}
+
+func (r *voyageRepository) GetActiveVoyageByMMSI(ctx context.Context, mmsi string) (*domain.Voyage, error) {
+	return r.findActiveVoyage(ctx, bson.M{"mmsi": mmsi, "status": domain.VoyageStatusInProgress})
+}
+
+// findActiveVoyage returns the most recently departed voyage matching filter
+func (r *voyageRepository) findActiveVoyage(ctx context.Context, filter bson.M) (*domain.Voyage, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
+	defer cancel()
+
+	opts := options.FindOne().SetSort(bson.D{{Key: "departure_time", Value: -1}})
+
+	var voyage domain.Voyage
+	err := r.collection.FindOne(ctx, withTenant(ctx, filter, tenantField), opts).Decode(&voyage)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, domain.ErrVoyageNotFound
 		}
 		return nil, err
 	}
@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// deliveryTenantField is the path of the tenant ID of a delivery's event
+const deliveryTenantField = "event." + tenantField
+
+type webhookDeliveryRepository struct {
+	collection *mongo.Collection
+	timeouts   Timeouts
+}
+
+// NewWebhookDeliveryRepository creates a new webhook delivery repository
+func NewWebhookDeliveryRepository(db *mongo.Database, timeouts Timeouts) domain.WebhookDeliveryRepository {
+	return &webhookDeliveryRepository{
+		collection: db.Collection("webhook_deliveries"),
+		timeouts:   timeouts.withDefaults(),
+	}
+}
+
+func (r *webhookDeliveryRepository) CreateDeliveries(ctx context.Context, deliveries []*domain.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Batch)
+	defer cancel()
+
+	docs := make([]interface{}, len(deliveries))
+	for i, delivery := range deliveries {
+		docs[i] = delivery
+	}
+
+	results, err := r.collection.InsertMany(ctx, docs)
+	if err != nil {
+		return err
+	}
+
+	for i, id := range results.InsertedIDs {
+		deliveries[i].ID = id.(primitive.ObjectID)
+	}
+
+	return nil
+}
+
+func (r *webhookDeliveryRepository) UpdateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
+	defer cancel()
+
+	update := bson.M{
+		"$set": bson.M{
+			"status":           delivery.Status,
+			"attempts":         delivery.Attempts,
+			"next_attempt_at":  delivery.NextAttemptAt,
+			"last_status_code": delivery.LastStatusCode,
+			"last_error":       delivery.LastError,
+			"delivered_at":     delivery.DeliveredAt,
+			"updated_at":       delivery.UpdatedAt,
+		},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, withTenant(ctx, bson.M{"_id": delivery.ID}, deliveryTenantField), update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return domain.ErrDeliveryNotFound
+	}
+
+	return nil
+}
+
+func (r *webhookDeliveryRepository) GetDeliveryByID(ctx context.Context, id string) (*domain.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, domain.ErrDeliveryNotFound
+	}
+
+	var delivery domain.WebhookDelivery
+	err = r.collection.FindOne(ctx, withTenant(ctx, bson.M{"_id": objectID}, deliveryTenantField)).Decode(&delivery)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, domain.ErrDeliveryNotFound
+		}
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+func (r *webhookDeliveryRepository) GetDeliveries(ctx context.Context, status string, limit, offset int) ([]*domain.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Batch)
+	defer cancel()
+
+	filter := withTenant(ctx, bson.M{}, deliveryTenantField)
+	if status != "" {
+		filter["status"] = status
+	}
+
+	opts := options.Find().
+		SetLimit(int64(limit)).
+		SetSkip(int64(offset)).
+		SetSort(bson.D{{Key: "updated_at", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	deliveries := []*domain.WebhookDelivery{}
+	if err = cursor.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (r *webhookDeliveryRepository) ClaimDueDelivery(ctx context.Context, now time.Time, lease time.Duration) (*domain.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
+	defer cancel()
+
+	filter := bson.M{
+		"status":          domain.DeliveryStatusPending,
+		"next_attempt_at": bson.M{"$lte": now},
+	}
+	// Moving next_attempt_at forward hides the delivery from other workers
+	// until the lease expires, so a crashed worker's claim is retried
+	update := bson.M{
+		"$set": bson.M{"next_attempt_at": now.Add(lease)},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var delivery domain.WebhookDelivery
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&delivery)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &delivery, nil
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type webhookRepository struct {
+	collection *mongo.Collection
+	timeouts   Timeouts
+}
+
+// NewWebhookRepository creates a new webhook subscription repository
+func NewWebhookRepository(db *mongo.Database, timeouts Timeouts) domain.WebhookRepository {
+	return &webhookRepository{
+		collection: db.Collection("webhook_subscriptions"),
+		timeouts:   timeouts.withDefaults(),
+	}
+}
+
+func (r *webhookRepository) CreateSubscription(ctx context.Context, sub *domain.WebhookSubscription) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
+	defer cancel()
+
+	result, err := r.collection.InsertOne(ctx, sub)
+	if err != nil {
+		return err
+	}
+
+	sub.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *webhookRepository) UpdateSubscription(ctx context.Context, sub *domain.WebhookSubscription) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
+	defer cancel()
+
+	update := bson.M{
+		"$set": bson.M{
+			"url":         sub.URL,
+			"event_types": sub.EventTypes,
+			"description": sub.Description,
+			"active":      sub.Active,
+			"updated_by":  sub.UpdatedBy,
+			"updated_at":  sub.UpdatedAt,
+		},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, withTenant(ctx, bson.M{"_id": sub.ID}, tenantField), update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return domain.ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+func (r *webhookRepository) DeleteSubscription(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrWebhookNotFound
+	}
+
+	result, err := r.collection.DeleteOne(ctx, withTenant(ctx, bson.M{"_id": objectID}, tenantField))
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return domain.ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+func (r *webhookRepository) GetSubscriptionByID(ctx context.Context, id string) (*domain.WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Query)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, domain.ErrWebhookNotFound
+	}
+
+	var sub domain.WebhookSubscription
+	err = r.collection.FindOne(ctx, withTenant(ctx, bson.M{"_id": objectID}, tenantField)).Decode(&sub)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, domain.ErrWebhookNotFound
+		}
+		return nil, err
+	}
+
+	return &sub, nil
+}
+
+func (r *webhookRepository) GetAllSubscriptions(ctx context.Context) ([]*domain.WebhookSubscription, error) {
+	return r.find(ctx, bson.M{})
+}
+
+func (r *webhookRepository) GetActiveSubscriptions(ctx context.Context) ([]*domain.WebhookSubscription, error) {
+	return r.find(ctx, bson.M{"active": true})
+}
+
+func (r *webhookRepository) find(ctx context.Context, filter bson.M) ([]*domain.WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeouts.Batch)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := r.collection.Find(ctx, withTenant(ctx, filter, tenantField), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	subs := []*domain.WebhookSubscription{}
+	if err = cursor.All(ctx, &subs); err != nil {
+		return nil, err
+	}
+
+	return subs, nil
+}
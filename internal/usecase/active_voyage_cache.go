@@ -0,0 +1,65 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+)
+
+// ActiveVoyageCache remembers each ship's active voyage for a short time so
+// that every fix from a device feed does not cost a database lookup
+type ActiveVoyageCache struct {
+	voyageUseCase *VoyageUseCase
+	ttl           time.Duration
+
+	mu      sync.Mutex
+	entries map[string]voyageCacheEntry
+}
+
+type voyageCacheEntry struct {
+	voyageID string
+	err      error
+	expires  time.Time
+}
+
+// NewActiveVoyageCache creates a cache whose entries expire after ttl
+func NewActiveVoyageCache(voyageUseCase *VoyageUseCase, ttl time.Duration) *ActiveVoyageCache {
+	return &ActiveVoyageCache{
+		voyageUseCase: voyageUseCase,
+		ttl:           ttl,
+		entries:       make(map[string]voyageCacheEntry),
+	}
+}
+
+// VoyageID returns the voyage ID of the ship's in-progress voyage.
+// Ships without one are cached too, so they are not looked up on every fix;
+// other failures are not, so a database hiccup is retried on the next fix.
+func (c *ActiveVoyageCache) VoyageID(ctx context.Context, shipID string) (string, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[shipID]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.voyageID, entry.err
+	}
+
+	voyage, err := c.voyageUseCase.GetActiveVoyageByShipID(ctx, shipID)
+	if err != nil && !errors.Is(err, domain.ErrVoyageNotFound) {
+		return "", err
+	}
+
+	entry = voyageCacheEntry{err: err, expires: now.Add(c.ttl)}
+	if err == nil {
+		entry.voyageID = voyage.VoyageID
+	}
+
+	c.mu.Lock()
+	c.entries[shipID] = entry
+	c.mu.Unlock()
+
+	return entry.voyageID, entry.err
+}
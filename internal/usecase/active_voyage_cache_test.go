@@ -0,0 +1,56 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+)
+
+// activeVoyages answers GetActiveVoyageByShipID with a fixed result and
+// counts the lookups
+type activeVoyages struct {
+	domain.VoyageRepository
+	voyage  *domain.Voyage
+	err     error
+	lookups int
+}
+
+func (r *activeVoyages) GetActiveVoyageByShipID(context.Context, string) (*domain.Voyage, error) {
+	r.lookups++
+	return r.voyage, r.err
+}
+
+func TestActiveVoyageCache(t *testing.T) {
+	errTimeout := errors.New("server selection timeout")
+
+	tests := []struct {
+		name    string
+		repo    *activeVoyages
+		want    string
+		wantErr error
+		lookups int // after two calls
+	}{
+		{"found", &activeVoyages{voyage: &domain.Voyage{VoyageID: "V1"}}, "V1", nil, 1},
+		{"no active voyage", &activeVoyages{err: domain.ErrVoyageNotFound}, "", domain.ErrVoyageNotFound, 1},
+		{"transient failure", &activeVoyages{err: errTimeout}, "", errTimeout, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewActiveVoyageCache(NewVoyageUseCase(tt.repo, nil, nil, nil, nil), time.Minute)
+
+			for i := 0; i < 2; i++ {
+				voyageID, err := cache.VoyageID(context.Background(), "S1")
+				if voyageID != tt.want || !errors.Is(err, tt.wantErr) {
+					t.Fatalf("VoyageID = %q, %v, want %q, %v", voyageID, err, tt.want, tt.wantErr)
+				}
+			}
+			if tt.repo.lookups != tt.lookups {
+				t.Fatalf("lookups = %d, want %d", tt.repo.lookups, tt.lookups)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/chats/sailing-backend/pkg/ais"
+)
+
+// ErrPositionUnavailable is returned for AIS position reports without a valid position
+var ErrPositionUnavailable = errors.New("AIS position not available")
+
+// AISUseCase maps decoded AIS messages onto the active voyage of the
+// transmitting ship, identified by its MMSI
+type AISUseCase struct {
+	voyageRepo      domain.VoyageRepository
+	gpsTrackUseCase *GPSTrackUseCase
+}
+
+// NewAISUseCase creates a new AISUseCase
+func NewAISUseCase(voyageRepo domain.VoyageRepository, gpsTrackUseCase *GPSTrackUseCase) *AISUseCase {
+	return &AISUseCase{
+		voyageRepo:      voyageRepo,
+		gpsTrackUseCase: gpsTrackUseCase,
+	}
+}
+
+// AISReport is a decoded AIS message and the time it was received
+type AISReport struct {
+	Message    ais.Message
+	ReceivedAt time.Time
+}
+
+// AISIngestResult summarises the outcome of ingesting a set of AIS messages
+type AISIngestResult struct {
+	Tracks         []*domain.GPSTrack
+	UpdatedVoyages []string
+	// Errors holds per-report failures keyed by the report's index
+	Errors map[int]error
+}
+
+// aisTrack is a track converted from the report at index
+type aisTrack struct {
+	index int
+	track *domain.GPSTrack
+}
+
+// Ingest appends position reports as GPS tracks and applies static voyage
+// data to the active voyages of the ships that sent them. Reports that
+// cannot be matched to a voyage, or whose fix the voyage rejects, are listed
+// in the result; an error is returned only if persisting the tracks fails.
+func (uc *AISUseCase) Ingest(ctx context.Context, reports []AISReport) (*AISIngestResult, error) {
+	result := &AISIngestResult{Errors: make(map[int]error)}
+	voyages := make(map[uint32]*domain.Voyage)
+	lookupErrs := make(map[uint32]error)
+
+	var voyageOrder []string
+	tracks := make(map[string][]aisTrack) // by voyage ID
+
+	for i, report := range reports {
+		mmsi := report.Message.Source()
+		voyage, ok := voyages[mmsi]
+		if !ok {
+			if err, failed := lookupErrs[mmsi]; failed {
+				result.Errors[i] = err
+				continue
+			}
+
+			var err error
+			voyage, err = uc.voyageRepo.GetActiveVoyageByMMSI(ctx, ais.MMSIString(mmsi))
+			if err == nil {
+				err = checkShip(ctx, voyage.ShipID, voyage.VoyageID)
+			}
+			if err != nil {
+				err = fmt.Errorf("MMSI %s: %w", ais.MMSIString(mmsi), err)
+				lookupErrs[mmsi] = err
+				result.Errors[i] = err
+				continue
+			}
+			voyages[mmsi] = voyage
+		}
+
+		switch m := report.Message.(type) {
+		case *ais.PositionReport:
+			if !m.PositionValid {
+				result.Errors[i] = ErrPositionUnavailable
+				continue
+			}
+			if len(tracks[voyage.VoyageID]) == 0 {
+				voyageOrder = append(voyageOrder, voyage.VoyageID)
+			}
+			tracks[voyage.VoyageID] = append(tracks[voyage.VoyageID], aisTrack{
+				index: i,
+				track: trackFromPositionReport(voyage.VoyageID, m, report.ReceivedAt),
+			})
+
+		case *ais.StaticVoyageData:
+			if err := uc.applyStaticData(ctx, voyage, m, report.ReceivedAt); err != nil {
+				result.Errors[i] = err
+				continue
+			}
+			result.UpdatedVoyages = append(result.UpdatedVoyages, voyage.VoyageID)
+		}
+	}
+
+	// Each voyage's fixes are written as one batch, so a fix rejected by one
+	// voyage does not hold back the other ships' fixes
+	for _, voyageID := range voyageOrder {
+		if err := uc.writeTracks(ctx, tracks[voyageID], result); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// writeTracks stores one voyage's tracks, adding them to the result. If the
+// voyage rejects the batch, e.g. because one fix falls outside its window,
+// the tracks are written one by one and the rejected ones listed in the
+// result.
+func (uc *AISUseCase) writeTracks(ctx context.Context, pending []aisTrack, result *AISIngestResult) error {
+	batch := make([]*domain.GPSTrack, len(pending))
+	for i, p := range pending {
+		batch[i] = p.track
+	}
+
+	err := uc.gpsTrackUseCase.CreateGPSTracksBatch(ctx, batch, IngestOptions{})
+	if err == nil {
+		result.Tracks = append(result.Tracks, batch...)
+		return nil
+	}
+	if !IsRejected(err) {
+		return err
+	}
+
+	for _, p := range pending {
+		if err := uc.gpsTrackUseCase.CreateGPSTrack(ctx, p.track, IngestOptions{}); err != nil {
+			if !IsRejected(err) {
+				return err
+			}
+			result.Errors[p.index] = err
+			continue
+		}
+		result.Tracks = append(result.Tracks, p.track)
+	}
+	return nil
+}
+
+// applyStaticData updates a voyage's destination, ETA and draught from a type 5 report
+func (uc *AISUseCase) applyStaticData(ctx context.Context, voyage *domain.Voyage, m *ais.StaticVoyageData, receivedAt time.Time) error {
+	before := *voyage
+
+	voyage.Destination = m.Destination
+	voyage.Draught = m.Draught
+	if eta, ok := m.ETA(receivedAt); ok {
+		voyage.ETA = &eta
+	} else {
+		voyage.ETA = nil
+	}
+	voyage.UpdatedBy = domain.SubjectFromContext(ctx)
+	voyage.UpdatedAt = time.Now()
+
+	if err := uc.voyageRepo.UpdateVoyage(ctx, voyage); err != nil {
+		return err
+	}
+
+	domain.RecordChange(ctx, domain.AuditActionUpdate, domain.AuditEntityVoyage, voyage.VoyageID, &before, voyage)
+	return nil
+}
+
+// trackFromPositionReport converts an AIS position report into a GPS track
+func trackFromPositionReport(voyageID string, m *ais.PositionReport, receivedAt time.Time) *domain.GPSTrack {
+	heading := m.Course
+	if m.HasHeading {
+		heading = m.Heading
+	}
+
+	return &domain.GPSTrack{
+		VoyageID: voyageID,
+		Location: domain.Location{
+			Latitude:  m.Latitude,
+			Longitude: m.Longitude,
+		},
+		Speed:     m.Speed,
+		Heading:   heading,
+		Timestamp: m.Timestamp(receivedAt),
+	}
+}
@@ -0,0 +1,98 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/chats/sailing-backend/pkg/ais"
+)
+
+// trackStore keeps voyages and written tracks in memory
+type trackStore struct {
+	domain.VoyageRepository
+	domain.GPSTrackRepository
+	domain.OutboxRepository
+
+	voyages map[string]*domain.Voyage // by MMSI
+	tracks  []*domain.GPSTrack
+	batches int
+}
+
+func (s *trackStore) GetActiveVoyageByMMSI(_ context.Context, mmsi string) (*domain.Voyage, error) {
+	if voyage, ok := s.voyages[mmsi]; ok {
+		return voyage, nil
+	}
+	return nil, domain.ErrVoyageNotFound
+}
+
+func (s *trackStore) GetVoyageByVoyageID(_ context.Context, voyageID string) (*domain.Voyage, error) {
+	for _, voyage := range s.voyages {
+		if voyage.VoyageID == voyageID {
+			return voyage, nil
+		}
+	}
+	return nil, domain.ErrVoyageNotFound
+}
+
+func (s *trackStore) TransactionalWrites(context.Context) bool {
+	return false
+}
+
+func (s *trackStore) CreateGPSTracksBatch(_ context.Context, tracks []*domain.GPSTrack) error {
+	s.batches++
+	s.tracks = append(s.tracks, tracks...)
+	return nil
+}
+
+func (s *trackStore) AddEvents(context.Context, []*domain.Event) error {
+	return nil
+}
+
+func (s *trackStore) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func TestAISIngestWritesPerVoyage(t *testing.T) {
+	now := time.Now()
+	store := &trackStore{voyages: map[string]*domain.Voyage{
+		// Departed half a minute ago: a fix from earlier in the minute is rejected
+		"211000001": {VoyageID: "V1", ShipID: "S1", Status: domain.VoyageStatusInProgress, DepartureTime: now.Add(-30 * time.Second)},
+		"211000002": {VoyageID: "V2", ShipID: "S2", Status: domain.VoyageStatusInProgress, DepartureTime: now.Add(-time.Hour)},
+	}}
+	uc := NewAISUseCase(store, NewGPSTrackUseCase(store, store, store, store))
+
+	report := func(mmsi uint32, received time.Time) AISReport {
+		return AISReport{
+			Message:    &ais.PositionReport{Type: 1, MMSI: mmsi, Latitude: 40, Longitude: -70, PositionValid: true, Second: 60},
+			ReceivedAt: received,
+		}
+	}
+	reports := []AISReport{
+		report(211000001, now.Add(-time.Minute)), // before V1 departed
+		report(211000001, now),
+		report(211000002, now),
+		report(211000003, now), // no voyage
+		report(211000002, now),
+	}
+
+	result, err := uc.Ingest(context.Background(), reports)
+	if err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+
+	if len(result.Tracks) != 3 || len(store.tracks) != 3 {
+		t.Fatalf("stored %d tracks, result lists %d; want 3", len(store.tracks), len(result.Tracks))
+	}
+	if len(result.Errors) != 2 {
+		t.Fatalf("errors = %v, want reports 0 and 3", result.Errors)
+	}
+	if !errors.Is(result.Errors[0], domain.ErrTimestampBeforeDeparture) {
+		t.Errorf("report 0: %v, want ErrTimestampBeforeDeparture", result.Errors[0])
+	}
+	if !errors.Is(result.Errors[3], domain.ErrVoyageNotFound) {
+		t.Errorf("report 3: %v, want ErrVoyageNotFound", result.Errors[3])
+	}
+}
@@ -0,0 +1,319 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// apiKeyPrefix starts every managed API key so leaked keys are easy to spot
+	apiKeyPrefix = "sk_"
+	// apiKeyMissTTL is how long an unknown key ID is remembered, so repeated
+	// guesses do not each cost a database lookup
+	apiKeyMissTTL = 5 * time.Second
+	// apiKeyCacheSweepSize is the cache size at which expired entries are removed
+	apiKeyCacheSweepSize = 10000
+)
+
+// APIKeyUseCase manages API keys and authenticates requests made with them
+type APIKeyUseCase struct {
+	apiKeyRepo domain.APIKeyRepository
+	cacheTTL   time.Duration
+
+	mu      sync.Mutex
+	cache   map[string]cachedAPIKey // by key ID
+	sweepAt time.Time
+}
+
+// cachedAPIKey is a looked up key, or a nil key for an unknown key ID
+type cachedAPIKey struct {
+	key     *domain.APIKey
+	expires time.Time
+}
+
+// NewAPIKeyUseCase creates a new APIKeyUseCase. Keys are cached for cacheTTL
+// after they are looked up, so revocations made by other instances take up
+// to cacheTTL to apply.
+func NewAPIKeyUseCase(apiKeyRepo domain.APIKeyRepository, cacheTTL time.Duration) *APIKeyUseCase {
+	if cacheTTL <= 0 {
+		cacheTTL = 30 * time.Second
+	}
+
+	return &APIKeyUseCase{
+		apiKeyRepo: apiKeyRepo,
+		cacheTTL:   cacheTTL,
+		cache:      make(map[string]cachedAPIKey),
+	}
+}
+
+// CreateAPIKey validates and stores a new key and returns the full key,
+// which cannot be recovered later
+func (uc *APIKeyUseCase) CreateAPIKey(ctx context.Context, key *domain.APIKey) (string, error) {
+	if err := validateAPIKey(key); err != nil {
+		return "", err
+	}
+	if len(key.Scopes) == 0 {
+		key.Scopes = key.Role.Scopes()
+	}
+	if err := checkGrantable(ctx, key.Scopes, domain.ErrInvalidAPIKey); err != nil {
+		return "", err
+	}
+	key.TenantID = assignTenant(ctx, key.TenantID)
+	if key.TenantID == "" && !key.Principal().Platform() {
+		return "", fmt.Errorf("%w: keys without a tenant_id need the %s scope", domain.ErrInvalidAPIKey, domain.ScopePlatform)
+	}
+	if key.Owner == "" {
+		if principal := domain.PrincipalFromContext(ctx); principal != nil {
+			key.Owner = principal.Subject
+		}
+	}
+
+	keyID, err := randomHex(8)
+	if err != nil {
+		return "", err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+
+	key.KeyID = keyID
+	key.SecretHash = hashAPIKeySecret(secret)
+	key.CreatedBy = domain.SubjectFromContext(ctx)
+	key.UpdatedBy = key.CreatedBy
+	key.CreatedAt = time.Now()
+	key.UpdatedAt = time.Now()
+
+	if err := uc.apiKeyRepo.CreateAPIKey(ctx, key); err != nil {
+		return "", err
+	}
+
+	domain.RecordChange(ctx, domain.AuditActionCreate, domain.AuditEntityAPIKey, key.ID.Hex(), nil, key)
+	return formatAPIKey(keyID, secret), nil
+}
+
+// GetAPIKey retrieves a key by ID
+func (uc *APIKeyUseCase) GetAPIKey(ctx context.Context, id string) (*domain.APIKey, error) {
+	return uc.apiKeyRepo.GetAPIKeyByID(ctx, id)
+}
+
+// GetAllAPIKeys retrieves all keys, including revoked and expired ones
+func (uc *APIKeyUseCase) GetAllAPIKeys(ctx context.Context) ([]*domain.APIKey, error) {
+	return uc.apiKeyRepo.GetAllAPIKeys(ctx)
+}
+
+// RotateAPIKey replaces a key's secret and returns the new full key. The old
+// secret stops working immediately on this instance.
+func (uc *APIKeyUseCase) RotateAPIKey(ctx context.Context, id string) (*domain.APIKey, string, error) {
+	key, err := uc.apiKeyRepo.GetAPIKeyByID(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	if key.RevokedAt != nil {
+		return nil, "", fmt.Errorf("%w: key is revoked", domain.ErrInvalidAPIKey)
+	}
+
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	before := *key
+	key.SecretHash = hashAPIKeySecret(secret)
+	key.UpdatedBy = domain.SubjectFromContext(ctx)
+	key.UpdatedAt = time.Now()
+	if err := uc.apiKeyRepo.UpdateAPIKey(ctx, key); err != nil {
+		return nil, "", err
+	}
+
+	uc.invalidate(key.KeyID)
+	domain.RecordChange(ctx, domain.AuditActionUpdate, domain.AuditEntityAPIKey, key.ID.Hex(), &before, key)
+	return key, formatAPIKey(key.KeyID, secret), nil
+}
+
+// RevokeAPIKey permanently disables a key
+func (uc *APIKeyUseCase) RevokeAPIKey(ctx context.Context, id string) (*domain.APIKey, error) {
+	key, err := uc.apiKeyRepo.GetAPIKeyByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt != nil {
+		return key, nil
+	}
+
+	before := *key
+	now := time.Now()
+	key.RevokedAt = &now
+	key.UpdatedBy = domain.SubjectFromContext(ctx)
+	key.UpdatedAt = now
+	if err := uc.apiKeyRepo.UpdateAPIKey(ctx, key); err != nil {
+		return nil, err
+	}
+
+	uc.invalidate(key.KeyID)
+	domain.RecordChange(ctx, domain.AuditActionUpdate, domain.AuditEntityAPIKey, key.ID.Hex(), &before, key)
+	return key, nil
+}
+
+// Authenticate returns the principal for a full API key. Unknown, revoked,
+// expired and malformed keys all return domain.ErrInvalidAPIKey.
+func (uc *APIKeyUseCase) Authenticate(ctx context.Context, apiKey string) (*domain.Principal, error) {
+	keyID, secret, ok := parseAPIKey(apiKey)
+	if !ok {
+		return nil, domain.ErrInvalidAPIKey
+	}
+
+	key, loaded, err := uc.lookup(ctx, keyID)
+	if err != nil {
+		if err == domain.ErrAPIKeyNotFound {
+			return nil, domain.ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	hash := hashAPIKeySecret(secret)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(key.SecretHash)) != 1 {
+		return nil, domain.ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	if !key.Usable(now) {
+		return nil, domain.ErrInvalidAPIKey
+	}
+
+	// Recording every use would add a write to each request; recording it
+	// when the key is loaded keeps last_used_at accurate to the cache TTL
+	if loaded {
+		touched := *key
+		touched.LastUsedAt = &now
+		if err := uc.apiKeyRepo.TouchAPIKey(ctx, &touched); err != nil {
+			log.Warn().Err(err).Str("key_id", key.KeyID).Msg("Failed to record API key use")
+		}
+	}
+
+	return key.Principal(), nil
+}
+
+// lookup returns the key with keyID, from the cache if possible, and
+// whether it was loaded from the repository. Unknown key IDs are cached
+// for apiKeyMissTTL.
+func (uc *APIKeyUseCase) lookup(ctx context.Context, keyID string) (*domain.APIKey, bool, error) {
+	now := time.Now()
+
+	uc.mu.Lock()
+	entry, ok := uc.cache[keyID]
+	uc.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		if entry.key == nil {
+			return nil, false, domain.ErrAPIKeyNotFound
+		}
+		return entry.key, false, nil
+	}
+
+	key, err := uc.apiKeyRepo.GetAPIKeyByKeyID(ctx, keyID)
+	switch {
+	case err == domain.ErrAPIKeyNotFound:
+		uc.store(keyID, cachedAPIKey{expires: now.Add(apiKeyMissTTL)}, now)
+		return nil, false, err
+	case err != nil:
+		return nil, false, err
+	}
+
+	uc.store(keyID, cachedAPIKey{key: key, expires: now.Add(uc.cacheTTL)}, now)
+	return key, true, nil
+}
+
+// store caches entry. Expired entries are swept at most once per
+// apiKeyMissTTL once the cache holds apiKeyCacheSweepSize keys; if it is
+// still that full, unknown key IDs are not cached.
+func (uc *APIKeyUseCase) store(keyID string, entry cachedAPIKey, now time.Time) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	if len(uc.cache) >= apiKeyCacheSweepSize && now.After(uc.sweepAt) {
+		for k, e := range uc.cache {
+			if now.After(e.expires) {
+				delete(uc.cache, k)
+			}
+		}
+		uc.sweepAt = now.Add(apiKeyMissTTL)
+	}
+	if entry.key == nil && len(uc.cache) >= apiKeyCacheSweepSize {
+		return
+	}
+	uc.cache[keyID] = entry
+}
+
+func (uc *APIKeyUseCase) invalidate(keyID string) {
+	uc.mu.Lock()
+	delete(uc.cache, keyID)
+	uc.mu.Unlock()
+}
+
+// validateAPIKey checks the fields a client may set on a new key
+func validateAPIKey(key *domain.APIKey) error {
+	if strings.TrimSpace(key.Name) == "" {
+		return fmt.Errorf("%w: name is required", domain.ErrInvalidAPIKey)
+	}
+	if !key.Role.Valid() {
+		return fmt.Errorf("%w: unknown role %q", domain.ErrInvalidAPIKey, key.Role)
+	}
+	for _, scope := range key.Scopes {
+		if !scope.Valid() {
+			return fmt.Errorf("%w: unknown scope %q", domain.ErrInvalidAPIKey, scope)
+		}
+		if !key.Role.Allows(scope) {
+			return fmt.Errorf("%w: role %q does not allow scope %q", domain.ErrInvalidAPIKey, key.Role, scope)
+		}
+	}
+	if key.Role == domain.RoleVessel && key.ShipID == "" {
+		return fmt.Errorf("%w: vessel keys require a ship_id", domain.ErrInvalidAPIKey)
+	}
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(time.Now()) {
+		return fmt.Errorf("%w: expires_at must be in the future", domain.ErrInvalidAPIKey)
+	}
+	return nil
+}
+
+// formatAPIKey builds the full key handed to the client: sk_<key ID>_<secret>
+func formatAPIKey(keyID, secret string) string {
+	return apiKeyPrefix + keyID + "_" + secret
+}
+
+// parseAPIKey splits a full key into its key ID and secret
+func parseAPIKey(apiKey string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(apiKey, apiKeyPrefix)
+	if !ok {
+		return "", "", false
+	}
+	keyID, secret, ok := strings.Cut(rest, "_")
+	if !ok || keyID == "" || secret == "" {
+		return "", "", false
+	}
+	return keyID, secret, true
+}
+
+// hashAPIKeySecret hashes a secret for storage. Secrets are 256 random bits,
+// so a fast unsalted hash is enough.
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
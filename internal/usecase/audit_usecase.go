@@ -0,0 +1,166 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/rs/zerolog/log"
+)
+
+// auditWriteAttempts is how often a batch of audit entries is written before
+// it is logged instead
+const auditWriteAttempts = 3
+
+// AuditConfig configures the audit log writer
+type AuditConfig struct {
+	BatchSize     int           // entries written per bulk insert
+	FlushInterval time.Duration // maximum time an entry waits in a partial batch
+	QueueSize     int           // entries buffered before Record writes synchronously
+	WriteTimeout  time.Duration // bounds one attempt to write a batch
+}
+
+// AuditUseCase writes the audit log in the background and queries it
+type AuditUseCase struct {
+	auditRepo domain.AuditRepository
+	cfg       AuditConfig
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan *domain.AuditEntry
+	done   chan struct{}
+}
+
+// NewAuditUseCase creates a new AuditUseCase
+func NewAuditUseCase(auditRepo domain.AuditRepository, cfg AuditConfig) *AuditUseCase {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 10000
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = 30 * time.Second
+	}
+
+	return &AuditUseCase{
+		auditRepo: auditRepo,
+		cfg:       cfg,
+		queue:     make(chan *domain.AuditEntry, cfg.QueueSize),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start launches the background writer
+func (uc *AuditUseCase) Start() {
+	go uc.write()
+}
+
+// Record queues an entry for writing. When the queue is full, or during
+// shutdown, the entry is written before Record returns so none are dropped.
+func (uc *AuditUseCase) Record(entry *domain.AuditEntry) {
+	uc.mu.RLock()
+	if !uc.closed {
+		select {
+		case uc.queue <- entry:
+			uc.mu.RUnlock()
+			return
+		default:
+		}
+	}
+	uc.mu.RUnlock()
+
+	uc.flush([]*domain.AuditEntry{entry})
+}
+
+// Shutdown writes the queued entries
+func (uc *AuditUseCase) Shutdown(ctx context.Context) error {
+	uc.mu.Lock()
+	uc.closed = true
+	close(uc.queue)
+	uc.mu.Unlock()
+
+	select {
+	case <-uc.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d audit entries not written: %w", len(uc.queue), ctx.Err())
+	}
+}
+
+// GetEntries lists audit entries, newest first
+func (uc *AuditUseCase) GetEntries(ctx context.Context, filter domain.AuditFilter, limit, offset int) ([]*domain.AuditEntry, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return uc.auditRepo.FindAuditEntries(ctx, filter, limit, offset)
+}
+
+// write drains the queue, flushing once BatchSize entries are collected or
+// FlushInterval has passed
+func (uc *AuditUseCase) write() {
+	defer close(uc.done)
+
+	ticker := time.NewTicker(uc.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	var batch []*domain.AuditEntry
+	for {
+		select {
+		case entry, ok := <-uc.queue:
+			if !ok {
+				uc.flush(batch)
+				return
+			}
+			batch = append(batch, entry)
+			if len(batch) >= uc.cfg.BatchSize {
+				uc.flush(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				uc.flush(batch)
+				batch = nil
+			}
+		}
+	}
+}
+
+// flush writes a batch, retrying with a short backoff
+func (uc *AuditUseCase) flush(batch []*domain.AuditEntry) {
+	if len(batch) == 0 {
+		return
+	}
+
+	var err error
+	for attempt := 1; attempt <= auditWriteAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), uc.cfg.WriteTimeout)
+		err = uc.auditRepo.InsertAuditEntries(ctx, batch)
+		cancel()
+		if err == nil {
+			return
+		}
+
+		log.Warn().Err(err).Int("attempt", attempt).Int("count", len(batch)).Msg("Failed to write audit entries")
+		time.Sleep(time.Duration(attempt) * 500 * time.Millisecond)
+	}
+
+	// The entries still reach the application log
+	for _, entry := range batch {
+		log.Error().Err(err).
+			Str("principal", entry.Principal).
+			Str("method", entry.Method).
+			Str("path", entry.Path).
+			Int("status", entry.Status).
+			Int("changes", len(entry.Changes)).
+			Msg("Dropped audit entry")
+	}
+}
@@ -0,0 +1,273 @@
+package usecase
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// refreshTokenPrefix starts every refresh token so leaked tokens are easy to spot
+const refreshTokenPrefix = "rt_"
+
+// TokenConfig controls the tokens issued by AuthUseCase
+type TokenConfig struct {
+	// Secret signs access tokens with HS256; AuthMiddleware verifies them
+	// with the same secret
+	Secret          string
+	Issuer          string
+	Audience        string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+}
+
+// Tokens is the result of a successful grant
+type Tokens struct {
+	AccessToken  string
+	ExpiresIn    time.Duration
+	RefreshToken string // empty for the client credentials grant
+	Scopes       []domain.Scope
+}
+
+// AuthUseCase issues access and refresh tokens
+type AuthUseCase struct {
+	userRepo         domain.UserRepository
+	refreshTokenRepo domain.RefreshTokenRepository
+	apiKeys          *APIKeyUseCase
+	cfg              TokenConfig
+
+	// dummyHash is compared against when a username is unknown so that
+	// unknown and known users take as long to reject
+	dummyHash []byte
+}
+
+// NewAuthUseCase creates a new AuthUseCase
+func NewAuthUseCase(userRepo domain.UserRepository, refreshTokenRepo domain.RefreshTokenRepository, apiKeys *APIKeyUseCase, cfg TokenConfig) *AuthUseCase {
+	if cfg.AccessTokenTTL <= 0 {
+		cfg.AccessTokenTTL = 15 * time.Minute
+	}
+	if cfg.RefreshTokenTTL <= 0 {
+		cfg.RefreshTokenTTL = 30 * 24 * time.Hour
+	}
+
+	dummyHash, _ := bcrypt.GenerateFromPassword([]byte("unused-password"), bcrypt.DefaultCost)
+
+	return &AuthUseCase{
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		apiKeys:          apiKeys,
+		cfg:              cfg,
+		dummyHash:        dummyHash,
+	}
+}
+
+// PasswordGrant signs a user in and starts a new refresh token family
+func (uc *AuthUseCase) PasswordGrant(ctx context.Context, username, password string) (*Tokens, error) {
+	user, err := uc.userRepo.GetUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			bcrypt.CompareHashAndPassword(uc.dummyHash, []byte(password))
+			return nil, domain.ErrInvalidGrant
+		}
+		return nil, err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil || user.Disabled {
+		return nil, domain.ErrInvalidGrant
+	}
+
+	family, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	return uc.issueUserTokens(ctx, user, family)
+}
+
+// ClientCredentialsGrant exchanges a managed API key for an access token.
+// The client ID is the key's key_id and the secret is the full key. No
+// refresh token is issued (RFC 6749 section 4.4.3): the client can
+// repeat the grant whenever it needs a new token.
+func (uc *AuthUseCase) ClientCredentialsGrant(ctx context.Context, clientID, clientSecret string) (*Tokens, error) {
+	keyID, _, ok := parseAPIKey(clientSecret)
+	if !ok || subtle.ConstantTimeCompare([]byte(keyID), []byte(clientID)) != 1 {
+		return nil, domain.ErrInvalidAPIKey
+	}
+
+	principal, err := uc.apiKeys.Authenticate(ctx, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := uc.signAccessToken(principal)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tokens{
+		AccessToken: accessToken,
+		ExpiresIn:   uc.cfg.AccessTokenTTL,
+		Scopes:      principal.Scopes,
+	}, nil
+}
+
+// RefreshGrant exchanges a refresh token for new tokens. The presented token
+// is used up; presenting it again revokes every token descended from the
+// same sign-in, since only a copied token would be replayed.
+func (uc *AuthUseCase) RefreshGrant(ctx context.Context, refreshToken string) (*Tokens, error) {
+	stored, err := uc.findRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if stored.RevokedAt != nil || !now.Before(stored.ExpiresAt) {
+		return nil, domain.ErrInvalidGrant
+	}
+
+	marked := false
+	if stored.UsedAt == nil {
+		marked, err = uc.refreshTokenRepo.MarkRefreshTokenUsed(ctx, stored.ID, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !marked {
+		log.Warn().Str("family_id", stored.FamilyID).Str("user_id", stored.UserID.Hex()).Msg("Refresh token reused; revoking its family")
+		if err := uc.refreshTokenRepo.RevokeFamily(ctx, stored.FamilyID, now); err != nil {
+			return nil, err
+		}
+		return nil, domain.ErrInvalidGrant
+	}
+
+	// The user may have changed or been disabled since the token was issued
+	user, err := uc.userRepo.GetUserByID(ctx, stored.UserID.Hex())
+	if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+		return nil, err
+	}
+	if user == nil || user.Disabled {
+		if err := uc.refreshTokenRepo.RevokeFamily(ctx, stored.FamilyID, now); err != nil {
+			return nil, err
+		}
+		return nil, domain.ErrInvalidGrant
+	}
+
+	return uc.issueUserTokens(ctx, user, stored.FamilyID)
+}
+
+// RevokeRefreshToken revokes a refresh token and every token rotated from
+// the same sign-in. Unknown tokens are ignored (RFC 7009 section 2.2).
+func (uc *AuthUseCase) RevokeRefreshToken(ctx context.Context, refreshToken string) error {
+	stored, err := uc.findRefreshToken(ctx, refreshToken)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidGrant) {
+			return nil
+		}
+		return err
+	}
+
+	return uc.refreshTokenRepo.RevokeFamily(ctx, stored.FamilyID, time.Now())
+}
+
+func (uc *AuthUseCase) findRefreshToken(ctx context.Context, refreshToken string) (*domain.RefreshToken, error) {
+	if !strings.HasPrefix(refreshToken, refreshTokenPrefix) {
+		return nil, domain.ErrInvalidGrant
+	}
+
+	stored, err := uc.refreshTokenRepo.GetRefreshTokenByHash(ctx, hashAPIKeySecret(refreshToken))
+	if err != nil {
+		if errors.Is(err, domain.ErrRefreshTokenNotFound) {
+			return nil, domain.ErrInvalidGrant
+		}
+		return nil, err
+	}
+	return stored, nil
+}
+
+// issueUserTokens signs an access token for the user and stores a new
+// refresh token in the family
+func (uc *AuthUseCase) issueUserTokens(ctx context.Context, user *domain.User, family string) (*Tokens, error) {
+	principal := user.Principal()
+	accessToken, err := uc.signAccessToken(principal)
+	if err != nil {
+		return nil, err
+	}
+
+	// Refresh tokens are random like API key secrets, so they are stored
+	// the same way
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken := refreshTokenPrefix + secret
+
+	now := time.Now()
+	err = uc.refreshTokenRepo.CreateRefreshToken(ctx, &domain.RefreshToken{
+		TokenHash: hashAPIKeySecret(refreshToken),
+		FamilyID:  family,
+		UserID:    user.ID,
+		ExpiresAt: now.Add(uc.cfg.RefreshTokenTTL),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tokens{
+		AccessToken:  accessToken,
+		ExpiresIn:    uc.cfg.AccessTokenTTL,
+		RefreshToken: refreshToken,
+		Scopes:       principal.Scopes,
+	}, nil
+}
+
+// signAccessToken builds a JWT carrying the claims AuthMiddleware reads
+func (uc *AuthUseCase) signAccessToken(principal *domain.Principal) (string, error) {
+	if uc.cfg.Secret == "" {
+		return "", errors.New("no JWT secret configured for issuing tokens")
+	}
+
+	jti, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+
+	scopes := make([]string, len(principal.Scopes))
+	for i, scope := range principal.Scopes {
+		scopes[i] = string(scope)
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub":   principal.Subject,
+		"role":  string(principal.Role),
+		"scope": strings.Join(scopes, " "),
+		"iat":   now.Unix(),
+		"exp":   now.Add(uc.cfg.AccessTokenTTL).Unix(),
+		"jti":   jti,
+	}
+	if principal.ShipID != "" {
+		claims["ship_id"] = principal.ShipID
+	}
+	if principal.TenantID != "" {
+		claims["tenant_id"] = principal.TenantID
+	}
+	if uc.cfg.Issuer != "" {
+		claims["iss"] = uc.cfg.Issuer
+	}
+	if uc.cfg.Audience != "" {
+		claims["aud"] = uc.cfg.Audience
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(uc.cfg.Secret))
+	if err != nil {
+		return "", fmt.Errorf("sign access token: %w", err)
+	}
+	return signed, nil
+}
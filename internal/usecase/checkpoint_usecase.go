@@ -2,63 +2,126 @@ package usecase
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"time"
 
 	"github.com/chats/sailing-backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // CheckpointUseCase handles checkpoint business logic
 type CheckpointUseCase struct {
 	checkpointRepo domain.CheckpointRepository
 	voyageRepo     domain.VoyageRepository
+	tx             domain.Transactor
+	outbox         domain.OutboxRepository
 }
 
 // NewCheckpointUseCase creates a new CheckpointUseCase
-func NewCheckpointUseCase(checkpointRepo domain.CheckpointRepository, voyageRepo domain.VoyageRepository) *CheckpointUseCase {
+func NewCheckpointUseCase(checkpointRepo domain.CheckpointRepository, voyageRepo domain.VoyageRepository, tx domain.Transactor, outbox domain.OutboxRepository) *CheckpointUseCase {
 	return &CheckpointUseCase{
 		checkpointRepo: checkpointRepo,
 		voyageRepo:     voyageRepo,
+		tx:             tx,
+		outbox:         outbox,
 	}
 }
 
 // CreateCheckpoint creates a new checkpoint
-func (uc *CheckpointUseCase) CreateCheckpoint(ctx context.Context, checkpoint *domain.Checkpoint) error {
+func (uc *CheckpointUseCase) CreateCheckpoint(ctx context.Context, checkpoint *domain.Checkpoint, opts IngestOptions) error {
 	if checkpoint.VoyageID == "" {
-		return errors.New("voyage_id is required")
+		return fmt.Errorf("%w: voyage_id is required", domain.ErrInvalidInput)
 	}
 
-	// Verify voyage exists
-	_, err := uc.voyageRepo.GetVoyageByVoyageID(ctx, checkpoint.VoyageID)
+	voyage, err := loadVoyage(ctx, uc.voyageRepo, checkpoint.VoyageID)
 	if err != nil {
-		return errors.New("voyage not found")
+		return err
+	}
+	if err := checkShip(ctx, voyage.ShipID, voyage.VoyageID); err != nil {
+		return err
 	}
 
-	checkpoint.CreatedAt = time.Now()
-	if checkpoint.Timestamp.IsZero() {
-		checkpoint.Timestamp = time.Now()
+	event, err := prepareCheckpoint(ctx, voyage, checkpoint, opts)
+	if err != nil {
+		return err
+	}
+
+	err = uc.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := uc.checkpointRepo.CreateCheckpoint(ctx, checkpoint); err != nil {
+			return err
+		}
+		return uc.outbox.AddEvents(ctx, []*domain.Event{event})
+	})
+	if err != nil {
+		return err
 	}
 
-	return uc.checkpointRepo.CreateCheckpoint(ctx, checkpoint)
+	domain.RecordChange(ctx, domain.AuditActionCreate, domain.AuditEntityCheckpoint, checkpoint.ID.Hex(), nil, nil)
+	return nil
 }
 
 // CreateCheckpointsBatch creates multiple checkpoints
-func (uc *CheckpointUseCase) CreateCheckpointsBatch(ctx context.Context, checkpoints []*domain.Checkpoint) error {
+func (uc *CheckpointUseCase) CreateCheckpointsBatch(ctx context.Context, checkpoints []*domain.Checkpoint, opts IngestOptions) error {
 	if len(checkpoints) == 0 {
-		return errors.New("no checkpoints provided")
+		return fmt.Errorf("%w: no checkpoints provided", domain.ErrInvalidInput)
 	}
 
+	voyages := newVoyageCache(uc.voyageRepo)
+	events := make([]*domain.Event, 0, len(checkpoints))
+
 	// Validate and set timestamps
-	for _, checkpoint := range checkpoints {
+	for i, checkpoint := range checkpoints {
 		if checkpoint.VoyageID == "" {
-			return errors.New("voyage_id is required for all checkpoints")
+			return fmt.Errorf("%w: voyage_id is required for all checkpoints", domain.ErrInvalidInput)
 		}
 
-		checkpoint.CreatedAt = time.Now()
-		if checkpoint.Timestamp.IsZero() {
-			checkpoint.Timestamp = time.Now()
+		voyage, err := voyages.get(ctx, checkpoint.VoyageID)
+		if err != nil {
+			return fmt.Errorf("checkpoint %d: %w", i, err)
+		}
+		if err := checkShip(ctx, voyage.ShipID, voyage.VoyageID); err != nil {
+			return fmt.Errorf("checkpoint %d: %w", i, err)
 		}
+
+		event, err := prepareCheckpoint(ctx, voyage, checkpoint, opts)
+		if err != nil {
+			return fmt.Errorf("checkpoint %d: %w", i, err)
+		}
+		events = append(events, event)
+	}
+
+	err := uc.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := uc.checkpointRepo.CreateCheckpointsBatch(ctx, checkpoints); err != nil {
+			return err
+		}
+		return uc.outbox.AddEvents(ctx, events)
+	})
+	if err != nil {
+		return err
+	}
+
+	voyageIDs := make([]string, len(checkpoints))
+	for i, checkpoint := range checkpoints {
+		voyageIDs[i] = checkpoint.VoyageID
+	}
+	recordBatch(ctx, domain.AuditEntityCheckpoint, voyageIDs)
+	return nil
+}
+
+// prepareCheckpoint stamps a checkpoint, checks it against its voyage and
+// builds the event announcing it
+func prepareCheckpoint(ctx context.Context, voyage *domain.Voyage, checkpoint *domain.Checkpoint, opts IngestOptions) (*domain.Event, error) {
+	checkpoint.CreatedBy = domain.SubjectFromContext(ctx)
+	checkpoint.CreatedAt = time.Now()
+	if checkpoint.Timestamp.IsZero() {
+		checkpoint.Timestamp = time.Now()
+	}
+
+	if err := checkVoyageWindow(voyage, checkpoint.Timestamp, opts); err != nil {
+		return nil, err
 	}
 
-	return uc.checkpointRepo.CreateCheckpointsBatch(ctx, checkpoints)
+	checkpoint.ID = primitive.NewObjectID()
+	checkpoint.TenantID = voyage.TenantID
+	return domain.NewEvent(domain.EventCheckpointCreated, voyage, &checkpoint.Location, checkpoint)
 }
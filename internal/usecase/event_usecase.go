@@ -0,0 +1,24 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/chats/sailing-backend/internal/domain"
+)
+
+// EventUseCase handles access to the persisted event log
+type EventUseCase struct {
+	eventRepo domain.EventRepository
+}
+
+// NewEventUseCase creates a new EventUseCase
+func NewEventUseCase(eventRepo domain.EventRepository) *EventUseCase {
+	return &EventUseCase{
+		eventRepo: eventRepo,
+	}
+}
+
+// ReplayEvents calls fn for each logged event after seq, oldest first
+func (uc *EventUseCase) ReplayEvents(ctx context.Context, seq int64, fn func(*domain.Event) error) error {
+	return uc.eventRepo.StreamEventsAfter(ctx, seq, fn)
+}
@@ -0,0 +1,390 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+const (
+	// ingestWriteAttempts is how often a batch write is tried before it is
+	// split or put back for a later flush
+	ingestWriteAttempts = 3
+	// ingestMaxRetryDelay bounds the wait between flushes of entries that failed to write
+	ingestMaxRetryDelay = 30 * time.Second
+	// sharedVoyageCacheSweepSize is the cache size at which expired entries are removed
+	sharedVoyageCacheSweepSize = 10000
+)
+
+// GPSIngestConfig configures the buffered GPS ingestion queue
+type GPSIngestConfig struct {
+	BatchSize      int           // tracks written per bulk insert
+	FlushInterval  time.Duration // maximum time a track waits in a partial batch
+	QueueSize      int           // accepted tracks not yet written before Enqueue refuses more
+	Writers        int           // concurrent bulk writers
+	VoyageCacheTTL time.Duration // how long voyage lookups are reused
+	WriteTimeout   time.Duration // bounds one attempt to write a batch
+}
+
+// GPSIngestQueue validates GPS tracks synchronously and writes them
+// asynchronously, coalescing tracks from many requests into bulk inserts
+type GPSIngestQueue struct {
+	gpsTrackUseCase *GPSTrackUseCase
+	cfg             GPSIngestConfig
+	voyages         *sharedVoyageCache
+
+	pending atomic.Int64
+
+	mu       sync.RWMutex
+	closed   bool
+	queue    chan *ingestEntry
+	abort    chan struct{} // closed when Shutdown gives up waiting
+	shutdown sync.Once
+	writers  sync.WaitGroup
+}
+
+// ingestEntry is one accepted request's tracks and the events announcing them
+type ingestEntry struct {
+	tracks []*domain.GPSTrack
+	events []*domain.Event
+}
+
+// NewGPSIngestQueue creates a new ingestion queue writing through gpsTrackUseCase
+func NewGPSIngestQueue(gpsTrackUseCase *GPSTrackUseCase, cfg GPSIngestConfig) *GPSIngestQueue {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 200 * time.Millisecond
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 20000
+	}
+	if cfg.Writers <= 0 {
+		cfg.Writers = 2
+	}
+	if cfg.VoyageCacheTTL <= 0 {
+		cfg.VoyageCacheTTL = 5 * time.Second
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = 30 * time.Second
+	}
+
+	return &GPSIngestQueue{
+		gpsTrackUseCase: gpsTrackUseCase,
+		cfg:             cfg,
+		voyages:         newSharedVoyageCache(gpsTrackUseCase.voyageRepo, cfg.VoyageCacheTTL),
+		queue:           make(chan *ingestEntry, cfg.QueueSize),
+		abort:           make(chan struct{}),
+	}
+}
+
+// Start launches the bulk writers
+func (q *GPSIngestQueue) Start() {
+	q.writers.Add(q.cfg.Writers)
+	for i := 0; i < q.cfg.Writers; i++ {
+		go q.write()
+	}
+	log.Info().
+		Int("writers", q.cfg.Writers).
+		Int("batch_size", q.cfg.BatchSize).
+		Int("queue_size", q.cfg.QueueSize).
+		Msg("GPS ingestion queue started")
+}
+
+// Enqueue validates tracks against their voyages and queues them for
+// writing. It returns domain.ErrIngestQueueFull when accepting them would
+// exceed the queue size, and domain.ErrIngestQueueClosed during shutdown.
+func (q *GPSIngestQueue) Enqueue(ctx context.Context, tracks []*domain.GPSTrack, opts IngestOptions) error {
+	if len(tracks) == 0 {
+		return fmt.Errorf("%w: no GPS tracks provided", domain.ErrInvalidInput)
+	}
+
+	entry := &ingestEntry{
+		tracks: tracks,
+		events: make([]*domain.Event, 0, len(tracks)),
+	}
+	for i, track := range tracks {
+		if track.VoyageID == "" {
+			return fmt.Errorf("%w: voyage_id is required for all GPS tracks", domain.ErrInvalidInput)
+		}
+
+		voyage, err := q.voyages.get(ctx, track.VoyageID)
+		if err != nil {
+			return fmt.Errorf("GPS track %d: %w", i, err)
+		}
+		if err := checkShip(ctx, voyage.ShipID, voyage.VoyageID); err != nil {
+			return fmt.Errorf("GPS track %d: %w", i, err)
+		}
+
+		event, err := prepareGPSTrack(ctx, voyage, track, opts)
+		if err != nil {
+			return fmt.Errorf("GPS track %d: %w", i, err)
+		}
+		entry.events = append(entry.events, event)
+	}
+
+	n := int64(len(tracks))
+	if q.pending.Add(n) > int64(q.cfg.QueueSize) {
+		q.pending.Add(-n)
+		return domain.ErrIngestQueueFull
+	}
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		q.pending.Add(-n)
+		return domain.ErrIngestQueueClosed
+	}
+
+	select {
+	case q.queue <- entry:
+		// Tracks are audited when accepted; they are written shortly after
+		recordTracks(ctx, tracks)
+		return nil
+	default:
+		q.pending.Add(-n)
+		return domain.ErrIngestQueueFull
+	}
+}
+
+// Shutdown refuses new tracks and waits until everything queued is written.
+// Tracks still unwritten when ctx is done are lost. Calling it again has
+// no effect.
+func (q *GPSIngestQueue) Shutdown(ctx context.Context) error {
+	q.shutdown.Do(func() {
+		q.mu.Lock()
+		q.closed = true
+		close(q.queue)
+		q.mu.Unlock()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		q.writers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		select {
+		case <-q.abort:
+		default:
+			close(q.abort)
+		}
+		return fmt.Errorf("%d GPS tracks not written: %w", q.pending.Load(), ctx.Err())
+	}
+}
+
+// write drains the queue, flushing once BatchSize tracks are collected or
+// FlushInterval has passed. Entries that fail to write are kept in a
+// backlog and written first at the next flush, waiting longer after each
+// failure; their tracks still count against QueueSize, so Enqueue refuses
+// new ones while storage is failing.
+func (q *GPSIngestQueue) write() {
+	defer q.writers.Done()
+
+	ticker := time.NewTicker(q.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	var backlog, batch []*ingestEntry
+	var retryAt time.Time
+	retryDelay := q.cfg.FlushInterval
+	size := 0
+
+	flush := func() {
+		if len(backlog) > 0 && time.Now().Before(retryAt) {
+			// Hold the batch back until the backlog is due, keeping order
+			return
+		}
+		backlog = q.flush(append(backlog, batch...))
+		batch, size = nil, 0
+		if len(backlog) == 0 {
+			retryDelay = q.cfg.FlushInterval
+			return
+		}
+		retryAt = time.Now().Add(retryDelay)
+		retryDelay = min(retryDelay*2, ingestMaxRetryDelay)
+	}
+
+	for {
+		select {
+		case entry, ok := <-q.queue:
+			if !ok {
+				q.drain(append(backlog, batch...))
+				return
+			}
+			batch = append(batch, entry)
+			size += len(entry.tracks)
+			if size >= q.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			if len(batch) > 0 || len(backlog) > 0 {
+				flush()
+			}
+		}
+	}
+}
+
+// drain writes the entries left at shutdown, retrying until they are
+// written or Shutdown gives up
+func (q *GPSIngestQueue) drain(entries []*ingestEntry) {
+	delay := q.cfg.FlushInterval
+	for {
+		if entries = q.flush(entries); len(entries) == 0 {
+			return
+		}
+
+		select {
+		case <-q.abort:
+			n := 0
+			for _, entry := range entries {
+				n += len(entry.tracks)
+			}
+			log.Error().Int("count", n).Msg("Shutting down with queued GPS tracks not written")
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, ingestMaxRetryDelay)
+	}
+}
+
+// flush writes entries and returns those that could not be written. A
+// failing batch is retried with a short backoff; if it still fails for a
+// reason other than the database being unreachable, it is split in halves
+// that are written separately, so one entry the database refuses does not
+// hold back the others. Tracks were validated on enqueue, so failures here
+// are storage errors.
+func (q *GPSIngestQueue) flush(entries []*ingestEntry) []*ingestEntry {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	err := q.writeEntries(entries)
+	if err == nil {
+		return nil
+	}
+	if len(entries) > 1 && !unavailable(err) {
+		half := len(entries) / 2
+		return append(q.flush(entries[:half]), q.flush(entries[half:])...)
+	}
+
+	n := 0
+	for _, entry := range entries {
+		n += len(entry.tracks)
+	}
+	log.Error().Err(err).Int("count", n).Msg("Failed to write queued GPS tracks, will retry")
+	return entries
+}
+
+// writeEntries writes entries' tracks and events, trying up to
+// ingestWriteAttempts times
+func (q *GPSIngestQueue) writeEntries(entries []*ingestEntry) error {
+	var tracks []*domain.GPSTrack
+	var events []*domain.Event
+	for _, entry := range entries {
+		tracks = append(tracks, entry.tracks...)
+		events = append(events, entry.events...)
+	}
+
+	uc := q.gpsTrackUseCase
+	var err error
+	for attempt := 1; attempt <= ingestWriteAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), q.cfg.WriteTimeout)
+		err = uc.storeTracks(ctx, tracks, events)
+		cancel()
+		if err == nil {
+			q.pending.Add(-int64(len(tracks)))
+			log.Debug().Int("count", len(tracks)).Msg("Queued GPS tracks written")
+			return nil
+		}
+
+		log.Warn().Err(err).Int("attempt", attempt).Int("count", len(tracks)).Msg("Failed to write queued GPS tracks")
+		if attempt < ingestWriteAttempts {
+			select {
+			case <-q.abort:
+				return err
+			case <-time.After(time.Duration(attempt) * 500 * time.Millisecond):
+			}
+		}
+	}
+	return err
+}
+
+// unavailable reports whether err means the database could not be reached,
+// as opposed to it refusing the write
+func unavailable(err error) bool {
+	return mongo.IsNetworkError(err) || mongo.IsTimeout(err) ||
+		errors.Is(err, context.DeadlineExceeded) || errors.As(err, new(topology.ServerSelectionError))
+}
+
+// sharedVoyageCache memoizes voyage lookups across requests for a short
+// time. Missing voyages are cached too, so fixes for an unknown voyage do
+// not each cost a query.
+type sharedVoyageCache struct {
+	voyageRepo domain.VoyageRepository
+	ttl        time.Duration
+
+	mu      sync.Mutex
+	entries map[string]sharedVoyageEntry // by tenant and voyage ID
+}
+
+type sharedVoyageEntry struct {
+	voyage  *domain.Voyage
+	err     error
+	expires time.Time
+}
+
+func newSharedVoyageCache(voyageRepo domain.VoyageRepository, ttl time.Duration) *sharedVoyageCache {
+	return &sharedVoyageCache{
+		voyageRepo: voyageRepo,
+		ttl:        ttl,
+		entries:    make(map[string]sharedVoyageEntry),
+	}
+}
+
+func (c *sharedVoyageCache) get(ctx context.Context, voyageID string) (*domain.Voyage, error) {
+	now := time.Now()
+
+	// Lookups are scoped to the caller's tenant, so results are cached per tenant
+	tenant, _ := domain.TenantFromContext(ctx)
+	key := tenant + "/" + voyageID
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.voyage, entry.err
+	}
+
+	voyage, err := loadVoyage(ctx, c.voyageRepo, voyageID)
+	if err != nil && !errors.Is(err, domain.ErrVoyageNotFound) {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	// Sweep expired entries now and then so lookups of many unknown
+	// voyage IDs do not grow the map without bound
+	if len(c.entries) >= sharedVoyageCacheSweepSize {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	c.entries[key] = sharedVoyageEntry{voyage: voyage, err: err, expires: now.Add(c.ttl)}
+	c.mu.Unlock()
+
+	return voyage, err
+}
@@ -2,63 +2,169 @@ package usecase
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"time"
 
 	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/chats/sailing-backend/pkg/nmea"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // GPSTrackUseCase handles GPS track business logic
 type GPSTrackUseCase struct {
 	gpsTrackRepo domain.GPSTrackRepository
 	voyageRepo   domain.VoyageRepository
+	tx           domain.Transactor
+	outbox       domain.OutboxRepository
 }
 
 // NewGPSTrackUseCase creates a new GPSTrackUseCase
-func NewGPSTrackUseCase(gpsTrackRepo domain.GPSTrackRepository, voyageRepo domain.VoyageRepository) *GPSTrackUseCase {
+func NewGPSTrackUseCase(gpsTrackRepo domain.GPSTrackRepository, voyageRepo domain.VoyageRepository, tx domain.Transactor, outbox domain.OutboxRepository) *GPSTrackUseCase {
 	return &GPSTrackUseCase{
 		gpsTrackRepo: gpsTrackRepo,
 		voyageRepo:   voyageRepo,
+		tx:           tx,
+		outbox:       outbox,
 	}
 }
 
 // CreateGPSTrack creates a new GPS track
-func (uc *GPSTrackUseCase) CreateGPSTrack(ctx context.Context, track *domain.GPSTrack) error {
+func (uc *GPSTrackUseCase) CreateGPSTrack(ctx context.Context, track *domain.GPSTrack, opts IngestOptions) error {
 	if track.VoyageID == "" {
-		return errors.New("voyage_id is required")
+		return fmt.Errorf("%w: voyage_id is required", domain.ErrInvalidInput)
 	}
 
-	// Verify voyage exists
-	_, err := uc.voyageRepo.GetVoyageByVoyageID(ctx, track.VoyageID)
+	voyage, err := loadVoyage(ctx, uc.voyageRepo, track.VoyageID)
 	if err != nil {
-		return errors.New("voyage not found")
+		return err
+	}
+	if err := checkShip(ctx, voyage.ShipID, voyage.VoyageID); err != nil {
+		return err
 	}
 
-	track.CreatedAt = time.Now()
-	if track.Timestamp.IsZero() {
-		track.Timestamp = time.Now()
+	event, err := prepareGPSTrack(ctx, voyage, track, opts)
+	if err != nil {
+		return err
 	}
 
-	return uc.gpsTrackRepo.CreateGPSTrack(ctx, track)
+	if err := uc.storeTracks(ctx, []*domain.GPSTrack{track}, []*domain.Event{event}); err != nil {
+		return err
+	}
+
+	recordTracks(ctx, []*domain.GPSTrack{track})
+	return nil
 }
 
 // CreateGPSTracksBatch creates multiple GPS tracks
-func (uc *GPSTrackUseCase) CreateGPSTracksBatch(ctx context.Context, tracks []*domain.GPSTrack) error {
+func (uc *GPSTrackUseCase) CreateGPSTracksBatch(ctx context.Context, tracks []*domain.GPSTrack, opts IngestOptions) error {
 	if len(tracks) == 0 {
-		return errors.New("no GPS tracks provided")
+		return fmt.Errorf("%w: no GPS tracks provided", domain.ErrInvalidInput)
 	}
 
+	voyages := newVoyageCache(uc.voyageRepo)
+	events := make([]*domain.Event, 0, len(tracks))
+
 	// Validate and set timestamps
-	for _, track := range tracks {
+	for i, track := range tracks {
 		if track.VoyageID == "" {
-			return errors.New("voyage_id is required for all GPS tracks")
+			return fmt.Errorf("%w: voyage_id is required for all GPS tracks", domain.ErrInvalidInput)
+		}
+
+		voyage, err := voyages.get(ctx, track.VoyageID)
+		if err != nil {
+			return fmt.Errorf("GPS track %d: %w", i, err)
+		}
+		if err := checkShip(ctx, voyage.ShipID, voyage.VoyageID); err != nil {
+			return fmt.Errorf("GPS track %d: %w", i, err)
 		}
 
-		track.CreatedAt = time.Now()
-		if track.Timestamp.IsZero() {
-			track.Timestamp = time.Now()
+		event, err := prepareGPSTrack(ctx, voyage, track, opts)
+		if err != nil {
+			return fmt.Errorf("GPS track %d: %w", i, err)
+		}
+		events = append(events, event)
+	}
+
+	if err := uc.storeTracks(ctx, tracks, events); err != nil {
+		return err
+	}
+
+	recordTracks(ctx, tracks)
+	return nil
+}
+
+// storeTracks writes tracks and the events announcing them in one
+// transaction. Time-series collections cannot be written inside one, so
+// there the tracks are written first and the events only once they are
+// stored; track writes skip tracks already stored, so retrying after a
+// failure between the two steps neither duplicates tracks nor loses events.
+// A transaction failing because the collection has just been converted is
+// retried without one.
+func (uc *GPSTrackUseCase) storeTracks(ctx context.Context, tracks []*domain.GPSTrack, events []*domain.Event) error {
+	if uc.gpsTrackRepo.TransactionalWrites(ctx) {
+		err := uc.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+			if err := uc.gpsTrackRepo.CreateGPSTracksBatch(ctx, tracks); err != nil {
+				return err
+			}
+			return uc.outbox.AddEvents(ctx, events)
+		})
+		if err == nil || uc.gpsTrackRepo.TransactionalWrites(ctx) {
+			return err
 		}
 	}
 
-	return uc.gpsTrackRepo.CreateGPSTracksBatch(ctx, tracks)
+	if err := uc.gpsTrackRepo.CreateGPSTracksBatch(ctx, tracks); err != nil {
+		return err
+	}
+	return uc.outbox.AddEvents(ctx, events)
+}
+
+// prepareGPSTrack stamps a track, checks it against its voyage and builds
+// the event announcing it
+func prepareGPSTrack(ctx context.Context, voyage *domain.Voyage, track *domain.GPSTrack, opts IngestOptions) (*domain.Event, error) {
+	track.CreatedBy = domain.SubjectFromContext(ctx)
+	track.CreatedAt = time.Now()
+	if track.Timestamp.IsZero() {
+		track.Timestamp = time.Now()
+	}
+
+	if err := checkVoyageWindow(voyage, track.Timestamp, opts); err != nil {
+		return nil, err
+	}
+
+	track.ID = primitive.NewObjectID()
+	track.ShipID = voyage.ShipID
+	track.TenantID = voyage.TenantID
+	return domain.NewEvent(domain.EventGPSTrackCreated, voyage, &track.Location, track)
+}
+
+// recordTracks adds created tracks to the request's audit trail. Tracks
+// cannot change after they are written, so a single track is recorded by
+// its ID and a batch by how many tracks each voyage received.
+func recordTracks(ctx context.Context, tracks []*domain.GPSTrack) {
+	if len(tracks) == 1 {
+		domain.RecordChange(ctx, domain.AuditActionCreate, domain.AuditEntityGPSTrack, tracks[0].ID.Hex(), nil, nil)
+		return
+	}
+
+	voyageIDs := make([]string, len(tracks))
+	for i, track := range tracks {
+		voyageIDs[i] = track.VoyageID
+	}
+	recordBatch(ctx, domain.AuditEntityGPSTrack, voyageIDs)
+}
+
+// GPSTrackFromFix converts an assembled NMEA fix into a GPS track for a voyage
+func GPSTrackFromFix(voyageID string, fix *nmea.Fix) *domain.GPSTrack {
+	return &domain.GPSTrack{
+		VoyageID: voyageID,
+		Location: domain.Location{
+			Latitude:  fix.Latitude,
+			Longitude: fix.Longitude,
+		},
+		Speed:     fix.Speed,
+		Heading:   fix.TrueHeading(),
+		Altitude:  fix.Altitude,
+		Timestamp: fix.Time,
+	}
 }
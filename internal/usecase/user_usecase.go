@@ -0,0 +1,154 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minPasswordLength = 12
+	// bcrypt ignores everything past 72 bytes
+	maxPasswordLength = 72
+)
+
+// UserUseCase manages users who sign in at the token endpoint
+type UserUseCase struct {
+	userRepo         domain.UserRepository
+	refreshTokenRepo domain.RefreshTokenRepository
+}
+
+// NewUserUseCase creates a new UserUseCase
+func NewUserUseCase(userRepo domain.UserRepository, refreshTokenRepo domain.RefreshTokenRepository) *UserUseCase {
+	return &UserUseCase{
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+	}
+}
+
+// CreateUser validates and stores a new user with the given password
+func (uc *UserUseCase) CreateUser(ctx context.Context, user *domain.User, password string) error {
+	user.Username = strings.TrimSpace(user.Username)
+	if err := validateUser(user); err != nil {
+		return err
+	}
+	if len(user.Scopes) == 0 {
+		user.Scopes = user.Role.Scopes()
+	}
+	if err := checkGrantable(ctx, user.Scopes, domain.ErrInvalidUser); err != nil {
+		return err
+	}
+	user.TenantID = assignTenant(ctx, user.TenantID)
+	if user.TenantID == "" && !user.Principal().Platform() {
+		return fmt.Errorf("%w: users without a tenant_id need the %s scope", domain.ErrInvalidUser, domain.ScopePlatform)
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = hash
+	user.CreatedBy = domain.SubjectFromContext(ctx)
+	user.UpdatedBy = user.CreatedBy
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = time.Now()
+
+	if err := uc.userRepo.CreateUser(ctx, user); err != nil {
+		return err
+	}
+
+	domain.RecordChange(ctx, domain.AuditActionCreate, domain.AuditEntityUser, user.ID.Hex(), nil, user)
+	return nil
+}
+
+// GetUser retrieves a user by ID
+func (uc *UserUseCase) GetUser(ctx context.Context, id string) (*domain.User, error) {
+	return uc.userRepo.GetUserByID(ctx, id)
+}
+
+// GetAllUsers retrieves all users, including disabled ones
+func (uc *UserUseCase) GetAllUsers(ctx context.Context) ([]*domain.User, error) {
+	return uc.userRepo.GetAllUsers(ctx)
+}
+
+// SetPassword replaces a user's password and signs them out everywhere
+func (uc *UserUseCase) SetPassword(ctx context.Context, id, password string) (*domain.User, error) {
+	user, err := uc.userRepo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *user
+	user.PasswordHash = hash
+	user.UpdatedBy = domain.SubjectFromContext(ctx)
+	user.UpdatedAt = time.Now()
+	if err := uc.userRepo.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	domain.RecordChange(ctx, domain.AuditActionUpdate, domain.AuditEntityUser, user.ID.Hex(), &before, user)
+	return user, uc.refreshTokenRepo.RevokeUserTokens(ctx, user.ID, user.UpdatedAt)
+}
+
+// DisableUser stops a user from signing in and revokes their refresh
+// tokens. Access tokens already issued stay valid until they expire.
+func (uc *UserUseCase) DisableUser(ctx context.Context, id string) (*domain.User, error) {
+	user, err := uc.userRepo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.Disabled {
+		before := *user
+		user.Disabled = true
+		user.UpdatedBy = domain.SubjectFromContext(ctx)
+		user.UpdatedAt = time.Now()
+		if err := uc.userRepo.UpdateUser(ctx, user); err != nil {
+			return nil, err
+		}
+		domain.RecordChange(ctx, domain.AuditActionUpdate, domain.AuditEntityUser, user.ID.Hex(), &before, user)
+	}
+
+	return user, uc.refreshTokenRepo.RevokeUserTokens(ctx, user.ID, time.Now())
+}
+
+// validateUser checks the fields a client may set on a new user
+func validateUser(user *domain.User) error {
+	if user.Username == "" {
+		return fmt.Errorf("%w: username is required", domain.ErrInvalidUser)
+	}
+	if !user.Role.Valid() {
+		return fmt.Errorf("%w: unknown role %q", domain.ErrInvalidUser, user.Role)
+	}
+	for _, scope := range user.Scopes {
+		if !scope.Valid() {
+			return fmt.Errorf("%w: unknown scope %q", domain.ErrInvalidUser, scope)
+		}
+		if !user.Role.Allows(scope) {
+			return fmt.Errorf("%w: role %q does not allow scope %q", domain.ErrInvalidUser, user.Role, scope)
+		}
+	}
+	if user.Role == domain.RoleVessel && user.ShipID == "" {
+		return fmt.Errorf("%w: vessel users require a ship_id", domain.ErrInvalidUser)
+	}
+	return nil
+}
+
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return "", fmt.Errorf("%w: password must be %d to %d bytes long", domain.ErrInvalidUser, minPasswordLength, maxPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
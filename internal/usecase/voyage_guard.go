@@ -0,0 +1,154 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/rs/zerolog/log"
+)
+
+// IngestOptions controls how checkpoints and GPS tracks are checked against
+// the voyage they are attached to
+type IngestOptions struct {
+	// AllowBackfill accepts records for voyages that have already completed,
+	// e.g. logs uploaded after arrival. Records must still fall between the
+	// voyage's departure and arrival times.
+	AllowBackfill bool
+}
+
+// IsRejected reports whether err means a record was refused because it does
+// not fit its voyage, rather than lost to a storage failure, so writing it
+// again cannot succeed
+func IsRejected(err error) bool {
+	return errors.Is(err, domain.ErrInvalidInput) ||
+		errors.Is(err, domain.ErrVoyageNotFound) ||
+		errors.Is(err, domain.ErrVoyageNotInProgress) ||
+		errors.Is(err, domain.ErrVoyageCancelled) ||
+		errors.Is(err, domain.ErrShipNotPermitted) ||
+		errors.Is(err, domain.ErrTimestampBeforeDeparture) ||
+		errors.Is(err, domain.ErrTimestampAfterArrival)
+}
+
+// loadVoyage fetches the voyage a record is attached to
+func loadVoyage(ctx context.Context, voyageRepo domain.VoyageRepository, voyageID string) (*domain.Voyage, error) {
+	voyage, err := voyageRepo.GetVoyageByVoyageID(ctx, voyageID)
+	if err != nil {
+		if errors.Is(err, domain.ErrVoyageNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to load voyage %s: %w", voyageID, err)
+	}
+	return voyage, nil
+}
+
+// assignTenant returns the tenant of records created by the caller: its own
+// tenant, or the requested one for platform principals and internal callers
+func assignTenant(ctx context.Context, requested string) string {
+	if tenant, limited := domain.TenantFromContext(ctx); limited {
+		return tenant
+	}
+	return requested
+}
+
+// checkGrantable returns an error wrapping invalid if the caller does not
+// hold every one of scopes, so that no credential can hand out more than it
+// has. Internal callers without a principal may grant any scope.
+func checkGrantable(ctx context.Context, scopes []domain.Scope, invalid error) error {
+	principal := domain.PrincipalFromContext(ctx)
+	if principal == nil {
+		return nil
+	}
+	for _, scope := range scopes {
+		if !principal.HasScope(scope) {
+			return fmt.Errorf("%w: cannot grant scope %q, which the caller does not hold", invalid, scope)
+		}
+	}
+	return nil
+}
+
+// recordBatch adds a batch of created records to the request's audit trail
+// as one change per voyage, given the voyage ID of each record
+func recordBatch(ctx context.Context, entity string, voyageIDs []string) {
+	var order []string
+	counts := make(map[string]int)
+	for _, voyageID := range voyageIDs {
+		if counts[voyageID] == 0 {
+			order = append(order, voyageID)
+		}
+		counts[voyageID]++
+	}
+	for _, voyageID := range order {
+		domain.RecordBulkCreate(ctx, entity, voyageID, counts[voyageID])
+	}
+}
+
+// checkShip verifies that the caller may write to voyages of shipID, logging
+// attempts by a vessel credential to write to another ship's voyage
+func checkShip(ctx context.Context, shipID, voyageID string) error {
+	principal := domain.PrincipalFromContext(ctx)
+	if principal.CanWriteShip(shipID) {
+		return nil
+	}
+
+	log.Warn().
+		Str("subject", principal.Subject).
+		Str("principal_ship_id", principal.ShipID).
+		Str("ship_id", shipID).
+		Str("voyage_id", voyageID).
+		Msg("Rejected write to another ship's voyage")
+	return domain.ErrShipNotPermitted
+}
+
+// checkVoyageWindow verifies that a record stamped at ts may be attached to voyage
+func checkVoyageWindow(voyage *domain.Voyage, ts time.Time, opts IngestOptions) error {
+	switch voyage.Status {
+	case domain.VoyageStatusInProgress:
+	case domain.VoyageStatusCompleted:
+		if !opts.AllowBackfill {
+			return domain.ErrVoyageNotInProgress
+		}
+	case domain.VoyageStatusCancelled:
+		return domain.ErrVoyageCancelled
+	default:
+		return domain.ErrVoyageNotInProgress
+	}
+
+	if ts.Before(voyage.DepartureTime) {
+		return domain.ErrTimestampBeforeDeparture
+	}
+	if voyage.ArrivalTime != nil && ts.After(*voyage.ArrivalTime) {
+		return domain.ErrTimestampAfterArrival
+	}
+
+	return nil
+}
+
+// voyageCache memoizes voyage lookups while validating a batch
+type voyageCache struct {
+	voyageRepo domain.VoyageRepository
+	voyages    map[string]*domain.Voyage
+}
+
+func newVoyageCache(voyageRepo domain.VoyageRepository) *voyageCache {
+	return &voyageCache{
+		voyageRepo: voyageRepo,
+		voyages:    make(map[string]*domain.Voyage),
+	}
+}
+
+func (c *voyageCache) get(ctx context.Context, voyageID string) (*domain.Voyage, error) {
+	if voyage, ok := c.voyages[voyageID]; ok {
+		return voyage, nil
+	}
+
+	voyage, err := loadVoyage(ctx, c.voyageRepo, voyageID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.voyages[voyageID] = voyage
+	return voyage, nil
+}
@@ -0,0 +1,159 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+)
+
+func TestCheckVoyageWindow(t *testing.T) {
+	departure := time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)
+	arrival := departure.Add(6 * time.Hour)
+
+	tests := []struct {
+		name   string
+		status string
+		ts     time.Time
+		opts   IngestOptions
+		want   error
+	}{
+		{"under way", domain.VoyageStatusInProgress, departure.Add(time.Hour), IngestOptions{}, nil},
+		{"at departure", domain.VoyageStatusInProgress, departure, IngestOptions{}, nil},
+		{"before start", domain.VoyageStatusInProgress, departure.Add(-time.Second), IngestOptions{}, domain.ErrTimestampBeforeDeparture},
+		{"completed", domain.VoyageStatusCompleted, departure.Add(time.Hour), IngestOptions{}, domain.ErrVoyageNotInProgress},
+		{"backfill", domain.VoyageStatusCompleted, departure.Add(time.Hour), IngestOptions{AllowBackfill: true}, nil},
+		{"backfill at arrival", domain.VoyageStatusCompleted, arrival, IngestOptions{AllowBackfill: true}, nil},
+		{"backfill after end", domain.VoyageStatusCompleted, arrival.Add(time.Second), IngestOptions{AllowBackfill: true}, domain.ErrTimestampAfterArrival},
+		{"backfill before start", domain.VoyageStatusCompleted, departure.Add(-time.Hour), IngestOptions{AllowBackfill: true}, domain.ErrTimestampBeforeDeparture},
+		{"cancelled", domain.VoyageStatusCancelled, departure.Add(time.Hour), IngestOptions{AllowBackfill: true}, domain.ErrVoyageCancelled},
+		{"unknown status", "planned", departure.Add(time.Hour), IngestOptions{}, domain.ErrVoyageNotInProgress},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			voyage := &domain.Voyage{Status: tt.status, DepartureTime: departure}
+			if tt.status == domain.VoyageStatusCompleted {
+				voyage.ArrivalTime = &arrival
+			}
+			if err := checkVoyageWindow(voyage, tt.ts, tt.opts); !errors.Is(err, tt.want) {
+				t.Fatalf("checkVoyageWindow = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// voyageLookup serves GetVoyageByVoyageID from a map
+type voyageLookup struct {
+	domain.VoyageRepository
+	voyages map[string]*domain.Voyage
+}
+
+func (r *voyageLookup) GetVoyageByVoyageID(_ context.Context, voyageID string) (*domain.Voyage, error) {
+	if voyage, ok := r.voyages[voyageID]; ok {
+		return voyage, nil
+	}
+	return nil, domain.ErrVoyageNotFound
+}
+
+func TestCreateCheckpointRejections(t *testing.T) {
+	departure := time.Now().Add(-time.Hour)
+	uc := NewCheckpointUseCase(nil, &voyageLookup{voyages: map[string]*domain.Voyage{
+		"V1": {VoyageID: "V1", ShipID: "S1", Status: domain.VoyageStatusInProgress, DepartureTime: departure},
+	}}, nil, nil)
+
+	tests := []struct {
+		name       string
+		checkpoint *domain.Checkpoint
+		want       error
+	}{
+		{"missing voyage ID", &domain.Checkpoint{}, domain.ErrInvalidInput},
+		{"missing voyage", &domain.Checkpoint{VoyageID: "V2", Timestamp: time.Now()}, domain.ErrVoyageNotFound},
+		{"before start", &domain.Checkpoint{VoyageID: "V1", Timestamp: departure.Add(-time.Minute)}, domain.ErrTimestampBeforeDeparture},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := uc.CreateCheckpoint(context.Background(), tt.checkpoint, IngestOptions{})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("CreateCheckpoint = %v, want %v", err, tt.want)
+			}
+			if !IsRejected(err) {
+				t.Fatalf("IsRejected(%v) = false, want true", err)
+			}
+		})
+	}
+}
+
+// credentialStore accepts every created API key and user
+type credentialStore struct {
+	domain.APIKeyRepository
+	domain.UserRepository
+	created int
+}
+
+func (s *credentialStore) CreateAPIKey(context.Context, *domain.APIKey) error {
+	s.created++
+	return nil
+}
+
+func (s *credentialStore) CreateUser(context.Context, *domain.User) error {
+	s.created++
+	return nil
+}
+
+func TestCreateCredentialScopes(t *testing.T) {
+	// A tenant admin whose credential lists only some of the admin scopes
+	caller := &domain.Principal{
+		Subject:  "user:ops",
+		Role:     domain.RoleAdmin,
+		Scopes:   []domain.Scope{domain.ScopeVoyagesRead, domain.ScopeTracksIngest, domain.ScopeAPIKeysManage, domain.ScopeUsersManage},
+		TenantID: "acme",
+	}
+
+	tests := []struct {
+		name   string
+		caller *domain.Principal
+		role   domain.Role
+		scopes []domain.Scope
+		want   bool // accepted
+	}{
+		{"subset", caller, domain.RoleVessel, []domain.Scope{domain.ScopeTracksIngest}, true},
+		{"same scopes", caller, domain.RoleAdmin, caller.Scopes, true},
+		{"scope the caller lacks", caller, domain.RoleOperator, []domain.Scope{domain.ScopeVoyagesWrite}, false},
+		{"role defaults beyond the caller", caller, domain.RoleOperator, nil, false},
+		{"audit for a tenant admin", caller, domain.RoleAdmin, []domain.Scope{domain.ScopeAuditRead}, false},
+		{"internal caller", nil, domain.RoleAdmin, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.caller != nil {
+				ctx = domain.WithPrincipal(ctx, tt.caller)
+			}
+			store := &credentialStore{}
+
+			key := &domain.APIKey{Name: "device", Role: tt.role, Scopes: tt.scopes, ShipID: "S1", TenantID: "acme"}
+			_, keyErr := NewAPIKeyUseCase(store, time.Minute).CreateAPIKey(ctx, key)
+
+			user := &domain.User{Username: "crew", Role: tt.role, Scopes: tt.scopes, ShipID: "S1", TenantID: "acme"}
+			userErr := NewUserUseCase(store, nil).CreateUser(ctx, user, "correct horse battery")
+
+			if tt.want {
+				if keyErr != nil || userErr != nil {
+					t.Fatalf("CreateAPIKey = %v, CreateUser = %v, want both accepted", keyErr, userErr)
+				}
+				return
+			}
+			if !errors.Is(keyErr, domain.ErrInvalidAPIKey) || !errors.Is(userErr, domain.ErrInvalidUser) {
+				t.Fatalf("CreateAPIKey = %v, CreateUser = %v, want both rejected", keyErr, userErr)
+			}
+			if store.created != 0 {
+				t.Fatalf("%d credentials stored", store.created)
+			}
+		})
+	}
+}
@@ -2,11 +2,12 @@ package usecase
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"time"
 
 	"github.com/chats/sailing-backend/internal/domain"
 	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // VoyageUseCase handles voyage business logic
@@ -14,60 +15,113 @@ type VoyageUseCase struct {
 	voyageRepo     domain.VoyageRepository
 	checkpointRepo domain.CheckpointRepository
 	gpsTrackRepo   domain.GPSTrackRepository
+	tx             domain.Transactor
+	outbox         domain.OutboxRepository
 }
 
 // NewVoyageUseCase creates a new VoyageUseCase
-func NewVoyageUseCase(voyageRepo domain.VoyageRepository, checkpointRepo domain.CheckpointRepository, gpsTrackRepo domain.GPSTrackRepository) *VoyageUseCase {
+func NewVoyageUseCase(voyageRepo domain.VoyageRepository, checkpointRepo domain.CheckpointRepository, gpsTrackRepo domain.GPSTrackRepository, tx domain.Transactor, outbox domain.OutboxRepository) *VoyageUseCase {
 	return &VoyageUseCase{
 		voyageRepo:     voyageRepo,
 		checkpointRepo: checkpointRepo,
 		gpsTrackRepo:   gpsTrackRepo,
+		tx:             tx,
+		outbox:         outbox,
 	}
 }
 
 // DepartVoyage creates a new voyage with departure information
 func (uc *VoyageUseCase) DepartVoyage(ctx context.Context, voyage *domain.Voyage) error {
 	if voyage.ShipID == "" {
-		return errors.New("ship_id is required")
+		return fmt.Errorf("%w: ship_id is required", domain.ErrInvalidInput)
 	}
 	if voyage.ShipName == "" {
-		return errors.New("ship_name is required")
+		return fmt.Errorf("%w: ship_name is required", domain.ErrInvalidInput)
 	}
 	if voyage.DeparturePort == "" {
-		return errors.New("departure_port is required")
+		return fmt.Errorf("%w: departure_port is required", domain.ErrInvalidInput)
 	}
+	if err := checkShip(ctx, voyage.ShipID, voyage.VoyageID); err != nil {
+		return err
+	}
+	voyage.TenantID = assignTenant(ctx, voyage.TenantID)
 
 	// Generate voyage ID if not provided
 	if voyage.VoyageID == "" {
 		voyage.VoyageID = uuid.New().String()
 	}
 
-	voyage.Status = "in_progress"
+	voyage.ID = primitive.NewObjectID()
+	voyage.Status = domain.VoyageStatusInProgress
 	voyage.DepartureTime = time.Now()
+	voyage.CreatedBy = domain.SubjectFromContext(ctx)
+	voyage.UpdatedBy = voyage.CreatedBy
 	voyage.CreatedAt = time.Now()
 	voyage.UpdatedAt = time.Now()
 
-	return uc.voyageRepo.CreateVoyage(ctx, voyage)
+	event, err := domain.NewEvent(domain.EventVoyageDeparted, voyage, nil, voyage)
+	if err != nil {
+		return err
+	}
+
+	err = uc.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := uc.voyageRepo.CreateVoyage(ctx, voyage); err != nil {
+			return err
+		}
+		return uc.outbox.AddEvents(ctx, []*domain.Event{event})
+	})
+	if err != nil {
+		return err
+	}
+
+	domain.RecordChange(ctx, domain.AuditActionCreate, domain.AuditEntityVoyage, voyage.VoyageID, nil, voyage)
+	return nil
 }
 
 // ArriveVoyage updates a voyage with arrival information
 func (uc *VoyageUseCase) ArriveVoyage(ctx context.Context, voyageID, arrivalPort string) error {
-	voyage, err := uc.voyageRepo.GetVoyageByVoyageID(ctx, voyageID)
+	var before, after domain.Voyage
+
+	// Reading inside the transaction makes a concurrent arrival of the same
+	// voyage conflict instead of both succeeding
+	err := uc.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		voyage, err := uc.voyageRepo.GetVoyageByVoyageID(ctx, voyageID)
+		if err != nil {
+			return err
+		}
+		if err := checkShip(ctx, voyage.ShipID, voyage.VoyageID); err != nil {
+			return err
+		}
+
+		if voyage.Status != domain.VoyageStatusInProgress {
+			return domain.ErrVoyageNotInProgress
+		}
+		before = *voyage
+
+		now := time.Now()
+		voyage.ArrivalPort = arrivalPort
+		voyage.ArrivalTime = &now
+		voyage.Status = domain.VoyageStatusCompleted
+		voyage.UpdatedBy = domain.SubjectFromContext(ctx)
+		voyage.UpdatedAt = time.Now()
+		after = *voyage
+
+		event, err := domain.NewEvent(domain.EventVoyageArrived, voyage, nil, voyage)
+		if err != nil {
+			return err
+		}
+
+		if err := uc.voyageRepo.UpdateVoyage(ctx, voyage); err != nil {
+			return err
+		}
+		return uc.outbox.AddEvents(ctx, []*domain.Event{event})
+	})
 	if err != nil {
 		return err
 	}
 
-	if voyage.Status != "in_progress" {
-		return errors.New("voyage is not in progress")
-	}
-
-	now := time.Now()
-	voyage.ArrivalPort = arrivalPort
-	voyage.ArrivalTime = &now
-	voyage.Status = "completed"
-	voyage.UpdatedAt = time.Now()
-
-	return uc.voyageRepo.UpdateVoyage(ctx, voyage)
+	domain.RecordChange(ctx, domain.AuditActionUpdate, domain.AuditEntityVoyage, after.VoyageID, &before, &after)
+	return nil
 }
 
 // GetAllVoyages retrieves all voyages with their checkpoints and GPS tracks
@@ -113,3 +167,34 @@ func (uc *VoyageUseCase) GetAllVoyages(ctx context.Context, limit, offset int) (
 func (uc *VoyageUseCase) GetVoyageByID(ctx context.Context, id string) (*domain.Voyage, error) {
 	return uc.voyageRepo.GetVoyageByID(ctx, id)
 }
+
+// ResolveVoyage retrieves a voyage by its database ID or by its voyage ID
+func (uc *VoyageUseCase) ResolveVoyage(ctx context.Context, id string) (*domain.Voyage, error) {
+	if primitive.IsValidObjectID(id) {
+		return uc.voyageRepo.GetVoyageByID(ctx, id)
+	}
+	return uc.voyageRepo.GetVoyageByVoyageID(ctx, id)
+}
+
+// GetActiveVoyageByShipID retrieves the in-progress voyage of a ship
+func (uc *VoyageUseCase) GetActiveVoyageByShipID(ctx context.Context, shipID string) (*domain.Voyage, error) {
+	return uc.voyageRepo.GetActiveVoyageByShipID(ctx, shipID)
+}
+
+// StreamVoyages calls fn for each voyage under way at some point within tr
+func (uc *VoyageUseCase) StreamVoyages(ctx context.Context, tr domain.TimeRange, fn func(*domain.Voyage) error) error {
+	if !tr.From.IsZero() && !tr.To.IsZero() && tr.To.Before(tr.From) {
+		return fmt.Errorf("%w: time range end is before its start", domain.ErrInvalidInput)
+	}
+	return uc.voyageRepo.StreamVoyages(ctx, tr, fn)
+}
+
+// StreamCheckpoints calls fn for each checkpoint of a voyage within tr, in timestamp order
+func (uc *VoyageUseCase) StreamCheckpoints(ctx context.Context, voyageID string, tr domain.TimeRange, fn func(*domain.Checkpoint) error) error {
+	return uc.checkpointRepo.StreamCheckpointsByVoyageID(ctx, voyageID, tr, fn)
+}
+
+// StreamGPSTracks calls fn for each GPS track of a voyage within tr, in timestamp order
+func (uc *VoyageUseCase) StreamGPSTracks(ctx context.Context, voyageID string, tr domain.TimeRange, fn func(*domain.GPSTrack) error) error {
+	return uc.gpsTrackRepo.StreamGPSTracksByVoyageID(ctx, voyageID, tr, fn)
+}
@@ -0,0 +1,365 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	mathrand "math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+	"github.com/chats/sailing-backend/pkg/webhook"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// subscriptionCacheTTL bounds how long Publish works from a stale subscription list
+const subscriptionCacheTTL = 15 * time.Second
+
+// subscribableEvents are the event types webhooks may subscribe to
+var subscribableEvents = map[string]bool{
+	domain.EventVoyageDeparted:    true,
+	domain.EventVoyageArrived:     true,
+	domain.EventCheckpointCreated: true,
+	domain.EventGPSTrackCreated:   true,
+}
+
+// WebhookPolicy controls how failed webhook deliveries are retried
+type WebhookPolicy struct {
+	MaxAttempts    int           // attempts before a delivery is moved to the dead-letter list
+	InitialBackoff time.Duration // delay after the first failure, doubled after each further one
+	MaxBackoff     time.Duration
+
+	// AllowPrivateNetworks permits URLs on loopback, private and link-local
+	// addresses, for receivers inside the deployment's own network
+	AllowPrivateNetworks bool
+}
+
+// WebhookUseCase handles webhook subscriptions and the delivery queue
+type WebhookUseCase struct {
+	webhookRepo  domain.WebhookRepository
+	deliveryRepo domain.WebhookDeliveryRepository
+	policy       WebhookPolicy
+
+	mu         sync.Mutex
+	active     []*domain.WebhookSubscription
+	activeTime time.Time
+}
+
+// NewWebhookUseCase creates a new WebhookUseCase
+func NewWebhookUseCase(webhookRepo domain.WebhookRepository, deliveryRepo domain.WebhookDeliveryRepository, policy WebhookPolicy) *WebhookUseCase {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 8
+	}
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = 10 * time.Second
+	}
+	if policy.MaxBackoff < policy.InitialBackoff {
+		policy.MaxBackoff = time.Hour
+	}
+
+	return &WebhookUseCase{
+		webhookRepo:  webhookRepo,
+		deliveryRepo: deliveryRepo,
+		policy:       policy,
+	}
+}
+
+// CreateSubscription validates and stores a new subscription with a freshly
+// generated signing secret
+func (uc *WebhookUseCase) CreateSubscription(ctx context.Context, sub *domain.WebhookSubscription) error {
+	if err := uc.validateSubscription(sub); err != nil {
+		return err
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return err
+	}
+
+	sub.Secret = secret
+	sub.TenantID = assignTenant(ctx, sub.TenantID)
+	sub.CreatedBy = domain.SubjectFromContext(ctx)
+	sub.UpdatedBy = sub.CreatedBy
+	sub.CreatedAt = time.Now()
+	sub.UpdatedAt = time.Now()
+
+	if err := uc.webhookRepo.CreateSubscription(ctx, sub); err != nil {
+		return err
+	}
+
+	uc.invalidate()
+	domain.RecordChange(ctx, domain.AuditActionCreate, domain.AuditEntityWebhook, sub.ID.Hex(), nil, sub)
+	return nil
+}
+
+// UpdateSubscription validates and saves changes to a subscription
+func (uc *WebhookUseCase) UpdateSubscription(ctx context.Context, sub *domain.WebhookSubscription) error {
+	if err := uc.validateSubscription(sub); err != nil {
+		return err
+	}
+
+	before, err := uc.webhookRepo.GetSubscriptionByID(ctx, sub.ID.Hex())
+	if err != nil {
+		return err
+	}
+
+	sub.UpdatedBy = domain.SubjectFromContext(ctx)
+	sub.UpdatedAt = time.Now()
+	if err := uc.webhookRepo.UpdateSubscription(ctx, sub); err != nil {
+		return err
+	}
+
+	uc.invalidate()
+	domain.RecordChange(ctx, domain.AuditActionUpdate, domain.AuditEntityWebhook, sub.ID.Hex(), before, sub)
+	return nil
+}
+
+// DeleteSubscription removes a subscription; its queued deliveries are dropped when claimed
+func (uc *WebhookUseCase) DeleteSubscription(ctx context.Context, id string) error {
+	before, err := uc.webhookRepo.GetSubscriptionByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := uc.webhookRepo.DeleteSubscription(ctx, id); err != nil {
+		return err
+	}
+
+	uc.invalidate()
+	domain.RecordChange(ctx, domain.AuditActionDelete, domain.AuditEntityWebhook, before.ID.Hex(), before, nil)
+	return nil
+}
+
+// GetSubscription retrieves a subscription by ID
+func (uc *WebhookUseCase) GetSubscription(ctx context.Context, id string) (*domain.WebhookSubscription, error) {
+	return uc.webhookRepo.GetSubscriptionByID(ctx, id)
+}
+
+// GetAllSubscriptions retrieves all subscriptions
+func (uc *WebhookUseCase) GetAllSubscriptions(ctx context.Context) ([]*domain.WebhookSubscription, error) {
+	return uc.webhookRepo.GetAllSubscriptions(ctx)
+}
+
+// Ping queues a webhook.ping event for a single subscription
+func (uc *WebhookUseCase) Ping(ctx context.Context, id string) (*domain.WebhookDelivery, error) {
+	sub, err := uc.webhookRepo.GetSubscriptionByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(map[string]string{"subscription_id": sub.ID.Hex()})
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := newDelivery(sub, &domain.Event{
+		ID:         uuid.New().String(),
+		Type:       domain.EventWebhookPing,
+		TenantID:   sub.TenantID,
+		OccurredAt: time.Now(),
+		Data:       data,
+	})
+	if err := uc.deliveryRepo.CreateDeliveries(ctx, []*domain.WebhookDelivery{delivery}); err != nil {
+		return nil, err
+	}
+
+	domain.RecordChange(ctx, domain.AuditActionCreate, domain.AuditEntityWebhookDelivery, delivery.ID.Hex(), nil, nil)
+	return delivery, nil
+}
+
+// Publish implements domain.EventPublisher by queueing a delivery of each
+// event for every active subscription that wants it
+func (uc *WebhookUseCase) Publish(ctx context.Context, events ...*domain.Event) {
+	subs, err := uc.activeSubscriptions(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load webhook subscriptions")
+		return
+	}
+	if len(subs) == 0 {
+		return
+	}
+
+	var deliveries []*domain.WebhookDelivery
+	for _, event := range events {
+		for _, sub := range subs {
+			if sub.Wants(event) {
+				deliveries = append(deliveries, newDelivery(sub, event))
+			}
+		}
+	}
+
+	if err := uc.deliveryRepo.CreateDeliveries(ctx, deliveries); err != nil {
+		log.Error().Err(err).Int("count", len(deliveries)).Msg("Failed to queue webhook deliveries")
+	}
+}
+
+// GetDeliveries lists deliveries, optionally only those with status
+func (uc *WebhookUseCase) GetDeliveries(ctx context.Context, status string, limit, offset int) ([]*domain.WebhookDelivery, error) {
+	return uc.deliveryRepo.GetDeliveries(ctx, status, limit, offset)
+}
+
+// RetryDelivery moves a dead-lettered delivery back to the queue
+func (uc *WebhookUseCase) RetryDelivery(ctx context.Context, id string) (*domain.WebhookDelivery, error) {
+	delivery, err := uc.deliveryRepo.GetDeliveryByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.Status != domain.DeliveryStatusDead {
+		return nil, fmt.Errorf("%w: only dead deliveries can be retried", domain.ErrInvalidWebhook)
+	}
+
+	before := *delivery
+	delivery.Status = domain.DeliveryStatusPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now()
+	delivery.UpdatedAt = time.Now()
+
+	if err := uc.deliveryRepo.UpdateDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+
+	domain.RecordChange(ctx, domain.AuditActionUpdate, domain.AuditEntityWebhookDelivery, delivery.ID.Hex(), &before, delivery)
+	return delivery, nil
+}
+
+// ClaimDelivery leases the next due delivery for lease and returns it with
+// its subscription, or nil if nothing is due. Deliveries whose subscription
+// was deleted or deactivated are dead-lettered instead.
+func (uc *WebhookUseCase) ClaimDelivery(ctx context.Context, lease time.Duration) (*domain.WebhookDelivery, *domain.WebhookSubscription, error) {
+	for {
+		delivery, err := uc.deliveryRepo.ClaimDueDelivery(ctx, time.Now(), lease)
+		if err != nil || delivery == nil {
+			return nil, nil, err
+		}
+
+		sub, err := uc.webhookRepo.GetSubscriptionByID(ctx, delivery.SubscriptionID.Hex())
+		if err != nil && err != domain.ErrWebhookNotFound {
+			return nil, nil, err
+		}
+		if sub != nil && sub.Active {
+			return delivery, sub, nil
+		}
+
+		delivery.Status = domain.DeliveryStatusDead
+		delivery.LastError = "subscription deleted or inactive"
+		delivery.UpdatedAt = time.Now()
+		if err := uc.deliveryRepo.UpdateDelivery(ctx, delivery); err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// RecordAttempt stores the outcome of a delivery attempt. A failed attempt
+// is rescheduled with exponential backoff, or dead-lettered once the
+// maximum number of attempts is reached.
+func (uc *WebhookUseCase) RecordAttempt(ctx context.Context, delivery *domain.WebhookDelivery, statusCode int, attemptErr error) error {
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+	delivery.UpdatedAt = now
+
+	switch {
+	case attemptErr == nil && statusCode >= http.StatusOK && statusCode < http.StatusMultipleChoices:
+		delivery.Status = domain.DeliveryStatusDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= uc.policy.MaxAttempts:
+		delivery.Status = domain.DeliveryStatusDead
+		delivery.LastError = attemptError(statusCode, attemptErr)
+	default:
+		delivery.LastError = attemptError(statusCode, attemptErr)
+		delivery.NextAttemptAt = now.Add(uc.backoff(delivery.Attempts))
+	}
+
+	return uc.deliveryRepo.UpdateDelivery(ctx, delivery)
+}
+
+// backoff returns the delay before the attempt following attempt n, with
+// up to 20% jitter so failing receivers are not hit in lockstep
+func (uc *WebhookUseCase) backoff(n int) time.Duration {
+	d := float64(uc.policy.InitialBackoff) * math.Pow(2, float64(n-1))
+	if d > float64(uc.policy.MaxBackoff) {
+		d = float64(uc.policy.MaxBackoff)
+	}
+	d -= d * 0.2 * mathrand.Float64()
+	return time.Duration(d)
+}
+
+func (uc *WebhookUseCase) activeSubscriptions(ctx context.Context) ([]*domain.WebhookSubscription, error) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	if uc.active != nil && time.Since(uc.activeTime) < subscriptionCacheTTL {
+		return uc.active, nil
+	}
+
+	subs, err := uc.webhookRepo.GetActiveSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	uc.active = subs
+	uc.activeTime = time.Now()
+	return subs, nil
+}
+
+func (uc *WebhookUseCase) invalidate() {
+	uc.mu.Lock()
+	uc.active = nil
+	uc.mu.Unlock()
+}
+
+func newDelivery(sub *domain.WebhookSubscription, event *domain.Event) *domain.WebhookDelivery {
+	now := time.Now()
+	return &domain.WebhookDelivery{
+		SubscriptionID: sub.ID,
+		Event:          event,
+		Status:         domain.DeliveryStatusPending,
+		NextAttemptAt:  now,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+}
+
+// validateSubscription checks the URL and event types of a subscription.
+// URLs naming internal hosts directly are rejected here; names resolving to
+// them are refused when the worker connects.
+func (uc *WebhookUseCase) validateSubscription(sub *domain.WebhookSubscription) error {
+	u, err := url.Parse(sub.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", domain.ErrInvalidWebhook)
+	}
+	if !uc.policy.AllowPrivateNetworks {
+		if err := webhook.CheckHost(u.Hostname()); err != nil {
+			return fmt.Errorf("%w: url must not point at a loopback, private or link-local address", domain.ErrInvalidWebhook)
+		}
+	}
+	for _, t := range sub.EventTypes {
+		if !subscribableEvents[t] {
+			return fmt.Errorf("%w: unknown event type %q", domain.ErrInvalidWebhook, t)
+		}
+	}
+	return nil
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+func attemptError(statusCode int, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("unexpected status %d", statusCode)
+}
@@ -0,0 +1,72 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chats/sailing-backend/internal/domain"
+)
+
+func TestWebhookBackoff(t *testing.T) {
+	uc := NewWebhookUseCase(nil, nil, WebhookPolicy{
+		InitialBackoff: 10 * time.Second,
+		MaxBackoff:     time.Minute,
+	})
+
+	tests := []struct {
+		attempt int
+		max     time.Duration // before jitter, which takes off up to 20%
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{4, time.Minute},
+		{10, time.Minute},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			got := uc.backoff(tt.attempt)
+			if got > tt.max || got < tt.max*8/10 {
+				t.Fatalf("backoff(%d) = %s, want between %s and %s", tt.attempt, got, tt.max*8/10, tt.max)
+			}
+		}
+	}
+}
+
+func TestValidateSubscription(t *testing.T) {
+	tests := []struct {
+		name         string
+		url          string
+		eventTypes   []string
+		allowPrivate bool
+		valid        bool
+	}{
+		{"public https", "https://hooks.example.com/sailing", nil, false, true},
+		{"public http", "http://93.184.216.34:8080/hook", []string{domain.EventVoyageArrived}, false, true},
+		{"relative", "/hook", nil, false, false},
+		{"other scheme", "ftp://hooks.example.com/", nil, false, false},
+		{"unknown event", "https://hooks.example.com/", []string{"voyage.sunk"}, false, false},
+		{"localhost", "http://localhost:9000/hook", nil, false, false},
+		{"loopback", "http://127.0.0.1/hook", nil, false, false},
+		{"ipv6 loopback", "http://[::1]/hook", nil, false, false},
+		{"private", "http://10.0.0.8/hook", nil, false, false},
+		{"metadata", "http://169.254.169.254/latest/meta-data/", nil, false, false},
+		{"private allowed", "http://10.0.0.8/hook", nil, true, true},
+		{"localhost allowed", "http://localhost:9000/hook", nil, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := NewWebhookUseCase(nil, nil, WebhookPolicy{AllowPrivateNetworks: tt.allowPrivate})
+			err := uc.validateSubscription(&domain.WebhookSubscription{URL: tt.url, EventTypes: tt.eventTypes})
+			if tt.valid && err != nil {
+				t.Fatalf("validateSubscription() = %v, want nil", err)
+			}
+			if !tt.valid && !errors.Is(err, domain.ErrInvalidWebhook) {
+				t.Fatalf("validateSubscription() = %v, want ErrInvalidWebhook", err)
+			}
+		})
+	}
+}
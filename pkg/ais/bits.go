@@ -0,0 +1,83 @@
+package ais
+
+import (
+	"fmt"
+	"strings"
+)
+
+// bitVector holds a de-armored AIS payload
+type bitVector struct {
+	bits []byte // one bit per element, MSB first
+}
+
+// unarmor converts a 6-bit ASCII armored payload into its bit sequence,
+// dropping the trailing fill bits
+func unarmor(payload string, fillBits int) (*bitVector, error) {
+	bits := make([]byte, 0, len(payload)*6)
+	for i := 0; i < len(payload); i++ {
+		c := payload[i]
+		if c < 48 || c > 119 || (c > 87 && c < 96) {
+			return nil, fmt.Errorf("invalid payload character %q", c)
+		}
+		v := c - 48
+		if v > 40 {
+			v -= 8
+		}
+		for shift := 5; shift >= 0; shift-- {
+			bits = append(bits, (v>>uint(shift))&1)
+		}
+	}
+
+	if fillBits < 0 || fillBits > 5 || fillBits > len(bits) {
+		return nil, fmt.Errorf("invalid fill bits %d", fillBits)
+	}
+
+	return &bitVector{bits: bits[:len(bits)-fillBits]}, nil
+}
+
+func (b *bitVector) len() int {
+	return len(b.bits)
+}
+
+// uint reads an unsigned integer of width bits starting at offset
+func (b *bitVector) uint(offset, width int) uint32 {
+	var v uint32
+	for i := offset; i < offset+width; i++ {
+		v <<= 1
+		if i < len(b.bits) {
+			v |= uint32(b.bits[i])
+		}
+	}
+	return v
+}
+
+// int reads a two's complement signed integer of width bits starting at offset
+func (b *bitVector) int(offset, width int) int32 {
+	v := b.uint(offset, width)
+	if v&(1<<uint(width-1)) != 0 {
+		return int32(v) - int32(1<<uint(width))
+	}
+	return int32(v)
+}
+
+// text reads chars six-bit characters starting at offset, trimming the
+// '@' padding and trailing spaces
+func (b *bitVector) text(offset, chars int) string {
+	var sb strings.Builder
+	for i := 0; i < chars; i++ {
+		if offset+(i+1)*6 > len(b.bits) {
+			break
+		}
+		c := byte(b.uint(offset+i*6, 6))
+		if c < 32 {
+			c += 64
+		}
+		sb.WriteByte(c)
+	}
+
+	s := sb.String()
+	if i := strings.IndexByte(s, '@'); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimRight(s, " ")
+}
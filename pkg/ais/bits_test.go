@@ -0,0 +1,119 @@
+package ais
+
+import "testing"
+
+// bitWriter builds payloads for tests, field by field
+type bitWriter struct {
+	bits []byte
+}
+
+func (w *bitWriter) put(v int64, width int) {
+	for i := width - 1; i >= 0; i-- {
+		w.bits = append(w.bits, byte(v>>uint(i))&1)
+	}
+}
+
+func (w *bitWriter) text(s string, chars int) {
+	for i := 0; i < chars; i++ {
+		c := byte('@')
+		if i < len(s) {
+			c = s[i]
+		}
+		w.put(int64(c&0x3f), 6)
+	}
+}
+
+func (w *bitWriter) pad(length int) {
+	for len(w.bits) < length {
+		w.bits = append(w.bits, 0)
+	}
+}
+
+// armor returns the 6-bit ASCII payload and its fill bit count
+func (w *bitWriter) armor() (string, int) {
+	fill := (6 - len(w.bits)%6) % 6
+	bits := append(append([]byte{}, w.bits...), make([]byte, fill)...)
+
+	payload := make([]byte, 0, len(bits)/6)
+	for i := 0; i < len(bits); i += 6 {
+		var v byte
+		for _, bit := range bits[i : i+6] {
+			v = v<<1 | bit
+		}
+		c := v + 48
+		if c > 87 {
+			c += 8
+		}
+		payload = append(payload, c)
+	}
+	return string(payload), fill
+}
+
+func TestUnarmor(t *testing.T) {
+	tests := []struct {
+		payload string
+		fill    int
+		want    []uint32 // 6-bit values after removing fill bits
+	}{
+		{"0", 0, []uint32{0}},
+		{"W", 0, []uint32{39}},
+		{"`", 0, []uint32{40}},
+		{"w", 0, []uint32{63}},
+		{"1w", 0, []uint32{1, 63}},
+	}
+	for _, tt := range tests {
+		b, err := unarmor(tt.payload, tt.fill)
+		if err != nil {
+			t.Fatalf("unarmor(%q): %v", tt.payload, err)
+		}
+		for i, want := range tt.want {
+			if got := b.uint(i*6, 6); got != want {
+				t.Errorf("unarmor(%q) value %d = %d, want %d", tt.payload, i, got, want)
+			}
+		}
+	}
+
+	b, err := unarmor("w", 2)
+	if err != nil {
+		t.Fatalf("unarmor with fill bits: %v", err)
+	}
+	if b.len() != 4 || b.uint(0, 4) != 15 {
+		t.Errorf("fill bits: len %d value %d, want 4 bits of 15", b.len(), b.uint(0, 4))
+	}
+
+	for _, bad := range []struct {
+		payload string
+		fill    int
+	}{
+		{"X", 0}, {"_", 0}, {"x", 0}, {"/", 0}, {"0", 6}, {"0", -1}, {"", 1},
+	} {
+		if _, err := unarmor(bad.payload, bad.fill); err == nil {
+			t.Errorf("unarmor(%q, %d) succeeded", bad.payload, bad.fill)
+		}
+	}
+}
+
+func TestBitVectorFields(t *testing.T) {
+	var w bitWriter
+	w.put(5, 3)
+	w.put(-3, 8)
+	w.put(100, 8)
+	w.text("AB 1", 6)
+
+	b := &bitVector{bits: w.bits}
+	if got := b.uint(0, 3); got != 5 {
+		t.Errorf("uint = %d, want 5", got)
+	}
+	if got := b.int(3, 8); got != -3 {
+		t.Errorf("int = %d, want -3", got)
+	}
+	if got := b.int(11, 8); got != 100 {
+		t.Errorf("int = %d, want 100", got)
+	}
+	if got := b.text(19, 6); got != "AB 1" {
+		t.Errorf("text = %q, want %q", got, "AB 1")
+	}
+	if got := b.text(19, 10); got != "AB 1" {
+		t.Errorf("text past the end = %q, want %q", got, "AB 1")
+	}
+}
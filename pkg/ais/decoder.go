@@ -0,0 +1,118 @@
+// Package ais decodes AIS messages carried in AIVDM/AIVDO NMEA sentences
+package ais
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chats/sailing-backend/pkg/nmea"
+)
+
+// Errors returned while reassembling multi-fragment messages
+var (
+	ErrNotAIS          = errors.New("not an AIVDM/AIVDO sentence")
+	ErrFragmentOrphan  = errors.New("fragment received without its preceding fragments")
+	ErrFragmentInvalid = errors.New("invalid fragment header")
+)
+
+// defaultFragmentTTL bounds how long an incomplete multi-fragment message is kept
+const defaultFragmentTTL = 10 * time.Second
+
+// Decoder reassembles multi-fragment AIVDM/AIVDO sentences and decodes the
+// resulting messages. A Decoder is not safe for concurrent use; use one per
+// feed so fragments from different sources are not mixed.
+type Decoder struct {
+	pending map[string]*fragmentGroup
+
+	// FragmentTTL discards incomplete messages older than this
+	FragmentTTL time.Duration
+	// Now returns the current time; overridable for deterministic decoding
+	Now func() time.Time
+}
+
+type fragmentGroup struct {
+	parts    []string
+	received int
+	started  time.Time
+}
+
+// NewDecoder creates a new Decoder
+func NewDecoder() *Decoder {
+	return &Decoder{
+		pending:     make(map[string]*fragmentGroup),
+		FragmentTTL: defaultFragmentTTL,
+		Now:         time.Now,
+	}
+}
+
+// Decode consumes one sentence. It returns a nil message and nil error when
+// the sentence is a fragment of a message that is not yet complete.
+func (d *Decoder) Decode(s *nmea.Sentence) (Message, error) {
+	if s.Type != "VDM" && s.Type != "VDO" {
+		return nil, ErrNotAIS
+	}
+	if len(s.Fields) < 6 {
+		return nil, ErrFragmentInvalid
+	}
+
+	count, err1 := strconv.Atoi(s.Fields[0])
+	number, err2 := strconv.Atoi(s.Fields[1])
+	fillBits, err3 := strconv.Atoi(s.Fields[5])
+	if err1 != nil || err2 != nil || err3 != nil || count < 1 || number < 1 || number > count {
+		return nil, ErrFragmentInvalid
+	}
+	payload := s.Fields[4]
+
+	if count == 1 {
+		return decodePayload(payload, fillBits)
+	}
+
+	now := d.Now()
+	d.evict(now)
+
+	key := strings.Join([]string{s.Talker, s.Type, s.Fields[2], s.Fields[3]}, "|")
+	group, ok := d.pending[key]
+	if number == 1 {
+		group = &fragmentGroup{parts: make([]string, count), started: now}
+		d.pending[key] = group
+	} else if !ok || len(group.parts) != count || group.parts[number-2] == "" {
+		return nil, ErrFragmentOrphan
+	}
+
+	if group.parts[number-1] == "" {
+		group.received++
+	}
+	group.parts[number-1] = payload
+
+	if group.received < count {
+		return nil, nil
+	}
+
+	delete(d.pending, key)
+	return decodePayload(strings.Join(group.parts, ""), fillBits)
+}
+
+// evict drops incomplete messages older than FragmentTTL
+func (d *Decoder) evict(now time.Time) {
+	for key, group := range d.pending {
+		if now.Sub(group.started) > d.FragmentTTL {
+			delete(d.pending, key)
+		}
+	}
+}
+
+func decodePayload(payload string, fillBits int) (Message, error) {
+	b, err := unarmor(payload, fillBits)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := decode(b)
+	if err != nil {
+		return nil, fmt.Errorf("decode AIS payload: %w", err)
+	}
+	return msg, nil
+}
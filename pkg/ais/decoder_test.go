@@ -0,0 +1,241 @@
+package ais
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/chats/sailing-backend/pkg/nmea"
+)
+
+func decodeLine(t *testing.T, d *Decoder, line string) (Message, error) {
+	t.Helper()
+	s, err := nmea.Parse(line)
+	if err != nil {
+		t.Fatalf("nmea.Parse(%q): %v", line, err)
+	}
+	return d.Decode(s)
+}
+
+// vdm wraps a payload in a single-fragment sentence
+func vdm(payload string, fill int) string {
+	body := fmt.Sprintf("AIVDM,1,1,,A,%s,%d", payload, fill)
+	return fmt.Sprintf("!%s*%02X", body, nmea.Checksum(body))
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestDecodeClassA(t *testing.T) {
+	msg, err := decodeLine(t, NewDecoder(), "!AIVDM,1,1,,B,15M67FC000G?ufbE`FepT@3n00Sa,0*5C")
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	m, ok := msg.(*PositionReport)
+	if !ok {
+		t.Fatalf("Decode = %T, want *PositionReport", msg)
+	}
+	if m.Type != 1 || m.MMSI != 366053209 || m.NavStatus != 3 || m.Second != 59 {
+		t.Errorf("type/MMSI/status/second = %d/%d/%d/%d", m.Type, m.MMSI, m.NavStatus, m.Second)
+	}
+	if !m.PositionValid || !near(m.Latitude, 37.802118333) || !near(m.Longitude, -122.341618333) {
+		t.Errorf("position = %v %f,%f", m.PositionValid, m.Latitude, m.Longitude)
+	}
+	if !m.HasSpeed || m.Speed != 0 || !m.HasCourse || m.Course != 219.3 || !m.HasHeading || m.Heading != 1 {
+		t.Errorf("speed/course/heading = %+v", m)
+	}
+}
+
+func TestDecodeStaticVoyageFragments(t *testing.T) {
+	d := NewDecoder()
+
+	msg, err := decodeLine(t, d, "!AIVDM,2,1,1,A,55?MbV02;H;s<HtKR20EHE:0@T4@Dn2222222216L961O5Gf0NSQEp6ClRp8,0*1C")
+	if msg != nil || err != nil {
+		t.Fatalf("first fragment = %v, %v; want nothing yet", msg, err)
+	}
+	msg, err = decodeLine(t, d, "!AIVDM,2,2,1,A,88888888880,2*25")
+	if err != nil {
+		t.Fatalf("second fragment: %v", err)
+	}
+
+	m, ok := msg.(*StaticVoyageData)
+	if !ok {
+		t.Fatalf("Decode = %T, want *StaticVoyageData", msg)
+	}
+	want := StaticVoyageData{
+		MMSI: 351759000, IMO: 9134270, CallSign: "3FOF8", ShipName: "EVER DIADEM", ShipType: 70,
+		ETAMonth: 5, ETADay: 15, ETAHour: 14, ETAMinute: 0, Draught: 12.2, Destination: "NEW YORK",
+	}
+	if *m != want {
+		t.Errorf("Decode = %+v, want %+v", *m, want)
+	}
+
+	eta, ok := m.ETA(time.Date(2024, 12, 30, 0, 0, 0, 0, time.UTC))
+	if !ok || !eta.Equal(time.Date(2025, 5, 15, 14, 0, 0, 0, time.UTC)) {
+		t.Errorf("ETA = %s %v, want 2025-05-15 14:00 UTC", eta, ok)
+	}
+}
+
+func TestDecodeFragmentErrors(t *testing.T) {
+	first := "!AIVDM,2,1,1,A,55?MbV02;H;s<HtKR20EHE:0@T4@Dn2222222216L961O5Gf0NSQEp6ClRp8,0*1C"
+	second := "!AIVDM,2,2,1,A,88888888880,2*25"
+
+	t.Run("orphan", func(t *testing.T) {
+		if _, err := decodeLine(t, NewDecoder(), second); !errors.Is(err, ErrFragmentOrphan) {
+			t.Fatalf("Decode = %v, want ErrFragmentOrphan", err)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+		d := NewDecoder()
+		d.Now = func() time.Time { return now }
+
+		decodeLine(t, d, first)
+		now = now.Add(d.FragmentTTL + time.Second)
+		if _, err := decodeLine(t, d, second); !errors.Is(err, ErrFragmentOrphan) {
+			t.Fatalf("Decode = %v, want ErrFragmentOrphan", err)
+		}
+	})
+
+	t.Run("other channel", func(t *testing.T) {
+		d := NewDecoder()
+		decodeLine(t, d, first)
+		body := "AIVDM,2,2,1,B,88888888880,2"
+		if _, err := decodeLine(t, d, fmt.Sprintf("!%s*%02X", body, nmea.Checksum(body))); !errors.Is(err, ErrFragmentOrphan) {
+			t.Fatalf("Decode = %v, want ErrFragmentOrphan", err)
+		}
+	})
+
+	t.Run("bad header", func(t *testing.T) {
+		body := "AIVDM,1,2,,A,15M67FC000G?ufbE`FepT@3n00Sa,0"
+		if _, err := decodeLine(t, NewDecoder(), fmt.Sprintf("!%s*%02X", body, nmea.Checksum(body))); !errors.Is(err, ErrFragmentInvalid) {
+			t.Fatalf("Decode = %v, want ErrFragmentInvalid", err)
+		}
+	})
+
+	t.Run("not AIS", func(t *testing.T) {
+		if _, err := decodeLine(t, NewDecoder(), "$GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W*6A"); !errors.Is(err, ErrNotAIS) {
+			t.Fatalf("Decode = %v, want ErrNotAIS", err)
+		}
+	})
+}
+
+func TestDecodeClassB(t *testing.T) {
+	tests := []struct {
+		name     string
+		msgType  int
+		sog      int64
+		lon, lat int64 // 1/10000 minute
+		cog      int64
+		heading  int64
+		shipName string
+		want     PositionReport
+	}{
+		{
+			name: "type 18", msgType: 18, sog: 123, lon: -73_500_000, lat: 24_300_000, cog: 2705, heading: 270,
+			want: PositionReport{Type: 18, MMSI: 211234560, NavStatus: 15, Latitude: 40.5, Longitude: -122.5,
+				Speed: 12.3, HasSpeed: true, Course: 270.5, HasCourse: true, Heading: 270, HasHeading: true, Second: 42, PositionValid: true},
+		},
+		{
+			name: "type 18 without data", msgType: 18, sog: 1023, lon: 181 * 600000, lat: 91 * 600000, cog: 3600, heading: 511,
+			want: PositionReport{Type: 18, MMSI: 211234560, NavStatus: 15, Latitude: 91, Longitude: 181, Second: 42},
+		},
+		{
+			name: "type 19", msgType: 19, sog: 50, lon: 6_000_000, lat: -20_400_000, cog: 900, heading: 91, shipName: "SEA BREEZE",
+			want: PositionReport{Type: 19, MMSI: 211234560, NavStatus: 15, Latitude: -34, Longitude: 10,
+				Speed: 5, HasSpeed: true, Course: 90, HasCourse: true, Heading: 91, HasHeading: true, Second: 42, ShipName: "SEA BREEZE", PositionValid: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var w bitWriter
+			w.put(int64(tt.msgType), 6)
+			w.put(0, 2)
+			w.put(211234560, 30)
+			w.put(0, 8)
+			w.put(tt.sog, 10)
+			w.put(0, 1)
+			w.put(tt.lon, 28)
+			w.put(tt.lat, 27)
+			w.put(tt.cog, 12)
+			w.put(tt.heading, 9)
+			w.put(42, 6)
+			length := 168
+			if tt.msgType == 19 {
+				w.put(0, 4)
+				w.text(tt.shipName, 20)
+				length = 312
+			}
+			w.pad(length)
+
+			msg, err := decodeLine(t, NewDecoder(), vdm(w.armor()))
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			m, ok := msg.(*PositionReport)
+			if !ok {
+				t.Fatalf("Decode = %T, want *PositionReport", msg)
+			}
+			if *m != tt.want {
+				t.Errorf("Decode = %+v\nwant %+v", *m, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	var short bitWriter
+	short.put(1, 6)
+	short.put(211234560, 32)
+	short.pad(100)
+
+	var unsupported bitWriter
+	unsupported.put(8, 6)
+	unsupported.pad(168)
+
+	tests := []struct {
+		name string
+		w    bitWriter
+		err  error
+	}{
+		{"short type 1", short, nil},
+		{"unsupported type", unsupported, ErrUnsupportedMessage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := decodeLine(t, NewDecoder(), vdm(tt.w.armor()))
+			if err == nil {
+				t.Fatalf("Decode = %+v, want an error", msg)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("Decode = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestPositionReportTimestamp(t *testing.T) {
+	received := time.Date(2024, 6, 1, 12, 30, 20, 0, time.UTC)
+	tests := []struct {
+		second int
+		want   time.Time
+	}{
+		{10, time.Date(2024, 6, 1, 12, 30, 10, 0, time.UTC)},
+		{20, received},
+		{50, time.Date(2024, 6, 1, 12, 29, 50, 0, time.UTC)},
+		{60, received},
+		{63, received},
+	}
+	for _, tt := range tests {
+		m := &PositionReport{Second: tt.second}
+		if got := m.Timestamp(received); !got.Equal(tt.want) {
+			t.Errorf("Timestamp with second %d = %s, want %s", tt.second, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package ais
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUnsupportedMessage is returned for message types the decoder does not handle
+var ErrUnsupportedMessage = errors.New("unsupported AIS message type")
+
+// Message is a decoded AIS message
+type Message interface {
+	// Source returns the MMSI of the transmitting station
+	Source() uint32
+}
+
+// PositionReport is a class A (types 1, 2, 3) or class B (types 18, 19)
+// position report
+type PositionReport struct {
+	Type          int
+	MMSI          uint32
+	NavStatus     int // class A only; 15 = not defined
+	Latitude      float64
+	Longitude     float64
+	Speed         float64 // speed over ground, knots; valid if HasSpeed
+	HasSpeed      bool
+	Course        float64 // course over ground, degrees; valid if HasCourse
+	HasCourse     bool
+	Heading       float64 // true heading, degrees; valid if HasHeading
+	HasHeading    bool
+	Second        int    // UTC second of the report; 60 or above means unavailable
+	ShipName      string // type 19 only
+	PositionValid bool
+}
+
+// Source implements Message
+func (m *PositionReport) Source() uint32 { return m.MMSI }
+
+// Timestamp places the report's UTC second within the minute preceding receivedAt
+func (m *PositionReport) Timestamp(receivedAt time.Time) time.Time {
+	if m.Second < 0 || m.Second > 59 {
+		return receivedAt
+	}
+
+	ts := receivedAt.Truncate(time.Minute).Add(time.Duration(m.Second) * time.Second)
+	if ts.After(receivedAt) {
+		ts = ts.Add(-time.Minute)
+	}
+	return ts
+}
+
+// StaticVoyageData is a class A static and voyage related data report (type 5)
+type StaticVoyageData struct {
+	MMSI        uint32
+	IMO         uint32
+	CallSign    string
+	ShipName    string
+	ShipType    int
+	ETAMonth    int // 0 = not available
+	ETADay      int // 0 = not available
+	ETAHour     int // 24 = not available
+	ETAMinute   int // 60 = not available
+	Draught     float64
+	Destination string
+}
+
+// Source implements Message
+func (m *StaticVoyageData) Source() uint32 { return m.MMSI }
+
+// ETA resolves the month/day/hour/minute ETA to the next matching UTC time
+// at or after ref. It returns false if the ETA is not available.
+func (m *StaticVoyageData) ETA(ref time.Time) (time.Time, bool) {
+	if m.ETAMonth < 1 || m.ETAMonth > 12 || m.ETADay < 1 || m.ETADay > 31 ||
+		m.ETAHour > 23 || m.ETAMinute > 59 {
+		return time.Time{}, false
+	}
+
+	ref = ref.UTC()
+	eta := time.Date(ref.Year(), time.Month(m.ETAMonth), m.ETADay, m.ETAHour, m.ETAMinute, 0, 0, time.UTC)
+	if eta.Before(ref.Add(-24 * time.Hour)) {
+		eta = eta.AddDate(1, 0, 0)
+	}
+	return eta, true
+}
+
+// MMSIString formats an MMSI as the nine-digit identifier used on voyages
+func MMSIString(mmsi uint32) string {
+	return fmt.Sprintf("%09d", mmsi)
+}
+
+// decode decodes a complete, de-armored payload
+func decode(b *bitVector) (Message, error) {
+	if b.len() < 38 {
+		return nil, fmt.Errorf("payload too short: %d bits", b.len())
+	}
+
+	msgType := int(b.uint(0, 6))
+	switch msgType {
+	case 1, 2, 3:
+		return decodeClassA(b, msgType)
+	case 5:
+		return decodeStaticVoyage(b)
+	case 18, 19:
+		return decodeClassB(b, msgType)
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedMessage, msgType)
+	}
+}
+
+func decodeClassA(b *bitVector, msgType int) (*PositionReport, error) {
+	if b.len() < 168 {
+		return nil, fmt.Errorf("type %d payload too short: %d bits", msgType, b.len())
+	}
+
+	m := &PositionReport{
+		Type:      msgType,
+		MMSI:      b.uint(8, 30),
+		NavStatus: int(b.uint(38, 4)),
+		Second:    int(b.uint(137, 6)),
+	}
+	setKinematics(m, b.uint(50, 10), b.int(61, 28), b.int(89, 27), b.uint(116, 12), b.uint(128, 9))
+	return m, nil
+}
+
+func decodeClassB(b *bitVector, msgType int) (*PositionReport, error) {
+	minBits := 168
+	if msgType == 19 {
+		minBits = 312
+	}
+	if b.len() < minBits {
+		return nil, fmt.Errorf("type %d payload too short: %d bits", msgType, b.len())
+	}
+
+	m := &PositionReport{
+		Type:      msgType,
+		MMSI:      b.uint(8, 30),
+		NavStatus: 15,
+		Second:    int(b.uint(133, 6)),
+	}
+	setKinematics(m, b.uint(46, 10), b.int(57, 28), b.int(85, 27), b.uint(112, 12), b.uint(124, 9))
+	if msgType == 19 {
+		m.ShipName = b.text(143, 20)
+	}
+	return m, nil
+}
+
+// setKinematics converts the raw speed, position, course and heading fields,
+// honouring the "not available" sentinel values
+func setKinematics(m *PositionReport, sog uint32, lon, lat int32, cog, heading uint32) {
+	if sog != 1023 {
+		m.Speed = float64(sog) / 10
+		m.HasSpeed = true
+	}
+	if cog < 3600 {
+		m.Course = float64(cog) / 10
+		m.HasCourse = true
+	}
+	if heading < 360 {
+		m.Heading = float64(heading)
+		m.HasHeading = true
+	}
+
+	m.Longitude = float64(lon) / 600000
+	m.Latitude = float64(lat) / 600000
+	m.PositionValid = m.Longitude >= -180 && m.Longitude <= 180 &&
+		m.Latitude >= -90 && m.Latitude <= 90
+}
+
+func decodeStaticVoyage(b *bitVector) (*StaticVoyageData, error) {
+	if b.len() < 422 {
+		return nil, fmt.Errorf("type 5 payload too short: %d bits", b.len())
+	}
+
+	return &StaticVoyageData{
+		MMSI:        b.uint(8, 30),
+		IMO:         b.uint(40, 30),
+		CallSign:    b.text(70, 7),
+		ShipName:    b.text(112, 20),
+		ShipType:    int(b.uint(232, 8)),
+		ETAMonth:    int(b.uint(274, 4)),
+		ETADay:      int(b.uint(278, 5)),
+		ETAHour:     int(b.uint(283, 5)),
+		ETAMinute:   int(b.uint(288, 6)),
+		Draught:     float64(b.uint(294, 8)) / 10,
+		Destination: b.text(302, 20),
+	}, nil
+}
@@ -0,0 +1,329 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// migrationsCollection records applied migrations, one document per version
+	migrationsCollection = "schema_migrations"
+	// migrationLockID is the document in migrationsCollection held while migrating
+	migrationLockID = "lock"
+	// migrationLockTTL is how long a lock is honoured after it was last
+	// renewed before it is considered abandoned by a crashed process
+	migrationLockTTL = 15 * time.Minute
+	// migrationLockRenewal is how often the holder renews the lock
+	migrationLockRenewal = migrationLockTTL / 3
+	// migrationLockRetry is how often a waiting process tries to take the lock
+	migrationLockRetry = 2 * time.Second
+)
+
+// ErrMigrationLocked is returned when another process still holds the
+// migration lock once the context is done
+var ErrMigrationLocked = errors.New("migrations are locked by another process")
+
+// Migration is one versioned change to the database, such as creating
+// indexes or backfilling a field. Up must be safe to run again if it was
+// interrupted before being recorded.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+}
+
+// MigrationState is a migration and when it was applied, if at all
+type MigrationState struct {
+	Version     int
+	Description string
+	AppliedAt   *time.Time
+}
+
+// migrationRecord is the stored form of an applied migration
+type migrationRecord struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+	DurationMS  int64     `bson:"duration_ms"`
+}
+
+// Step is an idempotent change kept outside the version sequence, such as
+// an opt-in conversion. Steps run after the migrations on every Migrate,
+// under the same lock, and are not recorded, so Up must do nothing once its
+// change has been made.
+type Step struct {
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+}
+
+// Migrate applies, in version order, every migration not yet recorded in
+// schema_migrations, then runs steps in order, and returns how many
+// migrations it applied. A lock document keeps concurrently starting
+// instances from migrating at the same time; the others wait for it. The
+// lock is renewed while migrations run, and if it is lost anyway, the
+// running migration's context is cancelled.
+func Migrate(ctx context.Context, db *mongo.Database, migrations []Migration, steps ...Step) (int, error) {
+	return migrate(ctx, db, mongoMigrationStore{db.Collection(migrationsCollection)}, migrations, steps)
+}
+
+func migrate(ctx context.Context, db *mongo.Database, store migrationStore, migrations []Migration, steps []Step) (int, error) {
+	migrations, err := sortMigrations(migrations)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, release, err := acquireMigrationLock(ctx, store)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	applied, err := store.appliedVersions(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		log.Info().Int("version", m.Version).Str("description", m.Description).Msg("Applying migration")
+		start := time.Now()
+		if err := m.Up(ctx, db); err != nil {
+			return count, fmt.Errorf("migration %d (%s): %w", m.Version, m.Description, err)
+		}
+
+		err := store.recordMigration(ctx, migrationRecord{
+			Version:     m.Version,
+			Description: m.Description,
+			AppliedAt:   time.Now(),
+			DurationMS:  time.Since(start).Milliseconds(),
+		})
+		if err != nil {
+			return count, fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+		}
+		count++
+	}
+
+	for _, step := range steps {
+		if err := step.Up(ctx, db); err != nil {
+			return count, fmt.Errorf("%s: %w", step.Description, err)
+		}
+	}
+
+	return count, nil
+}
+
+// MigrationStatus reports which migrations have been applied
+func MigrationStatus(ctx context.Context, db *mongo.Database, migrations []Migration) ([]MigrationState, error) {
+	migrations, err := sortMigrations(migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := mongoMigrationStore{db.Collection(migrationsCollection)}.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, len(migrations))
+	for i, m := range migrations {
+		states[i] = MigrationState{Version: m.Version, Description: m.Description}
+		if at, ok := applied[m.Version]; ok {
+			states[i].AppliedAt = &at
+		}
+	}
+
+	return states, nil
+}
+
+// sortMigrations returns migrations in version order, rejecting duplicate
+// or non-positive versions
+func sortMigrations(migrations []Migration) ([]Migration, error) {
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	for i, m := range sorted {
+		if m.Version <= 0 {
+			return nil, fmt.Errorf("migration %q has non-positive version %d", m.Description, m.Version)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("duplicate migration version %d", m.Version)
+		}
+	}
+
+	return sorted, nil
+}
+
+// migrationStore keeps the record of applied migrations and the lock
+type migrationStore interface {
+	appliedVersions(ctx context.Context) (map[int]time.Time, error)
+	recordMigration(ctx context.Context, record migrationRecord) error
+	// takeLock removes a lock last renewed before expired, then takes the
+	// lock for owner if it is free
+	takeLock(ctx context.Context, owner string, now, expired time.Time) (bool, error)
+	// renewLock reports false if owner no longer holds the lock
+	renewLock(ctx context.Context, owner string, now time.Time) (bool, error)
+	releaseLock(ctx context.Context, owner string) error
+}
+
+// mongoMigrationStore keeps applied migrations and the lock in one collection
+type mongoMigrationStore struct {
+	collection *mongo.Collection
+}
+
+func (s mongoMigrationStore) appliedVersions(ctx context.Context) (map[int]time.Time, error) {
+	cursor, err := s.collection.Find(ctx, bson.M{"_id": bson.M{"$type": "number"}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	applied := make(map[int]time.Time)
+	for cursor.Next(ctx) {
+		var record migrationRecord
+		if err := cursor.Decode(&record); err != nil {
+			return nil, err
+		}
+		applied[record.Version] = record.AppliedAt
+	}
+
+	return applied, cursor.Err()
+}
+
+func (s mongoMigrationStore) recordMigration(ctx context.Context, record migrationRecord) error {
+	_, err := s.collection.InsertOne(ctx, record)
+	return err
+}
+
+func (s mongoMigrationStore) takeLock(ctx context.Context, owner string, now, expired time.Time) (bool, error) {
+	// Locks written before they were renewed only carry acquired_at
+	_, err := s.collection.DeleteOne(ctx, bson.M{
+		"_id": migrationLockID,
+		"$or": bson.A{
+			bson.M{"renewed_at": bson.M{"$lt": expired}},
+			bson.M{"renewed_at": bson.M{"$exists": false}, "acquired_at": bson.M{"$lt": expired}},
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	_, err = s.collection.InsertOne(ctx, bson.M{
+		"_id":         migrationLockID,
+		"owner":       owner,
+		"acquired_at": now,
+		"renewed_at":  now,
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s mongoMigrationStore) renewLock(ctx context.Context, owner string, now time.Time) (bool, error) {
+	result, err := s.collection.UpdateOne(ctx,
+		bson.M{"_id": migrationLockID, "owner": owner},
+		bson.M{"$set": bson.M{"renewed_at": now}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (s mongoMigrationStore) releaseLock(ctx context.Context, owner string) error {
+	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": migrationLockID, "owner": owner})
+	return err
+}
+
+// acquireMigrationLock takes the lock, waiting while another process holds
+// it and taking over a lock not renewed for migrationLockTTL. It renews the
+// lock every migrationLockRenewal until the returned function releases it;
+// the returned context is cancelled if the lock is lost.
+func acquireMigrationLock(ctx context.Context, store migrationStore) (context.Context, func(), error) {
+	host, _ := os.Hostname()
+	owner := fmt.Sprintf("%s/%d/%d", host, os.Getpid(), time.Now().UnixNano())
+
+	for waited := false; ; waited = true {
+		now := time.Now()
+		ok, err := store.takeLock(ctx, owner, now, now.Add(-migrationLockTTL))
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			break
+		}
+
+		if !waited {
+			log.Info().Msg("Waiting for another process to finish migrating...")
+		}
+		select {
+		case <-ctx.Done():
+			return nil, nil, ErrMigrationLocked
+		case <-time.After(migrationLockRetry):
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	stop := make(chan struct{})
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		renewMigrationLock(ctx, store, owner, migrationLockRenewal, stop, cancel)
+	}()
+
+	return ctx, func() {
+		close(stop)
+		<-renewed
+		cancel()
+
+		ctx, cancelRelease := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelRelease()
+		if err := store.releaseLock(ctx, owner); err != nil {
+			log.Error().Err(err).Msg("Failed to release migration lock")
+		}
+	}, nil
+}
+
+// renewMigrationLock moves the lock's renewed_at forward every interval
+// until stop is closed. If the lock is gone or owned by another process, it
+// calls lost. A failed renewal is retried at the next tick; the lock only
+// expires after several have failed.
+func renewMigrationLock(ctx context.Context, store migrationStore, owner string, interval time.Duration, stop <-chan struct{}, lost func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		renewCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		held, err := store.renewLock(renewCtx, owner, time.Now())
+		cancel()
+		switch {
+		case err != nil:
+			log.Warn().Err(err).Msg("Failed to renew migration lock")
+		case !held:
+			log.Error().Msg("Migration lock was lost; stopping migrations")
+			lost()
+			return
+		}
+	}
+}
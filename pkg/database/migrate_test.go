@@ -0,0 +1,270 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// memoryMigrationStore keeps applied migrations and the lock in memory
+type memoryMigrationStore struct {
+	mu        sync.Mutex
+	applied   map[int]time.Time
+	owner     string
+	renewedAt time.Time
+	renewals  int
+	renewErr  error
+}
+
+func newMemoryMigrationStore() *memoryMigrationStore {
+	return &memoryMigrationStore{applied: make(map[int]time.Time)}
+}
+
+func (s *memoryMigrationStore) appliedVersions(context.Context) (map[int]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	applied := make(map[int]time.Time, len(s.applied))
+	for version, at := range s.applied {
+		applied[version] = at
+	}
+	return applied, nil
+}
+
+func (s *memoryMigrationStore) recordMigration(_ context.Context, record migrationRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.applied[record.Version] = record.AppliedAt
+	return nil
+}
+
+func (s *memoryMigrationStore) takeLock(_ context.Context, owner string, now, expired time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.owner != "" && s.renewedAt.Before(expired) {
+		s.owner = ""
+	}
+	if s.owner != "" {
+		return false, nil
+	}
+	s.owner, s.renewedAt = owner, now
+	return true, nil
+}
+
+func (s *memoryMigrationStore) renewLock(_ context.Context, owner string, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.renewals++
+	if s.renewErr != nil {
+		return false, s.renewErr
+	}
+	if s.owner != owner {
+		return false, nil
+	}
+	s.renewedAt = now
+	return true, nil
+}
+
+func (s *memoryMigrationStore) releaseLock(_ context.Context, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.owner == owner {
+		s.owner = ""
+	}
+	return nil
+}
+
+// recording returns a migration that appends its version to ran
+func recording(version int, ran *[]string) Migration {
+	return Migration{
+		Version:     version,
+		Description: fmt.Sprintf("migration %d", version),
+		Up: func(context.Context, *mongo.Database) error {
+			*ran = append(*ran, fmt.Sprint(version))
+			return nil
+		},
+	}
+}
+
+func TestMigrateOrder(t *testing.T) {
+	store := newMemoryMigrationStore()
+	store.applied[2] = time.Now()
+
+	var ran []string
+	step := Step{Description: "step", Up: func(context.Context, *mongo.Database) error {
+		ran = append(ran, "step")
+		return nil
+	}}
+	migrations := []Migration{recording(3, &ran), recording(1, &ran), recording(2, &ran), recording(10, &ran)}
+
+	count, err := migrate(context.Background(), nil, store, migrations, []Step{step})
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("count = %d, want 3", count)
+	}
+	if got := strings.Join(ran, ","); got != "1,3,10,step" {
+		t.Errorf("ran %s, want 1,3,10,step", got)
+	}
+	if store.owner != "" {
+		t.Errorf("lock still held by %q", store.owner)
+	}
+
+	// Applied migrations are skipped; steps run again
+	ran = nil
+	count, err = migrate(context.Background(), nil, store, migrations, []Step{step})
+	if err != nil || count != 0 {
+		t.Fatalf("second migrate = %d, %v, want 0, nil", count, err)
+	}
+	if got := strings.Join(ran, ","); got != "step" {
+		t.Errorf("second run ran %s, want step", got)
+	}
+}
+
+func TestMigrateStopsAtFailure(t *testing.T) {
+	store := newMemoryMigrationStore()
+	errBoom := errors.New("boom")
+
+	var ran []string
+	migrations := []Migration{
+		recording(1, &ran),
+		{Version: 2, Description: "fails", Up: func(context.Context, *mongo.Database) error { return errBoom }},
+		recording(3, &ran),
+	}
+
+	count, err := migrate(context.Background(), nil, store, migrations, nil)
+	if !errors.Is(err, errBoom) || count != 1 {
+		t.Fatalf("migrate = %d, %v, want 1, %v", count, err, errBoom)
+	}
+	if _, ok := store.applied[2]; ok {
+		t.Error("failed migration was recorded")
+	}
+	if got := strings.Join(ran, ","); got != "1" {
+		t.Errorf("ran %s, want 1", got)
+	}
+	if store.owner != "" {
+		t.Errorf("lock still held by %q", store.owner)
+	}
+}
+
+func TestSortMigrationsRejects(t *testing.T) {
+	up := func(context.Context, *mongo.Database) error { return nil }
+
+	if _, err := sortMigrations([]Migration{{Version: 1, Up: up}, {Version: 1, Up: up}}); err == nil {
+		t.Error("duplicate versions accepted")
+	}
+	if _, err := sortMigrations([]Migration{{Version: 0, Up: up}}); err == nil {
+		t.Error("version 0 accepted")
+	}
+}
+
+func TestMigrateWaitsForLock(t *testing.T) {
+	store := newMemoryMigrationStore()
+	store.owner, store.renewedAt = "other", time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var ran []string
+	_, err := migrate(ctx, nil, store, []Migration{recording(1, &ran)}, nil)
+	if !errors.Is(err, ErrMigrationLocked) {
+		t.Fatalf("migrate = %v, want %v", err, ErrMigrationLocked)
+	}
+	if len(ran) != 0 || store.owner != "other" {
+		t.Fatalf("ran %v with the lock held by %q", ran, store.owner)
+	}
+}
+
+func TestMigrateTakesAbandonedLock(t *testing.T) {
+	store := newMemoryMigrationStore()
+	store.owner, store.renewedAt = "crashed", time.Now().Add(-migrationLockTTL-time.Minute)
+
+	var ran []string
+	if _, err := migrate(context.Background(), nil, store, []Migration{recording(1, &ran)}, nil); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if len(ran) != 1 {
+		t.Fatalf("ran %v, want [1]", ran)
+	}
+}
+
+func TestRenewMigrationLock(t *testing.T) {
+	t.Run("renews until stopped", func(t *testing.T) {
+		store := newMemoryMigrationStore()
+		store.owner = "me"
+
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		lost := false
+		go func() {
+			defer close(done)
+			renewMigrationLock(context.Background(), store, "me", time.Millisecond, stop, func() { lost = true })
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+		close(stop)
+		<-done
+
+		if lost {
+			t.Error("lock reported lost")
+		}
+		if store.renewals == 0 || store.renewedAt.IsZero() {
+			t.Errorf("renewals = %d, renewed at %v", store.renewals, store.renewedAt)
+		}
+	})
+
+	t.Run("failures are retried", func(t *testing.T) {
+		store := newMemoryMigrationStore()
+		store.owner, store.renewErr = "me", errors.New("no primary")
+
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		lost := false
+		go func() {
+			defer close(done)
+			renewMigrationLock(context.Background(), store, "me", time.Millisecond, stop, func() { lost = true })
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+		close(stop)
+		<-done
+
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		if lost || store.renewals < 2 {
+			t.Errorf("lost = %v after %d renewals, want retries without losing the lock", lost, store.renewals)
+		}
+	})
+
+	t.Run("lost lock cancels", func(t *testing.T) {
+		store := newMemoryMigrationStore()
+		store.owner = "other"
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			renewMigrationLock(ctx, store, "me", time.Millisecond, make(chan struct{}), cancel)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("renewal did not stop after losing the lock")
+		}
+		if ctx.Err() == nil {
+			t.Error("context not cancelled after losing the lock")
+		}
+	})
+}
@@ -9,12 +9,13 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// ConnectMongoDB establishes a connection to MongoDB
-func ConnectMongoDB(uri, database string) (*mongo.Database, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// ConnectMongoDB establishes a connection to MongoDB, giving up after timeout
+func ConnectMongoDB(uri, database string, timeout time.Duration) (*mongo.Database, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	log.Info().Str("uri", uri).Msg("Connecting to MongoDB...")
+	// The URI is not logged because it may contain credentials
+	log.Info().Str("database", database).Msg("Connecting to MongoDB...")
 
 	clientOptions := options.Client().ApplyURI(uri)
 	client, err := mongo.Connect(ctx, clientOptions)
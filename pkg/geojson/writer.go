@@ -0,0 +1,117 @@
+// Package geojson streams RFC 7946 GeoJSON feature collections
+package geojson
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// Writer streams a FeatureCollection. LineString coordinates are written as
+// they are added, so arbitrarily long tracks never need to be held in memory.
+type Writer struct {
+	w        io.Writer
+	features int
+	inLine   bool
+	coords   int
+	last     [2]float64
+	err      error
+}
+
+// NewWriter writes the opening of a FeatureCollection
+func NewWriter(w io.Writer) (*Writer, error) {
+	gw := &Writer{w: w}
+	gw.write(`{"type":"FeatureCollection","features":[`)
+	return gw, gw.err
+}
+
+// WritePoint writes a Point feature
+func (w *Writer) WritePoint(id string, lon, lat float64, properties any) error {
+	w.beginFeature(id, properties)
+	w.write(`"geometry":{"type":"Point","coordinates":`)
+	w.write(position(lon, lat))
+	w.write(`}}`)
+	return w.err
+}
+
+// BeginLineString opens a LineString feature; add its positions with
+// AddPosition and finish it with EndLineString
+func (w *Writer) BeginLineString(id string, properties any) error {
+	w.beginFeature(id, properties)
+	w.write(`"geometry":{"type":"LineString","coordinates":[`)
+	w.inLine = true
+	w.coords = 0
+	return w.err
+}
+
+// AddPosition appends a position to the open LineString
+func (w *Writer) AddPosition(lon, lat float64) error {
+	if w.coords > 0 {
+		w.write(",")
+	}
+	w.write(position(lon, lat))
+	w.coords++
+	w.last = [2]float64{lon, lat}
+	return w.err
+}
+
+// EndLineString closes the open LineString. A line with a single position
+// is padded with a repeat of it, since a LineString needs at least two.
+func (w *Writer) EndLineString() error {
+	if !w.inLine {
+		return w.err
+	}
+	if w.coords == 1 {
+		_ = w.AddPosition(w.last[0], w.last[1])
+	}
+	w.write(`]}}`)
+	w.inLine = false
+	return w.err
+}
+
+// Close closes any open LineString and the FeatureCollection
+func (w *Writer) Close() error {
+	_ = w.EndLineString()
+	w.write(`]}`)
+	return w.err
+}
+
+func (w *Writer) beginFeature(id string, properties any) {
+	if w.features > 0 {
+		w.write(",")
+	}
+	w.features++
+
+	w.write(`{"type":"Feature",`)
+	if id != "" {
+		w.write(`"id":`)
+		w.writeJSON(id)
+		w.write(",")
+	}
+	w.write(`"properties":`)
+	w.writeJSON(properties)
+	w.write(",")
+}
+
+func (w *Writer) writeJSON(v any) {
+	if w.err != nil {
+		return
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		w.err = err
+		return
+	}
+	_, w.err = w.w.Write(b)
+}
+
+func (w *Writer) write(s string) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = io.WriteString(w.w, s)
+}
+
+func position(lon, lat float64) string {
+	return "[" + strconv.FormatFloat(lon, 'f', -1, 64) + "," + strconv.FormatFloat(lat, 'f', -1, 64) + "]"
+}
@@ -0,0 +1,150 @@
+// Package gpx reads and writes GPS Exchange Format (GPX) documents
+package gpx
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Namespaces used in written documents
+const (
+	Namespace            = "http://www.topografix.com/GPX/1/1"
+	TrackPointExtensions = "http://www.garmin.com/xmlschemas/TrackPointExtension/v2"
+	schemaLocation       = "http://www.topografix.com/GPX/1/1 http://www.topografix.com/GPX/1/1/gpx.xsd"
+)
+
+// metersPerSecondToKnots converts GPX speeds (m/s) to knots
+const metersPerSecondToKnots = 3600.0 / 1852.0
+
+// Document is a parsed GPX file. Elements are matched by local name, so GPX
+// 1.0 and 1.1 documents are both accepted.
+type Document struct {
+	Waypoints []Point `xml:"wpt"`
+	Tracks    []Track `xml:"trk"`
+}
+
+// Track is an ordered list of track segments
+type Track struct {
+	Name     string    `xml:"name"`
+	Segments []Segment `xml:"trkseg"`
+}
+
+// Segment is a continuous span of track points
+type Segment struct {
+	Points []Point `xml:"trkpt"`
+}
+
+// Point is a waypoint or track point
+type Point struct {
+	Latitude    float64    `xml:"lat,attr"`
+	Longitude   float64    `xml:"lon,attr"`
+	Elevation   *float64   `xml:"ele"`
+	Time        *time.Time `xml:"time"`
+	Name        string     `xml:"name"`
+	Description string     `xml:"desc"`
+	Comment     string     `xml:"cmt"`
+
+	// GPX 1.0 carries speed and course directly on the point; GPX 1.1
+	// writers commonly use the Garmin TrackPointExtension instead
+	LegacySpeed     *float64 `xml:"speed"`
+	LegacyCourse    *float64 `xml:"course"`
+	ExtensionSpeed  *float64 `xml:"extensions>TrackPointExtension>speed"`
+	ExtensionCourse *float64 `xml:"extensions>TrackPointExtension>course"`
+
+	hasLatitude, hasLongitude bool
+}
+
+// UnmarshalXML decodes a point and records which coordinate attributes were
+// present, since a missing attribute would otherwise read as 0
+func (p *Point) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type point Point // without the UnmarshalXML method
+	if err := d.DecodeElement((*point)(p), &start); err != nil {
+		return err
+	}
+
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "lat":
+			p.hasLatitude = true
+		case "lon":
+			p.hasLongitude = true
+		}
+	}
+	return nil
+}
+
+// SpeedKnots returns the point's speed in knots, if recorded
+func (p *Point) SpeedKnots() (float64, bool) {
+	switch {
+	case p.ExtensionSpeed != nil:
+		return *p.ExtensionSpeed * metersPerSecondToKnots, true
+	case p.LegacySpeed != nil:
+		return *p.LegacySpeed * metersPerSecondToKnots, true
+	default:
+		return 0, false
+	}
+}
+
+// Course returns the point's course over ground in degrees, if recorded
+func (p *Point) Course() (float64, bool) {
+	switch {
+	case p.ExtensionCourse != nil:
+		return *p.ExtensionCourse, true
+	case p.LegacyCourse != nil:
+		return *p.LegacyCourse, true
+	default:
+		return 0, false
+	}
+}
+
+// Parse decodes a GPX document
+func Parse(r io.Reader) (*Document, error) {
+	var doc Document
+	decoder := xml.NewDecoder(r)
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid GPX document: %w", err)
+	}
+
+	for _, wpt := range doc.Waypoints {
+		if err := wpt.validate(); err != nil {
+			return nil, err
+		}
+	}
+	for _, trk := range doc.Tracks {
+		for _, seg := range trk.Segments {
+			for _, pt := range seg.Points {
+				if err := pt.validate(); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return &doc, nil
+}
+
+func (p *Point) validate() error {
+	if !p.hasLatitude || !p.hasLongitude {
+		return errors.New("point is missing its lat or lon attribute")
+	}
+	if !finite(p.Latitude) || !finite(p.Longitude) ||
+		p.Latitude < -90 || p.Latitude > 90 || p.Longitude < -180 || p.Longitude > 180 {
+		return fmt.Errorf("point %f,%f out of range", p.Latitude, p.Longitude)
+	}
+
+	for _, v := range []*float64{p.Elevation, p.LegacySpeed, p.LegacyCourse, p.ExtensionSpeed, p.ExtensionCourse} {
+		if v != nil && !finite(*v) {
+			return fmt.Errorf("point %f,%f has a non-finite value", p.Latitude, p.Longitude)
+		}
+	}
+	return nil
+}
+
+// finite reports whether v is neither NaN nor infinite
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
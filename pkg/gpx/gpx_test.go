@@ -0,0 +1,83 @@
+package gpx
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	doc, err := Parse(strings.NewReader(`<?xml version="1.0"?>
+<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1"
+     xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v2">
+  <wpt lat="13.5" lon="100.25"><name>Start</name></wpt>
+  <trk>
+    <name>Leg 1</name>
+    <trkseg>
+      <trkpt lat="0" lon="0"><time>2024-06-01T08:00:00Z</time></trkpt>
+      <trkpt lat="-33.85" lon="151.2">
+        <ele>2.5</ele>
+        <extensions><gpxtpx:TrackPointExtension><gpxtpx:speed>5</gpxtpx:speed><gpxtpx:course>90</gpxtpx:course></gpxtpx:TrackPointExtension></extensions>
+      </trkpt>
+    </trkseg>
+  </trk>
+</gpx>`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if len(doc.Waypoints) != 1 || doc.Waypoints[0].Name != "Start" || doc.Waypoints[0].Latitude != 13.5 {
+		t.Fatalf("Waypoints = %+v", doc.Waypoints)
+	}
+	if len(doc.Tracks) != 1 || len(doc.Tracks[0].Segments) != 1 || len(doc.Tracks[0].Segments[0].Points) != 2 {
+		t.Fatalf("Tracks = %+v", doc.Tracks)
+	}
+
+	first, second := doc.Tracks[0].Segments[0].Points[0], doc.Tracks[0].Segments[0].Points[1]
+	if first.Latitude != 0 || first.Longitude != 0 || first.Time == nil || !first.Time.Equal(time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Fatalf("first point = %+v", first)
+	}
+	if speed, ok := second.SpeedKnots(); !ok || math.Abs(speed-5*metersPerSecondToKnots) > 1e-9 {
+		t.Fatalf("SpeedKnots = %v, %v", speed, ok)
+	}
+	if course, ok := second.Course(); !ok || course != 90 {
+		t.Fatalf("Course = %v, %v", course, ok)
+	}
+	if second.Elevation == nil || *second.Elevation != 2.5 {
+		t.Fatalf("Elevation = %v", second.Elevation)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		point string
+		want  string
+	}{
+		{"missing lat", `<trkpt lon="100"/>`, "missing its lat or lon"},
+		{"missing lon", `<trkpt lat="13"/>`, "missing its lat or lon"},
+		{"no coordinates", `<trkpt/>`, "missing its lat or lon"},
+		{"NaN latitude", `<trkpt lat="NaN" lon="100"/>`, "out of range"},
+		{"infinite longitude", `<trkpt lat="13" lon="+Inf"/>`, "out of range"},
+		{"latitude out of range", `<trkpt lat="91" lon="100"/>`, "out of range"},
+		{"longitude out of range", `<trkpt lat="13" lon="-180.5"/>`, "out of range"},
+		{"NaN elevation", `<trkpt lat="13" lon="100"><ele>NaN</ele></trkpt>`, "non-finite"},
+		{"infinite speed", `<trkpt lat="13" lon="100"><speed>Inf</speed></trkpt>`, "non-finite"},
+		{"unparseable latitude", `<trkpt lat="north" lon="100"/>`, "invalid GPX document"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(`<gpx><trk><trkseg>` + tt.point + `</trkseg></trk></gpx>`))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Parse = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+
+	_, err := Parse(strings.NewReader(`<gpx><wpt lat="13"/></gpx>`))
+	if err == nil || !strings.Contains(err.Error(), "missing its lat or lon") {
+		t.Fatalf("Parse waypoint = %v, want a missing coordinate error", err)
+	}
+}
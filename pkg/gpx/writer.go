@@ -0,0 +1,186 @@
+package gpx
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Writer streams a GPX 1.1 document. Per the GPX schema, all waypoints must
+// be written before the first track.
+type Writer struct {
+	enc     *xml.Encoder
+	inTrack bool
+	err     error
+}
+
+// Metadata describes the document being written
+type Metadata struct {
+	Name        string
+	Description string
+	Time        time.Time
+}
+
+// TrackPoint is a point written to a track
+type TrackPoint struct {
+	Latitude  float64
+	Longitude float64
+	Elevation *float64
+	Time      time.Time
+	Speed     *float64 // knots
+	Course    *float64 // degrees
+}
+
+// Waypoint is a named point written outside any track
+type Waypoint struct {
+	Latitude    float64
+	Longitude   float64
+	Time        time.Time
+	Name        string
+	Description string
+}
+
+// NewWriter writes the XML declaration and opening <gpx> element
+func NewWriter(w io.Writer, creator string, meta Metadata) (*Writer, error) {
+	gw := &Writer{enc: xml.NewEncoder(w)}
+	gw.enc.Indent("", "  ")
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return nil, err
+	}
+
+	gw.start("gpx",
+		xml.Attr{Name: xml.Name{Local: "version"}, Value: "1.1"},
+		xml.Attr{Name: xml.Name{Local: "creator"}, Value: creator},
+		xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: Namespace},
+		xml.Attr{Name: xml.Name{Local: "xmlns:xsi"}, Value: "http://www.w3.org/2001/XMLSchema-instance"},
+		xml.Attr{Name: xml.Name{Local: "xmlns:gpxtpx"}, Value: TrackPointExtensions},
+		xml.Attr{Name: xml.Name{Local: "xsi:schemaLocation"}, Value: schemaLocation},
+	)
+
+	gw.start("metadata")
+	gw.text("name", meta.Name)
+	gw.text("desc", meta.Description)
+	if !meta.Time.IsZero() {
+		gw.text("time", formatTime(meta.Time))
+	}
+	gw.end("metadata")
+
+	return gw, gw.err
+}
+
+// WriteWaypoint writes a <wpt> element
+func (w *Writer) WriteWaypoint(p Waypoint) error {
+	w.start("wpt", coordAttrs(p.Latitude, p.Longitude)...)
+	if !p.Time.IsZero() {
+		w.text("time", formatTime(p.Time))
+	}
+	w.text("name", p.Name)
+	w.text("desc", p.Description)
+	w.end("wpt")
+	return w.err
+}
+
+// BeginTrack opens a <trk> with a single <trkseg>
+func (w *Writer) BeginTrack(name string) error {
+	w.start("trk")
+	w.text("name", name)
+	w.start("trkseg")
+	w.inTrack = true
+	return w.err
+}
+
+// WriteTrackPoint writes a <trkpt> to the open track
+func (w *Writer) WriteTrackPoint(p TrackPoint) error {
+	w.start("trkpt", coordAttrs(p.Latitude, p.Longitude)...)
+	if p.Elevation != nil {
+		w.text("ele", formatFloat(*p.Elevation))
+	}
+	if !p.Time.IsZero() {
+		w.text("time", formatTime(p.Time))
+	}
+	if p.Speed != nil || p.Course != nil {
+		w.start("extensions")
+		w.start("gpxtpx:TrackPointExtension")
+		if p.Speed != nil {
+			w.text("gpxtpx:speed", formatFloat(*p.Speed/metersPerSecondToKnots))
+		}
+		if p.Course != nil {
+			w.text("gpxtpx:course", formatFloat(*p.Course))
+		}
+		w.end("gpxtpx:TrackPointExtension")
+		w.end("extensions")
+	}
+	w.end("trkpt")
+	return w.err
+}
+
+// EndTrack closes the open track
+func (w *Writer) EndTrack() error {
+	if w.inTrack {
+		w.end("trkseg")
+		w.end("trk")
+		w.inTrack = false
+	}
+	return w.err
+}
+
+// Close closes any open track and the document
+func (w *Writer) Close() error {
+	_ = w.EndTrack()
+	w.end("gpx")
+	if w.err == nil {
+		w.err = w.enc.Flush()
+	}
+	return w.err
+}
+
+// Flush writes buffered output to the underlying writer
+func (w *Writer) Flush() error {
+	if w.err == nil {
+		w.err = w.enc.Flush()
+	}
+	return w.err
+}
+
+func (w *Writer) start(name string, attrs ...xml.Attr) {
+	if w.err != nil {
+		return
+	}
+	w.err = w.enc.EncodeToken(xml.StartElement{Name: xml.Name{Local: name}, Attr: attrs})
+}
+
+func (w *Writer) end(name string) {
+	if w.err != nil {
+		return
+	}
+	w.err = w.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: name}})
+}
+
+// text writes a simple element, skipping it if value is empty
+func (w *Writer) text(name, value string) {
+	if value == "" {
+		return
+	}
+	w.start(name)
+	if w.err == nil {
+		w.err = w.enc.EncodeToken(xml.CharData(value))
+	}
+	w.end(name)
+}
+
+func coordAttrs(lat, lon float64) []xml.Attr {
+	return []xml.Attr{
+		{Name: xml.Name{Local: "lat"}, Value: formatFloat(lat)},
+		{Name: xml.Name{Local: "lon"}, Value: formatFloat(lon)},
+	}
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,343 @@
+// Package jwks loads JSON Web Key Sets (RFC 7517) from a file or URL and
+// keeps them fresh, so tokens signed by a rotated key are accepted as soon
+// as the issuer publishes it.
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrKeyNotFound is returned when no key in the set has the requested ID
+var ErrKeyNotFound = errors.New("jwks: key not found")
+
+// maxSetSize bounds the size of a fetched key set
+const maxSetSize = 1 << 20
+
+// Config controls where a key set is loaded from and how often it is reloaded
+type Config struct {
+	URL             string // https URL of the key set; takes precedence over File
+	File            string // path of a key set on disk
+	RefreshInterval time.Duration
+	// MinRefreshInterval rate-limits reloads triggered by tokens with an
+	// unknown key ID
+	MinRefreshInterval time.Duration
+	Client             *http.Client
+}
+
+// KeySet is a set of public keys indexed by key ID
+type KeySet struct {
+	cfg Config
+
+	mu          sync.RWMutex
+	keys        map[string]crypto.PublicKey
+	lastRefresh time.Time
+
+	refreshMu sync.Mutex
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// New loads the key set, failing if it cannot be read or holds no usable keys
+func New(ctx context.Context, cfg Config) (*KeySet, error) {
+	if cfg.URL == "" && cfg.File == "" {
+		return nil, errors.New("jwks: a URL or file is required")
+	}
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = 15 * time.Minute
+	}
+	if cfg.MinRefreshInterval <= 0 {
+		cfg.MinRefreshInterval = 30 * time.Second
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	s := &KeySet{
+		cfg:  cfg,
+		done: make(chan struct{}),
+	}
+	if err := s.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Start reloads the key set every RefreshInterval until Shutdown
+func (s *KeySet) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.cfg.RefreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				if err := s.Refresh(ctx); err != nil {
+					log.Error().Err(err).Msg("Failed to refresh JWKS; keeping previous keys")
+				}
+				cancel()
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Shutdown stops periodic reloads
+func (s *KeySet) Shutdown(ctx context.Context) error {
+	close(s.done)
+
+	stopped := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Key returns the key with the given ID. An unknown ID reloads the set, at
+// most once per MinRefreshInterval, in case the issuer rotated keys. A token
+// without a key ID may use the only key of a single-key set.
+func (s *KeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	// Another caller may have reloaded the set while this one waited
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+
+	s.mu.RLock()
+	recent := time.Since(s.lastRefresh) < s.cfg.MinRefreshInterval
+	s.mu.RUnlock()
+	if recent {
+		return nil, ErrKeyNotFound
+	}
+
+	if err := s.refresh(ctx); err != nil {
+		return nil, err
+	}
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+// Refresh reloads the key set now
+func (s *KeySet) Refresh(ctx context.Context) error {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+	return s.refresh(ctx)
+}
+
+func (s *KeySet) refresh(ctx context.Context) error {
+	data, err := s.read(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys, err := Parse(data)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return errors.New("jwks: no usable signing keys")
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.lastRefresh = time.Now()
+	s.mu.Unlock()
+
+	log.Debug().Int("keys", len(keys)).Msg("JWKS loaded")
+	return nil
+}
+
+func (s *KeySet) lookup(kid string) (crypto.PublicKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// read fetches the raw key set from the configured source
+func (s *KeySet) read(ctx context.Context) ([]byte, error) {
+	if s.cfg.URL == "" {
+		return os.ReadFile(s.cfg.File)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.cfg.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("jwks: fetch %s: %w", s.cfg.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks: fetch %s: status %d", s.cfg.URL, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxSetSize))
+}
+
+// jsonWebKey holds the JWK members used for RSA and EC signing keys
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// Parse decodes a key set into public keys by key ID. Encryption keys and
+// key types other than RSA and EC are skipped, as are keys that cannot be
+// used, such as RSA keys under 2048 bits or unsupported curves; those are
+// logged, so one bad key does not take down the rest of the set.
+func Parse(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		var (
+			key crypto.PublicKey
+			err error
+		)
+		switch jwk.Kty {
+		case "RSA":
+			key, err = jwk.rsaKey()
+		case "EC":
+			key, err = jwk.ecdsaKey()
+		default:
+			continue
+		}
+		if err != nil {
+			log.Warn().Err(err).Str("kid", jwk.Kid).Str("kty", jwk.Kty).Msg("Skipping unusable JWKS key")
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	return keys, nil
+}
+
+func (k *jsonWebKey) rsaKey() (*rsa.PublicKey, error) {
+	n, err := decodeBigInt(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("modulus: %w", err)
+	}
+	e, err := decodeBigInt(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("exponent: %w", err)
+	}
+	if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid exponent")
+	}
+	if n.BitLen() < 2048 {
+		return nil, errors.New("modulus shorter than 2048 bits")
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func (k *jsonWebKey) ecdsaKey() (*ecdsa.PublicKey, error) {
+	var (
+		curve elliptic.Curve
+		check ecdh.Curve
+	)
+	switch k.Crv {
+	case "P-256":
+		curve, check = elliptic.P256(), ecdh.P256()
+	case "P-384":
+		curve, check = elliptic.P384(), ecdh.P384()
+	case "P-521":
+		curve, check = elliptic.P521(), ecdh.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+	}
+
+	x, err := decodeBigInt(k.X)
+	if err != nil {
+		return nil, fmt.Errorf("x: %w", err)
+	}
+	y, err := decodeBigInt(k.Y)
+	if err != nil {
+		return nil, fmt.Errorf("y: %w", err)
+	}
+
+	// Reject points that are not on the curve
+	size := (curve.Params().BitSize + 7) / 8
+	if len(x.Bytes()) > size || len(y.Bytes()) > size {
+		return nil, errors.New("point is not on the curve")
+	}
+	point := make([]byte, 1+2*size)
+	point[0] = 4 // uncompressed
+	x.FillBytes(point[1 : 1+size])
+	y.FillBytes(point[1+size:])
+	if _, err := check.NewPublicKey(point); err != nil {
+		return nil, errors.New("point is not on the curve")
+	}
+
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, errors.New("missing")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
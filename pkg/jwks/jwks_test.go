@@ -0,0 +1,183 @@
+package jwks
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func rsaJWK(t *testing.T, kid string, bits int) map[string]string {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(t *testing.T, kid string) map[string]string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]string{
+		"kty": "EC",
+		"kid": kid,
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+func encodeSet(t *testing.T, keys ...map[string]string) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseSkipsUnusableKeys(t *testing.T) {
+	secp256k1 := ecJWK(t, "k1")
+	secp256k1["crv"] = "secp256k1"
+	offCurve := ecJWK(t, "off-curve")
+	offCurve["y"] = offCurve["x"]
+	encryption := rsaJWK(t, "enc", 2048)
+	encryption["use"] = "enc"
+
+	data := encodeSet(t,
+		rsaJWK(t, "rsa", 2048),
+		ecJWK(t, "ec"),
+		rsaJWK(t, "short", 1024),
+		secp256k1,
+		offCurve,
+		encryption,
+		map[string]string{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"},
+	)
+
+	keys, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("got %d keys, want only rsa and ec", len(keys))
+	}
+	if _, ok := keys["rsa"].(*rsa.PublicKey); !ok {
+		t.Error("rsa key missing")
+	}
+	if _, ok := keys["ec"].(*ecdsa.PublicKey); !ok {
+		t.Error("ec key missing")
+	}
+}
+
+func TestParseRejectsMalformedSet(t *testing.T) {
+	if _, err := Parse([]byte(`{"keys": [`)); err == nil {
+		t.Error("Parse accepted malformed JSON")
+	}
+}
+
+// jwksServer serves a key set that tests can replace, counting fetches
+type jwksServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	set     []byte
+	fetches int
+}
+
+func newJWKSServer(t *testing.T, set []byte) *jwksServer {
+	s := &jwksServer{set: set}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.fetches++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(s.set)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) publish(set []byte) {
+	s.mu.Lock()
+	s.set = set
+	s.mu.Unlock()
+}
+
+func (s *jwksServer) fetchCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetches
+}
+
+func TestKeySetReloadsForUnknownKeyID(t *testing.T) {
+	server := newJWKSServer(t, encodeSet(t, rsaJWK(t, "old", 2048)))
+	ctx := context.Background()
+
+	set, err := New(ctx, Config{URL: server.URL, MinRefreshInterval: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := set.Key(ctx, "old"); err != nil {
+		t.Fatalf("Key(old): %v", err)
+	}
+
+	// The issuer rotates keys; the next token with the new kid reloads the set
+	server.publish(encodeSet(t, rsaJWK(t, "new", 2048)))
+	if _, err := set.Key(ctx, "new"); err != ErrKeyNotFound {
+		t.Fatalf("Key(new) within MinRefreshInterval = %v, want ErrKeyNotFound", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, err := set.Key(ctx, "new"); err != nil {
+		t.Fatalf("Key(new) after rotation: %v", err)
+	}
+	if _, err := set.Key(ctx, "old"); err != ErrKeyNotFound {
+		t.Errorf("Key(old) after rotation = %v, want ErrKeyNotFound", err)
+	}
+	if n := server.fetchCount(); n != 2 {
+		t.Errorf("fetched %d times, want 2", n)
+	}
+}
+
+func TestKeySetKeepsKeysWhenRefreshFails(t *testing.T) {
+	server := newJWKSServer(t, encodeSet(t, rsaJWK(t, "current", 2048)))
+	ctx := context.Background()
+
+	set, err := New(ctx, Config{URL: server.URL})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	server.publish(encodeSet(t, rsaJWK(t, "short", 1024)))
+	if err := set.Refresh(ctx); err == nil {
+		t.Error("Refresh accepted a set without usable keys")
+	}
+	if _, err := set.Key(ctx, "current"); err != nil {
+		t.Errorf("Key(current) after failed refresh: %v", err)
+	}
+}
+
+func TestNewFailsWithoutUsableKeys(t *testing.T) {
+	server := newJWKSServer(t, encodeSet(t, rsaJWK(t, "short", 1024)))
+	if _, err := New(context.Background(), Config{URL: server.URL}); err == nil {
+		t.Error("New accepted a set without usable keys")
+	}
+}
@@ -0,0 +1,145 @@
+// Package kml streams KML 2.2 documents for Google Earth
+package kml
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Namespace is the KML 2.2 namespace
+const Namespace = "http://www.opengis.net/kml/2.2"
+
+// Writer streams a KML document. LineString coordinates are written as they
+// are added.
+type Writer struct {
+	enc    *xml.Encoder
+	inLine bool
+	err    error
+}
+
+// Placemark is a named point
+type Placemark struct {
+	Name        string
+	Description string
+	Time        time.Time
+	Latitude    float64
+	Longitude   float64
+}
+
+// NewWriter writes the XML declaration and opens the <Document>
+func NewWriter(w io.Writer, name, description string) (*Writer, error) {
+	kw := &Writer{enc: xml.NewEncoder(w)}
+	kw.enc.Indent("", "  ")
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return nil, err
+	}
+
+	kw.start("kml", xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: Namespace})
+	kw.start("Document")
+	kw.text("name", name)
+	kw.text("description", description)
+
+	return kw, kw.err
+}
+
+// WritePlacemark writes a point placemark
+func (w *Writer) WritePlacemark(p Placemark) error {
+	w.start("Placemark")
+	w.text("name", p.Name)
+	w.text("description", p.Description)
+	if !p.Time.IsZero() {
+		w.start("TimeStamp")
+		w.text("when", p.Time.UTC().Format(time.RFC3339))
+		w.end("TimeStamp")
+	}
+	w.start("Point")
+	w.text("coordinates", coordinate(p.Longitude, p.Latitude))
+	w.end("Point")
+	w.end("Placemark")
+	return w.err
+}
+
+// BeginLineString opens a line placemark; add its points with AddCoordinate
+// and finish it with EndLineString
+func (w *Writer) BeginLineString(name, description string, begin, end time.Time) error {
+	w.start("Placemark")
+	w.text("name", name)
+	w.text("description", description)
+	if !begin.IsZero() {
+		w.start("TimeSpan")
+		w.text("begin", begin.UTC().Format(time.RFC3339))
+		if !end.IsZero() {
+			w.text("end", end.UTC().Format(time.RFC3339))
+		}
+		w.end("TimeSpan")
+	}
+	w.start("LineString")
+	w.text("tessellate", "1")
+	w.start("coordinates")
+	w.inLine = true
+	return w.err
+}
+
+// AddCoordinate appends a point to the open line
+func (w *Writer) AddCoordinate(lon, lat float64) error {
+	if w.err == nil {
+		w.err = w.enc.EncodeToken(xml.CharData(coordinate(lon, lat) + " "))
+	}
+	return w.err
+}
+
+// EndLineString closes the open line placemark
+func (w *Writer) EndLineString() error {
+	if !w.inLine {
+		return w.err
+	}
+	w.end("coordinates")
+	w.end("LineString")
+	w.end("Placemark")
+	w.inLine = false
+	return w.err
+}
+
+// Close closes any open line and the document
+func (w *Writer) Close() error {
+	_ = w.EndLineString()
+	w.end("Document")
+	w.end("kml")
+	if w.err == nil {
+		w.err = w.enc.Flush()
+	}
+	return w.err
+}
+
+func (w *Writer) start(name string, attrs ...xml.Attr) {
+	if w.err != nil {
+		return
+	}
+	w.err = w.enc.EncodeToken(xml.StartElement{Name: xml.Name{Local: name}, Attr: attrs})
+}
+
+func (w *Writer) end(name string) {
+	if w.err != nil {
+		return
+	}
+	w.err = w.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: name}})
+}
+
+// text writes a simple element, skipping it if value is empty
+func (w *Writer) text(name, value string) {
+	if value == "" {
+		return
+	}
+	w.start(name)
+	if w.err == nil {
+		w.err = w.enc.EncodeToken(xml.CharData(value))
+	}
+	w.end(name)
+}
+
+func coordinate(lon, lat float64) string {
+	return strconv.FormatFloat(lon, 'f', -1, 64) + "," + strconv.FormatFloat(lat, 'f', -1, 64)
+}
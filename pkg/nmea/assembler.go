@@ -0,0 +1,148 @@
+package nmea
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNoCurrentFix is returned for VTG/HDT sentences received before any position sentence
+var ErrNoCurrentFix = errors.New("no position fix to attach sentence to")
+
+// Fix is a position report assembled from the sentences of one receiver epoch
+type Fix struct {
+	Time        time.Time
+	Latitude    float64
+	Longitude   float64
+	Speed       float64 // knots
+	Course      float64 // course over ground, degrees true
+	Heading     float64 // true heading, degrees; valid if HasHeading
+	HasHeading  bool
+	Altitude    float64 // meters above mean sea level; valid if HasAltitude
+	HasAltitude bool
+}
+
+// TrueHeading returns the vessel heading, falling back to course over ground
+// when no heading sensor sentence was received
+func (f *Fix) TrueHeading() float64 {
+	if f.HasHeading {
+		return f.Heading
+	}
+	return f.Course
+}
+
+// day is the length of a UTC day, by which GGA dates roll over
+const day = 24 * time.Hour
+
+// Assembler merges the RMC, GGA, VTG and HDT sentences a receiver emits for
+// each epoch into a single Fix. A new fix starts whenever a position sentence
+// carries a different timestamp than the one being assembled.
+type Assembler struct {
+	current *Fix
+	last    time.Time // time of the latest position sentence
+
+	// Now supplies the date for GGA sentences received before any RMC
+	Now func() time.Time
+}
+
+// NewAssembler creates a new Assembler
+func NewAssembler() *Assembler {
+	return &Assembler{Now: time.Now}
+}
+
+// Add consumes a sentence and returns the previous fix if the sentence
+// started a new one
+func (a *Assembler) Add(s *Sentence) (*Fix, error) {
+	switch s.Type {
+	case "RMC":
+		rmc, err := ParseRMC(s)
+		if err != nil {
+			return nil, err
+		}
+		done := a.advance(rmc.Time)
+		a.current.Latitude = rmc.Latitude
+		a.current.Longitude = rmc.Longitude
+		a.current.Speed = rmc.Speed
+		a.current.Course = rmc.Course
+		return done, nil
+
+	case "GGA":
+		gga, err := ParseGGA(s)
+		if err != nil {
+			return nil, err
+		}
+
+		done := a.advance(a.timeOf(gga.TimeOfDay))
+		a.current.Latitude = gga.Latitude
+		a.current.Longitude = gga.Longitude
+		a.current.Altitude = gga.Altitude
+		a.current.HasAltitude = true
+		return done, nil
+
+	case "VTG":
+		vtg, err := ParseVTG(s)
+		if err != nil {
+			return nil, err
+		}
+		if a.current == nil {
+			return nil, ErrNoCurrentFix
+		}
+		a.current.Speed = vtg.Speed
+		a.current.Course = vtg.Course
+		return nil, nil
+
+	case "HDT":
+		hdt, err := ParseHDT(s)
+		if err != nil {
+			return nil, err
+		}
+		if a.current == nil {
+			return nil, ErrNoCurrentFix
+		}
+		a.current.Heading = hdt.Heading
+		a.current.HasHeading = true
+		return nil, nil
+
+	default:
+		return nil, ErrUnsupported
+	}
+}
+
+// Flush returns the fix being assembled, if any, and resets the assembler
+func (a *Assembler) Flush() *Fix {
+	done := a.current
+	a.current = nil
+	return done
+}
+
+// advance starts a new fix at t unless the current fix already has that
+// timestamp, returning the completed fix if there was one
+func (a *Assembler) advance(t time.Time) *Fix {
+	if a.current != nil && a.current.Time.Equal(t) {
+		return nil
+	}
+
+	done := a.current
+	a.current = &Fix{Time: t}
+	a.last = t
+	return done
+}
+
+// timeOf dates a GGA time of day. It takes the date of the latest position
+// sentence, or of Now before the first one, and moves it a day forward or
+// back when that puts the fix more than half a day away, so fixes after UTC
+// midnight are not dated to the day before.
+func (a *Assembler) timeOf(tod time.Duration) time.Time {
+	ref := a.last
+	if ref.IsZero() {
+		ref = a.Now().UTC()
+	}
+
+	t := ref.Truncate(day).Add(tod)
+	switch {
+	case ref.Sub(t) > day/2:
+		t = t.Add(day)
+	case t.Sub(ref) > day/2:
+		t = t.Add(-day)
+	}
+	return t
+}
@@ -0,0 +1,147 @@
+package nmea
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// add feeds sentence bodies to a and returns the completed fixes
+func add(t *testing.T, a *Assembler, bodies ...string) []*Fix {
+	t.Helper()
+
+	var fixes []*Fix
+	for _, body := range bodies {
+		fix, err := a.Add(mustParse(t, line(body)))
+		if err != nil {
+			t.Fatalf("Add(%q): %v", body, err)
+		}
+		if fix != nil {
+			fixes = append(fixes, fix)
+		}
+	}
+	return fixes
+}
+
+func TestAssemblerMergesEpoch(t *testing.T) {
+	a := NewAssembler()
+	fixes := add(t, a,
+		"GPRMC,120000,A,4807.038,N,01131.000,E,5.0,90.0,010624,,",
+		"GPGGA,120000,4807.038,N,01131.000,E,1,08,0.9,12.5,M,,M,,",
+		"GPVTG,91.0,T,,M,5.2,N,,K",
+		"HEHDT,88.0,T",
+		"GPRMC,120001,A,4807.100,N,01131.000,E,5.0,90.0,010624,,",
+	)
+	if len(fixes) != 1 {
+		t.Fatalf("got %d fixes, want 1", len(fixes))
+	}
+
+	fix := fixes[0]
+	if want := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC); !fix.Time.Equal(want) {
+		t.Errorf("Time = %s, want %s", fix.Time, want)
+	}
+	if !fix.HasAltitude || fix.Altitude != 12.5 {
+		t.Errorf("altitude = %v %f, want 12.5", fix.HasAltitude, fix.Altitude)
+	}
+	if fix.Speed != 5.2 || fix.Course != 91.0 {
+		t.Errorf("speed/course = %f/%f, want the VTG values", fix.Speed, fix.Course)
+	}
+	if fix.TrueHeading() != 88.0 {
+		t.Errorf("TrueHeading = %f, want 88", fix.TrueHeading())
+	}
+
+	last := a.Flush()
+	if last == nil || !last.Time.Equal(time.Date(2024, 6, 1, 12, 0, 1, 0, time.UTC)) {
+		t.Fatalf("Flush = %+v, want the 12:00:01 fix", last)
+	}
+	if a.Flush() != nil {
+		t.Fatal("second Flush returned a fix")
+	}
+}
+
+func TestAssemblerGGADate(t *testing.T) {
+	tests := []struct {
+		name   string
+		now    time.Time
+		bodies []string
+		want   []time.Time
+	}{
+		{
+			name: "after RMC",
+			now:  time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+			bodies: []string{
+				"GPRMC,120000,A,4807.038,N,01131.000,E,,,010624,,",
+				"GPGGA,120001,4807.038,N,01131.000,E,1,08,0.9,,M,,M,,",
+				"GPGGA,120002,4807.038,N,01131.000,E,1,08,0.9,,M,,M,,",
+			},
+			want: []time.Time{
+				time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+				time.Date(2024, 6, 1, 12, 0, 1, 0, time.UTC),
+			},
+		},
+		{
+			name: "past UTC midnight",
+			now:  time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+			bodies: []string{
+				"GPRMC,235959,A,4807.038,N,01131.000,E,,,010624,,",
+				"GPGGA,000000,4807.038,N,01131.000,E,1,08,0.9,,M,,M,,",
+				"GPGGA,000001,4807.038,N,01131.000,E,1,08,0.9,,M,,M,,",
+			},
+			want: []time.Time{
+				time.Date(2024, 6, 1, 23, 59, 59, 0, time.UTC),
+				time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "before any RMC",
+			now:  time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC),
+			bodies: []string{
+				"GPGGA,095959,4807.038,N,01131.000,E,1,08,0.9,,M,,M,,",
+				"GPGGA,100000,4807.038,N,01131.000,E,1,08,0.9,,M,,M,,",
+			},
+			want: []time.Time{
+				time.Date(2024, 6, 1, 9, 59, 59, 0, time.UTC),
+			},
+		},
+		{
+			name: "received just after midnight",
+			now:  time.Date(2024, 6, 2, 0, 0, 30, 0, time.UTC),
+			bodies: []string{
+				"GPGGA,235958,4807.038,N,01131.000,E,1,08,0.9,,M,,M,,",
+				"GPGGA,235959,4807.038,N,01131.000,E,1,08,0.9,,M,,M,,",
+			},
+			want: []time.Time{
+				time.Date(2024, 6, 1, 23, 59, 58, 0, time.UTC),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAssembler()
+			a.Now = func() time.Time { return tt.now }
+
+			fixes := add(t, a, tt.bodies...)
+			if len(fixes) != len(tt.want) {
+				t.Fatalf("got %d fixes, want %d", len(fixes), len(tt.want))
+			}
+			for i, fix := range fixes {
+				if !fix.Time.Equal(tt.want[i]) {
+					t.Errorf("fix %d at %s, want %s", i, fix.Time, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAssemblerWithoutFix(t *testing.T) {
+	a := NewAssembler()
+	for _, body := range []string{"GPVTG,91.0,T,,M,5.2,N,,K", "HEHDT,88.0,T"} {
+		if _, err := a.Add(mustParse(t, line(body))); !errors.Is(err, ErrNoCurrentFix) {
+			t.Errorf("Add(%q) = %v, want ErrNoCurrentFix", body, err)
+		}
+	}
+	if _, err := a.Add(mustParse(t, line("GPGSV,3,1,11"))); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("GSV: %v, want ErrUnsupported", err)
+	}
+}
@@ -0,0 +1,96 @@
+// Package nmea parses NMEA 0183 sentences emitted by marine GPS receivers
+package nmea
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Errors returned when a line is not a well-formed NMEA sentence
+var (
+	ErrEmptySentence    = errors.New("empty sentence")
+	ErrInvalidStart     = errors.New("sentence must start with '$' or '!'")
+	ErrMissingChecksum  = errors.New("missing checksum")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrUnsupported      = errors.New("unsupported sentence type")
+)
+
+// Sentence is a checksum-validated NMEA sentence split into its fields
+type Sentence struct {
+	Raw    string
+	Start  byte     // '$' for parametric sentences, '!' for encapsulated (AIS) sentences
+	Talker string   // e.g. "GP", "GN", "HE"; empty for proprietary or talker-less addresses
+	Type   string   // e.g. "RMC", "GGA", "VDM"
+	Fields []string // data fields following the address field
+}
+
+// Parse validates the checksum of a raw line and splits it into fields
+func Parse(line string) (*Sentence, error) {
+	raw := strings.TrimSpace(line)
+	if raw == "" {
+		return nil, ErrEmptySentence
+	}
+	if raw[0] != '$' && raw[0] != '!' {
+		return nil, ErrInvalidStart
+	}
+
+	star := strings.LastIndexByte(raw, '*')
+	if star < 0 || len(raw)-star != 3 {
+		return nil, ErrMissingChecksum
+	}
+
+	body := raw[1:star]
+	want, err := strconv.ParseUint(raw[star+1:], 16, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checksum %q", raw[star+1:])
+	}
+	if Checksum(body) != byte(want) {
+		return nil, ErrChecksumMismatch
+	}
+
+	parts := strings.Split(body, ",")
+	address := parts[0]
+	if len(address) < 3 {
+		return nil, fmt.Errorf("invalid address field %q", address)
+	}
+
+	s := &Sentence{
+		Raw:    raw,
+		Start:  raw[0],
+		Type:   address[len(address)-3:],
+		Fields: parts[1:],
+	}
+	if len(address) == 5 {
+		s.Talker = address[:2]
+	}
+
+	return s, nil
+}
+
+// Checksum computes the XOR checksum of the characters between the start
+// delimiter and the '*'
+func Checksum(body string) byte {
+	var sum byte
+	for i := 0; i < len(body); i++ {
+		sum ^= body[i]
+	}
+	return sum
+}
+
+// field returns the i-th data field, or an empty string if it is absent
+func (s *Sentence) field(i int) string {
+	if i < len(s.Fields) {
+		return s.Fields[i]
+	}
+	return ""
+}
+
+// requireFields reports an error if the sentence has fewer than n data fields
+func (s *Sentence) requireFields(n int) error {
+	if len(s.Fields) < n {
+		return fmt.Errorf("%s: expected at least %d fields, got %d", s.Type, n, len(s.Fields))
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package nmea
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// line builds a sentence with a valid checksum from its body
+func line(body string) string {
+	return fmt.Sprintf("$%s*%02X", body, Checksum(body))
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		talker string
+		typ    string
+		fields int
+		err    error
+	}{
+		{"rmc", "$GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W*6A", "GP", "RMC", 11, nil},
+		{"gga", "$GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*47", "GP", "GGA", 14, nil},
+		{"ais", "!AIVDM,1,1,,B,15M67FC000G?ufbE`FepT@3n00Sa,0*5C", "AI", "VDM", 6, nil},
+		{"surrounding space", "  " + line("GNHDT,123.4,T") + "\r\n", "GN", "HDT", 2, nil},
+		{"lower-case checksum", "$GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W*6a", "GP", "RMC", 11, nil},
+		{"empty", "   ", "", "", 0, ErrEmptySentence},
+		{"no start", "GPRMC,123519,A*00", "", "", 0, ErrInvalidStart},
+		{"no checksum", "$GPRMC,123519,A", "", "", 0, ErrMissingChecksum},
+		{"short checksum", "$GPRMC,123519,A*6", "", "", 0, ErrMissingChecksum},
+		{"checksum mismatch", "$GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W*6B", "", "", 0, ErrChecksumMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.line)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("Parse = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if s.Talker != tt.talker || s.Type != tt.typ || len(s.Fields) != tt.fields {
+				t.Fatalf("Parse = talker %q, type %q, %d fields; want %q, %q, %d", s.Talker, s.Type, len(s.Fields), tt.talker, tt.typ, tt.fields)
+			}
+		})
+	}
+}
+
+func TestParseInvalidChecksum(t *testing.T) {
+	if _, err := Parse("$GPHDT,123.4,T*ZZ"); err == nil {
+		t.Fatal("Parse accepted a non-hex checksum")
+	}
+}
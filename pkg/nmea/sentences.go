@@ -0,0 +1,237 @@
+package nmea
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// ErrNoFix is returned for position sentences flagged as invalid by the receiver
+var ErrNoFix = errors.New("receiver reports no valid fix")
+
+// RMC is the recommended minimum navigation data
+type RMC struct {
+	Time      time.Time // UTC date and time of the fix
+	Latitude  float64
+	Longitude float64
+	Speed     float64 // speed over ground, knots
+	Course    float64 // course over ground, degrees true
+}
+
+// GGA is the GPS fix data
+type GGA struct {
+	TimeOfDay  time.Duration // UTC time since midnight; GGA carries no date
+	Latitude   float64
+	Longitude  float64
+	Quality    int
+	Satellites int
+	Altitude   float64 // antenna altitude above mean sea level, meters
+}
+
+// VTG is the track made good and ground speed
+type VTG struct {
+	Course float64 // degrees true
+	Speed  float64 // knots
+}
+
+// HDT is the true heading
+type HDT struct {
+	Heading float64 // degrees true
+}
+
+// ParseRMC decodes an RMC sentence
+func ParseRMC(s *Sentence) (*RMC, error) {
+	if err := s.requireFields(9); err != nil {
+		return nil, err
+	}
+	if s.field(1) != "A" {
+		return nil, ErrNoFix
+	}
+
+	tod, err := parseTimeOfDay(s.field(0))
+	if err != nil {
+		return nil, err
+	}
+	date, err := parseDate(s.field(8))
+	if err != nil {
+		return nil, err
+	}
+	lat, err := parseCoordinate(s.field(2), s.field(3), 90)
+	if err != nil {
+		return nil, err
+	}
+	lon, err := parseCoordinate(s.field(4), s.field(5), 180)
+	if err != nil {
+		return nil, err
+	}
+	speed, err := parseOptionalFloat(s.field(6), "speed")
+	if err != nil {
+		return nil, err
+	}
+	course, err := parseOptionalFloat(s.field(7), "course")
+	if err != nil {
+		return nil, err
+	}
+
+	return &RMC{
+		Time:      date.Add(tod),
+		Latitude:  lat,
+		Longitude: lon,
+		Speed:     speed,
+		Course:    course,
+	}, nil
+}
+
+// ParseGGA decodes a GGA sentence
+func ParseGGA(s *Sentence) (*GGA, error) {
+	if err := s.requireFields(9); err != nil {
+		return nil, err
+	}
+
+	quality, err := strconv.Atoi(s.field(5))
+	if err != nil {
+		return nil, fmt.Errorf("invalid fix quality %q", s.field(5))
+	}
+	if quality == 0 {
+		return nil, ErrNoFix
+	}
+
+	tod, err := parseTimeOfDay(s.field(0))
+	if err != nil {
+		return nil, err
+	}
+	lat, err := parseCoordinate(s.field(1), s.field(2), 90)
+	if err != nil {
+		return nil, err
+	}
+	lon, err := parseCoordinate(s.field(3), s.field(4), 180)
+	if err != nil {
+		return nil, err
+	}
+	satellites, _ := strconv.Atoi(s.field(6))
+	altitude, err := parseOptionalFloat(s.field(8), "altitude")
+	if err != nil {
+		return nil, err
+	}
+
+	return &GGA{
+		TimeOfDay:  tod,
+		Latitude:   lat,
+		Longitude:  lon,
+		Quality:    quality,
+		Satellites: satellites,
+		Altitude:   altitude,
+	}, nil
+}
+
+// ParseVTG decodes a VTG sentence
+func ParseVTG(s *Sentence) (*VTG, error) {
+	if err := s.requireFields(5); err != nil {
+		return nil, err
+	}
+
+	course, err := parseOptionalFloat(s.field(0), "course")
+	if err != nil {
+		return nil, err
+	}
+	speed, err := parseOptionalFloat(s.field(4), "speed")
+	if err != nil {
+		return nil, err
+	}
+
+	return &VTG{Course: course, Speed: speed}, nil
+}
+
+// ParseHDT decodes an HDT sentence
+func ParseHDT(s *Sentence) (*HDT, error) {
+	if err := s.requireFields(1); err != nil {
+		return nil, err
+	}
+
+	heading, ok := parseFloat(s.field(0))
+	if !ok {
+		return nil, fmt.Errorf("invalid heading %q", s.field(0))
+	}
+
+	return &HDT{Heading: heading}, nil
+}
+
+// parseTimeOfDay parses hhmmss(.sss) into a duration since midnight
+func parseTimeOfDay(v string) (time.Duration, error) {
+	if len(v) < 6 {
+		return 0, fmt.Errorf("invalid time %q", v)
+	}
+
+	hh, err1 := strconv.Atoi(v[0:2])
+	mm, err2 := strconv.Atoi(v[2:4])
+	ss, ok := parseFloat(v[4:])
+	if err1 != nil || err2 != nil || !ok || hh < 0 || mm < 0 || ss < 0 || hh > 23 || mm > 59 || ss >= 61 {
+		return 0, fmt.Errorf("invalid time %q", v)
+	}
+
+	return time.Duration(hh)*time.Hour +
+		time.Duration(mm)*time.Minute +
+		time.Duration(ss*float64(time.Second)), nil
+}
+
+// parseDate parses ddmmyy into midnight UTC of that day
+func parseDate(v string) (time.Time, error) {
+	t, err := time.Parse("020106", v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", v)
+	}
+	return t, nil
+}
+
+// parseCoordinate parses a (d)ddmm.mmmm value and its hemisphere into signed decimal degrees
+func parseCoordinate(v, hemisphere string, limit float64) (float64, error) {
+	raw, ok := parseFloat(v)
+	if !ok || raw < 0 {
+		return 0, fmt.Errorf("invalid coordinate %q", v)
+	}
+
+	degrees := float64(int(raw / 100))
+	minutes := raw - degrees*100
+	if minutes >= 60 {
+		return 0, fmt.Errorf("invalid coordinate %q", v)
+	}
+
+	value := degrees + minutes/60
+	switch hemisphere {
+	case "N", "E":
+	case "S", "W":
+		value = -value
+	default:
+		return 0, fmt.Errorf("invalid hemisphere %q", hemisphere)
+	}
+
+	if value < -limit || value > limit {
+		return 0, fmt.Errorf("coordinate %q out of range", v)
+	}
+
+	return value, nil
+}
+
+// parseOptionalFloat parses a numeric field that receivers may leave empty
+func parseOptionalFloat(v, name string) (float64, error) {
+	if v == "" {
+		return 0, nil
+	}
+	f, ok := parseFloat(v)
+	if !ok {
+		return 0, fmt.Errorf("invalid %s %q", name, v)
+	}
+	return f, nil
+}
+
+// parseFloat parses a decimal number, rejecting the NaN and infinity
+// spellings strconv accepts
+func parseFloat(v string) (float64, bool) {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return f, true
+}
@@ -0,0 +1,127 @@
+package nmea
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, l string) *Sentence {
+	t.Helper()
+	s, err := Parse(l)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", l, err)
+	}
+	return s
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestParseRMC(t *testing.T) {
+	rmc, err := ParseRMC(mustParse(t, "$GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W*6A"))
+	if err != nil {
+		t.Fatalf("ParseRMC: %v", err)
+	}
+
+	want := time.Date(1994, 3, 23, 12, 35, 19, 0, time.UTC)
+	if !rmc.Time.Equal(want) {
+		t.Errorf("Time = %s, want %s", rmc.Time, want)
+	}
+	if !near(rmc.Latitude, 48+7.038/60) || !near(rmc.Longitude, 11+31.0/60) {
+		t.Errorf("position = %f,%f, want 48.1173,11.516667", rmc.Latitude, rmc.Longitude)
+	}
+	if rmc.Speed != 22.4 || rmc.Course != 84.4 {
+		t.Errorf("speed/course = %f/%f, want 22.4/84.4", rmc.Speed, rmc.Course)
+	}
+}
+
+func TestParseRMCRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"no fix", "GPRMC,123519,V,4807.038,N,01131.000,E,022.4,084.4,230394,,"},
+		{"too few fields", "GPRMC,123519,A,4807.038,N"},
+		{"bad time", "GPRMC,1235,A,4807.038,N,01131.000,E,022.4,084.4,230394,,"},
+		{"NaN seconds", "GPRMC,1235NaN,A,4807.038,N,01131.000,E,022.4,084.4,230394,,"},
+		{"bad date", "GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,320394,,"},
+		{"NaN latitude", "GPRMC,123519,A,NaN,N,01131.000,E,022.4,084.4,230394,,"},
+		{"infinite longitude", "GPRMC,123519,A,4807.038,N,+Inf,E,022.4,084.4,230394,,"},
+		{"negative latitude", "GPRMC,123519,A,-4807.038,N,01131.000,E,022.4,084.4,230394,,"},
+		{"minutes over 60", "GPRMC,123519,A,4861.000,N,01131.000,E,022.4,084.4,230394,,"},
+		{"latitude over 90", "GPRMC,123519,A,9100.000,N,01131.000,E,022.4,084.4,230394,,"},
+		{"bad hemisphere", "GPRMC,123519,A,4807.038,X,01131.000,E,022.4,084.4,230394,,"},
+		{"NaN speed", "GPRMC,123519,A,4807.038,N,01131.000,E,NaN,084.4,230394,,"},
+		{"infinite course", "GPRMC,123519,A,4807.038,N,01131.000,E,022.4,Inf,230394,,"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rmc, err := ParseRMC(mustParse(t, line(tt.body))); err == nil {
+				t.Fatalf("ParseRMC = %+v, want an error", rmc)
+			}
+		})
+	}
+}
+
+func TestParseRMCNoFix(t *testing.T) {
+	_, err := ParseRMC(mustParse(t, line("GPRMC,123519,V,,,,,,,230394,,")))
+	if !errors.Is(err, ErrNoFix) {
+		t.Fatalf("ParseRMC = %v, want ErrNoFix", err)
+	}
+}
+
+func TestParseGGA(t *testing.T) {
+	gga, err := ParseGGA(mustParse(t, "$GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*47"))
+	if err != nil {
+		t.Fatalf("ParseGGA: %v", err)
+	}
+	if gga.TimeOfDay != 12*time.Hour+35*time.Minute+19*time.Second {
+		t.Errorf("TimeOfDay = %s", gga.TimeOfDay)
+	}
+	if gga.Quality != 1 || gga.Satellites != 8 || gga.Altitude != 545.4 {
+		t.Errorf("quality/satellites/altitude = %d/%d/%f", gga.Quality, gga.Satellites, gga.Altitude)
+	}
+
+	sw, err := ParseGGA(mustParse(t, line("GPGGA,000001.50,3351.200,S,15112.600,W,2,05,1.1,,M,,M,,")))
+	if err != nil {
+		t.Fatalf("ParseGGA: %v", err)
+	}
+	if !near(sw.Latitude, -(33+51.2/60)) || !near(sw.Longitude, -(151+12.6/60)) {
+		t.Errorf("position = %f,%f", sw.Latitude, sw.Longitude)
+	}
+	if sw.TimeOfDay != 1500*time.Millisecond {
+		t.Errorf("TimeOfDay = %s, want 1.5s", sw.TimeOfDay)
+	}
+
+	if _, err := ParseGGA(mustParse(t, line("GPGGA,123519,4807.038,N,01131.000,E,0,00,,,M,,M,,"))); !errors.Is(err, ErrNoFix) {
+		t.Errorf("quality 0: ParseGGA = %v, want ErrNoFix", err)
+	}
+	if _, err := ParseGGA(mustParse(t, line("GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,NaN,M,,M,,"))); err == nil {
+		t.Error("NaN altitude accepted")
+	}
+}
+
+func TestParseVTGAndHDT(t *testing.T) {
+	vtg, err := ParseVTG(mustParse(t, line("GPVTG,054.7,T,034.4,M,005.5,N,010.2,K")))
+	if err != nil {
+		t.Fatalf("ParseVTG: %v", err)
+	}
+	if vtg.Course != 54.7 || vtg.Speed != 5.5 {
+		t.Errorf("VTG = %+v", vtg)
+	}
+
+	hdt, err := ParseHDT(mustParse(t, line("HEHDT,274.07,T")))
+	if err != nil {
+		t.Fatalf("ParseHDT: %v", err)
+	}
+	if hdt.Heading != 274.07 {
+		t.Errorf("Heading = %f", hdt.Heading)
+	}
+	if _, err := ParseHDT(mustParse(t, line("HEHDT,NaN,T"))); err == nil {
+		t.Error("NaN heading accepted")
+	}
+}
@@ -0,0 +1,140 @@
+// Package ratelimit counts requests in fixed time windows. Counters live in
+// a Store, which may be shared by several processes so that limits hold
+// across replicas.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memorySweepInterval is how often a MemoryStore removes expired counters
+const memorySweepInterval = time.Minute
+
+// Store keeps request counters
+type Store interface {
+	// Increment adds one to the counter for key in the window starting at
+	// window and returns the new count. The counter may be discarded once
+	// expires has passed.
+	Increment(ctx context.Context, key string, window, expires time.Time) (int, error)
+}
+
+// Result describes a counter after a request was counted
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Time // end of the current window
+}
+
+// Limiter checks requests against per-key limits
+type Limiter struct {
+	store  Store
+	window time.Duration
+}
+
+// New creates a limiter counting in windows of the given length
+func New(store Store, window time.Duration) *Limiter {
+	if window <= 0 {
+		window = time.Minute
+	}
+	return &Limiter{store: store, window: window}
+}
+
+// Allow counts a request for key and reports whether it is within limit
+func (l *Limiter) Allow(ctx context.Context, key string, limit int) (Result, error) {
+	now := time.Now()
+	start := now.Truncate(l.window)
+	reset := start.Add(l.window)
+
+	count, err := l.store.Increment(ctx, key, start, reset)
+	if err != nil {
+		return Result{}, err
+	}
+
+	remaining := limit - count
+	if remaining < 0 {
+		remaining = 0
+	}
+	return Result{
+		Allowed:   count <= limit,
+		Limit:     limit,
+		Remaining: remaining,
+		Reset:     reset,
+	}, nil
+}
+
+// MemoryStore keeps counters in this process. Expired counters are removed
+// every memorySweepInterval until Close.
+type MemoryStore struct {
+	mu       sync.Mutex
+	counters map[string]*memoryCounter
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+type memoryCounter struct {
+	window  time.Time
+	count   int
+	expires time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store and starts its sweeper
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{
+		counters: make(map[string]*memoryCounter),
+		done:     make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(memorySweepInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				s.sweep(now)
+			case <-s.done:
+				return
+			}
+		}
+	}()
+	return s
+}
+
+// Close stops the sweeper
+func (s *MemoryStore) Close() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+// Increment implements Store
+func (s *MemoryStore) Increment(_ context.Context, key string, window, expires time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.counters[key]
+	if !ok || !c.window.Equal(window) {
+		c = &memoryCounter{window: window, expires: expires}
+		s.counters[key] = c
+	}
+	c.count++
+	return c.count, nil
+}
+
+// sweep removes counters that expired before now
+func (s *MemoryStore) sweep(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, c := range s.counters {
+		if now.After(c.expires) {
+			delete(s.counters, k)
+		}
+	}
+}
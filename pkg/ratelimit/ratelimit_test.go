@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreIncrement(t *testing.T) {
+	s := NewMemoryStore()
+	defer s.Close()
+
+	ctx := context.Background()
+	window := time.Date(2024, 6, 1, 8, 30, 0, 0, time.UTC)
+	next := window.Add(time.Minute)
+
+	for want := 1; want <= 3; want++ {
+		if got, _ := s.Increment(ctx, "a", window, next); got != want {
+			t.Fatalf("count = %d, want %d", got, want)
+		}
+	}
+	if got, _ := s.Increment(ctx, "b", window, next); got != 1 {
+		t.Errorf("count of another key = %d, want 1", got)
+	}
+	if got, _ := s.Increment(ctx, "a", next, next.Add(time.Minute)); got != 1 {
+		t.Errorf("count in the next window = %d, want 1", got)
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	s := NewMemoryStore()
+	defer s.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+	_, _ = s.Increment(ctx, "expired", now.Add(-2*time.Minute), now.Add(-time.Minute))
+	_, _ = s.Increment(ctx, "current", now.Truncate(time.Minute), now.Add(time.Minute))
+
+	s.sweep(now)
+
+	s.mu.Lock()
+	_, expired := s.counters["expired"]
+	_, current := s.counters["current"]
+	s.mu.Unlock()
+	if expired || !current {
+		t.Errorf("after sweep: expired kept = %v, current kept = %v", expired, current)
+	}
+}
+
+func TestMemoryStoreClose(t *testing.T) {
+	s := NewMemoryStore()
+
+	done := make(chan struct{})
+	go func() {
+		s.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Close did not stop the sweeper")
+	}
+}
+
+// failingStore fails every increment
+type failingStore struct{ err error }
+
+func (s failingStore) Increment(context.Context, string, time.Time, time.Time) (int, error) {
+	return 0, s.err
+}
+
+func TestLimiterAllow(t *testing.T) {
+	s := NewMemoryStore()
+	defer s.Close()
+
+	l := New(s, time.Hour)
+	ctx := context.Background()
+
+	for i := 1; i <= 3; i++ {
+		result, err := l.Allow(ctx, "key", 2)
+		if err != nil {
+			t.Fatalf("Allow: %v", err)
+		}
+		if result.Allowed != (i <= 2) {
+			t.Errorf("request %d: Allowed = %v", i, result.Allowed)
+		}
+		if want := max(2-i, 0); result.Remaining != want {
+			t.Errorf("request %d: Remaining = %d, want %d", i, result.Remaining, want)
+		}
+		if result.Limit != 2 || !result.Reset.After(time.Now()) || result.Reset.Sub(time.Now()) > time.Hour {
+			t.Errorf("request %d: Limit = %d, Reset = %v", i, result.Limit, result.Reset)
+		}
+	}
+
+	errDown := errors.New("store down")
+	if _, err := New(failingStore{errDown}, time.Minute).Allow(ctx, "key", 2); !errors.Is(err, errDown) {
+		t.Errorf("Allow = %v, want %v", err, errDown)
+	}
+}
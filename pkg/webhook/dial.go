@@ -0,0 +1,84 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned when a webhook URL points at an address
+// that receivers may not use
+var ErrForbiddenAddress = errors.New("webhook: destination address not allowed")
+
+// forbiddenPrefixes are ranges not covered by the netip predicates used in
+// PublicAddr: "this network" and carrier-grade NAT
+var forbiddenPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// PublicAddr reports whether ip is a public unicast address. Loopback,
+// private, link-local (including cloud metadata at 169.254.169.254),
+// multicast and unspecified addresses are not.
+func PublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, prefix := range forbiddenPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckHost rejects a URL host that is a non-public IP address or a name
+// for the local machine. Other names are checked when they are dialed.
+func CheckHost(host string) error {
+	name := strings.TrimSuffix(strings.ToLower(host), ".")
+	if name == "localhost" || strings.HasSuffix(name, ".localhost") {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && !PublicAddr(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	return nil
+}
+
+// NewClient returns an HTTP client for webhook deliveries that refuses to
+// connect to non-public addresses. The check runs on the resolved address
+// of every connection, including redirects, so DNS names that resolve to
+// internal hosts are rejected too. Proxies are not used, as they would
+// hide the destination from the check.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+		Control:   controlPublic,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// controlPublic is a net.Dialer Control function allowing only public addresses
+func controlPublic(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+	}
+	if !PublicAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, addrPort.Addr())
+	}
+	return nil
+}
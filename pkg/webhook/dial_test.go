@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00:ec2::254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"224.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		if got := PublicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("PublicAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	tests := []struct {
+		host    string
+		allowed bool
+	}{
+		{"hooks.example.com", true},
+		{"93.184.216.34", true},
+		{"localhost", false},
+		{"LOCALHOST.", false},
+		{"api.localhost", false},
+		{"127.0.0.1", false},
+		{"169.254.169.254", false},
+		{"::1", false},
+	}
+
+	for _, tt := range tests {
+		err := CheckHost(tt.host)
+		if tt.allowed && err != nil {
+			t.Errorf("CheckHost(%q) = %v, want nil", tt.host, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("CheckHost(%q) = %v, want ErrForbiddenAddress", tt.host, err)
+		}
+	}
+}
+
+func TestNewClientRefusesLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached the loopback server")
+	}))
+	defer server.Close()
+
+	resp, err := NewClient(time.Second).Post(server.URL, "application/json", nil)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("expected the connection to be refused")
+	}
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("error = %v, want ErrForbiddenAddress", err)
+	}
+}
@@ -0,0 +1,45 @@
+package webhook
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	secret := "whsec_test"
+	body := []byte(`{"type":"voyage.departed"}`)
+	sentAt := time.Unix(1700000000, 0)
+	header := Sign(secret, sentAt, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		header    string
+		body      []byte
+		tolerance time.Duration
+		now       time.Time
+		want      error
+	}{
+		{"valid", secret, header, body, 5 * time.Minute, sentAt.Add(time.Minute), nil},
+		{"no tolerance", secret, header, body, 0, sentAt.Add(24 * time.Hour), nil},
+		{"wrong secret", "whsec_other", header, body, 0, sentAt, ErrSignatureMismatch},
+		{"tampered body", secret, header, []byte(`{"type":"voyage.arrived"}`), 0, sentAt, ErrSignatureMismatch},
+		{"expired", secret, header, body, 5 * time.Minute, sentAt.Add(10 * time.Minute), ErrTimestampExpired},
+		{"from the future", secret, header, body, 5 * time.Minute, sentAt.Add(-10 * time.Minute), ErrTimestampExpired},
+		{"rotated secret", secret, Sign("whsec_old", sentAt, body) + ",v1=" + compute(secret, "1700000000", body), body, 0, sentAt, nil},
+		{"missing signature", secret, "t=1700000000", body, 0, sentAt, ErrMalformedHeader},
+		{"missing timestamp", secret, "v1=abc", body, 0, sentAt, ErrMalformedHeader},
+		{"bad timestamp", secret, "t=soon,v1=abc", body, 0, sentAt, ErrMalformedHeader},
+		{"garbage", secret, "garbage", body, 0, sentAt, ErrMalformedHeader},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.header, tt.body, tt.tolerance, tt.now)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}